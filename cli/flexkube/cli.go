@@ -2,9 +2,12 @@
 package flexkube
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 
 	"github.com/urfave/cli/v2"
 )
@@ -18,6 +21,24 @@ const (
 
 	// NoopFlag is const for --noop flag.
 	NoopFlag = "noop"
+
+	// TimeoutFlag is const for --timeout flag.
+	TimeoutFlag = "timeout"
+
+	// SkipPreflightFlag is const for --skip-preflight flag.
+	SkipPreflightFlag = "skip-preflight"
+
+	// JSONFlag is const for --json flag.
+	JSONFlag = "json"
+
+	// ThresholdFlag is const for --threshold flag.
+	ThresholdFlag = "threshold"
+
+	// DirFlag is const for --dir flag.
+	DirFlag = "dir"
+
+	// EtcdMemberFlag is const for --etcd-member flag.
+	EtcdMemberFlag = "etcd-member"
 )
 
 // Run executes flexkube CLI binary with given arguments (usually os.Args).
@@ -34,6 +55,14 @@ func Run(args []string) int {
 				Name:  NoopFlag,
 				Usage: "Only checks the status of the deployment, but does not do any changes",
 			},
+			&cli.DurationFlag{
+				Name:  TimeoutFlag,
+				Usage: "Cancels the execution after given time, e.g. '10m'. By default, there is no timeout",
+			},
+			&cli.BoolFlag{
+				Name:  SkipPreflightFlag,
+				Usage: "Deploy changes without running preflight checks on the hosts first",
+			},
 		},
 		Commands: []*cli.Command{
 			kubeletPoolCommand(),
@@ -44,10 +73,17 @@ func Run(args []string) int {
 			kubeconfigCommand(),
 			containersCommand(),
 			templateCommand(),
+			preflightCommand(),
+			bootstrapTokensCommand(),
 		},
 	}
 
-	err := app.Run(args)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go cancelOnInterrupt(ctx, cancel)
+
+	err := app.RunContext(ctx, args)
 	if err != nil {
 		fmt.Printf("Execution failed: %v\n", err)
 
@@ -57,6 +93,23 @@ func Run(args []string) int {
 	return 0
 }
 
+// cancelOnInterrupt calls given cancel function when interrupt signal is received, so running
+// operations can finish gracefully, e.g. by saving the state and removing temporary containers.
+// Sending the signal again terminates the process immediately.
+func cancelOnInterrupt(ctx context.Context, cancel context.CancelFunc) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+
+	select {
+	case <-c:
+		fmt.Println("Interrupt received, cancelling. Send interrupt again to terminate immediately.")
+		cancel()
+	case <-ctx.Done():
+	}
+
+	signal.Stop(c)
+}
+
 func templateCommand() *cli.Command {
 	return &cli.Command{
 		Name:      "template",
@@ -107,6 +160,122 @@ func pkiCommand() *cli.Command {
 		Action: func(c *cli.Context) error {
 			return withResource(c, pkiAction)
 		},
+		Subcommands: []*cli.Command{
+			{
+				Name:      "rotate-ca",
+				Usage:     "advances rotation of given CA certificate by one phase",
+				ArgsUsage: "[CA NAME]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiRotateCAAction)
+				},
+			},
+			{
+				Name:  "rotate-service-account-key",
+				Usage: "advances rotation of service account signing key by one phase",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiRotateServiceAccountKeyAction)
+				},
+			},
+			{
+				Name:  "report",
+				Usage: "prints information about all certificates in the state and fails, if any of them is expiring",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  JSONFlag,
+						Usage: "Print the report in JSON format",
+					},
+					&cli.DurationFlag{
+						Name:  ThresholdFlag,
+						Usage: "Report certificates expiring within given time, e.g. '720h'. By default, renew threshold of each certificate is used",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiReportAction)
+				},
+			},
+			{
+				Name:      "export-csrs",
+				Usage:     "writes certificate signing requests for externally signed certificates into given directory",
+				ArgsUsage: "[DIRECTORY]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiExportCSRsAction)
+				},
+			},
+			{
+				Name:      "import-certificates",
+				Usage:     "imports externally signed certificates from given directory",
+				ArgsUsage: "[DIRECTORY]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiImportCertificatesAction)
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "writes certificates from the state into given directory using kubeadm PKI layout",
+				Flags: kubeadmFlags(),
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiExportAction)
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "imports certificates from given directory using kubeadm PKI layout, e.g. '/etc/kubernetes/pki'",
+				Flags: kubeadmFlags(),
+				Action: func(c *cli.Context) error {
+					return withResource(c, pkiImportAction)
+				},
+			},
+		},
+	}
+}
+
+// kubeadmFlags returns flags for 'pki export' and 'pki import' subcommands.
+func kubeadmFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     DirFlag,
+			Usage:    "Directory with certificates in kubeadm PKI layout",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  EtcdMemberFlag,
+			Usage: "Name of etcd member, which server and peer certificates are stored in 'etcd' subdirectory",
+		},
+	}
+}
+
+func bootstrapTokensCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "bootstrap-tokens",
+		Usage: "generates configured bootstrap tokens and creates them in the cluster",
+		Action: func(c *cli.Context) error {
+			return withResource(c, bootstrapTokensAction)
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "prints bootstrap tokens stored in the state",
+				Action: func(c *cli.Context) error {
+					return withResource(c, bootstrapTokensListAction)
+				},
+			},
+			{
+				Name:      "rotate",
+				Usage:     "replaces given bootstrap token with a new one",
+				ArgsUsage: "[TOKEN NAME]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, bootstrapTokensRotateAction)
+				},
+			},
+			{
+				Name:      "revoke",
+				Usage:     "removes given bootstrap token from the cluster and from the state",
+				ArgsUsage: "[TOKEN NAME]",
+				Action: func(c *cli.Context) error {
+					return withResource(c, bootstrapTokensRevokeAction)
+				},
+			},
+		},
 	}
 }
 
@@ -140,6 +309,22 @@ func containersCommand() *cli.Command {
 	}
 }
 
+func preflightCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "preflight",
+		Usage: "verifies, that hosts of all configured resources are ready for deployment",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  JSONFlag,
+				Usage: "Print the report in JSON format",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return withResource(c, preflightAction)
+		},
+	}
+}
+
 // apiLoadBalancerPoolAction implements 'apiloadbalancer-pool' subcommand.
 func apiLoadBalancerPoolAction(c *cli.Context, r *Resource) error {
 	poolName, err := getPoolName(c)
@@ -147,17 +332,17 @@ func apiLoadBalancerPoolAction(c *cli.Context, r *Resource) error {
 		return fmt.Errorf("getting pool name: %w", err)
 	}
 
-	return r.RunAPILoadBalancerPool(poolName)
+	return r.RunAPILoadBalancerPool(c.Context, poolName)
 }
 
 // controlplaneAction implements 'controlplane' subcommand.
 func controlplaneAction(c *cli.Context, r *Resource) error {
-	return r.RunControlplane()
+	return r.RunControlplane(c.Context)
 }
 
 // etcdAction implements 'etcd' subcommand.
 func etcdAction(c *cli.Context, r *Resource) error {
-	return r.RunEtcd()
+	return r.RunEtcd(c.Context)
 }
 
 // getTemplate reads the template either from path given as an argument
@@ -226,13 +411,142 @@ func kubeletPoolAction(c *cli.Context, r *Resource) error {
 		return fmt.Errorf("getting pool name %w", err)
 	}
 
-	return r.RunKubeletPool(poolName)
+	return r.RunKubeletPool(c.Context, poolName)
+}
+
+// preflightAction implements 'preflight' subcommand.
+func preflightAction(c *cli.Context, r *Resource) error {
+	report, err := r.RunPreflight(c.Context)
+	if err != nil {
+		return fmt.Errorf("running preflight checks: %w", err)
+	}
+
+	if !c.Bool(JSONFlag) {
+		fmt.Print(report.String())
+
+		return report.Err()
+	}
+
+	o, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing report: %w", err)
+	}
+
+	fmt.Println(string(o))
+
+	return report.Err()
 }
 
 func pkiAction(c *cli.Context, r *Resource) error {
 	return r.RunPKI()
 }
 
+// pkiRotateCAAction implements 'pki rotate-ca' subcommand.
+func pkiRotateCAAction(c *cli.Context, r *Resource) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("exactly one CA name must be specified")
+	}
+
+	return r.RotateCA(c.Args().Get(0))
+}
+
+// pkiRotateServiceAccountKeyAction implements 'pki rotate-service-account-key' subcommand.
+func pkiRotateServiceAccountKeyAction(c *cli.Context, r *Resource) error {
+	return r.RotateServiceAccountKey()
+}
+
+// pkiReportAction implements 'pki report' subcommand.
+func pkiReportAction(c *cli.Context, r *Resource) error {
+	report, err := r.PKIReport(c.Duration(ThresholdFlag))
+	if err != nil {
+		return fmt.Errorf("creating PKI report: %w", err)
+	}
+
+	if !c.Bool(JSONFlag) {
+		fmt.Print(report.String())
+
+		return report.Err()
+	}
+
+	o, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing report: %w", err)
+	}
+
+	fmt.Println(string(o))
+
+	return report.Err()
+}
+
+// pkiExportCSRsAction implements 'pki export-csrs' subcommand.
+func pkiExportCSRsAction(c *cli.Context, r *Resource) error {
+	return r.ExportCertificateSigningRequests(getDirectory(c))
+}
+
+// pkiImportCertificatesAction implements 'pki import-certificates' subcommand.
+func pkiImportCertificatesAction(c *cli.Context, r *Resource) error {
+	return r.ImportCertificates(getDirectory(c))
+}
+
+// pkiExportAction implements 'pki export' subcommand.
+func pkiExportAction(c *cli.Context, r *Resource) error {
+	return r.ExportKubeadm(c.String(DirFlag), c.String(EtcdMemberFlag))
+}
+
+// pkiImportAction implements 'pki import' subcommand.
+func pkiImportAction(c *cli.Context, r *Resource) error {
+	return r.ImportKubeadm(c.String(DirFlag), c.String(EtcdMemberFlag))
+}
+
+func bootstrapTokensAction(c *cli.Context, r *Resource) error {
+	return r.RunBootstrapTokens()
+}
+
+// bootstrapTokensListAction implements 'bootstrap-tokens list' subcommand.
+func bootstrapTokensListAction(c *cli.Context, r *Resource) error {
+	fmt.Print(r.BootstrapTokensTable())
+
+	return nil
+}
+
+// bootstrapTokensRotateAction implements 'bootstrap-tokens rotate' subcommand.
+func bootstrapTokensRotateAction(c *cli.Context, r *Resource) error {
+	name, err := getTokenName(c)
+	if err != nil {
+		return err
+	}
+
+	return r.RotateBootstrapToken(name)
+}
+
+// bootstrapTokensRevokeAction implements 'bootstrap-tokens revoke' subcommand.
+func bootstrapTokensRevokeAction(c *cli.Context, r *Resource) error {
+	name, err := getTokenName(c)
+	if err != nil {
+		return err
+	}
+
+	return r.RevokeBootstrapToken(name)
+}
+
+// getTokenName returns bootstrap token name given as an argument.
+func getTokenName(c *cli.Context) (string, error) {
+	if c.NArg() != 1 {
+		return "", fmt.Errorf("exactly one token name must be specified")
+	}
+
+	return c.Args().Get(0), nil
+}
+
+// getDirectory returns directory given as an argument or current directory, if none is given.
+func getDirectory(c *cli.Context) string {
+	if d := c.Args().Get(0); d != "" {
+		return d
+	}
+
+	return "."
+}
+
 func getPoolName(c *cli.Context) (string, error) {
 	if c.NArg() > 1 {
 		return "", fmt.Errorf("only one pool can be managed at a time")
@@ -252,7 +566,7 @@ func containersAction(c *cli.Context, r *Resource) error {
 		return fmt.Errorf("getting pool name: %w", err)
 	}
 
-	return r.RunContainers(poolName)
+	return r.RunContainers(c.Context, poolName)
 }
 
 // withResource is a helper for action functions.
@@ -264,6 +578,7 @@ func withResource(c *cli.Context, rf func(*cli.Context, *Resource) error) error
 
 	r.Confirmed = c.Bool(YesFlag)
 	r.Noop = c.Bool(NoopFlag)
+	r.SkipPreflight = c.Bool(SkipPreflightFlag)
 
 	if r.Confirmed && r.Noop {
 		return fmt.Errorf("--%s and --%s flags are mutually exclusive", YesFlag, NoopFlag)
@@ -273,5 +588,12 @@ func withResource(c *cli.Context, rf func(*cli.Context, *Resource) error) error
 		fmt.Println("No-op run, no changes will be made.")
 	}
 
+	if t := c.Duration(TimeoutFlag); t > 0 {
+		ctx, cancel := context.WithTimeout(c.Context, t)
+		defer cancel()
+
+		c.Context = ctx
+	}
+
 	return rf(c, r)
 }
@@ -3,11 +3,16 @@ package flexkube
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"text/template"
+	"time"
 
 	sprig "github.com/Masterminds/sprig/v3"
 	"github.com/google/go-cmp/cmp"
@@ -19,7 +24,9 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container/resource"
 	"github.com/flexkube/libflexkube/pkg/controlplane"
 	"github.com/flexkube/libflexkube/pkg/etcd"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/kubelet"
+	"github.com/flexkube/libflexkube/pkg/kubernetes/bootstraptoken"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
@@ -71,6 +78,15 @@ type Resource struct {
 	// See container.ContainersState for available options.
 	Containers map[string]*container.ContainersState `json:"containers,omitempty"`
 
+	// BootstrapTokens allows to manage bootstrap tokens used by kubelets for TLS bootstrapping, where
+	// key is a name of the token. Generated tokens are created as Secrets in the cluster and token with
+	// the same name as kubelet pool is used by kubelets of the pool, which have no token configured.
+	// Already deployed kubelets keep the token they were created with, so regenerating the token does
+	// not restart them.
+	//
+	// See bootstraptoken.Config for available fields.
+	BootstrapTokens map[string]*bootstraptoken.Config `json:"bootstrapTokens,omitempty"`
+
 	// State stores state of all configured resources. Information about all created containers and generated certificates
 	// must be persisted, so it does not change on consecutive runs.
 	State *ResourceState `json:"state,omitempty"`
@@ -82,6 +98,9 @@ type Resource struct {
 	// Noop controls, if deployment should actually be executed. If set to 'true', only the difference between
 	// cluster existing state and desired state will be printed, but the State field won't be modified.
 	Noop bool `json:"noop,omitempty"`
+
+	// SkipPreflight controls, if preflight checks should be skipped before deploying changes.
+	SkipPreflight bool `json:"skipPreflight,omitempty"`
 }
 
 // ResourceState represents flexkube CLI state format.
@@ -104,6 +123,9 @@ type ResourceState struct {
 
 	// PKI stores generated Kubernetes certificates.
 	PKI *pki.PKI `json:"pki,omitempty"`
+
+	// BootstrapTokens stores generated bootstrap tokens.
+	BootstrapTokens map[string]*bootstraptoken.Token `json:"bootstrapTokens,omitempty"`
 }
 
 // getEtcd returns etcd resource, with state and PKI integration enabled.
@@ -125,6 +147,13 @@ func (r *Resource) getEtcd() (types.Resource, error) {
 		r.Etcd.PKI = r.State.PKI
 	}
 
+	// Removing all members does not use any certificates.
+	if len(r.Etcd.Members) > 0 {
+		if err := r.pendingCertificates("etcd.peerCertificates.", "etcd.serverCertificates."); err != nil {
+			return nil, err
+		}
+	}
+
 	return validateAndNew(r.Etcd)
 }
 
@@ -149,6 +178,18 @@ func (r *Resource) getControlplane() (types.Resource, error) {
 		r.Controlplane.PKI = r.State.PKI
 	}
 
+	// Destroying controlplane does not use any certificates.
+	if !r.Controlplane.Destroy {
+		if err := r.pendingCertificates(
+			"kubernetes.kubeAPIServer.",
+			"kubernetes.kubeControllerManagerCertificate",
+			"kubernetes.kubeSchedulerCertificate",
+			"etcd.clientCertificates.",
+		); err != nil {
+			return nil, err
+		}
+	}
+
 	return validateAndNew(r.Controlplane)
 }
 
@@ -176,9 +217,53 @@ func (r *Resource) getKubeletPool(name string) (types.Resource, error) {
 		pool.PKI = r.State.PKI
 	}
 
+	if err := r.pendingCertificates(
+		"kubernetes.adminCertificate",
+		"kubernetes.kubeletClientCertificates.",
+		"kubernetes.kubeletServerCertificates.",
+	); err != nil {
+		return nil, err
+	}
+
+	// Use generated bootstrap token for kubelets, which have none configured. Kubelets already
+	// deployed keep their token, so rotating the token does not restart them.
+	if t := r.bootstrapToken(name); t != nil && pool.BootstrapToken == "" {
+		pool.BootstrapToken = t.String()
+	}
+
 	return validateAndNew(pool)
 }
 
+// pendingCertificates returns error, if certificates from the state with names starting with one
+// of given prefixes await external signing, as resources using them can't be deployed until signed
+// certificates are imported.
+func (r *Resource) pendingCertificates(prefixes ...string) error {
+	if r.State == nil || r.State.PKI == nil {
+		return nil
+	}
+
+	pending := []string{}
+
+	for n := range r.State.PKI.CertificateSigningRequests() {
+		for _, p := range prefixes {
+			if strings.HasPrefix(n, p) {
+				pending = append(pending, n)
+
+				break
+			}
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	sort.Strings(pending)
+
+	return fmt.Errorf("certificates %s await external signing, import them using 'pki import-certificates' command first",
+		strings.Join(pending, ", "))
+}
+
 // getPKI returns PKI struct with state loaded on top.
 func (r *Resource) getPKI() (*pki.PKI, error) {
 	if r.PKI == nil {
@@ -265,11 +350,11 @@ func validateAndNew(rc types.ResourceConfig) (types.Resource, error) {
 	return r, nil
 }
 
-func (r *Resource) checkState(rs types.Resource) (string, error) {
+func (r *Resource) checkState(ctx context.Context, rs types.Resource) (string, error) {
 	// Check current state.
 	fmt.Println("Checking current state")
 
-	if err := rs.CheckCurrentState(); err != nil {
+	if err := rs.CheckCurrentState(ctx); err != nil {
 		return "", fmt.Errorf("failed checking current state: %w", err)
 	}
 
@@ -290,8 +375,8 @@ func (r *Resource) checkState(rs types.Resource) (string, error) {
 }
 
 // execute checks current state of the deployment and triggers the deployment if needed.
-func (r *Resource) execute(rs types.Resource, saveStateF func(types.Resource)) error {
-	diff, err := r.checkState(rs)
+func (r *Resource) execute(ctx context.Context, rs types.Resource, saveStateF func(types.Resource)) error {
+	diff, err := r.checkState(ctx, rs)
 	if err != nil {
 		return fmt.Errorf("failed checking current state: %w", err)
 	}
@@ -300,11 +385,35 @@ func (r *Resource) execute(rs types.Resource, saveStateF func(types.Resource)) e
 		return nil
 	}
 
-	return r.deploy(rs, saveStateF)
+	if err := r.preflight(ctx, rs); err != nil {
+		return fmt.Errorf("preflight checks failed: %w", err)
+	}
+
+	return r.deploy(ctx, rs, saveStateF)
+}
+
+// preflight runs preflight checks for given resource, if it supports them and they are
+// not disabled.
+func (r *Resource) preflight(ctx context.Context, rs types.Resource) error {
+	pc, ok := rs.(types.PreflightChecker)
+	if !ok || r.SkipPreflight {
+		return nil
+	}
+
+	fmt.Printf("Running preflight checks...\n\n")
+
+	report := preflight.Run(ctx, pc.PreflightChecks())
+
+	fmt.Println(report.String())
+
+	return report.Err()
 }
 
 // deploy confirms the deployment with the user and persists the state after the deployment.
-func (r *Resource) deploy(rs types.Resource, saveStateF func(types.Resource)) error {
+//
+// State is persisted also when deployment fails or gets cancelled, so changes which has
+// been made so far are not lost.
+func (r *Resource) deploy(ctx context.Context, rs types.Resource, saveStateF func(types.Resource)) error {
 	if !r.Confirmed {
 		confirmed, err := askForConfirmation()
 		if err != nil {
@@ -318,7 +427,7 @@ func (r *Resource) deploy(rs types.Resource, saveStateF func(types.Resource)) er
 		}
 	}
 
-	deployErr := rs.Deploy()
+	deployErr := rs.Deploy(ctx)
 
 	if r.State == nil {
 		r.State = &ResourceState{}
@@ -441,7 +550,7 @@ func (r *Resource) validateKubeconfigPKI() error {
 		return fmt.Errorf("Kubernetes admin certificate not available in PKI") //nolint:stylecheck
 	}
 
-	return nil
+	return r.pendingCertificates("kubernetes.adminCertificate")
 }
 
 // validateKubeconfigControlplane validates if required fields are populated in Controlplane
@@ -485,8 +594,8 @@ func (r *Resource) Kubeconfig() (string, error) {
 
 	cc := &client.Config{
 		Server:            fmt.Sprintf("%s:%d", r.Controlplane.APIServerAddress, r.Controlplane.APIServerPort),
-		CACertificate:     r.State.PKI.Kubernetes.CA.X509Certificate,
-		ClientCertificate: r.State.PKI.Kubernetes.AdminCertificate.X509Certificate,
+		CACertificate:     r.State.PKI.TrustBundle(pki.KubernetesCAName),
+		ClientCertificate: r.State.PKI.CertificateChain(r.State.PKI.Kubernetes.AdminCertificate, pki.KubernetesCAName),
 		ClientKey:         r.State.PKI.Kubernetes.AdminCertificate.PrivateKey,
 	}
 
@@ -499,7 +608,7 @@ func (r *Resource) Kubeconfig() (string, error) {
 }
 
 // RunAPILoadBalancerPool deploys given API Load Balancer pool.
-func (r *Resource) RunAPILoadBalancerPool(name string) error {
+func (r *Resource) RunAPILoadBalancerPool(ctx context.Context, name string) error {
 	p, err := r.getAPILoadBalancerPool(name)
 	if err != nil {
 		return fmt.Errorf("failed getting API Load Balancer pool %q from configuration: %w", name, err)
@@ -513,11 +622,11 @@ func (r *Resource) RunAPILoadBalancerPool(name string) error {
 		r.State.APILoadBalancerPools[name] = &p.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(p, saveStateF)
+	return r.execute(ctx, p, saveStateF)
 }
 
 // RunControlplane deploys configured static controlplane.
-func (r *Resource) RunControlplane() error {
+func (r *Resource) RunControlplane(ctx context.Context) error {
 	e, err := r.getControlplane()
 	if err != nil {
 		return fmt.Errorf("failed getting controlplane from the configuration: %w", err)
@@ -527,11 +636,11 @@ func (r *Resource) RunControlplane() error {
 		r.State.Controlplane = &e.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(e, saveStateF)
+	return r.execute(ctx, e, saveStateF)
 }
 
 // RunEtcd deploys configured etcd cluster.
-func (r *Resource) RunEtcd() error {
+func (r *Resource) RunEtcd(ctx context.Context) error {
 	e, err := r.getEtcd()
 	if err != nil {
 		return fmt.Errorf("preparing failed: %w", err)
@@ -541,11 +650,11 @@ func (r *Resource) RunEtcd() error {
 		r.State.Etcd = &e.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(e, saveStateF)
+	return r.execute(ctx, e, saveStateF)
 }
 
 // RunKubeletPool deploys given kubelet pool.
-func (r *Resource) RunKubeletPool(name string) error {
+func (r *Resource) RunKubeletPool(ctx context.Context, name string) error {
 	p, err := r.getKubeletPool(name)
 	if err != nil {
 		return fmt.Errorf("failed getting kubelet pool %q from configuration: %w", name, err)
@@ -559,7 +668,7 @@ func (r *Resource) RunKubeletPool(name string) error {
 		r.State.KubeletPools[name] = &p.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(p, saveStateF)
+	return r.execute(ctx, p, saveStateF)
 }
 
 // RunPKI generates configured PKI.
@@ -569,10 +678,34 @@ func (r *Resource) RunPKI() error {
 		return fmt.Errorf("failed loading PKI configuration: %w", err)
 	}
 
+	plan, err := pki.Plan()
+	if err != nil {
+		return fmt.Errorf("failed planning PKI changes: %w", err)
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("All certificates are up to date")
+
+		return nil
+	}
+
+	for _, renewal := range plan {
+		fmt.Printf("Certificate %s will be generated: %s\n", renewal.Name, strings.Join(renewal.Reasons, ", "))
+	}
+
+	if r.Noop {
+		return nil
+	}
+
 	fmt.Println("Generating PKI...")
 
 	genErr := pki.Generate()
 
+	if csrs := pki.CertificateSigningRequests(); len(csrs) > 0 {
+		fmt.Printf("%d certificates await external signing, export the requests using 'pki export-csrs' command. "+
+			"Resources using them can't be deployed until signed certificates are imported\n", len(csrs))
+	}
+
 	if r.State == nil {
 		r.State = &ResourceState{}
 	}
@@ -582,8 +715,544 @@ func (r *Resource) RunPKI() error {
 	return r.StateToFile(genErr)
 }
 
+// PKIReport returns information about all certificates stored in the state.
+func (r *Resource) PKIReport(threshold time.Duration) (*pki.Report, error) {
+	if r.State == nil || r.State.PKI == nil {
+		return nil, fmt.Errorf("PKI not found in the state")
+	}
+
+	return r.State.PKI.Report(threshold)
+}
+
+// ExportCertificateSigningRequests writes certificate signing requests for all certificates,
+// which await external signing, into given directory. Each request is written into file named
+// after the certificate, e.g. 'etcd.peerCertificates.foo.csr'.
+func (r *Resource) ExportCertificateSigningRequests(dir string) error {
+	pki, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("failed loading PKI configuration: %w", err)
+	}
+
+	csrs := pki.CertificateSigningRequests()
+
+	if len(csrs) == 0 {
+		fmt.Println("No certificates await external signing")
+
+		return nil
+	}
+
+	for n, csr := range csrs {
+		p := filepath.Join(dir, n+".csr")
+
+		if err := ioutil.WriteFile(p, []byte(csr), 0o600); err != nil {
+			return fmt.Errorf("failed writing certificate signing request to %q: %w", p, err)
+		}
+
+		fmt.Printf("Certificate signing request for %s written to %s\n", n, p)
+	}
+
+	return nil
+}
+
+// ImportCertificates imports externally signed certificates from given directory for all
+// certificates, which await external signing. Certificates are read from files named after
+// the certificate, e.g. 'etcd.peerCertificates.foo.crt'. Only valid certificates are imported.
+func (r *Resource) ImportCertificates(dir string) error {
+	pki, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("failed loading PKI configuration: %w", err)
+	}
+
+	var errors util.ValidateError
+
+	names := []string{}
+
+	for n := range pki.CertificateSigningRequests() {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		p := filepath.Join(dir, n+".crt")
+
+		cert, err := ioutil.ReadFile(p) // #nosec G304
+		if os.IsNotExist(err) {
+			fmt.Printf("Certificate for %s not found in %s, skipping\n", n, p)
+
+			continue
+		}
+
+		if err != nil {
+			errors = append(errors, fmt.Errorf("reading certificate from %q: %w", p, err))
+
+			continue
+		}
+
+		if err := pki.ImportCertificate(n, types.Certificate(cert)); err != nil {
+			errors = append(errors, fmt.Errorf("importing certificate %q: %w", n, err))
+
+			continue
+		}
+
+		fmt.Printf("Certificate for %s imported\n", n)
+	}
+
+	if r.Noop {
+		return errors.Return()
+	}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	r.State.PKI = pki
+
+	return r.StateToFile(errors.Return())
+}
+
+// ExportKubeadm writes certificates and private keys stored in the state into given directory,
+// using kubeadm PKI layout. Server and peer certificates of given etcd member are written, if
+// member name is not empty.
+func (r *Resource) ExportKubeadm(dir, etcdMember string) error {
+	if r.State == nil || r.State.PKI == nil {
+		return fmt.Errorf("PKI not found in the state")
+	}
+
+	files, err := r.State.PKI.ExportKubeadm(etcdMember)
+	if err != nil {
+		return fmt.Errorf("failed exporting PKI: %w", err)
+	}
+
+	names := []string{}
+
+	for n := range files {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		p := filepath.Join(dir, filepath.FromSlash(n))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			return fmt.Errorf("failed creating directory for %q: %w", p, err)
+		}
+
+		// Certificates and public keys are not secret.
+		mode := os.FileMode(0o644)
+
+		if strings.HasSuffix(n, ".key") {
+			mode = 0o600
+		}
+
+		if err := ioutil.WriteFile(p, []byte(files[n]), mode); err != nil {
+			return fmt.Errorf("failed writing %q: %w", p, err)
+		}
+
+		fmt.Printf("Written %s\n", p)
+	}
+
+	return nil
+}
+
+// ImportKubeadm imports certificates and private keys from given directory with kubeadm PKI
+// layout into the state. Server and peer certificates are imported for given etcd member,
+// if member name is not empty. Imported certificates are reused by subsequent runs.
+func (r *Resource) ImportKubeadm(dir, etcdMember string) error {
+	pki, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("failed loading PKI configuration: %w", err)
+	}
+
+	files, err := readKubeadmFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed reading PKI directory: %w", err)
+	}
+
+	if err := pki.ImportKubeadm(files, etcdMember); err != nil {
+		return fmt.Errorf("failed importing PKI: %w", err)
+	}
+
+	fmt.Printf("Imported PKI from %s\n", dir)
+
+	if r.Noop {
+		return nil
+	}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	r.State.PKI = pki
+
+	return r.StateToFile(nil)
+}
+
+// readKubeadmFiles reads all certificates and keys from given directory, where key is
+// a path relative to given directory, e.g. 'etcd/ca.crt'.
+func readKubeadmFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+
+	walkF := func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		switch filepath.Ext(path) {
+		case ".crt", ".key", ".pub":
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("failed getting relative path of %q: %w", path, err)
+		}
+
+		content, err := ioutil.ReadFile(path) // #nosec G304
+		if err != nil {
+			return fmt.Errorf("failed reading %q: %w", path, err)
+		}
+
+		files[filepath.ToSlash(rel)] = string(content)
+
+		return nil
+	}
+
+	if err := filepath.Walk(dir, walkF); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// RotateCA advances rotation of given CA certificate by one phase, generates the PKI
+// and saves the state. Each phase must be followed by deploying all resources using
+// the CA certificate.
+func (r *Resource) RotateCA(name string) error {
+	pki, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("failed loading PKI configuration: %w", err)
+	}
+
+	phase, err := pki.RotateCA(name)
+	if err != nil {
+		return fmt.Errorf("failed rotating CA %q: %w", name, err)
+	}
+
+	if phase == "" {
+		fmt.Printf("Rotation of CA %s finished, old CA is no longer trusted\n", name)
+	} else {
+		fmt.Printf("Rotation of CA %s advanced to phase %q\n", name, phase)
+	}
+
+	if r.Noop {
+		return nil
+	}
+
+	genErr := pki.Generate()
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	r.State.PKI = pki
+
+	if err := r.StateToFile(genErr); err != nil {
+		return err
+	}
+
+	fmt.Println("Deploy all resources using the CA before advancing the rotation again")
+
+	return nil
+}
+
+// RotateServiceAccountKey advances rotation of service account signing key by one phase,
+// generates the PKI and saves the state. Each phase must be followed by deploying the
+// controlplane.
+func (r *Resource) RotateServiceAccountKey() error {
+	pki, err := r.getPKI()
+	if err != nil {
+		return fmt.Errorf("failed loading PKI configuration: %w", err)
+	}
+
+	phase, err := pki.RotateServiceAccountKey()
+	if err != nil {
+		return fmt.Errorf("failed rotating service account key: %w", err)
+	}
+
+	if phase == "" {
+		fmt.Println("Rotation of service account key finished, retired keys are no longer trusted")
+	} else {
+		fmt.Printf("Rotation of service account key advanced to phase %q\n", phase)
+	}
+
+	if r.Noop {
+		return nil
+	}
+
+	genErr := pki.Generate()
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	r.State.PKI = pki
+
+	if err := r.StateToFile(genErr); err != nil {
+		return err
+	}
+
+	fmt.Println("Deploy the controlplane before advancing the rotation again")
+
+	return nil
+}
+
+// bootstrapToken returns generated bootstrap token with given name or nil, if token is not found.
+func (r *Resource) bootstrapToken(name string) *bootstraptoken.Token {
+	if r.State == nil {
+		return nil
+	}
+
+	return r.State.BootstrapTokens[name]
+}
+
+// kubernetesClient returns Kubernetes client using admin kubeconfig.
+func (r *Resource) kubernetesClient() (client.Client, error) {
+	kubeconfig, err := r.Kubeconfig()
+	if err != nil {
+		return nil, fmt.Errorf("generating admin kubeconfig: %w", err)
+	}
+
+	c, err := client.NewClient([]byte(kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("creating Kubernetes client: %w", err)
+	}
+
+	return c, nil
+}
+
+// RunBootstrapTokens generates configured bootstrap tokens, which are missing, expired or
+// were generated using different configuration, creates them in the cluster and saves the
+// state. Tokens removed from the configuration are revoked.
+func (r *Resource) RunBootstrapTokens() error {
+	var errors util.ValidateError
+
+	for _, n := range bootstrapTokenConfigNames(r.BootstrapTokens) {
+		if err := r.BootstrapTokens[n].Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating bootstrap token %q: %w", n, err))
+		}
+	}
+
+	if err := errors.Return(); err != nil {
+		return err
+	}
+
+	if r.State == nil {
+		r.State = &ResourceState{}
+	}
+
+	tokens := map[string]*bootstraptoken.Token{}
+
+	for n, t := range r.State.BootstrapTokens {
+		tokens[n] = t
+	}
+
+	for _, n := range bootstrapTokenNames(tokens) {
+		if _, ok := r.BootstrapTokens[n]; !ok {
+			fmt.Printf("Bootstrap token %s will be revoked\n", n)
+		}
+	}
+
+	for _, n := range bootstrapTokenConfigNames(r.BootstrapTokens) {
+		t, ok := tokens[n]
+
+		switch {
+		case !ok || t.Expired():
+			fmt.Printf("Bootstrap token %s will be generated\n", n)
+		case !t.Matches(r.BootstrapTokens[n]):
+			fmt.Printf("Bootstrap token %s will be regenerated, as its configuration changed\n", n)
+		}
+	}
+
+	if r.Noop {
+		return nil
+	}
+
+	c, err := r.kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	if r.State.BootstrapTokens == nil {
+		r.State.BootstrapTokens = map[string]*bootstraptoken.Token{}
+	}
+
+	for _, n := range bootstrapTokenNames(tokens) {
+		if _, ok := r.BootstrapTokens[n]; ok {
+			continue
+		}
+
+		if err := r.revokeBootstrapToken(c, n); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	for _, n := range bootstrapTokenConfigNames(r.BootstrapTokens) {
+		t, ok := tokens[n]
+		if ok && !t.Expired() && t.Matches(r.BootstrapTokens[n]) {
+			if err := c.ApplySecret(t.ToSecret()); err != nil {
+				errors = append(errors, fmt.Errorf("creating bootstrap token %q: %w", n, err))
+			}
+
+			continue
+		}
+
+		if err := r.generateBootstrapToken(c, n); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	return r.StateToFile(errors.Return())
+}
+
+// generateBootstrapToken generates new bootstrap token with given name, creates it in the
+// cluster and replaces the old token in the state, which is then removed from the cluster.
+func (r *Resource) generateBootstrapToken(c client.Client, name string) error {
+	config, ok := r.BootstrapTokens[name]
+	if !ok {
+		return fmt.Errorf("bootstrap token %q not configured", name)
+	}
+
+	t, err := config.New()
+	if err != nil {
+		return fmt.Errorf("generating bootstrap token %q: %w", name, err)
+	}
+
+	if err := c.ApplySecret(t.ToSecret()); err != nil {
+		return fmt.Errorf("creating bootstrap token %q: %w", name, err)
+	}
+
+	old := r.State.BootstrapTokens[name]
+	r.State.BootstrapTokens[name] = t
+
+	fmt.Printf("Bootstrap token %s generated with ID %s\n", name, t.ID)
+
+	if old == nil {
+		return nil
+	}
+
+	if err := c.DeleteSecret(bootstraptoken.Namespace, old.SecretName()); err != nil {
+		return fmt.Errorf("removing old bootstrap token %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// revokeBootstrapToken removes bootstrap token with given name from the cluster and from the state.
+func (r *Resource) revokeBootstrapToken(c client.Client, name string) error {
+	t := r.bootstrapToken(name)
+	if t == nil {
+		return fmt.Errorf("bootstrap token %q not found in the state", name)
+	}
+
+	if err := c.DeleteSecret(bootstraptoken.Namespace, t.SecretName()); err != nil {
+		return fmt.Errorf("revoking bootstrap token %q: %w", name, err)
+	}
+
+	delete(r.State.BootstrapTokens, name)
+
+	fmt.Printf("Bootstrap token %s revoked\n", name)
+
+	return nil
+}
+
+// RotateBootstrapToken generates new bootstrap token with given name, replaces the old
+// token in the cluster and saves the state. Kubelet pools using the token must be deployed
+// again to use the new token.
+func (r *Resource) RotateBootstrapToken(name string) error {
+	if r.bootstrapToken(name) == nil {
+		return fmt.Errorf("bootstrap token %q not found in the state", name)
+	}
+
+	if r.Noop {
+		fmt.Printf("Bootstrap token %s will be rotated\n", name)
+
+		return nil
+	}
+
+	c, err := r.kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	return r.StateToFile(r.generateBootstrapToken(c, name))
+}
+
+// RevokeBootstrapToken removes bootstrap token with given name from the cluster and from
+// the state. If the token is still configured, it will be generated again on next run.
+func (r *Resource) RevokeBootstrapToken(name string) error {
+	if r.bootstrapToken(name) == nil {
+		return fmt.Errorf("bootstrap token %q not found in the state", name)
+	}
+
+	if r.Noop {
+		fmt.Printf("Bootstrap token %s will be revoked\n", name)
+
+		return nil
+	}
+
+	c, err := r.kubernetesClient()
+	if err != nil {
+		return err
+	}
+
+	return r.StateToFile(r.revokeBootstrapToken(c, name))
+}
+
+// BootstrapTokensTable returns human readable list of bootstrap tokens stored in the state
+// as a table. Token secrets are not included.
+func (r *Resource) BootstrapTokensTable() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tID\tEXPIRATION\tUSAGES\tGROUPS\tDESCRIPTION")
+
+	if r.State != nil {
+		for _, n := range bootstrapTokenNames(r.State.BootstrapTokens) {
+			t := r.State.BootstrapTokens[n]
+
+			expiration := "never"
+
+			if t.Expiration != nil {
+				expiration = t.Expiration.Format(time.RFC3339)
+			}
+
+			if t.Expired() {
+				expiration += " (expired)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				n,
+				t.ID,
+				expiration,
+				strings.Join(t.Usages, ","),
+				strings.Join(t.Groups, ","),
+				t.Description,
+			)
+		}
+	}
+
+	// Writing to strings.Builder never fails.
+	_ = w.Flush()
+
+	return sb.String()
+}
+
 // RunContainers deploys given containers group.
-func (r *Resource) RunContainers(name string) error {
+func (r *Resource) RunContainers(ctx context.Context, name string) error {
 	p, err := r.getContainers(name)
 	if err != nil {
 		return fmt.Errorf("failed getting containers group %q from configuration: %w", name, err)
@@ -597,7 +1266,137 @@ func (r *Resource) RunContainers(name string) error {
 		r.State.Containers[name] = &p.Containers().ToExported().PreviousState
 	}
 
-	return r.execute(p, saveStateF)
+	return r.execute(ctx, p, saveStateF)
+}
+
+// PreflightChecks returns preflight checks for all configured resources, which support them.
+func (r *Resource) PreflightChecks() ([]preflight.HostChecks, error) {
+	resources := []types.Resource{}
+
+	add := func(rs types.Resource, err error) error {
+		if err != nil {
+			return err
+		}
+
+		resources = append(resources, rs)
+
+		return nil
+	}
+
+	if r.Etcd != nil {
+		if err := add(r.getEtcd()); err != nil {
+			return nil, fmt.Errorf("failed getting etcd from the configuration: %w", err)
+		}
+	}
+
+	if r.Controlplane != nil {
+		if err := add(r.getControlplane()); err != nil {
+			return nil, fmt.Errorf("failed getting controlplane from the configuration: %w", err)
+		}
+	}
+
+	for _, name := range kubeletPoolNames(r.KubeletPools) {
+		if err := add(r.getKubeletPool(name)); err != nil {
+			return nil, fmt.Errorf("failed getting kubelet pool %q from configuration: %w", name, err)
+		}
+	}
+
+	for _, name := range apiLoadBalancerPoolNames(r.APILoadBalancerPools) {
+		if err := add(r.getAPILoadBalancerPool(name)); err != nil {
+			return nil, fmt.Errorf("failed getting API Load Balancer pool %q from configuration: %w", name, err)
+		}
+	}
+
+	for _, name := range containersNames(r.Containers) {
+		if err := add(r.getContainers(name)); err != nil {
+			return nil, fmt.Errorf("failed getting containers group %q from configuration: %w", name, err)
+		}
+	}
+
+	hostChecks := []preflight.HostChecks{}
+
+	for _, rs := range resources {
+		if pc, ok := rs.(types.PreflightChecker); ok {
+			hostChecks = append(hostChecks, pc.PreflightChecks()...)
+		}
+	}
+
+	return hostChecks, nil
+}
+
+// RunPreflight runs preflight checks for all configured resources and returns the report.
+func (r *Resource) RunPreflight(ctx context.Context) (*preflight.Report, error) {
+	hostChecks, err := r.PreflightChecks()
+	if err != nil {
+		return nil, fmt.Errorf("collecting preflight checks: %w", err)
+	}
+
+	return preflight.Run(ctx, hostChecks), nil
+}
+
+// kubeletPoolNames returns sorted names of given kubelet pools.
+func kubeletPoolNames(m map[string]*kubelet.Pool) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// apiLoadBalancerPoolNames returns sorted names of given API load balancer pools.
+func apiLoadBalancerPoolNames(m map[string]*apiloadbalancer.APILoadBalancers) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// containersNames returns sorted names of given container resources.
+func containersNames(m map[string]*container.ContainersState) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// bootstrapTokenConfigNames returns sorted names of given configured bootstrap tokens.
+func bootstrapTokenConfigNames(m map[string]*bootstraptoken.Config) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// bootstrapTokenNames returns sorted names of given bootstrap tokens in the state.
+func bootstrapTokenNames(m map[string]*bootstraptoken.Token) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
 }
 
 // Template executes given Go template using configuration and state.
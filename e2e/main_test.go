@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -402,17 +403,17 @@ func TestE2e(t *testing.T) {
 		t.Fatalf("Running PKI: %v", err)
 	}
 
-	if err := r.StateToFile(r.RunEtcd()); err != nil {
+	if err := r.StateToFile(r.RunEtcd(context.Background())); err != nil {
 		t.Fatalf("Running etcd: %v", err)
 	}
 
 	for k := range r.APILoadBalancerPools {
-		if err := r.StateToFile(r.RunAPILoadBalancerPool(k)); err != nil {
+		if err := r.StateToFile(r.RunAPILoadBalancerPool(context.Background(), k)); err != nil {
 			t.Fatalf("Running API load balancer pool %q: %v", k, err)
 		}
 	}
 
-	if err := r.StateToFile(r.RunControlplane()); err != nil {
+	if err := r.StateToFile(r.RunControlplane(context.Background())); err != nil {
 		t.Fatalf("Running controlplane: %v", err)
 	}
 
@@ -499,7 +500,7 @@ tokens:
 
 	// Deploy kubelets.
 	for k := range r.KubeletPools {
-		if err := r.StateToFile(r.RunKubeletPool(k)); err != nil {
+		if err := r.StateToFile(r.RunKubeletPool(context.Background(), k)); err != nil {
 			t.Fatalf("Running kubelet pool %q: %v", k, err)
 		}
 	}
@@ -5,6 +5,7 @@ package utiltest
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -78,6 +79,26 @@ func GenerateECPrivateKey(t *testing.T) string {
 	return key.String()
 }
 
+// GenerateEd25519PrivateKey generates Ed25519 private key in PKCS8 format, PEM encoded.
+func GenerateEd25519PrivateKey(t *testing.T) string {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed generating Ed25519 key: %v", err)
+	}
+
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("Failed serializing Ed25519 private key: %v", err)
+	}
+
+	var key bytes.Buffer
+	if err := pem.Encode(&key, &pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}); err != nil {
+		t.Fatalf("Failed to write data to key.pem: %s", err)
+	}
+
+	return key.String()
+}
+
 // GeneratePKI generates PKI struct.
 func GeneratePKI(t *testing.T) *PKI {
 	p, err := GeneratePKIErr()
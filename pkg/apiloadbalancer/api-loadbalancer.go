@@ -11,7 +11,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
@@ -28,11 +27,23 @@ type APILoadBalancer struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by the container. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// Host describes on which machine member container should be created.
 	//
 	// This field is required.
 	Host host.Host `json:"host,omitempty"`
 
+	// Runtime stores configuration of container runtime used for running load balancer
+	// container. If empty, Docker with default configuration is used.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// Servers is a list of Kubernetes API server addresses, which should be used as a backend
 	// servers.
 	//
@@ -77,7 +88,9 @@ type APILoadBalancer struct {
 // apiLoadBalancer is validated and executable version of APILoadBalancer.
 type apiLoadBalancer struct {
 	image          string
+	imageSource    string
 	host           host.Host
+	runtime        *container.RuntimeConfig
 	servers        []string
 	name           string
 	hostConfigPath string
@@ -150,14 +163,12 @@ func (a *apiLoadBalancer) ToHostConfiguredContainer() (*container.HostConfigured
 	}
 
 	c := container.Container{
-		// TODO: This is weird. This sets docker as default runtime config.
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: *container.BuildRuntimeConfig(a.runtime, nil),
 		Config: types.ContainerConfig{
 			// TODO: Make it configurable? And don't force user to use HAProxy.
 			Name:        a.name,
 			Image:       a.image,
+			ImageSource: a.imageSource,
 			NetworkMode: "host",
 			// Run as unprivileged user.
 			User: "65534",
@@ -190,7 +201,9 @@ func (a *APILoadBalancer) New() (container.ResourceInstance, error) {
 
 	na := &apiLoadBalancer{
 		image:          a.Image,
+		imageSource:    a.ImageSource,
 		host:           a.Host,
+		runtime:        a.Runtime,
 		servers:        a.Servers,
 		name:           util.PickString(a.Name, ContainerName),
 		hostConfigPath: util.PickString(a.HostConfigPath, HostConfigPath),
@@ -3,7 +3,9 @@
 package apiloadbalancer
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strconv"
 
 	"sigs.k8s.io/yaml"
@@ -11,6 +13,7 @@ import (
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
@@ -40,6 +43,14 @@ type APILoadBalancers struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by instances. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// If specified, this value will be used for all instances, which do not have it defined.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// SSH stores common SSH configuration for all instances and will be merged with instances
 	// SSH configuration. If instance has some SSH fields defined, they take precedence over
 	// this block.
@@ -50,6 +61,18 @@ type APILoadBalancers struct {
 	// This field is optional.
 	SSH *ssh.Config `json:"ssh,omitempty"`
 
+	// Host stores common host configuration for all instances and will be merged with instances
+	// host configuration, e.g. to run all instances locally using direct transport.
+	//
+	// This field is optional and it is mutually exclusive with SSH field.
+	Host *host.Host `json:"host,omitempty"`
+
+	// Runtime stores common container runtime configuration for all instances, e.g. Docker
+	// address or TLS certificates. It will be merged with instances runtime configuration.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// Servers is a list of Kubernetes API server addresses, which should be used as a backend
 	// servers.
 	//
@@ -114,18 +137,30 @@ type APILoadBalancers struct {
 
 // apiLoadBalancers is validated and executable version of APILoadBalancers.
 type apiLoadBalancers struct {
-	containers container.ContainersInterface
+	containers    container.ContainersInterface
+	bindAddresses map[string]string
 }
 
-func (a *APILoadBalancers) propagateInstance(i *APILoadBalancer) {
+func (a *APILoadBalancers) propagateInstance(i *APILoadBalancer) error {
 	i.Image = util.PickString(i.Image, a.Image)
+	i.ImageSource = util.PickString(i.ImageSource, a.ImageSource)
 	i.Servers = util.PickStringSlice(i.Servers, a.Servers)
-	i.Host = host.BuildConfig(i.Host, host.Host{
-		SSHConfig: a.SSH,
-	})
+
+	c, err := container.BuildInstanceConfig(container.InstanceConfig{
+		Host:    i.Host,
+		Runtime: i.Runtime,
+	}, container.PoolDefaults(a.Host, a.SSH, a.Runtime))
+	if err != nil {
+		return fmt.Errorf("building instance configuration: %w", err)
+	}
+
+	i.Host, i.Runtime = c.Host, c.Runtime
+
 	i.Name = util.PickString(i.Name, a.Name)
 	i.HostConfigPath = util.PickString(i.HostConfigPath, a.HostConfigPath)
 	i.BindAddress = util.PickString(i.BindAddress, a.BindAddress)
+
+	return nil
 }
 
 // New validates APILoadBalancers struct and fills all required fields in members with default values
@@ -142,20 +177,26 @@ func (a *APILoadBalancers) New() (types.Resource, error) {
 		DesiredState:  make(container.ContainersState),
 	}
 
+	bindAddresses := map[string]string{}
+
 	for i, lb := range a.APILoadBalancers {
 		lb := lb
-		a.propagateInstance(&lb)
+
+		// Validate already checks for errors, so we can skip checking here.
+		_ = a.propagateInstance(&lb)
 
 		lbx, _ := lb.New()
 		lbxHcc, _ := lbx.ToHostConfiguredContainer()
 
 		cc.DesiredState[strconv.Itoa(i)] = lbxHcc
+		bindAddresses[strconv.Itoa(i)] = lb.BindAddress
 	}
 
 	c, _ := cc.New()
 
 	return &apiLoadBalancers{
-		containers: c,
+		containers:    c,
+		bindAddresses: bindAddresses,
 	}, nil
 }
 
@@ -163,6 +204,10 @@ func (a *APILoadBalancers) New() (types.Resource, error) {
 func (a *APILoadBalancers) Validate() error {
 	var errors util.ValidateError
 
+	if err := container.ValidatePoolDefaults(a.Host, a.SSH); err != nil {
+		errors = append(errors, fmt.Errorf("validating defaults: %w", err))
+	}
+
 	cc := &container.Containers{
 		PreviousState: a.State,
 		DesiredState:  make(container.ContainersState),
@@ -170,7 +215,12 @@ func (a *APILoadBalancers) Validate() error {
 
 	for i, lb := range a.APILoadBalancers {
 		lb := lb
-		a.propagateInstance(&lb)
+
+		if err := a.propagateInstance(&lb); err != nil {
+			errors = append(errors, fmt.Errorf("failed building load balancer instance %q configuration: %w", i, err))
+
+			continue
+		}
 
 		lbx, err := lb.New()
 		if err != nil {
@@ -212,17 +262,40 @@ func (a *apiLoadBalancers) StateToYaml() ([]byte, error) {
 }
 
 // CheckCurrentState reads current state of the deployed resources.
-func (a *apiLoadBalancers) CheckCurrentState() error {
-	return a.containers.CheckCurrentState()
+func (a *apiLoadBalancers) CheckCurrentState(ctx context.Context) error {
+	return a.containers.CheckCurrentState(ctx)
 }
 
 // Deploy checks current status of deployed group of instances and updates them if there is some
 // configuration drift.
-func (a *apiLoadBalancers) Deploy() error {
-	return a.containers.Deploy()
+func (a *apiLoadBalancers) Deploy(ctx context.Context) error {
+	return a.containers.Deploy(ctx)
 }
 
 // Containers implement types.Resource interface.
 func (a *apiLoadBalancers) Containers() container.ContainersInterface {
 	return a.containers
 }
+
+// PreflightChecks implements types.PreflightChecker interface.
+//
+// For new instances, port they bind to must be free.
+func (a *apiLoadBalancers) PreflightChecks() []preflight.HostChecks {
+	return container.PreflightChecks(a.containers, func(name string, isNew bool) []preflight.Check {
+		if !isNew {
+			return nil
+		}
+
+		_, p, err := net.SplitHostPort(a.bindAddresses[name])
+		if err != nil {
+			return nil
+		}
+
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+
+		return []preflight.Check{preflight.PortFree(port)}
+	})
+}
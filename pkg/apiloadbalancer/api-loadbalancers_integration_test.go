@@ -3,6 +3,7 @@
 package apiloadbalancer
 
 import (
+	"context"
 	"io/ioutil"
 	"testing"
 
@@ -35,11 +36,11 @@ ssh:
 		t.Fatalf("creating apiloadbalancers object should succeed, got: %v", err)
 	}
 
-	if err := c.CheckCurrentState(); err != nil {
+	if err := c.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("checking current state should succeed, got: %v", err)
 	}
 
-	if err := c.Deploy(); err != nil {
+	if err := c.Deploy(context.Background()); err != nil {
 		t.Fatalf("deploying should succeed, got: %v", err)
 	}
 
@@ -59,11 +60,11 @@ apiLoadBalancers: []
 		t.Fatalf("creating apiloadbalancers object for teardown should succeed, got: %v", err)
 	}
 
-	if err := c.CheckCurrentState(); err != nil {
+	if err := c.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("checking current state for teardown should succeed, got: %v", err)
 	}
 
-	if err := c.Deploy(); err != nil {
+	if err := c.Deploy(context.Background()); err != nil {
 		t.Fatalf("tearing down should succeed, got: %v", err)
 	}
 }
@@ -1,6 +1,7 @@
 package apiloadbalancer
 
 import (
+	"context"
 	"testing"
 
 	"github.com/flexkube/libflexkube/pkg/types"
@@ -76,7 +77,7 @@ func TestLoadBalancersStateToYAML(t *testing.T) {
 func TestLoadBalancersCheckCurrentState(t *testing.T) {
 	p := GetLoadBalancers(t)
 
-	if err := p.CheckCurrentState(); err != nil {
+	if err := p.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("Dumping state to YAML should work, got: %v", err)
 	}
 }
@@ -85,7 +86,7 @@ func TestLoadBalancersCheckCurrentState(t *testing.T) {
 func TestLoadBalancersDeploy(t *testing.T) {
 	p := GetLoadBalancers(t)
 
-	if err := p.Deploy(); err == nil {
+	if err := p.Deploy(context.Background()); err == nil {
 		t.Fatalf("Deploying in testing environment should fail")
 	}
 }
@@ -98,3 +99,18 @@ func TestLoadBalancersContainers(t *testing.T) {
 		t.Fatalf("Containers() should return non-nil value")
 	}
 }
+
+// PreflightChecks() tests.
+func TestLoadBalancersPreflightChecks(t *testing.T) {
+	hc := GetLoadBalancers(t).(*apiLoadBalancers).PreflightChecks()
+
+	if len(hc) != 1 {
+		t.Fatalf("Expected checks for 1 instance, got %d", len(hc))
+	}
+
+	checks := hc[0].Checks
+
+	if e := "TCP port 6443 is free"; checks[len(checks)-1].Name != e {
+		t.Fatalf("Expected check %q, got %q", e, checks[len(checks)-1].Name)
+	}
+}
@@ -1,6 +1,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -12,22 +13,22 @@ import (
 // Interface represents container capabilities, which may or may not exist.
 type Interface interface {
 	// Create creates the container.
-	Create() (InstanceInterface, error)
+	Create(ctx context.Context) (InstanceInterface, error)
 
 	// From status restores container instance from given status.
 	FromStatus() (InstanceInterface, error)
 
 	// UpdateStatus updates container status.
-	UpdateStatus() error
+	UpdateStatus(ctx context.Context) error
 
 	// Start starts the container.
-	Start() error
+	Start(ctx context.Context) error
 
 	// Stop stops the container.
-	Stop() error
+	Stop(ctx context.Context) error
 
 	// Delete removes the container.
-	Delete() error
+	Delete(ctx context.Context) error
 
 	// Status returns container status.
 	Status() *types.ContainerStatus
@@ -52,26 +53,26 @@ type Interface interface {
 // container.
 type InstanceInterface interface {
 	// Status returns container status read from the configured container runtime.
-	Status() (types.ContainerStatus, error)
+	Status(ctx context.Context) (types.ContainerStatus, error)
 
 	// Read reads content of the given file paths in the container.
-	Read(srcPath []string) ([]*types.File, error)
+	Read(ctx context.Context, srcPath []string) ([]*types.File, error)
 
 	// Copy copies file into the container.
-	Copy(files []*types.File) error
+	Copy(ctx context.Context, files []*types.File) error
 
 	// Stat checks if given files exist on the container and returns map of
 	// file modes. If key is missing, it means file does not exist in the container.
-	Stat(paths []string) (map[string]os.FileMode, error)
+	Stat(ctx context.Context, paths []string) (map[string]os.FileMode, error)
 
 	// Start starts the container.
-	Start() error
+	Start(ctx context.Context) error
 
 	// Stop stops the container.
-	Stop() error
+	Stop(ctx context.Context) error
 
 	// Delete deletes the container.
-	Delete() error
+	Delete(ctx context.Context) error
 }
 
 // Container allows managing single container on directly reachable, configured container
@@ -152,6 +153,12 @@ func (c *Container) Validate() error {
 		return fmt.Errorf("image must be set")
 	}
 
+	switch c.Config.ImagePullPolicy {
+	case "", types.PullIfNotPresent, types.PullAlways, types.PullNever:
+	default:
+		return fmt.Errorf("image pull policy must be one of %q, %q or %q", types.PullIfNotPresent, types.PullAlways, types.PullNever)
+	}
+
 	if c.Runtime.Docker == nil {
 		return fmt.Errorf("docker runtime must be set")
 	}
@@ -176,8 +183,8 @@ func (c *container) selectRuntime() error {
 }
 
 // Create creates container container from it's definition.
-func (c *container) Create() (InstanceInterface, error) {
-	id, err := c.runtime.Create(&c.config)
+func (c *container) Create(ctx context.Context) (InstanceInterface, error) {
+	id, err := c.runtime.Create(ctx, &c.config)
 	if err != nil {
 		return nil, fmt.Errorf("creating container failed: %w", err)
 	}
@@ -214,13 +221,13 @@ func (c *container) RuntimeConfig() runtime.Config {
 	return c.runtimeConfig
 }
 
-func (c *container) UpdateStatus() error {
+func (c *container) UpdateStatus(ctx context.Context) error {
 	ci, err := c.FromStatus()
 	if err != nil {
 		return fmt.Errorf("failed creating container instance: %w", err)
 	}
 
-	s, err := ci.Status()
+	s, err := ci.Status(ctx)
 	if err != nil {
 		return fmt.Errorf("failed checking container status: %w", err)
 	}
@@ -247,41 +254,41 @@ func (c *container) Runtime() runtime.Runtime {
 }
 
 // Start starts existing Container and updates it's status.
-func (c *container) Start() error {
+func (c *container) Start(ctx context.Context) error {
 	ci, err := c.FromStatus()
 	if err != nil {
 		return fmt.Errorf("getting containers instance from status: %w", err)
 	}
 
-	if err := ci.Start(); err != nil {
+	if err := ci.Start(ctx); err != nil {
 		return fmt.Errorf("starting container: %w", err)
 	}
 
-	return c.UpdateStatus()
+	return c.UpdateStatus(ctx)
 }
 
 // Stop stops existing Container and updates it's status.
-func (c *container) Stop() error {
+func (c *container) Stop(ctx context.Context) error {
 	ci, err := c.FromStatus()
 	if err != nil {
 		return fmt.Errorf("getting containers instance from status: %w", err)
 	}
 
-	if err := ci.Stop(); err != nil {
+	if err := ci.Stop(ctx); err != nil {
 		return fmt.Errorf("stopping container: %w", err)
 	}
 
-	return c.UpdateStatus()
+	return c.UpdateStatus(ctx)
 }
 
 // Delete removes container and removes it's status.
-func (c *container) Delete() error {
+func (c *container) Delete(ctx context.Context) error {
 	ci, err := c.FromStatus()
 	if err != nil {
 		return fmt.Errorf("getting containers instance from status: %w", err)
 	}
 
-	if err := ci.Delete(); err != nil {
+	if err := ci.Delete(ctx); err != nil {
 		return fmt.Errorf("deleting container: %w", err)
 	}
 
@@ -291,37 +298,37 @@ func (c *container) Delete() error {
 }
 
 // ReadState reads state of the container from container runtime and returns it to the user.
-func (c *containerInstance) Status() (types.ContainerStatus, error) {
-	return c.runtime.Status(c.status.ID)
+func (c *containerInstance) Status(ctx context.Context) (types.ContainerStatus, error) {
+	return c.runtime.Status(ctx, c.status.ID)
 }
 
 // Read reads given path from the container and returns reader with TAR format with file content.
-func (c *containerInstance) Read(srcPath []string) ([]*types.File, error) {
-	return c.runtime.Read(c.status.ID, srcPath)
+func (c *containerInstance) Read(ctx context.Context, srcPath []string) ([]*types.File, error) {
+	return c.runtime.Read(ctx, c.status.ID, srcPath)
 }
 
 // Copy takes output path and TAR reader as arguments and extracts this TAR archive into container.
-func (c *containerInstance) Copy(files []*types.File) error {
-	return c.runtime.Copy(c.status.ID, files)
+func (c *containerInstance) Copy(ctx context.Context, files []*types.File) error {
+	return c.runtime.Copy(ctx, c.status.ID, files)
 }
 
 // Stat checks if given path exists on the container and if yes, returns information whether
 // it is file, or directory etc.
-func (c *containerInstance) Stat(paths []string) (map[string]os.FileMode, error) {
-	return c.runtime.Stat(c.status.ID, paths)
+func (c *containerInstance) Stat(ctx context.Context, paths []string) (map[string]os.FileMode, error) {
+	return c.runtime.Stat(ctx, c.status.ID, paths)
 }
 
 // Start starts the container.
-func (c *containerInstance) Start() error {
-	return c.runtime.Start(c.status.ID)
+func (c *containerInstance) Start(ctx context.Context) error {
+	return c.runtime.Start(ctx, c.status.ID)
 }
 
 // Stop stops the container.
-func (c *containerInstance) Stop() error {
-	return c.runtime.Stop(c.status.ID)
+func (c *containerInstance) Stop(ctx context.Context) error {
+	return c.runtime.Stop(ctx, c.status.ID)
 }
 
 // Delete removes the container.
-func (c *containerInstance) Delete() error {
-	return c.runtime.Delete(c.status.ID)
+func (c *containerInstance) Delete(ctx context.Context) error {
+	return c.runtime.Delete(ctx, c.status.ID)
 }
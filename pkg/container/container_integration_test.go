@@ -3,6 +3,7 @@
 package container
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"fmt"
@@ -30,7 +31,7 @@ func TestDockerCreateNonExistingImage(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	if _, err = c.Create(); err == nil {
+	if _, err = c.Create(context.Background()); err == nil {
 		t.Fatalf("Creating container with non-existing image should fail")
 	}
 }
@@ -51,7 +52,7 @@ func TestDockerCreate(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	if _, err := c.Create(); err != nil {
+	if _, err := c.Create(context.Background()); err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 }
@@ -73,12 +74,12 @@ func TestDockerStatus(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	ci, err := c.Create()
+	ci, err := c.Create(context.Background())
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 
-	if _, err := ci.Status(); err != nil {
+	if _, err := ci.Status(context.Background()); err != nil {
 		t.Fatalf("Checking container status should succeed, got: %v", err)
 	}
 }
@@ -99,14 +100,14 @@ func TestDockerStatusNonExistingContainer(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	ci, err := c.Create()
+	ci, err := c.Create(context.Background())
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 
 	ci.(*containerInstance).status.ID = ""
 
-	status, err := ci.Status()
+	status, err := ci.Status(context.Background())
 	if err != nil {
 		t.Fatalf("Checking container status for non existing container should succeed")
 	}
@@ -133,12 +134,12 @@ func TestDockerStart(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	ci, err := c.Create()
+	ci, err := c.Create(context.Background())
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 
-	if err := ci.Start(); err != nil {
+	if err := ci.Start(context.Background()); err != nil {
 		t.Fatalf("Starting container should succeed, got: %v", err)
 	}
 }
@@ -160,16 +161,16 @@ func TestDockerStop(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	ci, err := c.Create()
+	ci, err := c.Create(context.Background())
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 
-	if err := ci.Start(); err != nil {
+	if err := ci.Start(context.Background()); err != nil {
 		t.Fatalf("Starting container should succeed, got: %v", err)
 	}
 
-	if err := ci.Stop(); err != nil {
+	if err := ci.Stop(context.Background()); err != nil {
 		t.Fatalf("Stopping container should succeed, got: %v", err)
 	}
 }
@@ -191,12 +192,12 @@ func TestDockerDelete(t *testing.T) {
 		t.Fatalf("Initializing container should succeed, got: %v", err)
 	}
 
-	ci, err := c.Create()
+	ci, err := c.Create(context.Background())
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %v", err)
 	}
 
-	if err := ci.Delete(); err != nil {
+	if err := ci.Delete(context.Background()); err != nil {
 		t.Fatalf("Removing container should succeed, got: %v", err)
 	}
 }
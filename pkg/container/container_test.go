@@ -1,6 +1,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -81,6 +82,22 @@ func TestValidateRequireImage(t *testing.T) {
 	}
 }
 
+func TestValidateBadImagePullPolicy(t *testing.T) {
+	c := &Container{
+		Runtime: RuntimeConfig{
+			Docker: &docker.Config{},
+		},
+		Config: types.ContainerConfig{
+			Name:            "foo",
+			Image:           "nonexistent",
+			ImagePullPolicy: "Sometimes",
+		},
+	}
+	if err := c.Validate(); err == nil {
+		t.Errorf("Validating container with unsupported image pull policy should fail")
+	}
+}
+
 // selectRuntime() tests.
 func TestSelectDockerRuntime(t *testing.T) {
 	c := &container{
@@ -135,7 +152,7 @@ func TestStatus(t *testing.T) {
 		},
 	}
 
-	if _, err := c.Status(); err == nil {
+	if _, err := c.Status(context.Background()); err == nil {
 		t.Fatalf("Checking container status should propagate failure")
 	}
 }
@@ -144,7 +161,7 @@ func TestStatus(t *testing.T) {
 func TestContainerUpdateStatusEmptyStatus(t *testing.T) {
 	c := &container{}
 
-	if err := c.UpdateStatus(); err == nil {
+	if err := c.UpdateStatus(context.Background()); err == nil {
 		t.Fatalf("Updating status of non-existing container should fail")
 	}
 }
@@ -163,7 +180,7 @@ func TestContainerUpdateStatusFail(t *testing.T) {
 		},
 	}
 
-	if err := c.UpdateStatus(); err == nil {
+	if err := c.UpdateStatus(context.Background()); err == nil {
 		t.Fatalf("Updating status with failing runtime should fail")
 	}
 }
@@ -188,7 +205,7 @@ func TestContainerUpdateStatus(t *testing.T) {
 		},
 	}
 
-	if err := c.UpdateStatus(); err != nil {
+	if err := c.UpdateStatus(context.Background()); err != nil {
 		t.Fatalf("Updating status should succeed, got: %v", err)
 	}
 
@@ -205,7 +222,7 @@ func TestContainerStartBadState(t *testing.T) {
 		},
 	}
 
-	if err := c.Start(); err == nil {
+	if err := c.Start(context.Background()); err == nil {
 		t.Fatalf("Starting non-existing container should fail")
 	}
 }
@@ -225,7 +242,7 @@ func TestContainerStartRuntimeError(t *testing.T) {
 		},
 	}
 
-	if err := c.Start(); err == nil {
+	if err := c.Start(context.Background()); err == nil {
 		t.Fatalf("Starting container should fail when runtime error occurs")
 	}
 }
@@ -253,7 +270,7 @@ func TestContainerStart(t *testing.T) {
 		},
 	}
 
-	if err := c.Start(); err != nil {
+	if err := c.Start(context.Background()); err != nil {
 		t.Fatalf("Starting should succeed, got: %v", err)
 	}
 
@@ -270,7 +287,7 @@ func TestContainerStopBadState(t *testing.T) {
 		},
 	}
 
-	if err := c.Stop(); err == nil {
+	if err := c.Stop(context.Background()); err == nil {
 		t.Fatalf("Stopping non-existing container should fail")
 	}
 }
@@ -290,7 +307,7 @@ func TestContainerStopRuntimeError(t *testing.T) {
 		},
 	}
 
-	if err := c.Stop(); err == nil {
+	if err := c.Stop(context.Background()); err == nil {
 		t.Fatalf("Stopping container should fail when runtime error occurs")
 	}
 }
@@ -318,7 +335,7 @@ func TestContainerStop(t *testing.T) {
 		},
 	}
 
-	if err := c.Stop(); err != nil {
+	if err := c.Stop(context.Background()); err != nil {
 		t.Fatalf("Stopping should succeed, got: %v", err)
 	}
 
@@ -335,7 +352,7 @@ func TestContainerDeleteBadState(t *testing.T) {
 		},
 	}
 
-	if err := c.Delete(); err == nil {
+	if err := c.Delete(context.Background()); err == nil {
 		t.Fatalf("Deleting non-existing container should fail")
 	}
 }
@@ -355,7 +372,7 @@ func TestContainerDeleteRuntimeError(t *testing.T) {
 		},
 	}
 
-	if err := c.Delete(); err == nil {
+	if err := c.Delete(context.Background()); err == nil {
 		t.Fatalf("Deleting container should fail when runtime error occurs")
 	}
 }
@@ -375,7 +392,7 @@ func TestContainerDelete(t *testing.T) {
 		},
 	}
 
-	if err := c.Delete(); err != nil {
+	if err := c.Delete(context.Background()); err != nil {
 		t.Fatalf("Deleting should succeed, got: %v", err)
 	}
 
@@ -3,14 +3,20 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"sigs.k8s.io/yaml"
 
 	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 )
 
 // ContainersInterface represents capabilities of containers struct.
@@ -21,12 +27,12 @@ type ContainersInterface interface {
 	//
 	// Calling CheckCurrentState is required before calling Deploy(), to ensure, that Deploy() executes
 	// correct actions.
-	CheckCurrentState() error
+	CheckCurrentState(ctx context.Context) error
 
 	// Deploy creates configured containers.
 	//
 	// CheckCurrentState() must be called before calling Deploy(), otherwise error will be returned.
-	Deploy() error
+	Deploy(ctx context.Context) error
 
 	// StateToYaml converts resource's containers state into YAML format and returns it to the user,
 	// so it can be persisted, e.g. to the file.
@@ -48,6 +54,16 @@ type ContainersInterface interface {
 	// Having those fields modified allows to minimize the difference when comparing previous state
 	// and desired state.
 	DesiredState() ContainersState
+
+	// Connections returns connection pool shared by the containers, which can be used to
+	// reuse connections to the hosts, where containers run. Connections in the pool are
+	// closed when CheckCurrentState() or Deploy() finishes.
+	Connections() *host.ConnectionPool
+
+	// RuntimeInfo returns information about container runtime on the host, where desired
+	// container with given name runs. Connection to the host is made using Connections(),
+	// so learned SSH host keys are verified and connection is reused by Deploy().
+	RuntimeInfo(ctx context.Context, name string) (types.RuntimeInfo, error)
 }
 
 // Containers allow to orchestrate and update multiple containers spread
@@ -72,6 +88,9 @@ type containers struct {
 
 	// resiredState is a user-defined desired containers configuration after validation.
 	desiredState containersState
+
+	// connections is shared by all containers, so connections to the same host are reused.
+	connections *host.ConnectionPool
 }
 
 // New validates Containers configuration and returns container object, which can be
@@ -85,10 +104,21 @@ func (c *Containers) New() (ContainersInterface, error) {
 	previousState, _ := c.PreviousState.New()
 	desiredState, _ := c.DesiredState.New()
 
-	return &containers{
+	co := &containers{
 		previousState: previousState.(containersState),
 		desiredState:  desiredState.(containersState),
-	}, nil
+		connections:   host.NewConnectionPool(),
+	}
+
+	for _, s := range []containersState{co.previousState, co.desiredState} {
+		for _, hcc := range s {
+			hcc.connections = co.connections
+		}
+	}
+
+	co.propagateLearnedHostKeys()
+
+	return co, nil
 }
 
 // Validate validates Containers struct and all structs used underneath.
@@ -123,13 +153,13 @@ func (c *Containers) Validate() error {
 //
 // Calling CheckCurrentState is required before calling Deploy(), to ensure, that Deploy() executes
 // correct actions.
-func (c *Containers) CheckCurrentState() error {
+func (c *Containers) CheckCurrentState(ctx context.Context) error {
 	containers, err := c.New()
 	if err != nil {
 		return fmt.Errorf("failed creating containers configuration: %w", err)
 	}
 
-	if err := containers.CheckCurrentState(); err != nil {
+	if err := containers.CheckCurrentState(ctx); err != nil {
 		return fmt.Errorf("failed checking current state of the containers: %w", err)
 	}
 
@@ -141,7 +171,7 @@ func (c *Containers) CheckCurrentState() error {
 // Deploy creates configured containers.
 //
 // CheckCurrentState() must be called before calling Deploy(), otherwise error will be returned.
-func (c *Containers) Deploy() error {
+func (c *Containers) Deploy(ctx context.Context) error {
 	containers, err := c.New()
 	if err != nil {
 		return fmt.Errorf("initializing containers: %w", err)
@@ -156,11 +186,11 @@ func (c *Containers) Deploy() error {
 	// some optimization.
 	// Alternatively we can have serializable plan and a knob in execute command to control whether we should
 	// make additional validation or not.
-	if err := containers.CheckCurrentState(); err != nil {
+	if err := containers.CheckCurrentState(ctx); err != nil {
 		return fmt.Errorf("checking current state: %w", err)
 	}
 
-	if err := containers.Deploy(); err != nil {
+	if err := containers.Deploy(ctx); err != nil {
 		return fmt.Errorf("deploying: %w", err)
 	}
 
@@ -171,7 +201,11 @@ func (c *Containers) Deploy() error {
 
 // CheckCurrentState copies previous state to current state, to mark, that it has been called at least once
 // and then updates state of all containers.
-func (c *containers) CheckCurrentState() error {
+//
+// Connections opened while checking the state are closed before returning.
+func (c *containers) CheckCurrentState(ctx context.Context) (err error) {
+	defer c.closeConnections(&err)
+
 	if c.currentState == nil {
 		// We just assign the pointer, but it's fine, since we don't need previous
 		// state anyway.
@@ -179,7 +213,59 @@ func (c *containers) CheckCurrentState() error {
 		c.currentState = c.previousState
 	}
 
-	return c.currentState.CheckState()
+	if err := c.currentState.CheckState(ctx); err != nil {
+		return err
+	}
+
+	// Checking the state may learn new host keys, so make them available for deployment.
+	c.propagateLearnedHostKeys()
+
+	return nil
+}
+
+// propagateLearnedHostKeys copies SSH host keys learned using trust on first use from the
+// previous state to the desired state, so new connections to the same hosts are verified
+// against them.
+func (c *containers) propagateLearnedHostKeys() {
+	keys := map[string]string{}
+
+	for _, hcc := range c.previousState {
+		for _, s := range sshConfigs(hcc.host.SSHConfig) {
+			if s.LearnedHostKey != "" {
+				keys[sshAddress(s)] = s.LearnedHostKey
+			}
+		}
+	}
+
+	for _, hcc := range c.desiredState {
+		for _, s := range sshConfigs(hcc.host.SSHConfig) {
+			if s.TrustsOnFirstUse() && s.LearnedHostKey == "" {
+				s.LearnedHostKey = keys[sshAddress(s)]
+			}
+		}
+	}
+}
+
+// sshConfigs returns given SSH configuration together with configurations of it's jump hosts.
+func sshConfigs(s *ssh.Config) []*ssh.Config {
+	if s == nil {
+		return nil
+	}
+
+	r := []*ssh.Config{s}
+
+	for _, j := range s.JumpHosts {
+		if j != nil {
+			r = append(r, j)
+		}
+	}
+
+	return r
+}
+
+// sshAddress returns address of the host with port defined in given SSH configuration.
+func sshAddress(s *ssh.Config) string {
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
 }
 
 // filesToUpdate returns list of files, which needs to be updated, based on the current state of the container.
@@ -208,7 +294,7 @@ func filesToUpdate(d hostConfiguredContainer, c *hostConfiguredContainer) []stri
 }
 
 // ensureConfigured makes sure that all desired configuration files are correct.
-func (c *containers) ensureConfigured(n string) error {
+func (c *containers) ensureConfigured(ctx context.Context, n string) error {
 	d := c.desiredState[n]
 
 	// Container won't be needed anyway, so skip everything.
@@ -220,7 +306,7 @@ func (c *containers) ensureConfigured(n string) error {
 
 	f := filesToUpdate(*d, r)
 
-	err := d.Configure(f)
+	err := d.Configure(ctx, f)
 
 	if err != nil && reflect.DeepEqual(f, filesToUpdate(*d, r)) {
 		return fmt.Errorf("no files has been updated: %w", err)
@@ -243,7 +329,7 @@ func (c *containers) ensureConfigured(n string) error {
 }
 
 // ensureRunning makes sure that given container is running.
-func ensureRunning(c *hostConfiguredContainer) error {
+func ensureRunning(ctx context.Context, c *hostConfiguredContainer) error {
 	if c == nil {
 		return fmt.Errorf("can't start non-existing container")
 	}
@@ -252,10 +338,10 @@ func ensureRunning(c *hostConfiguredContainer) error {
 		return nil
 	}
 
-	return c.Start()
+	return c.Start(ctx)
 }
 
-func (c *containers) ensureExists(n string) error {
+func (c *containers) ensureExists(ctx context.Context, n string) error {
 	r := c.currentState[n]
 	if r != nil && r.container.Status().Exists() {
 		return nil
@@ -265,7 +351,7 @@ func (c *containers) ensureExists(n string) error {
 
 	d := c.desiredState[n]
 
-	err := c.desiredState.CreateAndStart(n)
+	err := c.desiredState.CreateAndStart(ctx, n)
 
 	// Container creation failed and it does not exist, meaning state is clean.
 	if err != nil && !d.container.Status().Exists() {
@@ -316,19 +402,40 @@ func (c *containers) diffHost(n string) (string, error) {
 		return "", fmt.Errorf("can't diff container: %w", err)
 	}
 
-	return cmp.Diff(c.currentState[n].host, c.desiredState[n].host), nil
+	// Learned host key is managed automatically, while keepalive, agent, authentication and host key
+	// verification settings only affect how connection is made and maintained, so none of them affect
+	// where container runs.
+	ignoreConnectionFields := cmpopts.IgnoreFields(ssh.Config{},
+		"LearnedHostKey",
+		"KeepaliveInterval",
+		"KeepaliveCountMax",
+		"ForwardAgent",
+		"Password",
+		"PrivateKey",
+		"PrivateKeyFile",
+		"Certificate",
+		"CertificateFile",
+		"OpenSSHConfigFile",
+		"HostAlias",
+		"HostKeys",
+		"KnownHostsFile",
+		"TrustOnFirstUse",
+		"InsecureIgnoreHostKey",
+	)
+
+	return cmp.Diff(c.currentState[n].host, c.desiredState[n].host, ignoreConnectionFields), nil
 }
 
 // recreate is a helper, which removes container from current state and creates new one from
 // desired state.
-func (c *containers) recreate(n string) error {
-	if err := c.currentState.RemoveContainer(n); err != nil {
+func (c *containers) recreate(ctx context.Context, n string) error {
+	if err := c.currentState.RemoveContainer(ctx, n); err != nil {
 		return fmt.Errorf("failed removing old container to recreate it: %w", err)
 	}
 
 	c.currentState[n] = nil
 
-	err := c.desiredState.CreateAndStart(n)
+	err := c.desiredState.CreateAndStart(ctx, n)
 
 	c.currentState[n] = c.desiredState[n]
 
@@ -342,22 +449,24 @@ func (c *containers) recreate(n string) error {
 // ensureHost makes sure container is running on the right host.
 //
 // If host configuration changes, existing container will be removed and new one will be created.
-//
-// TODO This might be an overkill. e.g. changing SSH key for deployment will re-create all containers.
-func (c *containers) ensureHost(n string) error {
+func (c *containers) ensureHost(ctx context.Context, n string) error {
 	diff, err := c.diffHost(n)
 	if err != nil {
 		return fmt.Errorf("failed to check host diff: %w", err)
 	}
 
 	if diff == "" {
+		// Connection settings are not compared, so store the desired ones, so they are
+		// used for further connections and saved in the state.
+		c.currentState[n].host = c.desiredState[n].host
+
 		return nil
 	}
 
 	fmt.Printf("Detected host configuration drift '%s'\n", n)
 	fmt.Printf("  Diff: %v\n", util.ColorizeDiff(diff))
 
-	return c.recreate(n)
+	return c.recreate(ctx, n)
 }
 
 // diffContainer compares container fields of the container and returns it's diff.
@@ -368,16 +477,100 @@ func (c *containers) diffContainer(n string) (string, error) {
 		return "", fmt.Errorf("can't diff container: %w", err)
 	}
 
-	cd := cmp.Diff(c.currentState[n].container.Config(), c.desiredState[n].container.Config())
+	// Image source is only used to make image available on the host, so changing the path
+	// alone should not cause container to be re-created. Changes to the image in the source
+	// are detected by comparing image IDs.
+	ignoreImageSource := cmpopts.IgnoreFields(types.ContainerConfig{}, "ImageSource")
+
+	cd := cmp.Diff(c.currentState[n].container.Config(), c.desiredState[n].container.Config(), ignoreImageSource)
 	rcd := cmp.Diff(c.currentState[n].container.RuntimeConfig(), c.desiredState[n].container.RuntimeConfig())
 
-	return cd + rcd, nil
+	id, err := c.diffImage(n)
+	if err != nil {
+		return "", fmt.Errorf("can't diff container image: %w", err)
+	}
+
+	return cd + rcd + id, nil
+}
+
+// diffImage compares ID of the image, which container has been created from with ID of the
+// image with the same name currently present on the host. If they differ, for example
+// when the tag has been re-pushed and pulled again, container should be re-created.
+//
+// If digest of the image in the registry is known, it is compared with registry digests of
+// the image, which container has been created from, so re-pushed tag is detected before
+// pulling it. Similarly, if desired image source is set, ID of the image in the source is
+// compared, so changed image source is detected before loading it.
+func (c *containers) diffImage(n string) (string, error) {
+	r := c.currentState[n]
+
+	diff := ""
+
+	if r.imageID != "" && r.container.Status().ImageID != "" {
+		diff = cmp.Diff(r.container.Status().ImageID, r.imageID)
+	}
+
+	sourceDiff, err := c.diffImageSource(n)
+	if err != nil {
+		return "", err
+	}
+
+	diff += sourceDiff
+
+	if r.registryDigest == "" {
+		return diff, nil
+	}
+
+	digests := imageDigests(r.container.Status().RepoDigests)
+
+	for _, d := range digests {
+		if d == r.registryDigest {
+			return diff, nil
+		}
+	}
+
+	return diff + cmp.Diff(digests, []string{r.registryDigest}), nil
+}
+
+// diffImageSource compares ID of the image, which container has been created from, with ID
+// of the image in the desired image source. With 'Always' image pull policy, image source is
+// only used when pulling fails, so it is not compared.
+func (c *containers) diffImageSource(n string) (string, error) {
+	config := c.desiredState[n].container.Config()
+	id := c.currentState[n].container.Status().ImageID
+
+	if config.ImageSource == "" || config.ImagePullPolicy == types.PullAlways || id == "" {
+		return "", nil
+	}
+
+	sourceID, err := docker.ImageSourceID(config.ImageSource, config.Image)
+	if err != nil {
+		return "", fmt.Errorf("reading image ID from image source %q: %w", config.ImageSource, err)
+	}
+
+	if sourceID == "" {
+		return "", nil
+	}
+
+	return cmp.Diff(id, sourceID), nil
+}
+
+// imageDigests returns digests from given registry digests of the image,
+// e.g. 'sha256:foo' from 'quay.io/coreos/etcd@sha256:foo'.
+func imageDigests(repoDigests []string) []string {
+	digests := []string{}
+
+	for _, rd := range repoDigests {
+		digests = append(digests, rd[strings.LastIndex(rd, "@")+1:])
+	}
+
+	return digests
 }
 
 // ensureContainer makes sure container configuration is up to date.
 //
 // If container configuration changes, existing container will be removed and new one will be created.
-func (c *containers) ensureContainer(n string) error {
+func (c *containers) ensureContainer(ctx context.Context, n string) error {
 	diff, err := c.diffContainer(n)
 	if err != nil {
 		return fmt.Errorf("failed to check container diff: %w", err)
@@ -390,7 +583,7 @@ func (c *containers) ensureContainer(n string) error {
 	fmt.Printf("Detected container configuration drift '%s'\n", n)
 	fmt.Printf("  Diff: %v\n", util.ColorizeDiff(diff))
 
-	return c.recreate(n)
+	return c.recreate(ctx, n)
 }
 
 // hasUpdates return bool if there are any pending configuration changes to the container.
@@ -410,7 +603,7 @@ func (c *containers) hasUpdates(n string) (bool, error) {
 	return diffHost != "" || len(f) != 0 || diffContainer != "", nil
 }
 
-func (c *containers) ensureCurrentContainer(n string, r hostConfiguredContainer) (*hostConfiguredContainer, error) {
+func (c *containers) ensureCurrentContainer(ctx context.Context, n string, r hostConfiguredContainer) (*hostConfiguredContainer, error) {
 	// Gather facts about the container..
 	exists := r.container.Status().Exists()
 	_, isDesired := c.desiredState[n]
@@ -435,41 +628,41 @@ func (c *containers) ensureCurrentContainer(n string, r hostConfiguredContainer)
 
 	// If container exist, is desired or has no pending updates, make sure it's running.
 	if exists && isDesired && !hasUpdates {
-		return &r, ensureRunning(&r)
+		return &r, ensureRunning(ctx, &r)
 	}
 
 	return &r, nil
 }
 
 // ensureNewContainer handles configuring and creating new containers.
-func (c *containers) ensureNewContainer(i string) error {
+func (c *containers) ensureNewContainer(ctx context.Context, i string) error {
 	if _, existingContainer := c.currentState[i]; existingContainer {
 		return nil
 	}
 
-	if err := c.ensureConfigured(i); err != nil {
+	if err := c.ensureConfigured(ctx, i); err != nil {
 		return fmt.Errorf("failed configuring container %s: %w", i, err)
 	}
 
-	if err := c.ensureExists(i); err != nil {
+	if err := c.ensureExists(ctx, i); err != nil {
 		return fmt.Errorf("failed creating new container %s: %w", i, err)
 	}
 
 	return nil
 }
 
-func (c *containers) ensureUpToDate(i string) error {
+func (c *containers) ensureUpToDate(ctx context.Context, i string) error {
 	// Update containers on hosts.
 	// This can move containers between hosts, but NOT the data.
-	if err := c.ensureHost(i); err != nil {
+	if err := c.ensureHost(ctx, i); err != nil {
 		return fmt.Errorf("failed updating host configuration of container %s: %w", i, err)
 	}
 
-	if err := c.ensureConfigured(i); err != nil {
+	if err := c.ensureConfigured(ctx, i); err != nil {
 		return fmt.Errorf("failed updating configuration for container %s: %w", i, err)
 	}
 
-	if err := c.ensureContainer(i); err != nil {
+	if err := c.ensureContainer(ctx, i); err != nil {
 		return fmt.Errorf("failed updating container %s: %w", i, err)
 	}
 
@@ -478,17 +671,17 @@ func (c *containers) ensureUpToDate(i string) error {
 
 // updateExistingContainer handles updating existing containers. It either removes them
 // if they are not needed anymore or makes sure that their configuration is up to date.
-func (c *containers) updateExistingContainers() error {
+func (c *containers) updateExistingContainers(ctx context.Context) error {
 	for i := range c.currentState {
 		if _, exists := c.desiredState[i]; !exists {
-			if err := c.currentState.RemoveContainer(i); err != nil {
+			if err := c.currentState.RemoveContainer(ctx, i); err != nil {
 				return fmt.Errorf("failed removing old container: %w", err)
 			}
 
 			continue
 		}
 
-		if err := c.ensureUpToDate(i); err != nil {
+		if err := c.ensureUpToDate(ctx, i); err != nil {
 			return fmt.Errorf("failed ensuring, that container %s is up to date: %w", i, err)
 		}
 	}
@@ -503,7 +696,9 @@ func (c *containers) updateExistingContainers() error {
 // TODO currently we only compare previous configuration with new configuration.
 // We should also read runtime parameters and confirm that everything is according
 // to the spec.
-func (c *containers) Deploy() error {
+func (c *containers) Deploy(ctx context.Context) (err error) {
+	defer c.closeConnections(&err)
+
 	if c.currentState == nil {
 		return fmt.Errorf("can't execute without knowing current state of the containers")
 	}
@@ -511,7 +706,7 @@ func (c *containers) Deploy() error {
 	fmt.Println("Checking for stopped and missing containers")
 
 	for n, r := range c.currentState {
-		d, err := c.ensureCurrentContainer(n, *r)
+		d, err := c.ensureCurrentContainer(ctx, n, *r)
 
 		if d != nil {
 			c.currentState[n] = d
@@ -525,14 +720,26 @@ func (c *containers) Deploy() error {
 	fmt.Println("Configuring and creating new containers")
 
 	for i := range c.desiredState {
-		if err := c.ensureNewContainer(i); err != nil {
+		if err := c.ensureNewContainer(ctx, i); err != nil {
 			return fmt.Errorf("failed creating new container %s: %w", i, err)
 		}
 	}
 
 	fmt.Println("Updating existing containers")
 
-	return c.updateExistingContainers()
+	return c.updateExistingContainers(ctx)
+}
+
+// closeConnections closes all connections opened to the hosts. If closing fails and
+// given error is nil, it will be set to the closing error.
+func (c *containers) closeConnections(err *error) {
+	if c.connections == nil {
+		return
+	}
+
+	if cerr := c.connections.Close(); cerr != nil && *err == nil {
+		*err = fmt.Errorf("closing connections: %w", cerr)
+	}
 }
 
 // FromYaml allows to load containers configuration and state from YAML format.
@@ -576,19 +783,31 @@ func (c *containers) DesiredState() ContainersState {
 	for h := range d {
 		// If container already exist, append it's ID to desired state to reduce the diff.
 		id := ""
+		imageID := ""
+
+		var repoDigests []string
 
 		cs, ok := c.previousState[h]
 		if ok && cs.container.Status().ID != "" {
 			id = cs.container.Status().ID
 		}
 
+		// If image name has not changed, expect the container to run the image currently
+		// present on the host, so re-pushed images show up in the diff.
+		if ok && cs.container.Config().Image == d[h].Container.Config.Image {
+			imageID = util.PickString(cs.imageID, cs.container.Status().ImageID)
+			repoDigests = cs.container.Status().RepoDigests
+		}
+
 		// Make sure, that desired state has correct status. Container should always be running
 		// and optionally, we also set the ID of already existing container. If there are changes
 		// to the container, it will get new ID anyway, but user does not care about this change,
 		// so we can hide it this way from the diff.
 		d[h].Container.Status = &types.ContainerStatus{
-			Status: "running",
-			ID:     id,
+			Status:      "running",
+			ID:          id,
+			ImageID:     imageID,
+			RepoDigests: repoDigests,
 		}
 	}
 
@@ -599,3 +818,18 @@ func (c *containers) DesiredState() ContainersState {
 func (c *containers) Containers() ContainersInterface {
 	return c
 }
+
+// Connections returns connection pool shared by the containers.
+func (c *containers) Connections() *host.ConnectionPool {
+	return c.connections
+}
+
+// RuntimeInfo returns information about container runtime on the host of the desired container.
+func (c *containers) RuntimeInfo(ctx context.Context, name string) (types.RuntimeInfo, error) {
+	hcc, ok := c.desiredState[name]
+	if !ok {
+		return types.RuntimeInfo{}, fmt.Errorf("container %q not found in desired state", name)
+	}
+
+	return hcc.RuntimeInfo(ctx)
+}
@@ -1,7 +1,11 @@
 package container
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -13,6 +17,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 )
 
 const (
@@ -53,6 +58,74 @@ func GetContainers(t *testing.T) ContainersInterface {
 	return c
 }
 
+func TestContainersNewSharedConnections(t *testing.T) {
+	c := GetContainers(t).(*containers)
+
+	if c.connections == nil {
+		t.Fatalf("Containers should have connection pool initialized")
+	}
+
+	if c.desiredState[foo].connections != c.connections {
+		t.Fatalf("All containers should share the connection pool")
+	}
+}
+
+// Connections() tests.
+func TestContainersConnections(t *testing.T) {
+	c := GetContainers(t)
+
+	if c.Connections() == nil || c.Connections() != c.(*containers).desiredState[foo].connections {
+		t.Fatalf("Connections() should return connection pool shared by the containers")
+	}
+}
+
+// RuntimeInfo() tests.
+func TestContainersRuntimeInfo(t *testing.T) {
+	pool := host.NewConnectionPool()
+
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					DirectConfig: &direct.Config{},
+				},
+				container: &container{
+					base: base{
+						runtimeConfig: &runtime.FakeConfig{
+							Runtime: &runtime.Fake{
+								InfoF: func() (types.RuntimeInfo, error) {
+									return types.RuntimeInfo{
+										CgroupDriver: bar,
+									}, nil
+								},
+							},
+						},
+					},
+				},
+				connections: pool,
+			},
+		},
+		connections: pool,
+	}
+
+	i, err := c.RuntimeInfo(context.Background(), foo)
+	if err != nil {
+		t.Fatalf("Getting runtime info should succeed, got: %v", err)
+	}
+
+	if i.CgroupDriver != bar {
+		t.Fatalf("Expected cgroup driver %q, got %q", bar, i.CgroupDriver)
+	}
+}
+
+func TestContainersRuntimeInfoNotFound(t *testing.T) {
+	c := &containers{}
+
+	if _, err := c.RuntimeInfo(context.Background(), foo); err == nil {
+		t.Fatalf("Getting runtime info of not existing container should fail")
+	}
+}
+
 // Containers() tests.
 func TestContainersContainers(t *testing.T) {
 	c := &containers{}
@@ -66,7 +139,7 @@ func TestContainersContainers(t *testing.T) {
 func TestContainersCheckCurrentStateNew(t *testing.T) {
 	c := GetContainers(t)
 
-	if err := c.CheckCurrentState(); err != nil {
+	if err := c.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("Checking current state for new Containers should work, got: %v", err)
 	}
 }
@@ -286,6 +359,189 @@ func TestDiffHost(t *testing.T) {
 	}
 }
 
+func TestDiffHostIgnoreLearnedHostKey(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						TrustOnFirstUse: true,
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						TrustOnFirstUse: true,
+						LearnedHostKey:  "foo",
+					},
+				},
+			},
+		},
+	}
+
+	diff, err := c.diffHost(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff != "" {
+		t.Fatalf("Learned host key should not cause host diff, got: %s", diff)
+	}
+}
+
+func TestDiffHostIgnoreConnectionSettings(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						Address:         "foo",
+						PrivateKeyFile:  "/home/core/.ssh/id_ed25519",
+						CertificateFile: "/home/core/.ssh/id_ed25519-cert.pub",
+						HostKeys:        []string{"SHA256:foo"},
+						JumpHosts: []*ssh.Config{
+							{
+								Address:  "bastion",
+								Password: "bar",
+							},
+						},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						Address:               "foo",
+						PrivateKey:            "foo",
+						Certificate:           "bar",
+						OpenSSHConfigFile:     "/home/core/.ssh/config",
+						HostAlias:             "foo",
+						InsecureIgnoreHostKey: true,
+						JumpHosts: []*ssh.Config{
+							{
+								Address:         "bastion",
+								Password:        "foo",
+								TrustOnFirstUse: true,
+								KnownHostsFile:  "/home/core/.ssh/known_hosts",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	diff, err := c.diffHost(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff != "" {
+		t.Fatalf("Authentication and host key verification settings should not cause host diff, got: %s", diff)
+	}
+}
+
+// propagateLearnedHostKeys() tests.
+func TestPropagateLearnedHostKeys(t *testing.T) {
+	learned := &ssh.Config{
+		Address:         "foo",
+		Port:            ssh.Port,
+		TrustOnFirstUse: true,
+		LearnedHostKey:  "foo",
+	}
+
+	desired := &ssh.Config{
+		Address:         "foo",
+		Port:            ssh.Port,
+		TrustOnFirstUse: true,
+	}
+
+	other := &ssh.Config{
+		Address:         "bar",
+		Port:            ssh.Port,
+		TrustOnFirstUse: true,
+	}
+
+	c := &containers{
+		previousState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: learned,
+				},
+			},
+		},
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: desired,
+				},
+			},
+			"bar": &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: other,
+				},
+			},
+		},
+	}
+
+	c.propagateLearnedHostKeys()
+
+	if desired.LearnedHostKey != learned.LearnedHostKey {
+		t.Fatalf("Learned host key should be propagated to desired state, got: %q", desired.LearnedHostKey)
+	}
+
+	if other.LearnedHostKey != "" {
+		t.Fatalf("Learned host key should not be propagated to other hosts, got: %q", other.LearnedHostKey)
+	}
+}
+
+func TestPropagateLearnedHostKeysJumpHosts(t *testing.T) {
+	jumpHost := &ssh.Config{
+		Address:         "bastion",
+		Port:            ssh.Port,
+		TrustOnFirstUse: true,
+	}
+
+	c := &containers{
+		previousState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						JumpHosts: []*ssh.Config{
+							{
+								Address:         "bastion",
+								Port:            ssh.Port,
+								TrustOnFirstUse: true,
+								LearnedHostKey:  "foo",
+							},
+						},
+					},
+				},
+			},
+		},
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						JumpHosts: []*ssh.Config{jumpHost},
+					},
+				},
+			},
+		},
+	}
+
+	c.propagateLearnedHostKeys()
+
+	if jumpHost.LearnedHostKey != "foo" {
+		t.Fatalf("Learned host key should be propagated to jump host, got: %q", jumpHost.LearnedHostKey)
+	}
+}
+
 // diffContainer() tests.
 func TestDiffContainerNotUpdatable(t *testing.T) {
 	c := &containers{
@@ -365,6 +621,223 @@ func TestDiffContainer(t *testing.T) {
 	}
 }
 
+func TestDiffContainerImageChanged(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+						status: types.ContainerStatus{
+							ID:      foo,
+							ImageID: foo,
+						},
+					},
+				},
+				imageID: bar,
+			},
+		},
+	}
+
+	diff, err := c.diffContainer(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff == "" {
+		t.Fatalf("Container with changed image on the host should return diff")
+	}
+}
+
+func TestDiffContainerRegistryDigestChanged(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+						status: types.ContainerStatus{
+							ID:          foo,
+							RepoDigests: []string{"foo@sha256:foo"},
+						},
+					},
+				},
+				registryDigest: "sha256:bar",
+			},
+		},
+	}
+
+	diff, err := c.diffContainer(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff == "" {
+		t.Fatalf("Container with re-pushed image in the registry should return diff")
+	}
+}
+
+func TestDiffContainerRegistryDigestNotChanged(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+						status: types.ContainerStatus{
+							ID:          foo,
+							RepoDigests: []string{"foo@sha256:foo"},
+						},
+					},
+				},
+				registryDigest: "sha256:foo",
+			},
+		},
+	}
+
+	diff, err := c.diffContainer(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff != "" {
+		t.Fatalf("Container with unchanged image in the registry should not return diff, got: %s", diff)
+	}
+}
+
+// writeImageSource writes OCI image layout with single image with given ID to temporary
+// directory and returns path to it.
+func writeImageSource(t *testing.T, id string) string {
+	t.Helper()
+
+	d := t.TempDir()
+
+	files := map[string]string{
+		"oci-layout":        `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json":        `{"manifests":[{"digest":"sha256:1234"}]}`,
+		"blobs/sha256/1234": fmt.Sprintf(`{"config":{"digest":%q}}`, id),
+	}
+
+	for n, c := range files {
+		p := filepath.Join(d, filepath.FromSlash(n))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatalf("Failed creating directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(c), 0o600); err != nil {
+			t.Fatalf("Failed writing file: %v", err)
+		}
+	}
+
+	return d
+}
+
+func TestDiffContainerImageSourceChanged(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{
+							ImageSource: writeImageSource(t, bar),
+						},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{},
+						status: types.ContainerStatus{
+							ID:      foo,
+							ImageID: foo,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	diff, err := c.diffContainer(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff == "" {
+		t.Fatalf("Container with changed image in the image source should return diff")
+	}
+}
+
+func TestDiffContainerIgnoreImageSource(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{
+							ImageSource: writeImageSource(t, foo),
+						},
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{
+							ImageSource: bar,
+						},
+						status: types.ContainerStatus{
+							ID:      foo,
+							ImageID: foo,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	diff, err := c.diffContainer(foo)
+	if err != nil {
+		t.Fatalf("Updatable container should return diff, got: %v", err)
+	}
+
+	if diff != "" {
+		t.Fatalf("Changing only image source path should not return diff, got: %s", diff)
+	}
+}
+
 func TestDiffContainerRuntimeConfig(t *testing.T) {
 	c := &containers{
 		desiredState: containersState{
@@ -407,7 +880,7 @@ func TestEnsureRunningNonExistent(t *testing.T) {
 		currentState: containersState{},
 	}
 
-	if err := ensureRunning(c.currentState[bar]); err == nil {
+	if err := ensureRunning(context.Background(), c.currentState[bar]); err == nil {
 		t.Fatalf("Ensuring that non existing container is running should fail")
 	}
 }
@@ -438,7 +911,7 @@ func TestEnsureRunning(t *testing.T) {
 		},
 	}
 
-	if err := ensureRunning(c.currentState[foo]); err != nil {
+	if err := ensureRunning(context.Background(), c.currentState[foo]); err != nil {
 		t.Fatalf("Ensuring that running container is running should succeed, got: %v", err)
 	}
 }
@@ -460,7 +933,7 @@ func TestEnsureExistsAlreadyExists(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureExists(foo); err != nil {
+	if err := c.ensureExists(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that existing container exists should succeed, got: %v", err)
 	}
 }
@@ -489,7 +962,7 @@ func TestEnsureExistsFailCreate(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureExists(foo); err == nil {
+	if err := c.ensureExists(context.Background(), foo); err == nil {
 		t.Fatalf("Ensuring that new container exists should propagate create error")
 	}
 
@@ -534,7 +1007,7 @@ func TestEnsureExistsFailStart(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureExists(foo); err == nil {
+	if err := c.ensureExists(context.Background(), foo); err == nil {
 		t.Fatalf("Ensuring that new container exists should fail")
 	}
 
@@ -579,7 +1052,7 @@ func TestEnsureExist(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureExists(foo); err != nil {
+	if err := c.ensureExists(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that new container exists should succeed, got: %v", err)
 	}
 
@@ -607,11 +1080,44 @@ func TestEnsureHostNoDiff(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureHost(foo); err != nil {
+	if err := c.ensureHost(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that container's host configuration is up to date should succeed, got: %v", err)
 	}
 }
 
+func TestEnsureHostUpdateConnectionSettings(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						Address:  "foo",
+						Password: "bar",
+					},
+				},
+			},
+		},
+		currentState: containersState{
+			foo: &hostConfiguredContainer{
+				host: host.Host{
+					SSHConfig: &ssh.Config{
+						Address:  "foo",
+						Password: "foo",
+					},
+				},
+			},
+		},
+	}
+
+	if err := c.ensureHost(context.Background(), foo); err != nil {
+		t.Fatalf("Ensuring that container's host configuration is up to date should succeed, got: %v", err)
+	}
+
+	if p := c.currentState[foo].host.SSHConfig.Password; p != "bar" {
+		t.Fatalf("Current state should use desired connection settings, got password %q", p)
+	}
+}
+
 func TestEnsureHostFailStart(t *testing.T) { //nolint:funlen
 	c := &containers{
 		desiredState: containersState{
@@ -677,7 +1183,7 @@ func TestEnsureHostFailStart(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.ensureHost(foo); err == nil {
+	if err := c.ensureHost(context.Background(), foo); err == nil {
 		t.Fatalf("Ensuring that container's host configuration is up to date should fail")
 	}
 
@@ -751,7 +1257,7 @@ func TestEnsureHost(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.ensureHost(foo); err != nil {
+	if err := c.ensureHost(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that container's host configuration is up to date should succeed, got: %v", err)
 	}
 
@@ -783,7 +1289,7 @@ func TestEnsureContainerNoDiff(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureContainer(foo); err != nil {
+	if err := c.ensureContainer(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that container configuration is up to date should succeed, got: %v", err)
 	}
 }
@@ -857,7 +1363,7 @@ func TestEnsureContainerFailStart(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.ensureContainer(foo); err == nil {
+	if err := c.ensureContainer(context.Background(), foo); err == nil {
 		t.Fatalf("Ensuring that container configuration is up to date should fail")
 	}
 
@@ -935,7 +1441,7 @@ func TestEnsureContainer(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.ensureContainer(foo); err != nil {
+	if err := c.ensureContainer(context.Background(), foo); err != nil {
 		t.Fatalf("Ensuring that container configuration is up to date should succeed, got: %v", err)
 	}
 
@@ -947,7 +1453,7 @@ func TestEnsureContainer(t *testing.T) { //nolint:funlen
 // recreate() tests.
 func TestRecreateNonExistent(t *testing.T) {
 	c := &containers{}
-	if err := c.recreate(foo); err == nil {
+	if err := c.recreate(context.Background(), foo); err == nil {
 		t.Fatalf("Recreating on empty containers should fail")
 	}
 }
@@ -955,7 +1461,7 @@ func TestRecreateNonExistent(t *testing.T) {
 // Deploy() tests.
 func TestDeployNoCurrentState(t *testing.T) {
 	c := &containers{}
-	if err := c.Deploy(); err == nil {
+	if err := c.Deploy(context.Background()); err == nil {
 		t.Fatalf("Execute without current state should fail")
 	}
 }
@@ -1109,7 +1615,7 @@ func TestEnsureConfiguredDisposable(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureConfigured(foo); err != nil {
+	if err := c.ensureConfigured(context.Background(), foo); err != nil {
 		t.Fatalf("Ensure configured should succeed when container is going to be removed, got: %v", err)
 	}
 }
@@ -1128,7 +1634,7 @@ func TestEnsureConfiguredNoUpdates(t *testing.T) {
 		},
 	}
 
-	if err := c.ensureConfigured(foo); err != nil {
+	if err := c.ensureConfigured(context.Background(), foo); err != nil {
 		t.Fatalf("Ensure configured should succeed, got: %v", err)
 	}
 }
@@ -1211,7 +1717,7 @@ func TestEnsureConfigured(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.ensureConfigured(f); err != nil {
+	if err := c.ensureConfigured(context.Background(), f); err != nil {
 		t.Fatalf("Ensure configured should succeed, got: %v", err)
 	}
 
@@ -1274,7 +1780,7 @@ func TestEnsureConfiguredFreshState(t *testing.T) {
 		currentState: containersState{},
 	}
 
-	if err := c.ensureConfigured(f); err != nil {
+	if err := c.ensureConfigured(context.Background(), f); err != nil {
 		t.Fatalf("Ensure configured should succeed, got: %v", err)
 	}
 
@@ -1322,7 +1828,7 @@ func TestEnsureConfiguredNoStateUpdateOnFail(t *testing.T) {
 		currentState: containersState{},
 	}
 
-	if err := c.ensureConfigured(f); err == nil {
+	if err := c.ensureConfigured(context.Background(), f); err == nil {
 		t.Fatalf("Ensure configured should fail")
 	}
 
@@ -1526,7 +2032,7 @@ func TestUpdateExistingContainersRemoveAllOld(t *testing.T) { //nolint:funlen
 		},
 	}
 
-	if err := c.updateExistingContainers(); err != nil {
+	if err := c.updateExistingContainers(context.Background()); err != nil {
 		t.Fatalf("Updating existing containers should succeed, got: %v", err)
 	}
 
@@ -1564,7 +2070,7 @@ func TestEnsureCurrentContainer(t *testing.T) {
 		},
 	}
 
-	_, err := c.ensureCurrentContainer(foo, hcc)
+	_, err := c.ensureCurrentContainer(context.Background(), foo, hcc)
 	if err == nil {
 		t.Fatalf("ensure stopped container should try to start the container and fail")
 	}
@@ -1602,7 +2108,7 @@ func TestEnsureCurrentContainerNonExisting(t *testing.T) {
 		},
 	}
 
-	if _, err := c.ensureCurrentContainer(foo, hcc); err != nil {
+	if _, err := c.ensureCurrentContainer(context.Background(), foo, hcc); err != nil {
 		t.Fatalf("ensure stopped container should not fail on non-existing container, got: %v", err)
 	}
 
@@ -1610,3 +2116,55 @@ func TestEnsureCurrentContainerNonExisting(t *testing.T) {
 		t.Fatalf("ensuring removed container should remove it from current state to trigger creation")
 	}
 }
+
+func TestContainersDesiredStateImageID(t *testing.T) {
+	c := &containers{
+		desiredState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{
+							Image: "a",
+						},
+						runtimeConfig: docker.DefaultConfig(),
+					},
+				},
+			},
+		},
+		previousState: containersState{
+			foo: &hostConfiguredContainer{
+				container: &container{
+					base: base{
+						config: types.ContainerConfig{
+							Image: "a",
+						},
+						runtimeConfig: docker.DefaultConfig(),
+						status: types.ContainerStatus{
+							Status:  "running",
+							ID:      foo,
+							ImageID: foo,
+						},
+					},
+				},
+				imageID: bar,
+			},
+		},
+	}
+
+	e := ContainersState{
+		foo: {
+			Container: Container{
+				Config: types.ContainerConfig{Image: "a"},
+				Status: &types.ContainerStatus{ID: foo, Status: "running", ImageID: bar},
+				Runtime: RuntimeConfig{
+					Docker: docker.DefaultConfig(),
+				},
+			},
+			ConfigFiles: map[string]string{},
+		},
+	}
+
+	if diff := cmp.Diff(e, c.DesiredState()); diff != "" {
+		t.Fatalf("Unexpected diff: %s", diff)
+	}
+}
@@ -1,6 +1,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
@@ -17,13 +18,13 @@ const (
 type ContainersStateInterface interface {
 	// CheckState updates the state of all previously configured containers
 	// and their configuration on the host
-	CheckState() error
+	CheckState(ctx context.Context) error
 
 	// RemoveContainer removes the container by ID.
-	RemoveContainer(containerName string) error
+	RemoveContainer(ctx context.Context, containerName string) error
 
 	// CreateAndStart is a helper, which creates and spawns given container.
-	CreateAndStart(containerName string) error
+	CreateAndStart(ctx context.Context, containerName string) error
 
 	// Export converts unexported containersState to exported type, so it can be serialized and stored.
 	Export() ContainersState
@@ -57,9 +58,9 @@ func (s ContainersState) New() (ContainersStateInterface, error) {
 
 // CheckState updates the state of all previously configured containers
 // and their configuration on the host.
-func (s containersState) CheckState() error {
+func (s containersState) CheckState(ctx context.Context) error {
 	for i, hcc := range s {
-		if err := hcc.Status(); err != nil {
+		if err := hcc.Status(ctx); err != nil {
 			hcc.container.SetStatus(types.ContainerStatus{
 				Status: err.Error(),
 			})
@@ -73,7 +74,7 @@ func (s containersState) CheckState() error {
 			})
 		}
 
-		if err := hcc.ConfigurationStatus(); err != nil {
+		if err := hcc.ConfigurationStatus(ctx); err != nil {
 			return fmt.Errorf("checking container %q configuration status: %w", i, err)
 		}
 	}
@@ -82,7 +83,7 @@ func (s containersState) CheckState() error {
 }
 
 // RemoveContainer removes the container by ID.
-func (s containersState) RemoveContainer(containerName string) error {
+func (s containersState) RemoveContainer(ctx context.Context, containerName string) error {
 	if _, exists := s[containerName]; !exists {
 		return fmt.Errorf("can't remove non-existing container")
 	}
@@ -90,13 +91,13 @@ func (s containersState) RemoveContainer(containerName string) error {
 	status := s[containerName].container.Status()
 
 	if status.Exists() && (status.Running() || status.Restarting()) {
-		if err := s[containerName].Stop(); err != nil {
+		if err := s[containerName].Stop(ctx); err != nil {
 			return fmt.Errorf("stopping container before removing: %w", err)
 		}
 	}
 
 	if status.Exists() {
-		if err := s[containerName].Delete(); err != nil {
+		if err := s[containerName].Delete(ctx); err != nil {
 			return fmt.Errorf("removing container: %w", err)
 		}
 	}
@@ -107,16 +108,16 @@ func (s containersState) RemoveContainer(containerName string) error {
 }
 
 // CreateAndStart is a helper, which creates and spawns given container.
-func (s containersState) CreateAndStart(containerName string) error {
+func (s containersState) CreateAndStart(ctx context.Context, containerName string) error {
 	if _, exists := s[containerName]; !exists {
 		return fmt.Errorf("can't create non-existing container")
 	}
 
-	if err := s[containerName].Create(); err != nil {
+	if err := s[containerName].Create(ctx); err != nil {
 		return fmt.Errorf("failed creating new container: %w", err)
 	}
 
-	if err := s[containerName].Start(); err != nil {
+	if err := s[containerName].Start(ctx); err != nil {
 		return fmt.Errorf("failed starting container: %w", err)
 	}
 
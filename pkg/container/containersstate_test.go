@@ -1,6 +1,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -71,7 +72,7 @@ func TestContainersStateCheckStateFailStatus(t *testing.T) {
 		},
 	}
 
-	if err := c.CheckState(); err != nil {
+	if err := c.CheckState(context.Background()); err != nil {
 		t.Fatalf("Should not fail with failing status")
 	}
 
@@ -113,7 +114,7 @@ func TestContainersStateCheckStateGone(t *testing.T) {
 		},
 	}
 
-	if err := c.CheckState(); err != nil {
+	if err := c.CheckState(context.Background()); err != nil {
 		t.Fatalf("Checking state should succeed, got: %v", err)
 	}
 
@@ -157,7 +158,7 @@ func TestRemoveContainerDontStopStopped(t *testing.T) { //nolint:dupl
 		},
 	}
 
-	if err := c.RemoveContainer("foo"); err != nil {
+	if err := c.RemoveContainer(context.Background(), "foo"); err != nil {
 		t.Fatalf("removing stopped container shouldn't try to stop it again")
 	}
 }
@@ -190,7 +191,7 @@ func TestRemoveContainerDontRemoveMissing(t *testing.T) {
 		},
 	}
 
-	if err := c.RemoveContainer("foo"); err != nil {
+	if err := c.RemoveContainer(context.Background(), "foo"); err != nil {
 		t.Fatalf("removing missing container shouldn't try to remove it again, got: %v", err)
 	}
 }
@@ -229,7 +230,7 @@ func TestRemoveContainerPropagateStopError(t *testing.T) { //nolint:dupl
 		},
 	}
 
-	if err := c.RemoveContainer("foo"); err == nil {
+	if err := c.RemoveContainer(context.Background(), "foo"); err == nil {
 		t.Fatalf("removing stopped container should propagate stop error")
 	}
 }
@@ -268,7 +269,7 @@ func TestRemoveContainerPropagateDeleteError(t *testing.T) {
 		},
 	}
 
-	if err := c.RemoveContainer("foo"); err == nil {
+	if err := c.RemoveContainer(context.Background(), "foo"); err == nil {
 		t.Fatalf("removing stopped container should propagate delete error")
 	}
 }
@@ -277,7 +278,7 @@ func TestRemoveContainerPropagateDeleteError(t *testing.T) {
 func TestCreateAndStartFailOnMissingContainer(t *testing.T) {
 	c := containersState{}
 
-	if err := c.CreateAndStart("foo"); err == nil {
+	if err := c.CreateAndStart(context.Background(), "foo"); err == nil {
 		t.Fatalf("creating and starting non existing container should give error")
 	}
 }
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"fmt"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+// InstanceConfig holds configuration common for instances of pool-type resources, like etcd
+// members or kubelets, which can be defaulted on the pool level.
+type InstanceConfig struct {
+	// Host describes on which machine the instance container should be created.
+	Host host.Host
+
+	// Runtime stores configuration of container runtime used by the instance.
+	Runtime *RuntimeConfig
+
+	// Labels are resource specific labels, e.g. kubelet node labels.
+	Labels map[string]string
+
+	// ExtraMounts defines extra mounts from host filesystem, which should be added to
+	// the instance container.
+	ExtraMounts []types.Mount
+}
+
+// PoolDefaults returns default instance configuration from given pool-level configuration.
+//
+// Pool-level SSH configuration is merged into host SSH configuration, so pools, which only
+// have SSH field, keep working the same way.
+func PoolDefaults(h *host.Host, sshConfig *ssh.Config, r *RuntimeConfig) InstanceConfig {
+	d := InstanceConfig{
+		Runtime: r,
+	}
+
+	if h != nil {
+		d.Host = *h
+	}
+
+	if d.Host.SSHConfig == nil && d.Host.DirectConfig == nil && d.Host.KubernetesConfig == nil {
+		d.Host.SSHConfig = sshConfig
+	}
+
+	return d
+}
+
+// ValidatePoolDefaults validates pool-level defaults.
+func ValidatePoolDefaults(h *host.Host, sshConfig *ssh.Config) error {
+	if h != nil && sshConfig != nil {
+		return fmt.Errorf("host and ssh fields are mutually exclusive")
+	}
+
+	return nil
+}
+
+// BuildInstanceConfig merges given instance configuration with given default values. This
+// is a helper method used for building hierarchical configuration. Values set on the instance
+// take precedence.
+func BuildInstanceConfig(config, defaults InstanceConfig) (InstanceConfig, error) {
+	h, err := host.BuildConfig(config.Host, defaults.Host)
+	if err != nil {
+		return InstanceConfig{}, fmt.Errorf("building host configuration: %w", err)
+	}
+
+	config.Host = h
+	config.Runtime = BuildRuntimeConfig(config.Runtime, defaults.Runtime)
+	config.Labels = util.PickStringMap(config.Labels, defaults.Labels)
+
+	if len(config.ExtraMounts) == 0 {
+		config.ExtraMounts = defaults.ExtraMounts
+	}
+
+	return config, nil
+}
+
+// BuildRuntimeConfig merges given runtime configuration with given default values and returns
+// new configuration. If both are empty, Docker with default configuration is used.
+func BuildRuntimeConfig(config, defaults *RuntimeConfig) *RuntimeConfig {
+	if config == nil {
+		config = &RuntimeConfig{}
+	}
+
+	if defaults == nil {
+		defaults = &RuntimeConfig{}
+	}
+
+	return &RuntimeConfig{
+		Docker: docker.BuildConfig(config.Docker, defaults.Docker),
+	}
+}
@@ -0,0 +1,228 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
+	"github.com/flexkube/libflexkube/pkg/container/types"
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/kubernetes"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+// PoolDefaults() tests.
+func TestPoolDefaultsSSH(t *testing.T) {
+	s := &ssh.Config{
+		User: "foo",
+	}
+
+	d := PoolDefaults(nil, s, nil)
+
+	if d.Host.SSHConfig != s {
+		t.Fatalf("Pool SSH configuration should be used as default host SSH configuration")
+	}
+}
+
+func TestPoolDefaultsHost(t *testing.T) {
+	h := &host.Host{
+		DirectConfig: &direct.Config{},
+	}
+
+	d := PoolDefaults(h, nil, nil)
+
+	if d.Host.DirectConfig == nil || d.Host.SSHConfig != nil {
+		t.Fatalf("Pool host configuration should be used as default host configuration, got: %+v", d.Host)
+	}
+}
+
+// ValidatePoolDefaults() tests.
+func TestValidatePoolDefaultsHostAndSSH(t *testing.T) {
+	if err := ValidatePoolDefaults(&host.Host{}, &ssh.Config{}); err == nil {
+		t.Fatalf("Setting both host and SSH configuration should fail")
+	}
+}
+
+func TestValidatePoolDefaults(t *testing.T) {
+	if err := ValidatePoolDefaults(nil, &ssh.Config{}); err != nil {
+		t.Fatalf("Setting only SSH configuration should succeed, got: %v", err)
+	}
+}
+
+// BuildInstanceConfig() tests.
+func TestBuildInstanceConfigDefaults(t *testing.T) {
+	d := InstanceConfig{
+		Host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		Runtime: &RuntimeConfig{
+			Docker: &docker.Config{
+				Host: "tcp://foo:2376",
+			},
+		},
+		Labels: map[string]string{
+			"foo": "bar",
+		},
+		ExtraMounts: []types.Mount{
+			{
+				Source: "/foo",
+				Target: "/foo",
+			},
+		},
+	}
+
+	expected := InstanceConfig{
+		Host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		Runtime: &RuntimeConfig{
+			Docker: &docker.Config{
+				Host: "tcp://foo:2376",
+			},
+		},
+		Labels:      d.Labels,
+		ExtraMounts: d.ExtraMounts,
+	}
+
+	ic, err := BuildInstanceConfig(InstanceConfig{}, d)
+	if err != nil {
+		t.Fatalf("Building instance configuration should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, ic); diff != "" {
+		t.Fatalf("Unexpected instance configuration: %s", diff)
+	}
+}
+
+func TestBuildInstanceConfigOverride(t *testing.T) {
+	d := InstanceConfig{
+		Runtime: &RuntimeConfig{
+			Docker: &docker.Config{
+				Host:       "tcp://foo:2376",
+				APIVersion: "v1.40",
+			},
+		},
+		Labels: map[string]string{
+			"foo": "bar",
+		},
+		ExtraMounts: []types.Mount{
+			{
+				Source: "/foo",
+				Target: "/foo",
+			},
+		},
+	}
+
+	c := InstanceConfig{
+		Runtime: &RuntimeConfig{
+			Docker: &docker.Config{
+				Host: "tcp://bar:2376",
+			},
+		},
+		Labels: map[string]string{
+			"bar": "baz",
+		},
+		ExtraMounts: []types.Mount{
+			{
+				Source: "/bar",
+				Target: "/bar",
+			},
+		},
+	}
+
+	expected := InstanceConfig{
+		Host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		Runtime: &RuntimeConfig{
+			Docker: &docker.Config{
+				Host:       "tcp://bar:2376",
+				APIVersion: "v1.40",
+			},
+		},
+		Labels:      c.Labels,
+		ExtraMounts: c.ExtraMounts,
+	}
+
+	ic, err := BuildInstanceConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building instance configuration should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, ic); diff != "" {
+		t.Fatalf("Unexpected instance configuration: %s", diff)
+	}
+}
+
+func TestBuildInstanceConfigKubernetesDefaults(t *testing.T) {
+	d := PoolDefaults(&host.Host{
+		KubernetesConfig: &kubernetes.Config{
+			Kubeconfig: "foo",
+			Namespace:  "bar",
+		},
+	}, nil, nil)
+
+	c := InstanceConfig{
+		Host: host.Host{
+			KubernetesConfig: &kubernetes.Config{
+				NodeName: "baz",
+			},
+		},
+	}
+
+	expected := host.Host{
+		KubernetesConfig: &kubernetes.Config{
+			Kubeconfig: "foo",
+			NodeName:   "baz",
+			Namespace:  "bar",
+		},
+	}
+
+	ic, err := BuildInstanceConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building instance configuration should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(expected, ic.Host); diff != "" {
+		t.Fatalf("Unexpected host configuration: %s", diff)
+	}
+
+	ic, err = BuildInstanceConfig(InstanceConfig{}, d)
+	if err != nil {
+		t.Fatalf("Building instance configuration should succeed, got: %v", err)
+	}
+
+	expected.KubernetesConfig.NodeName = ""
+
+	if diff := cmp.Diff(expected, ic.Host); diff != "" {
+		t.Fatalf("Instance without host configuration should use kubernetes defaults: %s", diff)
+	}
+}
+
+func TestBuildInstanceConfigDirectDefaults(t *testing.T) {
+	d := PoolDefaults(&host.Host{
+		DirectConfig: &direct.Config{},
+	}, nil, nil)
+
+	ic, err := BuildInstanceConfig(InstanceConfig{}, d)
+	if err != nil {
+		t.Fatalf("Building instance configuration should succeed, got: %v", err)
+	}
+
+	expected := host.Host{
+		DirectConfig: &direct.Config{},
+	}
+
+	if diff := cmp.Diff(expected, ic.Host); diff != "" {
+		t.Fatalf("Instance without host configuration should use direct defaults: %s", diff)
+	}
+}
+
+// BuildRuntimeConfig() tests.
+func TestBuildRuntimeConfigEmpty(t *testing.T) {
+	if diff := cmp.Diff(&RuntimeConfig{Docker: docker.DefaultConfig()}, BuildRuntimeConfig(nil, nil)); diff != "" {
+		t.Fatalf("Empty runtime configuration should default to Docker: %s", diff)
+	}
+}
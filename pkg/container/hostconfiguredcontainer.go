@@ -1,12 +1,17 @@
 package container
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
 
 // ResourceInstance interface represents struct, which can be converted to HostConfiguredContainer.
@@ -17,7 +22,7 @@ type ResourceInstance interface {
 // HostConfiguredContainerInterface defines capabilities of validated HostConfiguredContainer.
 type HostConfiguredContainerInterface interface {
 	// ConfigurationStatus updates configuration file struct with current state on the target host.
-	ConfigurationStatus() error
+	ConfigurationStatus(ctx context.Context) error
 
 	// Configure copies specified configuration files on target host.
 	//
@@ -29,24 +34,27 @@ type HostConfiguredContainerInterface interface {
 	// multiple images, which will save disk space and time. If it happens that this image does not have 'tar' binary,
 	// user can override ConfigImage field in the configuration, to specify different image which should be
 	// pulled and used for configuration management.
-	Configure(paths []string) error
+	Configure(ctx context.Context, paths []string) error
 
 	// Create creates new container on target host. If container already exists,
 	// error should be returned.
-	Create() error
+	Create(ctx context.Context) error
 
 	// Status updates container status.
-	Status() error
+	Status(ctx context.Context) error
 
 	// Start starts created container. Container must be created before it's started.
-	Start() error
+	Start(ctx context.Context) error
 
 	// Stop stops the container.
-	Stop() error
+	Stop(ctx context.Context) error
 
 	// Delete removes the container from the host. Host volumes and configuration files
 	// won't be removed.
-	Delete() error
+	Delete(ctx context.Context) error
+
+	// RuntimeInfo returns information about container runtime on the host.
+	RuntimeInfo(ctx context.Context) (types.RuntimeInfo, error)
 }
 
 const (
@@ -56,8 +64,15 @@ const (
 	// configFileMode is default configuration file permissions.
 	configFileMode = 0o600
 
+	// tcpScheme is a prefix of runtime addresses, which should be forwarded using TCP.
+	tcpScheme = "tcp://"
+
 	// mountpointDirMode is default host mountpoint directory permission.
 	mountpointDirMode = 0o700
+
+	// cleanupTimeout is a time given for removing configuration container, which is
+	// done even if the operation has been cancelled.
+	cleanupTimeout = 1 * time.Minute
 )
 
 // Hooks defines type of hooks HostConfiguredContainer supports.
@@ -67,7 +82,10 @@ type Hooks struct {
 }
 
 // Hook is an action, which may be called before or after certain container operation, like starting or creating.
-type Hook func() error
+//
+// Given executor allows running commands on the host, where the container runs, using the same connection as
+// the container operation.
+type Hook func(ctx context.Context, e transport.Executor) error
 
 // HostConfiguredContainer represents single container, running on remote host with it's configuration files.
 type HostConfiguredContainer struct {
@@ -95,6 +113,19 @@ type hostConfiguredContainer struct {
 	configFiles     map[string]string
 	configContainer InstanceInterface
 	hooks           *Hooks
+
+	// imageID stores ID of the image with configured name, which is currently
+	// present on the host. It is filled when checking the container status.
+	imageID string
+
+	// registryDigest stores digest of the image with configured name, which is currently
+	// stored in the registry. It is filled when checking the container status, only
+	// when 'Always' image pull policy is used.
+	registryDigest string
+
+	// connections, if set, is used to reuse connections to the host between operations.
+	// If nil, new connection is opened and closed for every operation.
+	connections *host.ConnectionPool
 }
 
 // New validates HostConfiguredContainer struct and return the interface implementation, which
@@ -133,38 +164,67 @@ func (m *HostConfiguredContainer) Validate() error {
 	return nil
 }
 
-// connectAndForward instantiates new host object, connects to it and then
-// forwards given UNIX socket using this connection.
+// connectAndForward connects to the host using given connection pool and then
+// forwards given UNIX socket or TCP address using this connection.
 //
-// It returns address of local UNIX socket, where user can connect.
-func (m *hostConfiguredContainer) connectAndForward(a string) (string, error) {
-	h, err := m.host.New()
+// It returns used connection and local address, where user can connect. Forwarding is stopped
+// when the pool is closed, so cleanup operations can still reach the runtime after the context
+// is cancelled.
+func (m *hostConfiguredContainer) connectAndForward(ctx context.Context, p *host.ConnectionPool, a string) (transport.Connected, string, error) {
+	hc, err := p.Connect(ctx, m.host)
 	if err != nil {
-		return "", fmt.Errorf("initializing host: %w", err)
+		return nil, "", fmt.Errorf("connecting: %w", err)
 	}
 
-	hc, err := h.Connect()
-	if err != nil {
-		return "", fmt.Errorf("connecting: %w", err)
+	// Runtimes listening on TCP, e.g. Docker with TLS, must be forwarded using TCP.
+	if strings.HasPrefix(a, tcpScheme) {
+		s, err := hc.ForwardTCP(ctx, strings.TrimPrefix(a, tcpScheme))
+		if err != nil {
+			return nil, "", fmt.Errorf("forwarding TCP address: %w", err)
+		}
+
+		return hc, tcpScheme + s, nil
 	}
 
-	s, err := hc.ForwardUnixSocket(a)
+	s, err := hc.ForwardUnixSocket(ctx, a)
 	if err != nil {
-		return "", fmt.Errorf("forwarding unix socket: %w", err)
+		return nil, "", fmt.Errorf("forwarding unix socket: %w", err)
 	}
 
-	return s, nil
+	return hc, s, nil
 }
 
 // withForwardedRuntime takes action function as an argument and before executing it, it configures the runtime
 // address to be forwarded using SSH. After the action is finished, it restores original address of the runtime.
-func (m *hostConfiguredContainer) withForwardedRuntime(action func() error) error {
+//
+// If container has no connection pool assigned, connection is closed after the action is finished.
+func (m *hostConfiguredContainer) withForwardedRuntime(ctx context.Context, action func() error) error {
+	return m.withForwardedRuntimeConnection(ctx, func(transport.Connected) error {
+		return action()
+	})
+}
+
+// withForwardedRuntimeConnection is like withForwardedRuntime, but it also passes connection to the host
+// to the action function.
+func (m *hostConfiguredContainer) withForwardedRuntimeConnection(ctx context.Context, action func(transport.Connected) error) (err error) {
+	p := m.connections
+
+	if p == nil {
+		p = host.NewConnectionPool()
+
+		defer func() {
+			if cerr := p.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("closing connection: %w", cerr)
+			}
+		}()
+	}
+
 	c := m.container.RuntimeConfig()
 
 	// Store originally configured address so we can restore it later.
 	a := c.GetAddress()
 
-	s, err := m.connectAndForward(a)
+	hc, s, err := m.connectAndForward(ctx, p, a)
 	if err != nil {
 		return fmt.Errorf("forwarding host failed: %w", err)
 	}
@@ -191,17 +251,36 @@ func (m *hostConfiguredContainer) withForwardedRuntime(action func() error) erro
 	// After we're done calling action, restore original runtime to the container.
 	defer m.container.SetRuntime(ro)
 
-	return action()
+	// Errors from forwarding usually explain, why the action failed, e.g. when
+	// connection to the host has been lost.
+	if err := action(hc); err != nil {
+		if ferr := transport.ForwardingErrors(hc); ferr != nil {
+			return fmt.Errorf("%w, forwarding errors: %v", err, ferr)
+		}
+
+		return err
+	}
+
+	return nil
 }
 
 // createConfigurationContainer creates container used for reading and updating configuration and
 // stores saves it reference.
-func (m *hostConfiguredContainer) createConfigurationContainer() error {
+func (m *hostConfiguredContainer) createConfigurationContainer(ctx context.Context) error {
+	// Configuration container is also created when checking the current state, so never pull
+	// the image here with 'Always' policy, as this is done when creating the actual container.
+	pullPolicy := ""
+	if m.container.Config().ImagePullPolicy == types.PullNever {
+		pullPolicy = types.PullNever
+	}
+
 	cc := &container{
 		base: base{
 			config: types.ContainerConfig{
-				Name:  fmt.Sprintf("%s-config", m.container.Config().Name),
-				Image: m.container.Config().Image,
+				Name:            fmt.Sprintf("%s-config", m.container.Config().Name),
+				Image:           m.container.Config().Image,
+				ImagePullPolicy: pullPolicy,
+				ImageSource:     m.container.Config().ImageSource,
 				Mounts: []types.Mount{
 					{
 						Source: "/",
@@ -215,7 +294,7 @@ func (m *hostConfiguredContainer) createConfigurationContainer() error {
 
 	// Docker container does not need to run (be started) to be able to copy files from it.
 	// TODO: This might not be the case for other container runtimes.
-	ci, err := cc.Create()
+	ci, err := cc.Create(ctx)
 	if err != nil {
 		return fmt.Errorf("failed creating config container while checking configuration: %w", err)
 	}
@@ -227,8 +306,8 @@ func (m *hostConfiguredContainer) createConfigurationContainer() error {
 
 // removeConfigurationContainer removes configuration container created with createConfigurationContainer.
 // If container does not exist, nil is immediately returned, which makes this function idempotent.
-func (m *hostConfiguredContainer) removeConfigurationContainer() error {
-	s, err := m.configContainer.Status()
+func (m *hostConfiguredContainer) removeConfigurationContainer(ctx context.Context) error {
+	s, err := m.configContainer.Status(ctx)
 	if err != nil {
 		return fmt.Errorf("failed checking if container exists: %w", err)
 	}
@@ -237,12 +316,12 @@ func (m *hostConfiguredContainer) removeConfigurationContainer() error {
 		return nil
 	}
 
-	return m.configContainer.Delete()
+	return m.configContainer.Delete(ctx)
 }
 
 // updateConfigurationStatus overrides configFiles field with current content of configuration files.
 // If configuration file is missing, the entry is removed from the map.
-func (m *hostConfiguredContainer) updateConfigurationStatus() error {
+func (m *hostConfiguredContainer) updateConfigurationStatus(ctx context.Context) error {
 	// If there is no config files configured, don't do anything.
 	if len(m.configFiles) == 0 {
 		return nil
@@ -261,7 +340,7 @@ func (m *hostConfiguredContainer) updateConfigurationStatus() error {
 		paths[cpath] = p
 	}
 
-	f, err := m.configContainer.Read(files)
+	f, err := m.configContainer.Read(ctx, files)
 	if err != nil {
 		return fmt.Errorf("failed to read configuration status: %w", err)
 	}
@@ -280,14 +359,18 @@ func (m *hostConfiguredContainer) updateConfigurationStatus() error {
 // desired action and makes sure it's removed after the action is finished.
 //
 // If error occurs in the desired action, this error is returned and configuration container is opportunistically
-// removed. If that operation fails as well, error is only logged.
-func (m *hostConfiguredContainer) withConfigurationContainer(action func() error) error {
-	if err := m.createConfigurationContainer(); err != nil {
+// removed. If that operation fails as well, error is only logged. The removal is also attempted when
+// given context gets cancelled, so configuration containers are not left behind on the host.
+func (m *hostConfiguredContainer) withConfigurationContainer(ctx context.Context, action func() error) error {
+	if err := m.createConfigurationContainer(ctx); err != nil {
 		return fmt.Errorf("failed to create container for managing configuration: %w", err)
 	}
 
 	defer func() {
-		if err := m.removeConfigurationContainer(); err != nil {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+
+		if err := m.removeConfigurationContainer(cleanupCtx); err != nil {
 			fmt.Printf("Removing configuration container failed: %v", err)
 		}
 	}()
@@ -296,13 +379,15 @@ func (m *hostConfiguredContainer) withConfigurationContainer(action func() error
 		return fmt.Errorf("running action: %w", err)
 	}
 
-	return m.removeConfigurationContainer()
+	return m.removeConfigurationContainer(ctx)
 }
 
 // ConfigurationStatus updates configuration file struct with current state on the target host.
-func (m *hostConfiguredContainer) ConfigurationStatus() error {
-	return m.withForwardedRuntime(func() error {
-		return m.withConfigurationContainer(m.updateConfigurationStatus)
+func (m *hostConfiguredContainer) ConfigurationStatus(ctx context.Context) error {
+	return m.withForwardedRuntime(ctx, func() error {
+		return m.withConfigurationContainer(ctx, func() error {
+			return m.updateConfigurationStatus(ctx)
+		})
 	})
 }
 
@@ -316,21 +401,21 @@ func (m *hostConfiguredContainer) ConfigurationStatus() error {
 // multiple images, which will save disk space and time. If it happens that this image does not have 'tar' binary,
 // user can override ConfigImage field in the configuration, to specify different image which should be
 // pulled and used for configuration management.
-func (m *hostConfiguredContainer) Configure(paths []string) error {
+func (m *hostConfiguredContainer) Configure(ctx context.Context, paths []string) error {
 	if len(paths) == 0 {
 		return nil
 	}
 
-	return m.withForwardedRuntime(func() error {
-		return m.withConfigurationContainer(func() error {
-			return m.copyConfigFiles(paths)
+	return m.withForwardedRuntime(ctx, func() error {
+		return m.withConfigurationContainer(ctx, func() error {
+			return m.copyConfigFiles(ctx, paths)
 		})
 	})
 }
 
 // copyConfigFiles takes list of configuration files which should be created in the container
 // and creates them in batch. This function requires functional config container.
-func (m *hostConfiguredContainer) copyConfigFiles(paths []string) error {
+func (m *hostConfiguredContainer) copyConfigFiles(ctx context.Context, paths []string) error {
 	files := []*types.File{}
 
 	for _, p := range paths {
@@ -348,7 +433,7 @@ func (m *hostConfiguredContainer) copyConfigFiles(paths []string) error {
 		})
 	}
 
-	if err := m.configContainer.Copy(files); err != nil {
+	if err := m.configContainer.Copy(ctx, files); err != nil {
 		return fmt.Errorf("copying configuration files: %w", err)
 	}
 
@@ -356,7 +441,7 @@ func (m *hostConfiguredContainer) copyConfigFiles(paths []string) error {
 }
 
 // statMounts fetches information about mounts on the host.
-func (m *hostConfiguredContainer) statMounts() (map[string]os.FileMode, error) {
+func (m *hostConfiguredContainer) statMounts(ctx context.Context) (map[string]os.FileMode, error) {
 	paths := []string{}
 
 	// Loop over mount points.
@@ -369,7 +454,7 @@ func (m *hostConfiguredContainer) statMounts() (map[string]os.FileMode, error) {
 		return map[string]os.FileMode{}, nil
 	}
 
-	return m.configContainer.Stat(paths)
+	return m.configContainer.Stat(ctx, paths)
 }
 
 // isDirMount checks if given path is intended to be a directory by checking for a
@@ -390,9 +475,9 @@ func (m *hostConfiguredContainer) dirMounts() []types.Mount {
 //
 // Requested mount source must have trailing slash ('/') in the name to be created as a directory.
 // If requested directory mount is found on host file system as a file, the error is returned.
-func (m *hostConfiguredContainer) createMissingMounts() error {
+func (m *hostConfiguredContainer) createMissingMounts(ctx context.Context) error {
 	// Get information about existing mountpoints.
-	rc, err := m.statMounts()
+	rc, err := m.statMounts(ctx)
 	if err != nil {
 		return fmt.Errorf("failed checking if mountpoints exist: %w", err)
 	}
@@ -424,23 +509,23 @@ func (m *hostConfiguredContainer) createMissingMounts() error {
 	}
 
 	// Create missing mountpoints.
-	return m.configContainer.Copy(files)
+	return m.configContainer.Copy(ctx, files)
 }
 
 // Create creates new container on target host.
-func (m *hostConfiguredContainer) Create() error {
-	return m.withForwardedRuntime(func() error {
-		return m.withConfigurationContainer(func() error {
-			if err := m.createMissingMounts(); err != nil {
+func (m *hostConfiguredContainer) Create(ctx context.Context) error {
+	return m.withForwardedRuntime(ctx, func() error {
+		return m.withConfigurationContainer(ctx, func() error {
+			if err := m.createMissingMounts(ctx); err != nil {
 				return fmt.Errorf("failed creating missing mountpoints: %w", err)
 			}
 
-			i, err := m.container.Create()
+			i, err := m.container.Create(ctx)
 			if err != nil {
 				return fmt.Errorf("failed creating container: %w", err)
 			}
 
-			s, err := i.Status()
+			s, err := i.Status(ctx)
 			if err != nil {
 				return fmt.Errorf("failed getting container status: %w", err)
 			}
@@ -453,38 +538,110 @@ func (m *hostConfiguredContainer) Create() error {
 }
 
 // Status updates container status.
-func (m *hostConfiguredContainer) Status() error {
+func (m *hostConfiguredContainer) Status(ctx context.Context) error {
 	// If container does not exist, skip checking the status of it, as it won't work.
 	if !m.container.Status().Exists() {
 		return fmt.Errorf("can't check status of non existing container")
 	}
 
-	return m.withForwardedRuntime(m.container.UpdateStatus)
+	return m.withForwardedRuntime(ctx, func() error {
+		if err := m.container.UpdateStatus(ctx); err != nil {
+			return fmt.Errorf("updating status: %w", err)
+		}
+
+		return m.updateImageID(ctx)
+	})
+}
+
+// RuntimeInfo returns information about container runtime on the host.
+func (m *hostConfiguredContainer) RuntimeInfo(ctx context.Context) (types.RuntimeInfo, error) {
+	var i types.RuntimeInfo
+
+	err := m.withForwardedRuntime(ctx, func() error {
+		ri, err := m.container.Runtime().Info(ctx)
+		if err != nil {
+			return fmt.Errorf("getting runtime information: %w", err)
+		}
+
+		i = ri
+
+		return nil
+	})
+
+	return i, err
+}
+
+// updateImageID reads the ID of the image with configured name currently present on
+// the host, so it can be compared with the image ID, which container has been created from.
+// With 'Always' image pull policy, digest of the image in the registry is read as well.
+func (m *hostConfiguredContainer) updateImageID(ctx context.Context) error {
+	// Without knowing from which image container has been created, there is nothing to compare with.
+	if !m.container.Status().Exists() || m.container.Status().ImageID == "" {
+		return nil
+	}
+
+	id, err := m.container.Runtime().ImageID(ctx, m.container.Config().Image)
+	if err != nil {
+		return fmt.Errorf("checking image ID: %w", err)
+	}
+
+	m.imageID = id
+
+	// Without registry digests of the image container has been created from, for example
+	// when image has been loaded from the archive, there is nothing to compare with.
+	if m.container.Config().ImagePullPolicy != types.PullAlways || len(m.container.Status().RepoDigests) == 0 {
+		return nil
+	}
+
+	digest, err := m.container.Runtime().RegistryDigest(ctx, m.container.Config().Image)
+	if err != nil {
+		return fmt.Errorf("checking image digest in the registry: %w", err)
+	}
+
+	m.registryDigest = digest
+
+	return nil
 }
 
 // Start starts created container.
-func (m *hostConfiguredContainer) Start() error {
-	return withHook(nil, func() error {
-		return m.withForwardedRuntime(m.container.Start)
-	}, m.hooks.PostStart)
+func (m *hostConfiguredContainer) Start(ctx context.Context) error {
+	return m.withForwardedRuntimeConnection(ctx, func(hc transport.Connected) error {
+		return withHook(ctx, &connectionExecutor{hc}, nil, func() error {
+			return m.container.Start(ctx)
+		}, m.hooks.PostStart)
+	})
 }
 
 // Stop stops created container.
-func (m *hostConfiguredContainer) Stop() error {
-	return m.withForwardedRuntime(m.container.Stop)
+func (m *hostConfiguredContainer) Stop(ctx context.Context) error {
+	return m.withForwardedRuntime(ctx, func() error {
+		return m.container.Stop(ctx)
+	})
 }
 
 // Delete removes node's data and removes the container.
-func (m *hostConfiguredContainer) Delete() error {
-	return m.withForwardedRuntime(m.container.Delete)
+func (m *hostConfiguredContainer) Delete(ctx context.Context) error {
+	return m.withForwardedRuntime(ctx, func() error {
+		return m.container.Delete(ctx)
+	})
+}
+
+// connectionExecutor executes commands using given connection, if it supports it.
+type connectionExecutor struct {
+	connected transport.Connected
+}
+
+// Exec implements transport.Executor interface.
+func (c *connectionExecutor) Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	return transport.Exec(ctx, c.connected, command, stdin)
 }
 
-// withHook wraps given action function with pre and post functionality.
+// withHook wraps given action function with pre and post functionality. Given executor is passed to the hooks.
 //
 // This allows to inject custom actions before and after hostConfiguredContainer operations.
-func withHook(preHook *Hook, action func() error, postHook *Hook) error {
+func withHook(ctx context.Context, e transport.Executor, preHook *Hook, action func() error, postHook *Hook) error {
 	if preHook != nil {
-		if err := (*preHook)(); err != nil {
+		if err := (*preHook)(ctx, e); err != nil {
 			return fmt.Errorf("running pre-hook: %w", err)
 		}
 	}
@@ -497,5 +654,5 @@ func withHook(preHook *Hook, action func() error, postHook *Hook) error {
 		return nil
 	}
 
-	return (*postHook)()
+	return (*postHook)(ctx, e)
 }
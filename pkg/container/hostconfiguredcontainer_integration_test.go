@@ -3,6 +3,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"testing"
@@ -11,6 +12,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
 )
 
@@ -58,22 +60,22 @@ func TestHostConfiguredContainerDeployConfigFile(t *testing.T) { //nolint:funlen
 		t.Fatalf("Initializing host configured container should succeed, got: %v", err)
 	}
 
-	if err = hcc.Configure([]string{f}); err != nil {
+	if err = hcc.Configure(context.Background(), []string{f}); err != nil {
 		t.Fatalf("Configuring host configured container should succeed, got: %v", err)
 	}
 
-	if err = hcc.Create(); err != nil {
+	if err = hcc.Create(context.Background()); err != nil {
 		t.Fatalf("Creating host configured container should succeed, got: %v", err)
 	}
 
-	if err = hcc.Start(); err != nil {
+	if err = hcc.Start(context.Background()); err != nil {
 		t.Fatalf("Starting host configured container should succeed, got: %v", err)
 	}
 
 	// Sleep a bit, to make sure container starts etc.
 	time.Sleep(containerRunningDelay)
 
-	if err = hcc.Status(); err != nil {
+	if err = hcc.Status(context.Background()); err != nil {
 		t.Fatalf("Checking host configured container status should succeed, got: %v", err)
 	}
 
@@ -82,11 +84,11 @@ func TestHostConfiguredContainerDeployConfigFile(t *testing.T) { //nolint:funlen
 		t.Errorf("Host configured container should be running, got status %v", s)
 	}
 
-	if err = hcc.Stop(); err != nil {
+	if err = hcc.Stop(context.Background()); err != nil {
 		t.Errorf("Stopping host configured container status should succeed, got: %v", err)
 	}
 
-	if err = hcc.Delete(); err != nil {
+	if err = hcc.Delete(context.Background()); err != nil {
 		t.Fatalf("Deleting host configured container status should succeed, got: %v", err)
 	}
 }
@@ -94,9 +96,13 @@ func TestHostConfiguredContainerDeployConfigFile(t *testing.T) { //nolint:funlen
 func TestHostConfiguredContainerPostStartHook(t *testing.T) {
 	hookCalled := false
 
-	f := Hook(func() error {
+	f := Hook(func(ctx context.Context, e transport.Executor) error {
 		hookCalled = true
 
+		if _, err := e.Exec(ctx, "true", nil); err != nil {
+			return fmt.Errorf("executing command on the host: %w", err)
+		}
+
 		return nil
 	})
 
@@ -123,11 +129,11 @@ func TestHostConfiguredContainerPostStartHook(t *testing.T) {
 		t.Fatalf("Initializing host configured container should succeed, got: %v", err)
 	}
 
-	if err = hcc.Create(); err != nil {
+	if err = hcc.Create(context.Background()); err != nil {
 		t.Fatalf("Creating host configured container should succeed, got: %v", err)
 	}
 
-	if err = hcc.Start(); err != nil {
+	if err = hcc.Start(context.Background()); err != nil {
 		t.Fatalf("Starting host configured container should succeed, got: %v", err)
 	}
 
@@ -135,11 +141,11 @@ func TestHostConfiguredContainerPostStartHook(t *testing.T) {
 		t.Errorf("PostStart hook should be called")
 	}
 
-	if err = hcc.Stop(); err != nil {
+	if err = hcc.Stop(context.Background()); err != nil {
 		t.Errorf("Stopping host configured container status should succeed, got: %v", err)
 	}
 
-	if err = hcc.Delete(); err != nil {
+	if err = hcc.Delete(context.Background()); err != nil {
 		t.Fatalf("Deleting host configured container status should succeed, got: %v", err)
 	}
 }
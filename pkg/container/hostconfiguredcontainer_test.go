@@ -1,6 +1,7 @@
 package container
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container/runtime"
 	"github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
 )
 
@@ -19,7 +21,7 @@ import (
 func TestWithHook(t *testing.T) {
 	action := false
 
-	if err := withHook(nil, func() error {
+	if err := withHook(context.Background(), nil, nil, func() error {
 		action = true
 
 		return nil
@@ -35,13 +37,13 @@ func TestWithHook(t *testing.T) {
 func TestWithPreHook(t *testing.T) {
 	pre := false
 
-	f := Hook(func() error {
+	f := Hook(func(context.Context, transport.Executor) error {
 		pre = true
 
 		return nil
 	})
 
-	if err := withHook(&f, func() error {
+	if err := withHook(context.Background(), nil, &f, func() error {
 		return nil
 	}, nil); err != nil {
 		t.Fatalf("withHook should not return error, got: %v", err)
@@ -55,13 +57,13 @@ func TestWithPreHook(t *testing.T) {
 func TestWithPostHook(t *testing.T) {
 	post := false
 
-	f := Hook(func() error {
+	f := Hook(func(context.Context, transport.Executor) error {
 		post = true
 
 		return nil
 	})
 
-	if err := withHook(nil, func() error {
+	if err := withHook(context.Background(), nil, nil, func() error {
 		return nil
 	}, &f); err != nil {
 		t.Fatalf("withHook should not return error, got: %v", err)
@@ -72,6 +74,24 @@ func TestWithPostHook(t *testing.T) {
 	}
 }
 
+func TestWithHookPassExecutor(t *testing.T) {
+	e := &connectionExecutor{}
+
+	f := Hook(func(ctx context.Context, he transport.Executor) error {
+		if he != e {
+			return fmt.Errorf("unexpected executor %v", he)
+		}
+
+		return nil
+	})
+
+	if err := withHook(context.Background(), e, &f, func() error {
+		return nil
+	}, &f); err != nil {
+		t.Fatalf("withHook should pass executor to hooks, got: %v", err)
+	}
+}
+
 func TestConnectAndForward(t *testing.T) {
 	addr := &net.UnixAddr{
 		Name: "@foo",
@@ -89,7 +109,15 @@ func TestConnectAndForward(t *testing.T) {
 		},
 	}
 
-	s, err := h.connectAndForward(fmt.Sprintf("unix://%s", addr.String()))
+	p := host.NewConnectionPool()
+
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("Closing connection pool: %v", err)
+		}
+	}()
+
+	_, s, err := h.connectAndForward(context.Background(), p, fmt.Sprintf("unix://%s", addr.String()))
 	if err != nil {
 		t.Fatalf("Direct forwarding to open listener should work, got: %v", err)
 	}
@@ -103,13 +131,40 @@ func TestConnectAndForward(t *testing.T) {
 	}
 }
 
+func TestConnectAndForwardTCP(t *testing.T) {
+	h := &hostConfiguredContainer{
+		host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+	}
+
+	a := "tcp://127.0.0.1:2376"
+
+	p := host.NewConnectionPool()
+
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("Closing connection pool: %v", err)
+		}
+	}()
+
+	_, s, err := h.connectAndForward(context.Background(), p, a)
+	if err != nil {
+		t.Fatalf("Direct forwarding of TCP address should work, got: %v", err)
+	}
+
+	if s != a {
+		t.Fatalf("Direct forwarding of TCP address should return the same address, expected %q, got %q", a, s)
+	}
+}
+
 // Status() tests.
 func TestHostConfiguredContainerStatusNotExist(t *testing.T) {
 	h := &hostConfiguredContainer{
 		container: &container{},
 	}
 
-	if err := h.Status(); err == nil {
+	if err := h.Status(context.Background()); err == nil {
 		t.Fatalf("checking status of non existing container should fail, got: %v", err)
 	}
 }
@@ -135,11 +190,90 @@ func TestHostConfiguredContainerStatus(t *testing.T) {
 		},
 	}
 
-	if err := h.Status(); err != nil {
+	if err := h.Status(context.Background()); err != nil {
 		t.Fatalf("checking status of existing container should succeed, got: %v", err)
 	}
 }
 
+func TestHostConfiguredContainerStatusImageID(t *testing.T) {
+	h := &hostConfiguredContainer{
+		host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		container: &container{
+			base: base{
+				runtimeConfig: &runtime.FakeConfig{
+					Runtime: &runtime.Fake{
+						StatusF: func(id string) (types.ContainerStatus, error) {
+							return types.ContainerStatus{
+								ID:      foo,
+								ImageID: foo,
+							}, nil
+						},
+						ImageIDF: func(image string) (string, error) {
+							return bar, nil
+						},
+					},
+				},
+				status: types.ContainerStatus{
+					ID: foo,
+				},
+			},
+		},
+	}
+
+	if err := h.Status(context.Background()); err != nil {
+		t.Fatalf("checking status of existing container should succeed, got: %v", err)
+	}
+
+	if h.imageID != bar {
+		t.Fatalf("checking status should store ID of the image present on the host, got %q", h.imageID)
+	}
+}
+
+func TestHostConfiguredContainerStatusRegistryDigest(t *testing.T) {
+	h := &hostConfiguredContainer{
+		host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		container: &container{
+			base: base{
+				config: types.ContainerConfig{
+					ImagePullPolicy: types.PullAlways,
+				},
+				runtimeConfig: &runtime.FakeConfig{
+					Runtime: &runtime.Fake{
+						StatusF: func(id string) (types.ContainerStatus, error) {
+							return types.ContainerStatus{
+								ID:          foo,
+								ImageID:     foo,
+								RepoDigests: []string{"foo@sha256:foo"},
+							}, nil
+						},
+						ImageIDF: func(image string) (string, error) {
+							return foo, nil
+						},
+						RegistryDigestF: func(image string) (string, error) {
+							return bar, nil
+						},
+					},
+				},
+				status: types.ContainerStatus{
+					ID: foo,
+				},
+			},
+		},
+	}
+
+	if err := h.Status(context.Background()); err != nil {
+		t.Fatalf("checking status of existing container should succeed, got: %v", err)
+	}
+
+	if h.registryDigest != bar {
+		t.Fatalf("checking status should store digest of the image in the registry, got %q", h.registryDigest)
+	}
+}
+
 // createConfigurationContainer() tests.
 func TestHostConfiguredContainerCreateConfigurationContainer(t *testing.T) {
 	h := &hostConfiguredContainer{
@@ -154,7 +288,7 @@ func TestHostConfiguredContainerCreateConfigurationContainer(t *testing.T) {
 		},
 	}
 
-	if err := h.createConfigurationContainer(); err == nil {
+	if err := h.createConfigurationContainer(context.Background()); err == nil {
 		t.Fatalf("creating configuration container should fail")
 	}
 }
@@ -190,7 +324,7 @@ func TestHostConfiguredContainerRemoveConfigurationContainer(t *testing.T) {
 		},
 	}
 
-	if err := h.removeConfigurationContainer(); err != nil {
+	if err := h.removeConfigurationContainer(context.Background()); err != nil {
 		t.Fatalf("removing configuration container should succeed, got: %v", err)
 	}
 
@@ -212,7 +346,7 @@ func TestHostConfiguredContainerRemoveConfigurationContainerFailStatus(t *testin
 		},
 	}
 
-	if err := h.removeConfigurationContainer(); err == nil {
+	if err := h.removeConfigurationContainer(context.Background()); err == nil {
 		t.Fatalf("removing configuration container should fail")
 	}
 }
@@ -223,7 +357,7 @@ func TestStatMountsNoMounts(t *testing.T) {
 		container: &container{},
 	}
 
-	if _, err := h.statMounts(); err != nil {
+	if _, err := h.statMounts(context.Background()); err != nil {
 		t.Fatalf("Stating mounts when there is no mounts defined should always succeed, got: %v", err)
 	}
 }
@@ -253,7 +387,7 @@ func TestStatMountsRuntimeError(t *testing.T) {
 		},
 	}
 
-	if _, err := h.statMounts(); err == nil {
+	if _, err := h.statMounts(context.Background()); err == nil {
 		t.Fatalf("Stating mount should fail when runtime error occurs")
 	}
 }
@@ -287,7 +421,7 @@ func TestStatMounts(t *testing.T) {
 		},
 	}
 
-	s, err := h.statMounts()
+	s, err := h.statMounts(context.Background())
 	if err != nil {
 		t.Fatalf("Stating mount should succeed, got: %v", err)
 	}
@@ -323,7 +457,7 @@ func TestCreateMissingMountpointsStatFail(t *testing.T) {
 		},
 	}
 
-	if err := h.createMissingMounts(); err == nil {
+	if err := h.createMissingMounts(context.Background()); err == nil {
 		t.Fatalf("Creating missing mountpoints should fail when stating mounts fails")
 	}
 }
@@ -355,7 +489,7 @@ func TestCreateMissingMountpointsMountpointFile(t *testing.T) {
 		},
 	}
 
-	if err := h.createMissingMounts(); err == nil {
+	if err := h.createMissingMounts(context.Background()); err == nil {
 		t.Fatalf("Creating missing mountpoints should fail when stated mount is a file")
 	}
 }
@@ -387,7 +521,7 @@ func TestCreateMissingMountpointsNoMountsToCreate(t *testing.T) {
 		},
 	}
 
-	if err := h.createMissingMounts(); err != nil {
+	if err := h.createMissingMounts(context.Background()); err != nil {
 		t.Fatalf("Creating missing mountpoints without runtime should succeed, if there is no mountpoints to create, got: %v", err)
 	}
 }
@@ -420,7 +554,7 @@ func TestCreateMissingMountpointsCopyFail(t *testing.T) {
 		},
 	}
 
-	if err := h.createMissingMounts(); err == nil {
+	if err := h.createMissingMounts(context.Background()); err == nil {
 		t.Fatalf("Creating missing mountpoints should fail when copying fails")
 	}
 }
@@ -469,7 +603,7 @@ func TestCreateMissingMountpoints(t *testing.T) {
 		},
 	}
 
-	if err := h.createMissingMounts(); err != nil {
+	if err := h.createMissingMounts(context.Background()); err != nil {
 		t.Fatalf("Creating missing mountpoints should succeed, got: %v", err)
 	}
 
@@ -518,7 +652,7 @@ func TestWithForwardedRuntimeFailForward(t *testing.T) {
 		},
 	}
 
-	if err := h.withForwardedRuntime(func() error {
+	if err := h.withForwardedRuntime(context.Background(), func() error {
 		return nil
 	}); err == nil {
 		t.Fatalf("should fail with bad host")
@@ -537,7 +671,7 @@ func TestWithForwardedRuntimeFailRuntime(t *testing.T) {
 		},
 	}
 
-	if err := h.withForwardedRuntime(func() error {
+	if err := h.withForwardedRuntime(context.Background(), func() error {
 		return nil
 	}); err == nil {
 		t.Fatalf("should fail with bad runtime")
@@ -561,13 +695,44 @@ func TestWithForwardedRuntime(t *testing.T) {
 	}
 
 	// TODO: Test runtime manipulation here.
-	if err := h.withForwardedRuntime(func() error {
+	if err := h.withForwardedRuntime(context.Background(), func() error {
 		return nil
 	}); err != nil {
 		t.Fatalf("should work, got: %v", err)
 	}
 }
 
+// RuntimeInfo() tests.
+func TestHostConfiguredContainerRuntimeInfo(t *testing.T) {
+	h := &hostConfiguredContainer{
+		host: host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		container: &container{
+			base: base{
+				runtimeConfig: &runtime.FakeConfig{
+					Runtime: &runtime.Fake{
+						InfoF: func() (types.RuntimeInfo, error) {
+							return types.RuntimeInfo{
+								CgroupDriver: foo,
+							}, nil
+						},
+					},
+				},
+			},
+		},
+	}
+
+	i, err := h.RuntimeInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Getting runtime info should succeed, got: %v", err)
+	}
+
+	if i.CgroupDriver != foo {
+		t.Fatalf("Expected cgroup driver %q, got %q", foo, i.CgroupDriver)
+	}
+}
+
 // Create() tests.
 func TestHostConfiguredContainerCreateFailMountpoints(t *testing.T) {
 	h := &hostConfiguredContainer{
@@ -604,7 +769,7 @@ func TestHostConfiguredContainerCreateFailMountpoints(t *testing.T) {
 		},
 	}
 
-	if err := h.Create(); err == nil {
+	if err := h.Create(context.Background()); err == nil {
 		t.Fatalf("create with failing stat should fail")
 	}
 }
@@ -654,7 +819,7 @@ func TestHostConfiguredContainerCreateFail(t *testing.T) {
 		},
 	}
 
-	if err := h.Create(); err == nil {
+	if err := h.Create(context.Background()); err == nil {
 		t.Fatalf("create with failing create from runtime should fail")
 	}
 }
@@ -704,7 +869,7 @@ func TestHostConfiguredContainerCreateFailStatus(t *testing.T) {
 		},
 	}
 
-	if err := h.Create(); err == nil {
+	if err := h.Create(context.Background()); err == nil {
 		t.Fatalf("create with failing status from runtime should fail")
 	}
 }
@@ -748,7 +913,7 @@ func TestHostConfiguredContainerCreate(t *testing.T) {
 		},
 	}
 
-	if err := h.Create(); err != nil {
+	if err := h.Create(context.Background()); err != nil {
 		t.Fatalf("create should succeed, got: %v", err)
 	}
 
@@ -779,7 +944,7 @@ func TestHostConfiguredContainerUpdateConfigurationStatusNoAction(t *testing.T)
 		},
 	}
 
-	if err := h.updateConfigurationStatus(); err != nil {
+	if err := h.updateConfigurationStatus(context.Background()); err != nil {
 		t.Fatalf("Updating configuration status without configuration files should always succeed, got: %v", err)
 	}
 }
@@ -813,7 +978,7 @@ func TestHostConfiguredContainerUpdateConfigurationStatusFileMissing(t *testing.
 		},
 	}
 
-	if err := h.updateConfigurationStatus(); err != nil {
+	if err := h.updateConfigurationStatus(context.Background()); err != nil {
 		t.Fatalf("Updating configuration status without configuration files should always succeed, got: %v", err)
 	}
 
@@ -852,7 +1017,7 @@ func TestHostConfiguredContainerUpdateConfigurationStatusNewContent(t *testing.T
 		},
 	}
 
-	if err := h.updateConfigurationStatus(); err != nil {
+	if err := h.updateConfigurationStatus(context.Background()); err != nil {
 		t.Fatalf("Updating configuration status without configuration files should always succeed, got: %v", err)
 	}
 
@@ -890,7 +1055,7 @@ func TestHostConfiguredContainerUpdateConfigurationStatusReadRuntimeError(t *tes
 		},
 	}
 
-	if err := h.updateConfigurationStatus(); err == nil {
+	if err := h.updateConfigurationStatus(context.Background()); err == nil {
 		t.Fatalf("Updating configuration status should return error when runtime read fails")
 	}
 }
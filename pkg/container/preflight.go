@@ -0,0 +1,55 @@
+package container
+
+import (
+	"sort"
+
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
+)
+
+// PreflightChecks returns preflight checks for all containers in the desired state of given
+// containers, verifying that the container runtime is available on the host.
+//
+// Additional checks for each container can be returned by given function, which receives the
+// name of the container in the desired state and information, whether the container is new,
+// so checks like verifying that ports are free can be skipped for already running containers.
+func PreflightChecks(c ContainersInterface, checks func(name string, isNew bool) []preflight.Check) []preflight.HostChecks {
+	hostChecks := []preflight.HostChecks{}
+
+	// Containers may not be initialized, e.g. when destroying resource without state.
+	if c == nil {
+		return hostChecks
+	}
+
+	desiredState := c.DesiredState()
+	previousState := c.ToExported().PreviousState
+
+	names := []string{}
+
+	for n := range desiredState {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		hcc := desiredState[n]
+
+		_, exists := previousState[n]
+
+		hc := preflight.HostChecks{
+			Target: hcc.Container.Config.Name,
+			Host:   hcc.Host,
+			Checks: []preflight.Check{
+				preflight.RuntimeReachable(hcc.Container.Runtime.Docker.GetAddress()),
+			},
+		}
+
+		if checks != nil {
+			hc.Checks = append(hc.Checks, checks(n, !exists)...)
+		}
+
+		hostChecks = append(hostChecks, hc)
+	}
+
+	return hostChecks
+}
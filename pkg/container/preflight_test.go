@@ -0,0 +1,48 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
+)
+
+// PreflightChecks() tests.
+func TestPreflightChecks(t *testing.T) {
+	called := false
+
+	hc := PreflightChecks(GetContainers(t), func(name string, isNew bool) []preflight.Check {
+		called = true
+
+		if name != foo {
+			t.Errorf("Expected container name %q, got %q", foo, name)
+		}
+
+		if !isNew {
+			t.Errorf("Container not present in previous state should be new")
+		}
+
+		return []preflight.Check{{Name: "extra"}}
+	})
+
+	if !called {
+		t.Fatalf("Additional checks function should be called")
+	}
+
+	if len(hc) != 1 {
+		t.Fatalf("Expected checks for 1 host, got %d", len(hc))
+	}
+
+	if hc[0].Target != foo {
+		t.Errorf("Container name should be used as target, got %q", hc[0].Target)
+	}
+
+	if len(hc[0].Checks) != 2 {
+		t.Fatalf("Expected runtime check and additional check, got %d checks", len(hc[0].Checks))
+	}
+}
+
+func TestPreflightChecksNoContainers(t *testing.T) {
+	if hc := PreflightChecks(nil, nil); len(hc) != 0 {
+		t.Fatalf("No checks should be returned for uninitialized containers, got %v", hc)
+	}
+}
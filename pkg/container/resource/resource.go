@@ -3,11 +3,13 @@
 package resource
 
 import (
+	"context"
 	"fmt"
 
 	"sigs.k8s.io/yaml"
 
 	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -70,8 +72,8 @@ func (c *containers) StateToYaml() ([]byte, error) {
 }
 
 // CheckCurrentState is part of container.ContainersInterface.
-func (c *containers) CheckCurrentState() error {
-	return c.containers.CheckCurrentState()
+func (c *containers) CheckCurrentState(ctx context.Context) error {
+	return c.containers.CheckCurrentState(ctx)
 }
 
 // Deploy creates configured containers.
@@ -79,8 +81,8 @@ func (c *containers) CheckCurrentState() error {
 // CheckCurrentState() must be called before calling Deploy(), otherwise error will be returned.
 //
 // Deploy is part of container.ContainersInterface.
-func (c *containers) Deploy() error {
-	return c.containers.Deploy()
+func (c *containers) Deploy(ctx context.Context) error {
+	return c.containers.Deploy(ctx)
 }
 
 // ToExported converts unexported containers struct into exported one, which can be then
@@ -112,3 +114,8 @@ func (c *containers) DesiredState() container.ContainersState {
 func (c *containers) Containers() container.ContainersInterface {
 	return c.containers
 }
+
+// PreflightChecks implements types.PreflightChecker interface.
+func (c *containers) PreflightChecks() []preflight.HostChecks {
+	return container.PreflightChecks(c.containers, nil)
+}
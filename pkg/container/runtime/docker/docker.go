@@ -6,9 +6,12 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -18,6 +21,8 @@ import (
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	networktypes "github.com/docker/docker/api/types/network"
+	registrytypes "github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 
@@ -30,6 +35,9 @@ import (
 const (
 	// stopTimeout is how long we wait when gracefully stopping the container before force-killing it.
 	stopTimeout = 30 * time.Second
+
+	// engineName is a runtime name reported by Info().
+	engineName = "docker"
 )
 
 // Config struct represents Docker container runtime configuration.
@@ -37,6 +45,30 @@ type Config struct {
 	// Host is a Docker runtime URL. Usually 'unix:///run/docker.sock'. If empty
 	// Docker's default URL will be used.
 	Host string `json:"host,omitempty"`
+
+	// CACertificate is a PEM encoded X.509 CA certificate, which will be used to verify
+	// Docker daemon certificate when connecting over TCP with TLS. If set, TLS will be
+	// used for the connection.
+	//
+	// Types from pkg/types cannot be used here, as pkg/types depends on this package.
+	CACertificate string `json:"caCertificate,omitempty"`
+
+	// ClientCertificate is a PEM encoded X.509 certificate, which will be used for
+	// authenticating to the Docker daemon. Requires ClientKey to be set.
+	ClientCertificate string `json:"clientCertificate,omitempty"`
+
+	// ClientKey is a PEM encoded private key for ClientCertificate.
+	ClientKey string `json:"clientKey,omitempty"`
+
+	// ServerName overrides the name used to verify Docker daemon certificate. If empty,
+	// host part of Host field is used. As address gets forwarded to the local one when
+	// connecting over SSH, daemon certificate should either include 127.0.0.1 as IP address
+	// or this field should be set.
+	ServerName string `json:"serverName,omitempty"`
+
+	// APIVersion pins Docker API version used for talking to the daemon, e.g. 'v1.40'.
+	// If empty, API version is negotiated with the daemon.
+	APIVersion string `json:"apiVersion,omitempty"`
 }
 
 // dockerClient is a wrapper interface over
@@ -53,11 +85,16 @@ type dockerClient interface { //nolint:dupl
 	ContainerStatPath(ctx context.Context, container, path string) (dockertypes.ContainerPathStat, error)
 	ImageList(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error)
 	ImagePull(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error)
+	ImageLoad(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error)
+	ImageInspectWithRaw(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error)
+	DistributionInspect(ctx context.Context, image, encodedRegistryAuth string) (registrytypes.DistributionInspect, error)
+	Info(ctx context.Context) (dockertypes.Info, error)
+	ServerVersion(ctx context.Context) (dockertypes.Version, error)
+	ClientVersion() string
 }
 
 // docker struct is a struct, which can be used to manage Docker containers.
 type docker struct {
-	ctx context.Context
 	cli dockerClient
 }
 
@@ -84,14 +121,34 @@ func (c *Config) New() (runtime.Runtime, error) {
 	}
 
 	return &docker{
-		ctx: context.Background(),
 		cli: cli,
 	}, nil
 }
 
 func (c *Config) getDockerClient() (*client.Client, error) {
 	opts := []client.Opt{
-		client.WithVersion(defaults.DockerAPIVersion),
+		client.WithAPIVersionNegotiation(),
+	}
+
+	if c != nil && c.APIVersion != "" {
+		opts = []client.Opt{
+			client.WithVersion(strings.TrimPrefix(c.APIVersion, "v")),
+		}
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("building TLS configuration: %w", err)
+	}
+
+	// HTTP client must be set before the host, as setting the host configures the transport.
+	if tlsConfig != nil {
+		opts = append(opts, client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+			CheckRedirect: client.CheckRedirect,
+		}))
 	}
 
 	if c != nil && c.Host != "" {
@@ -101,20 +158,97 @@ func (c *Config) getDockerClient() (*client.Client, error) {
 	return client.NewClientWithOpts(opts...)
 }
 
-// pullImageIfNotPresent pulls image if it's not already present on the host.
-func (d *docker) pullImageIfNotPresent(image string) error {
-	// Pull image to make sure it's available.
-	// TODO make it configurable?
-	id, err := d.imageID(image)
+// tlsConfig builds TLS configuration for talking to Docker daemon from configured
+// certificates. If no certificates are configured, nil is returned.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c == nil || (c.CACertificate == "" && c.ClientCertificate == "" && c.ClientKey == "") {
+		return nil, nil
+	}
+
+	if c.CACertificate == "" {
+		return nil, fmt.Errorf("CA certificate must be set when using client certificate")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(c.CACertificate)) {
+		return nil, fmt.Errorf("failed parsing CA certificate")
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    pool,
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.ClientCertificate == "" && c.ClientKey == "" {
+		return tlsConfig, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(c.ClientCertificate), []byte(c.ClientKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing client certificate and key: %w", err)
+	}
+
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	return tlsConfig, nil
+}
+
+// ensureImage makes sure, that given image is available on the host according
+// to given image pull policy. If image source is given, it is used as a fallback when
+// pulling the image fails or to update the image present on the host.
+func (d *docker) ensureImage(ctx context.Context, image, pullPolicy, source string) error {
+	switch pullPolicy {
+	case types.PullAlways:
+		return d.pullOrLoadImage(ctx, image, source)
+	case types.PullNever:
+		id, err := d.imageID(ctx, image)
+		if err != nil {
+			return fmt.Errorf("failed checking for image presence: %w", err)
+		}
+
+		if id != "" {
+			return d.loadImageIfChanged(ctx, image, id, source)
+		}
+
+		if source == "" {
+			return fmt.Errorf("image %q is not present on the host and pull policy is %q", image, pullPolicy)
+		}
+
+		return d.loadImage(ctx, image, source)
+	default:
+		return d.pullImageIfNotPresent(ctx, image, source)
+	}
+}
+
+// pullImageIfNotPresent pulls image if it's not already present on the host. If image
+// is present and image source is given, image is loaded from the source if it's ID differs.
+func (d *docker) pullImageIfNotPresent(ctx context.Context, image, source string) error {
+	id, err := d.imageID(ctx, image)
 	if err != nil {
 		return fmt.Errorf("failed checking for image presence: %w", err)
 	}
 
 	if id != "" {
-		return nil
+		return d.loadImageIfChanged(ctx, image, id, source)
 	}
 
-	return d.pullImage(image)
+	return d.pullOrLoadImage(ctx, image, source)
+}
+
+// pullOrLoadImage pulls given image. If pulling fails and image source is given,
+// image is loaded from the source instead.
+func (d *docker) pullOrLoadImage(ctx context.Context, image, source string) error {
+	err := d.pullImage(ctx, image)
+	if err == nil || source == "" {
+		return err
+	}
+
+	if err := d.loadImage(ctx, image, source); err != nil {
+		return fmt.Errorf("failed loading image from source after pull failed: %w", err)
+	}
+
+	return nil
 }
 
 // buildPorts converts container PortMap type to Docker port maps.
@@ -204,8 +338,8 @@ func convertContainerConfig(config *types.ContainerConfig) (*containertypes.Conf
 }
 
 // Start starts Docker container.
-func (d *docker) Create(config *types.ContainerConfig) (string, error) {
-	if err := d.pullImageIfNotPresent(config.Image); err != nil {
+func (d *docker) Create(ctx context.Context, config *types.ContainerConfig) (string, error) {
+	if err := d.ensureImage(ctx, config.Image, config.ImagePullPolicy, config.ImageSource); err != nil {
 		return "", fmt.Errorf("failed pulling image: %w", err)
 	}
 
@@ -215,7 +349,7 @@ func (d *docker) Create(config *types.ContainerConfig) (string, error) {
 	}
 
 	// Create container.
-	c, err := d.cli.ContainerCreate(d.ctx, dockerConfig, hostConfig, &networktypes.NetworkingConfig{}, config.Name)
+	c, err := d.cli.ContainerCreate(ctx, dockerConfig, hostConfig, &networktypes.NetworkingConfig{}, config.Name)
 	if err != nil {
 		return "", fmt.Errorf("creating container: %w", err)
 	}
@@ -224,25 +358,25 @@ func (d *docker) Create(config *types.ContainerConfig) (string, error) {
 }
 
 // Start starts Docker container.
-func (d *docker) Start(id string) error {
-	return d.cli.ContainerStart(d.ctx, id, dockertypes.ContainerStartOptions{})
+func (d *docker) Start(ctx context.Context, id string) error {
+	return d.cli.ContainerStart(ctx, id, dockertypes.ContainerStartOptions{})
 }
 
 // Stop stops Docker container.
-func (d *docker) Stop(id string) error {
+func (d *docker) Stop(ctx context.Context, id string) error {
 	// TODO make timeout configurable?
 	timeout := stopTimeout
 
-	return d.cli.ContainerStop(d.ctx, id, &timeout)
+	return d.cli.ContainerStop(ctx, id, &timeout)
 }
 
 // Status returns container status.
-func (d *docker) Status(id string) (types.ContainerStatus, error) {
+func (d *docker) Status(ctx context.Context, id string) (types.ContainerStatus, error) {
 	s := types.ContainerStatus{
 		ID: id,
 	}
 
-	status, err := d.cli.ContainerInspect(d.ctx, id)
+	status, err := d.cli.ContainerInspect(ctx, id)
 	if err != nil {
 		// If container is missing, return status with empty ID.
 		if client.IsErrNotFound(err) {
@@ -255,25 +389,52 @@ func (d *docker) Status(id string) (types.ContainerStatus, error) {
 	}
 
 	s.Status = status.State.Status
+	s.ImageID = status.Image
+
+	repoDigests, err := d.repoDigests(ctx, status.Image)
+	if err != nil {
+		return s, fmt.Errorf("checking image digests: %w", err)
+	}
+
+	s.RepoDigests = repoDigests
 
 	return s, nil
 }
 
+// repoDigests returns registry digests of the image with given ID. If image is not
+// present on the host, empty list is returned.
+func (d *docker) repoDigests(ctx context.Context, id string) ([]string, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	i, _, err := d.cli.ImageInspectWithRaw(ctx, id)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("inspecting image failed: %w", err)
+	}
+
+	return i.RepoDigests, nil
+}
+
 // Delete removes the container.
-func (d *docker) Delete(id string) error {
-	return d.cli.ContainerRemove(d.ctx, id, dockertypes.ContainerRemoveOptions{})
+func (d *docker) Delete(ctx context.Context, id string) error {
+	return d.cli.ContainerRemove(ctx, id, dockertypes.ContainerRemoveOptions{})
 }
 
 // Copy takes map of files and their content and copies it to the container using TAR archive.
 //
 // TODO Add support for base64 encoded content to support copying binary files.
-func (d *docker) Copy(id string, files []*types.File) error {
+func (d *docker) Copy(ctx context.Context, id string, files []*types.File) error {
 	t, err := filesToTar(files)
 	if err != nil {
 		return fmt.Errorf("failed packing files to TAR archive: %w", err)
 	}
 
-	return d.cli.CopyToContainer(d.ctx, id, "/", t, dockertypes.CopyToContainerOptions{})
+	return d.cli.CopyToContainer(ctx, id, "/", t, dockertypes.CopyToContainerOptions{})
 }
 
 // filesToTar converts list of container files to tar archive format.
@@ -355,11 +516,11 @@ func tarToFiles(rc io.Reader) ([]*types.File, error) {
 }
 
 // Stat check if given paths exist on the container.
-func (d *docker) Stat(id string, paths []string) (map[string]os.FileMode, error) {
+func (d *docker) Stat(ctx context.Context, id string, paths []string) (map[string]os.FileMode, error) {
 	result := map[string]os.FileMode{}
 
 	for _, p := range paths {
-		s, err := d.cli.ContainerStatPath(d.ctx, id, p)
+		s, err := d.cli.ContainerStatPath(ctx, id, p)
 		if err != nil && !client.IsErrNotFound(err) {
 			return nil, fmt.Errorf("statting path %q: %w", p, err)
 		}
@@ -373,11 +534,11 @@ func (d *docker) Stat(id string, paths []string) (map[string]os.FileMode, error)
 }
 
 // Read reads files from container.
-func (d *docker) Read(id string, srcPaths []string) ([]*types.File, error) {
+func (d *docker) Read(ctx context.Context, id string, srcPaths []string) ([]*types.File, error) {
 	files := []*types.File{}
 
 	for _, p := range srcPaths {
-		rc, _, err := d.cli.CopyFromContainer(d.ctx, id, p)
+		rc, _, err := d.cli.CopyFromContainer(ctx, id, p)
 		if err != nil && !client.IsErrNotFound(err) {
 			return nil, fmt.Errorf("failed copying from container: %w", err)
 		}
@@ -414,14 +575,61 @@ func sanitizeImageName(image string) string {
 	return image
 }
 
-// imageID lists images which are pulled on the host and looks for the tag given by the user.
+// Info returns information about Docker daemon. If API version negotiated with the
+// daemon is older than minimal supported version, error is returned.
+func (d *docker) Info(ctx context.Context) (types.RuntimeInfo, error) {
+	v, err := d.cli.ServerVersion(ctx)
+	if err != nil {
+		return types.RuntimeInfo{}, fmt.Errorf("getting Docker version: %w", err)
+	}
+
+	i, err := d.cli.Info(ctx)
+	if err != nil {
+		return types.RuntimeInfo{}, fmt.Errorf("getting Docker info: %w", err)
+	}
+
+	// After first request, client version is set to the negotiated one.
+	apiVersion := d.cli.ClientVersion()
+	minAPIVersion := strings.TrimPrefix(defaults.DockerAPIVersion, "v")
+
+	if versions.LessThan(apiVersion, minAPIVersion) {
+		return types.RuntimeInfo{}, fmt.Errorf("API version %s of Docker %s is not supported, minimal supported API version is %s", apiVersion, v.Version, minAPIVersion)
+	}
+
+	return types.RuntimeInfo{
+		Name:          engineName,
+		Version:       v.Version,
+		APIVersion:    apiVersion,
+		CgroupDriver:  i.CgroupDriver,
+		StorageDriver: i.Driver,
+	}, nil
+}
+
+// ImageID returns ID of the image with given name present on the host. If image
+// is not present, empty string is returned.
+func (d *docker) ImageID(ctx context.Context, image string) (string, error) {
+	return d.imageID(ctx, image)
+}
+
+// RegistryDigest returns digest of the image with given name stored in the registry,
+// without pulling the image.
+func (d *docker) RegistryDigest(ctx context.Context, image string) (string, error) {
+	i, err := d.cli.DistributionInspect(ctx, image, "")
+	if err != nil {
+		return "", fmt.Errorf("inspecting image in the registry failed: %w", err)
+	}
+
+	return i.Descriptor.Digest.String(), nil
+}
+
+// imageID lists images which are pulled on the host and looks for the tag or digest given by the user.
 //
-// If image with given tag is found, it's ID is returned.
+// If image with given tag or digest is found, it's ID is returned.
 // If image is not pulled, empty string is returned.
 //
 // This method allows to check if the image is present on the host.
-func (d *docker) imageID(image string) (string, error) {
-	images, err := d.cli.ImageList(d.ctx, dockertypes.ImageListOptions{})
+func (d *docker) imageID(ctx context.Context, image string) (string, error) {
+	images, err := d.cli.ImageList(ctx, dockertypes.ImageListOptions{})
 	if err != nil {
 		return "", fmt.Errorf("listing docker images failed: %w", err)
 	}
@@ -434,14 +642,21 @@ func (d *docker) imageID(image string) (string, error) {
 				return i.ID, nil
 			}
 		}
+
+		// Images pinned by digest, like 'foo@sha256:...', are only listed in RepoDigests.
+		for _, digest := range i.RepoDigests {
+			if digest == name {
+				return i.ID, nil
+			}
+		}
 	}
 
 	return "", nil
 }
 
 // pullImage pulls specified container image.
-func (d *docker) pullImage(image string) error {
-	out, err := d.cli.ImagePull(d.ctx, image, dockertypes.ImagePullOptions{})
+func (d *docker) pullImage(ctx context.Context, image string) error {
+	out, err := d.cli.ImagePull(ctx, image, dockertypes.ImagePullOptions{})
 	if err != nil {
 		return fmt.Errorf("pulling image failed: %w", err)
 	}
@@ -453,6 +668,35 @@ func (d *docker) pullImage(image string) error {
 	return out.Close()
 }
 
+// BuildConfig merges given Docker configuration with given default values and returns new
+// configuration, so given configurations are not modified. Values set in config take precedence.
+// If both are empty, Docker's default configuration is returned.
+func BuildConfig(config, defaults *Config) *Config {
+	c := DefaultConfig()
+
+	if config == nil {
+		config = &Config{}
+	}
+
+	if defaults == nil {
+		defaults = &Config{}
+	}
+
+	c.Host = util.PickString(config.Host, defaults.Host, c.Host)
+	c.CACertificate = util.PickString(config.CACertificate, defaults.CACertificate)
+	c.ServerName = util.PickString(config.ServerName, defaults.ServerName)
+	c.APIVersion = util.PickString(config.APIVersion, defaults.APIVersion)
+
+	// Client certificate must be paired with the key from the same configuration.
+	c.ClientCertificate, c.ClientKey = defaults.ClientCertificate, defaults.ClientKey
+
+	if config.ClientCertificate != "" || config.ClientKey != "" {
+		c.ClientCertificate, c.ClientKey = config.ClientCertificate, config.ClientKey
+	}
+
+	return c
+}
+
 // DefaultConfig returns Docker's runtime default configuration.
 func DefaultConfig() *Config {
 	return &Config{
@@ -3,6 +3,7 @@
 package docker
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -22,7 +23,7 @@ func TestContainerCreate(t *testing.T) {
 		Image: defaults.EtcdImage,
 	}
 
-	if _, err := r.Create(cc); err != nil {
+	if _, err := r.Create(context.Background(), cc); err != nil {
 		t.Errorf("Creating container should succeed, got: %s", err)
 	}
 }
@@ -34,12 +35,12 @@ func TestContainerCreateDelete(t *testing.T) {
 		Image: defaults.EtcdImage,
 	}
 
-	id, err := r.Create(cc)
+	id, err := r.Create(context.Background(), cc)
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %s", err)
 	}
 
-	if err := r.Delete(id); err != nil {
+	if err := r.Delete(context.Background(), id); err != nil {
 		t.Errorf("Removing container should succeed, got: %s", err)
 	}
 }
@@ -51,7 +52,7 @@ func TestContainerCreateNonExistingImage(t *testing.T) {
 		Image: "nonexistingimage",
 	}
 
-	if _, err := r.Create(cc); err == nil {
+	if _, err := r.Create(context.Background(), cc); err == nil {
 		t.Errorf("Creating container with non-existing image should fail")
 	}
 }
@@ -68,12 +69,12 @@ func TestContainerCreatePullImage(t *testing.T) {
 		Image: image,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container should pull image and succeed, got: %s", err)
 	}
 
-	if err := r.Delete(id); err != nil {
+	if err := r.Delete(context.Background(), id); err != nil {
 		t.Errorf("Removing container should succeed, got: %s", err)
 	}
 }
@@ -89,12 +90,12 @@ func TestContainerCreateWithArgs(t *testing.T) {
 		Entrypoint: []string{"/usr/local/bin/etcd"},
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container with args should succeed, got: %v", err)
 	}
 
-	data, err := d.cli.ContainerInspect(d.ctx, id)
+	data, err := d.cli.ContainerInspect(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Inspecting created container should succeed, got: %v", err)
 	}
@@ -114,12 +115,12 @@ func TestContainerCreateWithEntrypoint(t *testing.T) {
 		Entrypoint: entrypoint,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container with entrypoint should succeed, got: %v", err)
 	}
 
-	data, err := d.cli.ContainerInspect(d.ctx, id)
+	data, err := d.cli.ContainerInspect(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Inspecting created container should succeed, got: %v", err)
 	}
@@ -137,12 +138,12 @@ func TestContainerStart(t *testing.T) {
 		Image: defaults.EtcdImage,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %s", err)
 	}
 
-	if err := r.Start(id); err != nil {
+	if err := r.Start(context.Background(), id); err != nil {
 		t.Errorf("Starting container should work, got: %s", err)
 	}
 }
@@ -155,16 +156,16 @@ func TestContainerStop(t *testing.T) {
 		Image: defaults.EtcdImage,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container should succeed, got: %s", err)
 	}
 
-	if err := r.Start(id); err != nil {
+	if err := r.Start(context.Background(), id); err != nil {
 		t.Fatalf("Starting container should work, got: %s", err)
 	}
 
-	if err := r.Stop(id); err != nil {
+	if err := r.Stop(context.Background(), id); err != nil {
 		t.Errorf("Stopping container should work, got: %s", err)
 	}
 }
@@ -177,12 +178,12 @@ func TestContainerStatus(t *testing.T) {
 		Image: defaults.EtcdImage,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Errorf("Creating container should succeed, got: %s", err)
 	}
 
-	if _, err = r.Status(id); err != nil {
+	if _, err = r.Status(context.Background(), id); err != nil {
 		t.Errorf("Getting container status should work, got: %s", err)
 	}
 }
@@ -190,7 +191,7 @@ func TestContainerStatus(t *testing.T) {
 func TestContainerStatusNonExistent(t *testing.T) {
 	r, _ := getDockerRuntime(t)
 
-	status, err := r.Status("nonexistent")
+	status, err := r.Status(context.Background(), "nonexistent")
 	if err != nil {
 		t.Errorf("Getting non-existent container status shouldn't return error, got: %s", err)
 	}
@@ -223,7 +224,7 @@ func getDockerClient(t *testing.T) *client.Client {
 func deleteImage(t *testing.T, image string) {
 	_, d := getDockerRuntime(t)
 
-	id, err := d.imageID(image)
+	id, err := d.imageID(context.Background(), image)
 	if err != nil {
 		t.Fatalf("Finding image to delete failed: %v", err)
 	}
@@ -234,7 +235,7 @@ func deleteImage(t *testing.T, image string) {
 
 	c := getDockerClient(t)
 
-	if _, err := c.ImageRemove(d.ctx, id, dockertypes.ImageRemoveOptions{}); err != nil {
+	if _, err := c.ImageRemove(context.Background(), id, dockertypes.ImageRemoveOptions{}); err != nil {
 		t.Fatalf("Removing existing docker image should succeed, got: %v", err)
 	}
 }
@@ -246,11 +247,11 @@ func TestImageID(t *testing.T) {
 	image := "haproxy:2.0.7-alpine"
 
 	// Make sure image is present on the host.
-	if err := d.pullImage(image); err != nil {
+	if err := d.pullImage(context.Background(), image); err != nil {
 		t.Fatalf("Pulling image failed: %v", err)
 	}
 
-	id, err := d.imageID(image)
+	id, err := d.imageID(context.Background(), image)
 	if err != nil {
 		t.Fatalf("Checking image presence failed: %v", err)
 	}
@@ -267,7 +268,7 @@ func TestImageIDMissing(t *testing.T) {
 
 	deleteImage(t, image)
 
-	id, err := d.imageID(image)
+	id, err := d.imageID(context.Background(), image)
 	if err != nil {
 		t.Fatalf("Getting image ID failed: %v", err)
 	}
@@ -285,7 +286,7 @@ func TestPullImage(t *testing.T) {
 
 	deleteImage(t, image)
 
-	id, err := d.imageID(image)
+	id, err := d.imageID(context.Background(), image)
 	if err != nil {
 		t.Fatalf("Getting image ID failed: %v", err)
 	}
@@ -294,11 +295,11 @@ func TestPullImage(t *testing.T) {
 		t.Fatalf("Deleted image should not be not found")
 	}
 
-	if err := d.pullImage(image); err != nil {
+	if err := d.pullImage(context.Background(), image); err != nil {
 		t.Fatalf("Pulling image failed: %v", err)
 	}
 
-	id, err = d.imageID(image)
+	id, err = d.imageID(context.Background(), image)
 	if err != nil {
 		t.Fatalf("Getting image ID failed: %v", err)
 	}
@@ -319,12 +320,12 @@ func TestContainerEnv(t *testing.T) {
 		Env:   env,
 	}
 
-	id, err := r.Create(c)
+	id, err := r.Create(context.Background(), c)
 	if err != nil {
 		t.Fatalf("Creating container with environment variables should succeed, got: %v", err)
 	}
 
-	data, err := d.cli.ContainerInspect(d.ctx, id)
+	data, err := d.cli.ContainerInspect(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Inspecting created container should succeed, got: %v", err)
 	}
@@ -16,6 +16,7 @@ import (
 	dockertypes "github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	networktypes "github.com/docker/docker/api/types/network"
+	registrytypes "github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/errdefs"
 	"github.com/google/go-cmp/cmp"
@@ -55,6 +56,43 @@ func TestNewClientWithHost(t *testing.T) {
 	}
 }
 
+func TestNewClientWithAPIVersion(t *testing.T) {
+	config := &Config{
+		APIVersion: "v1.40",
+	}
+
+	c, err := config.getDockerClient()
+	if err != nil {
+		t.Fatalf("Creating new docker client should work, got: %s", err)
+	}
+
+	if v := c.ClientVersion(); v != "1.40" {
+		t.Fatalf("Client with API version set should use %q API version, got: %q", "1.40", v)
+	}
+}
+
+func TestNewClientTLSWithoutCACertificate(t *testing.T) {
+	config := &Config{
+		Host:      "tcp://127.0.0.1:2376",
+		ClientKey: "foo",
+	}
+
+	if _, err := config.getDockerClient(); err == nil {
+		t.Fatalf("Creating client with client key and without CA certificate should fail")
+	}
+}
+
+func TestNewClientTLSBadCACertificate(t *testing.T) {
+	config := &Config{
+		Host:          "tcp://127.0.0.1:2376",
+		CACertificate: "foo",
+	}
+
+	if _, err := config.getDockerClient(); err == nil {
+		t.Fatalf("Creating client with malformed CA certificate should fail")
+	}
+}
+
 // sanitizeImageName() tests.
 func TestSanitizeImageName(t *testing.T) {
 	e := "foo:latest"
@@ -77,7 +115,6 @@ func TestStatus(t *testing.T) {
 	es := "running"
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerInspectF: func(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
 				return dockertypes.ContainerJSON{
@@ -85,13 +122,19 @@ func TestStatus(t *testing.T) {
 						State: &dockertypes.ContainerState{
 							Status: es,
 						},
+						Image: defaultImageID,
 					},
 				}, nil
 			},
+			ImageInspectWithRawF: func(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error) {
+				return dockertypes.ImageInspect{
+					RepoDigests: []string{defaultRepoDigest},
+				}, nil, nil
+			},
 		},
 	}
 
-	s, err := d.Status("foo")
+	s, err := d.Status(context.Background(), "foo")
 	if err != nil {
 		t.Fatalf("Checking for status should succeed, got: %v", err)
 	}
@@ -103,11 +146,45 @@ func TestStatus(t *testing.T) {
 	if s.Status != es {
 		t.Fatalf("Received status should be %s, got %s", es, s.Status)
 	}
+
+	if s.ImageID != defaultImageID {
+		t.Fatalf("Received image ID should be %s, got %s", defaultImageID, s.ImageID)
+	}
+
+	if diff := cmp.Diff([]string{defaultRepoDigest}, s.RepoDigests); diff != "" {
+		t.Fatalf("Unexpected repo digests: %s", diff)
+	}
+}
+
+func TestStatusImageNotFound(t *testing.T) {
+	d := &docker{
+		cli: &FakeClient{
+			ContainerInspectF: func(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
+				return dockertypes.ContainerJSON{
+					ContainerJSONBase: &dockertypes.ContainerJSONBase{
+						State: &dockertypes.ContainerState{},
+						Image: defaultImageID,
+					},
+				}, nil
+			},
+			ImageInspectWithRawF: func(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error) {
+				return dockertypes.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("not found"))
+			},
+		},
+	}
+
+	s, err := d.Status(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("Checking for status should succeed when image is removed, got: %v", err)
+	}
+
+	if len(s.RepoDigests) != 0 {
+		t.Fatalf("Repo digests of removed image should be empty, got: %v", s.RepoDigests)
+	}
 }
 
 func TestStatusNotFound(t *testing.T) {
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerInspectF: func(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
 				return dockertypes.ContainerJSON{}, errdefs.NotFound(fmt.Errorf("not found"))
@@ -115,7 +192,7 @@ func TestStatusNotFound(t *testing.T) {
 		},
 	}
 
-	s, err := d.Status("foo")
+	s, err := d.Status(context.Background(), "foo")
 	if err != nil {
 		t.Fatalf("Checking for status should succeed, got: %v", err)
 	}
@@ -127,7 +204,6 @@ func TestStatusNotFound(t *testing.T) {
 
 func TestStatusRuntimeError(t *testing.T) {
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerInspectF: func(ctx context.Context, id string) (dockertypes.ContainerJSON, error) {
 				return dockertypes.ContainerJSON{}, fmt.Errorf("can't check status of container")
@@ -135,7 +211,7 @@ func TestStatusRuntimeError(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Status("foo"); err == nil {
+	if _, err := d.Status(context.Background(), "foo"); err == nil {
 		t.Fatalf("Checking for status should fail")
 	}
 }
@@ -143,7 +219,6 @@ func TestStatusRuntimeError(t *testing.T) {
 // Copy() tests.
 func TestCopyRuntimeError(t *testing.T) {
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			CopyToContainerF: func(ctx context.Context, id, path string, content io.Reader, options dockertypes.CopyToContainerOptions) error {
 				return fmt.Errorf("Copying failed")
@@ -151,7 +226,7 @@ func TestCopyRuntimeError(t *testing.T) {
 		},
 	}
 
-	if err := d.Copy("foo", []*types.File{}); err == nil {
+	if err := d.Copy(context.Background(), "foo", []*types.File{}); err == nil {
 		t.Fatalf("should fail when runtime returns error")
 	}
 }
@@ -161,7 +236,6 @@ func TestReadRuntimeError(t *testing.T) {
 	p := defaultPath
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			CopyFromContainerF: func(ctx context.Context, id, path string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
 				if path != p {
@@ -173,21 +247,23 @@ func TestReadRuntimeError(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Read("foo", []string{p}); err == nil {
+	if _, err := d.Read(context.Background(), "foo", []string{p}); err == nil {
 		t.Fatalf("should fail when runtime returns error")
 	}
 }
 
 const (
-	defaultMode = 420
-	defaultPath = "/foo"
+	defaultMode    = 420
+	defaultPath    = "/foo"
+	defaultImageID = "sha256:foo"
+
+	defaultRepoDigest = "foo@sha256:bar"
 )
 
 func TestRead(t *testing.T) {
 	p := defaultPath
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			CopyFromContainerF: func(ctx context.Context, id, path string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
 				return ioutil.NopCloser(testTar(t)), dockertypes.ContainerPathStat{
@@ -197,7 +273,7 @@ func TestRead(t *testing.T) {
 		},
 	}
 
-	fs, err := d.Read("foo", []string{p})
+	fs, err := d.Read(context.Background(), "foo", []string{p})
 	if err != nil {
 		t.Fatalf("Reading should succeed, got: %v", err)
 	}
@@ -221,7 +297,6 @@ func TestReadFileMissing(t *testing.T) {
 	p := defaultPath
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			CopyFromContainerF: func(ctx context.Context, id, path string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
 				return nil, dockertypes.ContainerPathStat{}, nil
@@ -229,7 +304,7 @@ func TestReadFileMissing(t *testing.T) {
 		},
 	}
 
-	fs, err := d.Read("foo", []string{p})
+	fs, err := d.Read(context.Background(), "foo", []string{p})
 	if err != nil {
 		t.Fatalf("read should succeed, got: %v", err)
 	}
@@ -256,7 +331,6 @@ func TestReadVerifyTarArchive(t *testing.T) {
 	p := defaultPath
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			CopyFromContainerF: func(ctx context.Context, id, path string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
 				return ioutil.NopCloser(strings.NewReader("asdasd")), dockertypes.ContainerPathStat{}, nil
@@ -264,7 +338,7 @@ func TestReadVerifyTarArchive(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Read("foo", []string{p}); err == nil {
+	if _, err := d.Read(context.Background(), "foo", []string{p}); err == nil {
 		t.Fatalf("read should fail on bad TAR archive")
 	}
 }
@@ -368,7 +442,6 @@ func TestFilesToTarNumericUserGroup(t *testing.T) {
 // Create() tests.
 func TestCreatePullImageFail(t *testing.T) {
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
 				return []dockertypes.ImageSummary{}, fmt.Errorf("runtime error")
@@ -376,7 +449,7 @@ func TestCreatePullImageFail(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Create(&types.ContainerConfig{}); err == nil {
+	if _, err := d.Create(context.Background(), &types.ContainerConfig{}); err == nil {
 		t.Fatalf("Should fail when runtime error occurs")
 	}
 }
@@ -389,7 +462,6 @@ func TestCreateSetUser(t *testing.T) {
 	}
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerCreateF: func(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, networkingConfig *networktypes.NetworkingConfig, containerName string) (containertypes.ContainerCreateCreatedBody, error) {
 				if config.User != c.User {
@@ -407,7 +479,7 @@ func TestCreateSetUser(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Create(c); err != nil {
+	if _, err := d.Create(context.Background(), c); err != nil {
 		t.Fatalf("Create should succeed, got: %v", err)
 	}
 }
@@ -421,7 +493,6 @@ func TestCreateSetUserGroup(t *testing.T) {
 	e := fmt.Sprintf("%s:%s", c.User, c.Group)
 
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerCreateF: func(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, networkingConfig *networktypes.NetworkingConfig, containerName string) (containertypes.ContainerCreateCreatedBody, error) {
 				if config.User != e {
@@ -439,14 +510,13 @@ func TestCreateSetUserGroup(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Create(c); err != nil {
+	if _, err := d.Create(context.Background(), c); err != nil {
 		t.Fatalf("Create should succeed, got: %v", err)
 	}
 }
 
 func TestCreateRuntimeFail(t *testing.T) {
 	d := &docker{
-		ctx: context.Background(),
 		cli: &FakeClient{
 			ContainerCreateF: func(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, networkingConfig *networktypes.NetworkingConfig, containerName string) (containertypes.ContainerCreateCreatedBody, error) {
 				return containertypes.ContainerCreateCreatedBody{}, fmt.Errorf("runtime error")
@@ -460,13 +530,126 @@ func TestCreateRuntimeFail(t *testing.T) {
 		},
 	}
 
-	if _, err := d.Create(&types.ContainerConfig{}); err == nil {
+	if _, err := d.Create(context.Background(), &types.ContainerConfig{}); err == nil {
 		t.Fatalf("Should fail when runtime error occurs")
 	}
 }
 
 func TestCreate(t *testing.T) {}
 
+func TestCreatePullPolicyAlways(t *testing.T) {
+	pulled := false
+
+	d := &docker{
+		cli: &FakeClient{
+			ContainerCreateF: func(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, networkingConfig *networktypes.NetworkingConfig, containerName string) (containertypes.ContainerCreateCreatedBody, error) {
+				return containertypes.ContainerCreateCreatedBody{}, nil
+			},
+			ImagePullF: func(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error) {
+				pulled = true
+
+				return ioutil.NopCloser(strings.NewReader("")), nil
+			},
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				return []dockertypes.ImageSummary{
+					{
+						ID:       defaultImageID,
+						RepoTags: []string{"foo:latest"},
+					},
+				}, nil
+			},
+		},
+	}
+
+	c := &types.ContainerConfig{
+		Image:           "foo",
+		ImagePullPolicy: types.PullAlways,
+	}
+
+	if _, err := d.Create(context.Background(), c); err != nil {
+		t.Fatalf("Create should succeed, got: %v", err)
+	}
+
+	if !pulled {
+		t.Fatalf("Image should be pulled even if it's present with 'Always' pull policy")
+	}
+}
+
+func TestCreatePullPolicyNeverMissingImage(t *testing.T) {
+	d := &docker{
+		cli: &FakeClient{
+			ImagePullF: func(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error) {
+				t.Fatalf("Image should not be pulled with 'Never' pull policy")
+
+				return nil, nil
+			},
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				return []dockertypes.ImageSummary{}, nil
+			},
+		},
+	}
+
+	c := &types.ContainerConfig{
+		Image:           "foo",
+		ImagePullPolicy: types.PullNever,
+	}
+
+	if _, err := d.Create(context.Background(), c); err == nil {
+		t.Fatalf("Create should fail when image is missing and pull policy is 'Never'")
+	}
+}
+
+// ImageID() tests.
+func TestImageIDByDigest(t *testing.T) {
+	image := "foo@sha256:bar"
+
+	d := &docker{
+		cli: &FakeClient{
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				return []dockertypes.ImageSummary{
+					{
+						ID:          defaultImageID,
+						RepoDigests: []string{image},
+					},
+				}, nil
+			},
+		},
+	}
+
+	id, err := d.ImageID(context.Background(), image)
+	if err != nil {
+		t.Fatalf("Getting image ID should succeed, got: %v", err)
+	}
+
+	if id != defaultImageID {
+		t.Fatalf("Expected image ID %q, got %q", defaultImageID, id)
+	}
+}
+
+// RegistryDigest() tests.
+func TestRegistryDigest(t *testing.T) {
+	d := &docker{
+		cli: &FakeClient{
+			DistributionInspectF: func(ctx context.Context, image, encodedRegistryAuth string) (registrytypes.DistributionInspect, error) {
+				var i registrytypes.DistributionInspect
+
+				i.Descriptor.Digest = "sha256:bar"
+
+				return i, nil
+			},
+		},
+	}
+
+	digest, err := d.RegistryDigest(context.Background(), "foo")
+	if err != nil {
+		t.Fatalf("Getting registry digest should succeed, got: %v", err)
+	}
+
+	if digest != "sha256:bar" {
+		t.Fatalf("Expected digest %q, got %q", "sha256:bar", digest)
+	}
+}
+
 // DefaultConfig() tests.
 func TestDefaultConfig(t *testing.T) {
 	if DefaultConfig().Host != client.DefaultDockerHost {
@@ -474,6 +657,71 @@ func TestDefaultConfig(t *testing.T) {
 	}
 }
 
+// BuildConfig() tests.
+func TestBuildConfig(t *testing.T) {
+	cases := map[string]struct {
+		config   *Config
+		defaults *Config
+		expected *Config
+	}{
+		"empty": {
+			expected: DefaultConfig(),
+		},
+		"defaults": {
+			config: &Config{},
+			defaults: &Config{
+				Host:          "tcp://foo:2376",
+				CACertificate: "foo",
+				APIVersion:    "v1.40",
+			},
+			expected: &Config{
+				Host:          "tcp://foo:2376",
+				CACertificate: "foo",
+				APIVersion:    "v1.40",
+			},
+		},
+		"override": {
+			config: &Config{
+				Host:       "tcp://bar:2376",
+				ClientKey:  "bar",
+				ServerName: "bar",
+			},
+			defaults: &Config{
+				Host:              "tcp://foo:2376",
+				ClientCertificate: "foo",
+				ClientKey:         "foo",
+			},
+			expected: &Config{
+				Host:       "tcp://bar:2376",
+				ClientKey:  "bar",
+				ServerName: "bar",
+			},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+
+		t.Run(n, func(t *testing.T) {
+			if diff := cmp.Diff(c.expected, BuildConfig(c.config, c.defaults)); diff != "" {
+				t.Fatalf("Unexpected config: %s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildConfigDoNotModify(t *testing.T) {
+	d := &Config{}
+
+	c := BuildConfig(nil, d)
+
+	c.Host = "foo"
+
+	if d.Host != "" {
+		t.Fatalf("Building config should not modify defaults")
+	}
+}
+
 // GetAddress() tests.
 func TestGetAddressNilConfig(t *testing.T) {
 	var c *Config
@@ -519,3 +767,56 @@ func TestConvertContainerConfigEnvVariables(t *testing.T) {
 		t.Fatalf("Configured environment variables should be included in container configuration")
 	}
 }
+
+// Info() tests.
+func infoClient(apiVersion string) *FakeClient {
+	return &FakeClient{
+		ServerVersionF: func(ctx context.Context) (dockertypes.Version, error) {
+			return dockertypes.Version{
+				Version: "19.03.12",
+			}, nil
+		},
+		InfoF: func(ctx context.Context) (dockertypes.Info, error) {
+			return dockertypes.Info{
+				CgroupDriver: "systemd",
+				Driver:       "overlay2",
+			}, nil
+		},
+		ClientVersionF: func() string {
+			return apiVersion
+		},
+	}
+}
+
+func TestInfo(t *testing.T) {
+	d := &docker{
+		cli: infoClient("1.40"),
+	}
+
+	i, err := d.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Getting info should succeed, got: %v", err)
+	}
+
+	expected := types.RuntimeInfo{
+		Name:          "docker",
+		Version:       "19.03.12",
+		APIVersion:    "1.40",
+		CgroupDriver:  "systemd",
+		StorageDriver: "overlay2",
+	}
+
+	if diff := cmp.Diff(expected, i); diff != "" {
+		t.Fatalf("Unexpected runtime info: %s", diff)
+	}
+}
+
+func TestInfoUnsupportedAPIVersion(t *testing.T) {
+	d := &docker{
+		cli: infoClient("1.30"),
+	}
+
+	if _, err := d.Info(context.Background()); err == nil {
+		t.Fatalf("Getting info with unsupported API version should fail")
+	}
+}
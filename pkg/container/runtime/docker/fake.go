@@ -8,6 +8,7 @@ import (
 	dockertypes "github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	networktypes "github.com/docker/docker/api/types/network"
+	registrytypes "github.com/docker/docker/api/types/registry"
 )
 
 // FakeClient is a mock of Docker client, which should be used only for testing.
@@ -41,6 +42,24 @@ type FakeClient struct { //nolint:dupl
 
 	// ImagePullF will be called by ImagePull.
 	ImagePullF func(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error)
+
+	// ImageLoadF will be called by ImageLoad.
+	ImageLoadF func(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error)
+
+	// ImageInspectWithRawF will be called by ImageInspectWithRaw.
+	ImageInspectWithRawF func(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error)
+
+	// DistributionInspectF will be called by DistributionInspect.
+	DistributionInspectF func(ctx context.Context, image, encodedRegistryAuth string) (registrytypes.DistributionInspect, error)
+
+	// InfoF will be called by Info.
+	InfoF func(ctx context.Context) (dockertypes.Info, error)
+
+	// ServerVersionF will be called by ServerVersion.
+	ServerVersionF func(ctx context.Context) (dockertypes.Version, error)
+
+	// ClientVersionF will be called by ClientVersion.
+	ClientVersionF func() string
 }
 
 // ContainerCreate mocks Docker client ContainerCreate().
@@ -92,3 +111,33 @@ func (f *FakeClient) ImageList(ctx context.Context, options dockertypes.ImageLis
 func (f *FakeClient) ImagePull(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error) {
 	return f.ImagePullF(ctx, ref, options)
 }
+
+// ImageLoad mocks Docker client ImageLoad().
+func (f *FakeClient) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error) {
+	return f.ImageLoadF(ctx, input, quiet)
+}
+
+// ImageInspectWithRaw mocks Docker client ImageInspectWithRaw().
+func (f *FakeClient) ImageInspectWithRaw(ctx context.Context, image string) (dockertypes.ImageInspect, []byte, error) {
+	return f.ImageInspectWithRawF(ctx, image)
+}
+
+// DistributionInspect mocks Docker client DistributionInspect().
+func (f *FakeClient) DistributionInspect(ctx context.Context, image, encodedRegistryAuth string) (registrytypes.DistributionInspect, error) {
+	return f.DistributionInspectF(ctx, image, encodedRegistryAuth)
+}
+
+// Info mocks Docker client Info().
+func (f *FakeClient) Info(ctx context.Context) (dockertypes.Info, error) {
+	return f.InfoF(ctx)
+}
+
+// ServerVersion mocks Docker client ServerVersion().
+func (f *FakeClient) ServerVersion(ctx context.Context) (dockertypes.Version, error) {
+	return f.ServerVersionF(ctx)
+}
+
+// ClientVersion mocks Docker client ClientVersion().
+func (f *FakeClient) ClientVersion() string {
+	return f.ClientVersionF()
+}
@@ -0,0 +1,402 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// dockerArchiveManifest is a name of the file in the 'docker save' archive, which
+	// describes images stored in the archive.
+	dockerArchiveManifest = "manifest.json"
+
+	// ociLayoutIndex is a name of the file in the OCI image layout, which describes
+	// manifests stored in the layout.
+	ociLayoutIndex = "index.json"
+
+	// ociLayoutFile is a name of the file, which marks the directory as OCI image layout.
+	ociLayoutFile = "oci-layout"
+
+	// ociImageIndexMediaType is a media type of OCI image index, which may be referenced
+	// from the top level index of OCI image layout.
+	ociImageIndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+	// ociImageNameAnnotation is an annotation used by Docker and containerd to store
+	// full image name in the OCI image index.
+	ociImageNameAnnotation = "io.containerd.image.name"
+
+	// ociRefNameAnnotation is a standard OCI annotation for image reference name.
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+	// maxMetadataFileSize limits how big metadata files will be read into memory from
+	// the TAR archive.
+	maxMetadataFileSize = 1 << 20
+)
+
+// dockerArchiveManifestEntry represents single image entry in 'docker save' archive manifest.
+type dockerArchiveManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+}
+
+// ociDescriptor represents OCI content descriptor.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociIndex represents OCI image index.
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// ociManifest represents OCI image manifest.
+type ociManifest struct {
+	Config ociDescriptor `json:"config"`
+}
+
+// loadImageIfChanged loads the image from given source, if the image ID in the source
+// differs from given ID of the image present on the host. If source is empty or the ID
+// of the image in the source cannot be determined, nothing is done.
+func (d *docker) loadImageIfChanged(ctx context.Context, image, id, source string) error {
+	if source == "" {
+		return nil
+	}
+
+	sourceID, err := ImageSourceID(source, image)
+	if err != nil {
+		return fmt.Errorf("failed reading image ID from image source %q: %w", source, err)
+	}
+
+	if sourceID == "" || sourceID == id {
+		return nil
+	}
+
+	return d.loadImage(ctx, image, source)
+}
+
+// loadImage streams the content of given image source to the image load API and
+// verifies, that the image is present on the host afterwards.
+func (d *docker) loadImage(ctx context.Context, image, source string) error {
+	r, err := openImageSource(source)
+	if err != nil {
+		return fmt.Errorf("failed opening image source %q: %w", source, err)
+	}
+
+	defer r.Close() // #nosec G307
+
+	resp, err := d.cli.ImageLoad(ctx, r, true)
+	if err != nil {
+		return fmt.Errorf("loading image from %q failed: %w", source, err)
+	}
+
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return fmt.Errorf("failed to discard loading messages: %w", err)
+	}
+
+	if err := resp.Body.Close(); err != nil {
+		return fmt.Errorf("failed closing image load response: %w", err)
+	}
+
+	id, err := d.imageID(ctx, image)
+	if err != nil {
+		return fmt.Errorf("failed checking for image presence: %w", err)
+	}
+
+	if id == "" {
+		return fmt.Errorf("image %q not found on the host after loading image source %q", image, source)
+	}
+
+	return nil
+}
+
+// openImageSource returns reader with TAR archive of given image source. If source
+// is a directory, it gets archived on the fly, so it can be streamed to the runtime.
+func openImageSource(source string) (io.ReadCloser, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed checking image source: %w", err)
+	}
+
+	if !fi.IsDir() {
+		return os.Open(source) // #nosec G304
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(archiveDirectory(source, pw))
+	}()
+
+	return pr, nil
+}
+
+// archiveDirectory writes content of given directory to w as TAR archive.
+func archiveDirectory(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return fmt.Errorf("failed getting relative path for %q: %w", p, err)
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		h, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return fmt.Errorf("failed creating TAR header for %q: %w", p, err)
+		}
+
+		h.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(h); err != nil {
+			return fmt.Errorf("failed writing TAR header for %q: %w", p, err)
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		return copyFile(tw, p)
+	})
+	if err != nil {
+		return fmt.Errorf("failed archiving directory %q: %w", dir, err)
+	}
+
+	return tw.Close()
+}
+
+// copyFile copies content of the file with given path to w.
+func copyFile(w io.Writer, p string) error {
+	f, err := os.Open(p) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("failed opening file: %w", err)
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("failed copying file %q: %w", p, err)
+	}
+
+	return f.Close()
+}
+
+// metadataReader reads files with given paths from the image source. Files, which are
+// not present in the source, are not included in the returned map.
+type metadataReader func(paths ...string) (map[string][]byte, error)
+
+// ImageSourceID returns ID of the given image stored in given image source. If the
+// image cannot be found in the source, empty string is returned.
+func ImageSourceID(source, image string) (string, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("failed checking image source: %w", err)
+	}
+
+	read := archiveMetadataReader(source)
+	if fi.IsDir() {
+		read = directoryMetadataReader(source)
+	}
+
+	files, err := read(dockerArchiveManifest, ociLayoutFile, ociLayoutIndex)
+	if err != nil {
+		return "", err
+	}
+
+	name := sanitizeImageName(image)
+
+	if m, ok := files[dockerArchiveManifest]; ok {
+		return dockerArchiveImageID(m, name)
+	}
+
+	if _, ok := files[ociLayoutFile]; !ok {
+		return "", fmt.Errorf("neither %q nor %q found", dockerArchiveManifest, ociLayoutFile)
+	}
+
+	i, ok := files[ociLayoutIndex]
+	if !ok {
+		return "", fmt.Errorf("%q not found in OCI image layout", ociLayoutIndex)
+	}
+
+	return ociLayoutImageID(i, read, name)
+}
+
+// directoryMetadataReader returns metadataReader reading files from given directory.
+func directoryMetadataReader(dir string) metadataReader {
+	return func(paths ...string) (map[string][]byte, error) {
+		files := map[string][]byte{}
+
+		for _, p := range paths {
+			c, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p))) // #nosec G304
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("failed reading %q: %w", p, err)
+			}
+
+			files[p] = c
+		}
+
+		return files, nil
+	}
+}
+
+// archiveMetadataReader returns metadataReader reading files from given TAR archive.
+// Only requested files are read into memory, so layers are skipped.
+func archiveMetadataReader(source string) metadataReader {
+	return func(paths ...string) (map[string][]byte, error) {
+		wanted := map[string]bool{}
+
+		for _, p := range paths {
+			wanted[p] = true
+		}
+
+		f, err := os.Open(source) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("failed opening image source: %w", err)
+		}
+
+		defer f.Close() // #nosec G307
+
+		files := map[string][]byte{}
+
+		tr := tar.NewReader(f)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return files, nil
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("failed reading TAR archive: %w", err)
+			}
+
+			n := path.Clean(h.Name)
+
+			if h.Typeflag != tar.TypeReg || !wanted[n] {
+				continue
+			}
+
+			if h.Size > maxMetadataFileSize {
+				return nil, fmt.Errorf("%q in TAR archive is too big, got %d bytes, maximum is %d", n, h.Size, maxMetadataFileSize)
+			}
+
+			c, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading %q from TAR archive: %w", n, err)
+			}
+
+			files[n] = c
+		}
+	}
+}
+
+// dockerArchiveImageID returns ID of the image with given name from the 'docker save'
+// manifest. If archive contains only one image, it's ID is returned regardless of the name.
+func dockerArchiveImageID(manifest []byte, name string) (string, error) {
+	entries := []dockerArchiveManifestEntry{}
+	if err := json.Unmarshal(manifest, &entries); err != nil {
+		return "", fmt.Errorf("failed parsing %q: %w", dockerArchiveManifest, err)
+	}
+
+	for _, e := range entries {
+		if len(entries) == 1 || hasTag(e.RepoTags, name) {
+			// Config is either '<hex>.json' or 'blobs/sha256/<hex>'.
+			return "sha256:" + strings.TrimSuffix(path.Base(e.Config), ".json"), nil
+		}
+	}
+
+	return "", nil
+}
+
+// hasTag checks if given tag is in the list of tags.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ociLayoutImageID returns ID of the image with given name from the OCI image layout.
+// If layout contains only one image, it's ID is returned regardless of the name.
+func ociLayoutImageID(i []byte, read metadataReader, name string) (string, error) {
+	index := &ociIndex{}
+	if err := json.Unmarshal(i, index); err != nil {
+		return "", fmt.Errorf("failed parsing %q: %w", ociLayoutIndex, err)
+	}
+
+	for _, d := range index.Manifests {
+		if len(index.Manifests) != 1 && d.Annotations[ociImageNameAnnotation] != name && d.Annotations[ociRefNameAnnotation] != name {
+			continue
+		}
+
+		return ociManifestImageID(d, read)
+	}
+
+	return "", nil
+}
+
+// ociManifestImageID returns ID of the image described by the manifest with given descriptor.
+// If descriptor refers to the image index, for example of multi-platform image, first manifest
+// from the index is used.
+func ociManifestImageID(d ociDescriptor, read metadataReader) (string, error) {
+	p := blobPath(d.Digest)
+
+	files, err := read(p)
+	if err != nil {
+		return "", err
+	}
+
+	c, ok := files[p]
+	if !ok {
+		return "", fmt.Errorf("manifest %q not found", d.Digest)
+	}
+
+	if d.MediaType == ociImageIndexMediaType {
+		index := &ociIndex{}
+		if err := json.Unmarshal(c, index); err != nil {
+			return "", fmt.Errorf("failed parsing index %q: %w", d.Digest, err)
+		}
+
+		if len(index.Manifests) == 0 {
+			return "", fmt.Errorf("index %q has no manifests", d.Digest)
+		}
+
+		return ociManifestImageID(index.Manifests[0], read)
+	}
+
+	m := &ociManifest{}
+	if err := json.Unmarshal(c, m); err != nil {
+		return "", fmt.Errorf("failed parsing manifest %q: %w", d.Digest, err)
+	}
+
+	return m.Config.Digest, nil
+}
+
+// blobPath returns path of the blob with given digest in OCI image layout.
+func blobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
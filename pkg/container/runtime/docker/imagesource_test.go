@@ -0,0 +1,324 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
+
+	"github.com/flexkube/libflexkube/pkg/container/types"
+)
+
+const (
+	sourceImageID = "sha256:abcd"
+)
+
+// writeDockerArchive writes 'docker save' like archive with single image to temporary directory
+// and returns path to it.
+func writeDockerArchive(t *testing.T, image string) string {
+	t.Helper()
+
+	return writeArchive(t, map[string]string{
+		"abcd.json":     "{}",
+		"manifest.json": fmt.Sprintf(`[{"Config":"abcd.json","RepoTags":[%q],"Layers":[]}]`, image),
+	})
+}
+
+// writeArchive writes TAR archive with given files to temporary directory and returns path to it.
+func writeArchive(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "image.tar")
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatalf("Failed creating archive: %v", err)
+	}
+
+	tw := tar.NewWriter(f)
+
+	for n, c := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: n, Mode: 0o600, Size: int64(len(c)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed writing header: %v", err)
+		}
+
+		if _, err := tw.Write([]byte(c)); err != nil {
+			t.Fatalf("Failed writing file: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed closing archive: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Failed closing file: %v", err)
+	}
+
+	return p
+}
+
+// writeOCILayout writes OCI image layout with single image to temporary directory
+// and returns path to it.
+func writeOCILayout(t *testing.T, image string) string {
+	t.Helper()
+
+	d := t.TempDir()
+
+	files := map[string]string{
+		"oci-layout":        `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json":        fmt.Sprintf(`{"manifests":[{"digest":"sha256:1234","annotations":{%q:%q}}]}`, ociImageNameAnnotation, image),
+		"blobs/sha256/1234": fmt.Sprintf(`{"config":{"digest":%q}}`, sourceImageID),
+	}
+
+	for n, c := range files {
+		p := filepath.Join(d, filepath.FromSlash(n))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatalf("Failed creating directory: %v", err)
+		}
+
+		if err := ioutil.WriteFile(p, []byte(c), 0o600); err != nil {
+			t.Fatalf("Failed writing file: %v", err)
+		}
+	}
+
+	return d
+}
+
+// fakeContainerCreate mocks successful container creation.
+func fakeContainerCreate(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, networkingConfig *networktypes.NetworkingConfig, containerName string) (containertypes.ContainerCreateCreatedBody, error) {
+	return containertypes.ContainerCreateCreatedBody{ID: "foo"}, nil
+}
+
+// ImageSourceID() tests.
+func TestImageSourceIDDockerArchive(t *testing.T) {
+	id, err := ImageSourceID(writeDockerArchive(t, "foo:latest"), "foo")
+	if err != nil {
+		t.Fatalf("Reading image ID should succeed, got: %v", err)
+	}
+
+	if id != sourceImageID {
+		t.Fatalf("Expected image ID %q, got %q", sourceImageID, id)
+	}
+}
+
+func TestImageSourceIDOCILayout(t *testing.T) {
+	id, err := ImageSourceID(writeOCILayout(t, "foo:latest"), "foo")
+	if err != nil {
+		t.Fatalf("Reading image ID should succeed, got: %v", err)
+	}
+
+	if id != sourceImageID {
+		t.Fatalf("Expected image ID %q, got %q", sourceImageID, id)
+	}
+}
+
+func TestImageSourceIDOCILayoutArchive(t *testing.T) {
+	source := writeArchive(t, map[string]string{
+		"oci-layout":        `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json":        `{"manifests":[{"digest":"sha256:1234"}]}`,
+		"blobs/sha256/1234": fmt.Sprintf(`{"config":{"digest":%q}}`, sourceImageID),
+		"blobs/sha256/5678": strings.Repeat("a", maxMetadataFileSize+1),
+	})
+
+	id, err := ImageSourceID(source, "foo")
+	if err != nil {
+		t.Fatalf("Reading image ID should succeed, got: %v", err)
+	}
+
+	if id != sourceImageID {
+		t.Fatalf("Expected image ID %q, got %q", sourceImageID, id)
+	}
+}
+
+func TestImageSourceIDOCILayoutNestedIndex(t *testing.T) {
+	source := writeArchive(t, map[string]string{
+		"oci-layout":        `{"imageLayoutVersion":"1.0.0"}`,
+		"index.json":        fmt.Sprintf(`{"manifests":[{"mediaType":%q,"digest":"sha256:1234"}]}`, ociImageIndexMediaType),
+		"blobs/sha256/1234": `{"manifests":[{"digest":"sha256:5678"}]}`,
+		"blobs/sha256/5678": fmt.Sprintf(`{"config":{"digest":%q}}`, sourceImageID),
+	})
+
+	id, err := ImageSourceID(source, "foo")
+	if err != nil {
+		t.Fatalf("Reading image ID should succeed, got: %v", err)
+	}
+
+	if id != sourceImageID {
+		t.Fatalf("Expected image ID %q, got %q", sourceImageID, id)
+	}
+}
+
+func TestImageSourceIDOCILayoutMissingLayoutFile(t *testing.T) {
+	source := writeArchive(t, map[string]string{
+		"index.json": `{"manifests":[]}`,
+	})
+
+	if _, err := ImageSourceID(source, "foo"); err == nil {
+		t.Fatalf("Reading image ID from archive without %q should fail", ociLayoutFile)
+	}
+}
+
+func TestImageSourceIDMissing(t *testing.T) {
+	if _, err := ImageSourceID(t.TempDir(), "foo"); err == nil {
+		t.Fatalf("Reading image ID from empty directory should fail")
+	}
+}
+
+// openImageSource() tests.
+func TestOpenImageSourceDirectory(t *testing.T) {
+	r, err := openImageSource(writeOCILayout(t, "foo:latest"))
+	if err != nil {
+		t.Fatalf("Opening image source should succeed, got: %v", err)
+	}
+
+	defer r.Close()
+
+	files := map[string]bool{}
+
+	tr := tar.NewReader(r)
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("Reading archive should succeed, got: %v", err)
+		}
+
+		files[h.Name] = true
+	}
+
+	for _, n := range []string{"oci-layout", "index.json", "blobs/sha256/1234"} {
+		if !files[n] {
+			t.Errorf("Archive should contain file %q", n)
+		}
+	}
+}
+
+// Create() tests.
+func TestCreateLoadImageWhenPullFails(t *testing.T) {
+	loaded := false
+
+	d := &docker{
+		cli: &FakeClient{
+			ContainerCreateF: fakeContainerCreate,
+			ImagePullF: func(ctx context.Context, ref string, options dockertypes.ImagePullOptions) (io.ReadCloser, error) {
+				return nil, fmt.Errorf("registry unreachable")
+			},
+			ImageLoadF: func(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error) {
+				if _, err := io.Copy(ioutil.Discard, input); err != nil {
+					t.Fatalf("Reading image source should succeed, got: %v", err)
+				}
+
+				loaded = true
+
+				return dockertypes.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				if !loaded {
+					return []dockertypes.ImageSummary{}, nil
+				}
+
+				return []dockertypes.ImageSummary{
+					{
+						ID:       sourceImageID,
+						RepoTags: []string{"foo:latest"},
+					},
+				}, nil
+			},
+		},
+	}
+
+	c := &types.ContainerConfig{
+		Image:       "foo",
+		ImageSource: writeDockerArchive(t, "foo:latest"),
+	}
+
+	if _, err := d.Create(context.Background(), c); err != nil {
+		t.Fatalf("Create should succeed, got: %v", err)
+	}
+
+	if !loaded {
+		t.Fatalf("Image should be loaded from the image source")
+	}
+}
+
+func TestCreateSkipLoadImageWhenIDMatches(t *testing.T) {
+	d := &docker{
+		cli: &FakeClient{
+			ContainerCreateF: fakeContainerCreate,
+			ImageLoadF: func(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error) {
+				t.Fatalf("Image should not be loaded, when it's ID matches")
+
+				return dockertypes.ImageLoadResponse{}, nil
+			},
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				return []dockertypes.ImageSummary{
+					{
+						ID:       sourceImageID,
+						RepoTags: []string{"foo:latest"},
+					},
+				}, nil
+			},
+		},
+	}
+
+	c := &types.ContainerConfig{
+		Image:       "foo",
+		ImageSource: writeOCILayout(t, "foo:latest"),
+	}
+
+	if _, err := d.Create(context.Background(), c); err != nil {
+		t.Fatalf("Create should succeed, got: %v", err)
+	}
+}
+
+func TestCreateLoadImageWhenIDDiffers(t *testing.T) {
+	loaded := false
+
+	d := &docker{
+		cli: &FakeClient{
+			ContainerCreateF: fakeContainerCreate,
+			ImageLoadF: func(ctx context.Context, input io.Reader, quiet bool) (dockertypes.ImageLoadResponse, error) {
+				loaded = true
+
+				return dockertypes.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+			ImageListF: func(ctx context.Context, options dockertypes.ImageListOptions) ([]dockertypes.ImageSummary, error) {
+				return []dockertypes.ImageSummary{
+					{
+						ID:       defaultImageID,
+						RepoTags: []string{"foo:latest"},
+					},
+				}, nil
+			},
+		},
+	}
+
+	c := &types.ContainerConfig{
+		Image:           "foo",
+		ImagePullPolicy: types.PullNever,
+		ImageSource:     writeDockerArchive(t, "foo:latest"),
+	}
+
+	if _, err := d.Create(context.Background(), c); err != nil {
+		t.Fatalf("Create should succeed, got: %v", err)
+	}
+
+	if !loaded {
+		t.Fatalf("Image should be loaded from the image source, when image ID differs")
+	}
+}
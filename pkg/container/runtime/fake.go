@@ -1,13 +1,15 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/flexkube/libflexkube/pkg/container/types"
 )
 
-// Fake is a fake runtime client, which can be used for testing.
+// Fake is a fake runtime client, which can be used for testing. Context passed
+// to the methods is not passed to the mocked functions.
 type Fake struct {
 	// CreateF will be Create by method.
 	CreateF func(config *types.ContainerConfig) (string, error)
@@ -32,48 +34,72 @@ type Fake struct {
 
 	// StatF will be called by Stat method.
 	StatF func(id string, paths []string) (map[string]os.FileMode, error)
+
+	// ImageIDF will be called by ImageID method.
+	ImageIDF func(image string) (string, error)
+
+	// RegistryDigestF will be called by RegistryDigest method.
+	RegistryDigestF func(image string) (string, error)
+
+	// InfoF will be called by Info method.
+	InfoF func() (types.RuntimeInfo, error)
 }
 
 // Create mocks runtime Create().
-func (f Fake) Create(config *types.ContainerConfig) (string, error) {
+func (f Fake) Create(ctx context.Context, config *types.ContainerConfig) (string, error) {
 	return f.CreateF(config)
 }
 
 // Delete mocks runtime Delete().
-func (f Fake) Delete(id string) error {
+func (f Fake) Delete(ctx context.Context, id string) error {
 	return f.DeleteF(id)
 }
 
 // Start mocks runtime Start().
-func (f Fake) Start(id string) error {
+func (f Fake) Start(ctx context.Context, id string) error {
 	return f.StartF(id)
 }
 
 // Status mocks runtime Status().
-func (f Fake) Status(id string) (types.ContainerStatus, error) {
+func (f Fake) Status(ctx context.Context, id string) (types.ContainerStatus, error) {
 	return f.StatusF(id)
 }
 
 // Stop mocks runtime Stop().
-func (f Fake) Stop(id string) error {
+func (f Fake) Stop(ctx context.Context, id string) error {
 	return f.StopF(id)
 }
 
 // Copy mocks runtime Copy().
-func (f Fake) Copy(id string, files []*types.File) error {
+func (f Fake) Copy(ctx context.Context, id string, files []*types.File) error {
 	return f.CopyF(id, files)
 }
 
 // Read mocks runtime Read().
-func (f Fake) Read(id string, srcPath []string) ([]*types.File, error) {
+func (f Fake) Read(ctx context.Context, id string, srcPath []string) ([]*types.File, error) {
 	return f.ReadF(id, srcPath)
 }
 
 // Stat mocks runtime Stat().
-func (f Fake) Stat(id string, paths []string) (map[string]os.FileMode, error) {
+func (f Fake) Stat(ctx context.Context, id string, paths []string) (map[string]os.FileMode, error) {
 	return f.StatF(id, paths)
 }
 
+// ImageID mocks runtime ImageID().
+func (f Fake) ImageID(ctx context.Context, image string) (string, error) {
+	return f.ImageIDF(image)
+}
+
+// RegistryDigest mocks runtime RegistryDigest().
+func (f Fake) RegistryDigest(ctx context.Context, image string) (string, error) {
+	return f.RegistryDigestF(image)
+}
+
+// Info mocks runtime Info().
+func (f Fake) Info(ctx context.Context) (types.RuntimeInfo, error) {
+	return f.InfoF()
+}
+
 // FakeConfig is a Fake runtime configuration struct.
 type FakeConfig struct {
 	// Runtime holds container runtime to return by New() method.
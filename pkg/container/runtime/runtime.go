@@ -3,6 +3,7 @@
 package runtime
 
 import (
+	"context"
 	"os"
 
 	"github.com/flexkube/libflexkube/pkg/container/types"
@@ -10,35 +11,50 @@ import (
 
 // Runtime interface describes universal way of managing containers
 // across different container runtimes.
+//
+// All methods take context, which allows to cancel long running operations, like
+// pulling the images.
 type Runtime interface {
 	// Create creates container and returns it's unique identifier.
-	Create(config *types.ContainerConfig) (string, error)
+	Create(ctx context.Context, config *types.ContainerConfig) (string, error)
 
 	// Delete removes the container.
-	Delete(ID string) error
+	Delete(ctx context.Context, ID string) error
 
 	// Start starts created container.
-	Start(ID string) error
+	Start(ctx context.Context, ID string) error
 
 	// Status returns status of the container.
-	Status(ID string) (types.ContainerStatus, error)
+	Status(ctx context.Context, ID string) (types.ContainerStatus, error)
 
 	// Stop takes unique identifier as a parameter and stops the container.
-	Stop(ID string) error
+	Stop(ctx context.Context, ID string) error
 
 	// Copy allows to copy TAR archive into the container.
 	//
 	// Docker currently does not allow to copy multiple files over https://github.com/moby/moby/issues/7710
 	// It seems kubelet does https://github.com/kubernetes/kubernetes/pull/72641/files
-	Copy(ID string, files []*types.File) error
+	Copy(ctx context.Context, ID string, files []*types.File) error
 
 	// Read allows to read file in TAR archive format from container.
 	//
 	// TODO check if we should return some information about read file
-	Read(ID string, srcPath []string) ([]*types.File, error)
+	Read(ctx context.Context, ID string, srcPath []string) ([]*types.File, error)
 
 	// Stat returns os.FileMode for requested files from inside the container.
-	Stat(ID string, paths []string) (map[string]os.FileMode, error)
+	Stat(ctx context.Context, ID string, paths []string) (map[string]os.FileMode, error)
+
+	// ImageID returns runtime specific digest of the given image present on the host.
+	// If image is not present, empty string is returned.
+	ImageID(ctx context.Context, image string) (string, error)
+
+	// RegistryDigest returns digest of the given image currently stored in the registry,
+	// without pulling the image.
+	RegistryDigest(ctx context.Context, image string) (string, error)
+
+	// Info returns information about the container runtime. If runtime version is
+	// not supported, error should be returned.
+	Info(ctx context.Context) (types.RuntimeInfo, error)
 }
 
 // Config defines interface for runtime configuration. Since some feature are generic to runtime,
@@ -2,6 +2,20 @@
 // to avoid cyclic dependencies while importing.
 package types
 
+const (
+	// PullIfNotPresent is an image pull policy, which pulls the image only if it's
+	// not present on the host. This is a default pull policy.
+	PullIfNotPresent = "IfNotPresent"
+
+	// PullAlways is an image pull policy, which always pulls the image before creating
+	// the container, so re-pushed tags are picked up.
+	PullAlways = "Always"
+
+	// PullNever is an image pull policy, which never pulls the image. Creating the
+	// container fails, if the image is not present on the host.
+	PullNever = "Never"
+)
+
 // ContainerConfig stores runtime-agnostic information how to run the container.
 type ContainerConfig struct {
 	// Name is a name of the container.
@@ -10,6 +24,19 @@ type ContainerConfig struct {
 	// Image is a container image to use.
 	Image string `json:"image"`
 
+	// ImagePullPolicy controls, when the image should be pulled. Valid values are
+	// 'IfNotPresent', 'Always' and 'Never'. If empty, 'IfNotPresent' is used.
+	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
+
+	// ImageSource is an optional path on the local filesystem to either TAR archive
+	// created using 'docker save' or to the directory with OCI image layout, which
+	// contains the image. It allows deploying to hosts without access to the image registry.
+	//
+	// If set and image cannot be pulled, or the image present on the host differs from the
+	// one in the source, image will be loaded from it. Unless 'Always' image pull policy is
+	// used, container is re-created, when the image in the source changes.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// Args is a list of arguments to pass to the container.
 	Args []string `json:"args,omitempty"`
 
@@ -61,6 +88,40 @@ type ContainerStatus struct {
 
 	// Status is a runtime specific status string.
 	Status string `json:"status,omitempty"`
+
+	// ImageID is a runtime specific digest of the image, which container has been
+	// created from. It allows to detect, when image with the same name has changed.
+	//
+	// Example value: 'sha256:e4d4f34a2d2b6a7c2b58b5b4d5c1d1f0...'.
+	ImageID string `json:"imageID,omitempty"`
+
+	// RepoDigests is a list of registry digests of the image, which container has been
+	// created from. It allows to detect, when the tag has been re-pushed to the registry.
+	//
+	// Example element: 'quay.io/coreos/etcd@sha256:e4d4f34a2d2b6a7c2b58b5b4d5c1d1f0...'.
+	RepoDigests []string `json:"repoDigests,omitempty"`
+}
+
+// RuntimeInfo stores information about the container runtime.
+type RuntimeInfo struct {
+	// Name is a name of the container runtime engine.
+	//
+	// Example value: 'docker'.
+	Name string `json:"name,omitempty"`
+
+	// Version is a version of the container runtime engine.
+	Version string `json:"version,omitempty"`
+
+	// APIVersion is a version of the runtime API used for communication.
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// CgroupDriver is a cgroup driver used by the runtime, either 'cgroupfs' or 'systemd'.
+	CgroupDriver string `json:"cgroupDriver,omitempty"`
+
+	// StorageDriver is a storage driver used by the runtime.
+	//
+	// Example value: 'overlay2'.
+	StorageDriver string `json:"storageDriver,omitempty"`
 }
 
 // PortMap is basically a github.com/docker/go-connections/nat.PortMap.
@@ -3,6 +3,7 @@
 package controlplane
 
 import (
+	"context"
 	"fmt"
 
 	"sigs.k8s.io/yaml"
@@ -11,12 +12,21 @@ import (
 	"github.com/flexkube/libflexkube/pkg/container"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
+const (
+	// kubeControllerManagerPort is a default secure port of kube-controller-manager.
+	kubeControllerManagerPort = 10257
+
+	// kubeSchedulerPort is a default secure port of kube-scheduler.
+	kubeSchedulerPort = 10259
+)
+
 // Common struct contains fields, which are common between all controlplane components.
 type Common struct {
 	// Image allows to set Docker image with tag, which will be used by all controlplane containers,
@@ -28,6 +38,14 @@ type Common struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by controlplane containers. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// As all controlplane containers share it, it should contain images of all components.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// KubernetesCACertificate stores Kubernetes X.509 CA certificate, PEM encoded.
 	//
 	// This field is optional.
@@ -89,14 +107,15 @@ type Controlplane struct {
 // controlplane is executable version of Controlplane, with validated fields and calculated containers.
 type controlplane struct {
 	containers container.ContainersInterface
+	securePort int
 }
 
 // propagateKubeconfig merges given client config with values stored in Controlplane.
 // Values in given config has priority over ones from the Controlplane.
 func (c *Controlplane) propagateKubeconfig(d *client.Config) {
 	pkiCA := types.Certificate("")
-	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.CA != nil {
-		pkiCA = c.PKI.Kubernetes.CA.X509Certificate
+	if c.PKI != nil {
+		pkiCA = c.PKI.TrustBundle(pki.KubernetesCAName)
 	}
 
 	d.CACertificate = d.CACertificate.Pick(c.Common.KubernetesCACertificate, pkiCA)
@@ -108,16 +127,19 @@ func (c *Controlplane) propagateKubeconfig(d *client.Config) {
 
 // propagateHost merges given host configuration with values stored in Controlplane.
 // Values in given host config has priority over ones from the Controlplane.
-func (c *Controlplane) propagateHost(h *host.Host) *host.Host {
+func (c *Controlplane) propagateHost(h *host.Host) (*host.Host, error) {
 	if h == nil {
 		h = &host.Host{}
 	}
 
-	nh := host.BuildConfig(*h, host.Host{
+	nh, err := host.BuildConfig(*h, host.Host{
 		SSHConfig: c.SSH,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("building host configuration: %w", err)
+	}
 
-	return &nh
+	return &nh, nil
 }
 
 // propagateCommon merges given common configuration with values stored in Controlplane.
@@ -132,15 +154,15 @@ func (c *Controlplane) propagateCommon(co *Common) {
 	}
 
 	co.Image = util.PickString(co.Image, c.Common.Image)
+	co.ImageSource = util.PickString(co.ImageSource, c.Common.ImageSource)
 
 	var pkiCA types.Certificate
-	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.CA != nil {
-		pkiCA = c.PKI.Kubernetes.CA.X509Certificate
-	}
 
 	var frontProxyCA types.Certificate
-	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.FrontProxyCA != nil {
-		frontProxyCA = c.PKI.Kubernetes.FrontProxyCA.X509Certificate
+
+	if c.PKI != nil {
+		pkiCA = c.PKI.TrustBundle(pki.KubernetesCAName)
+		frontProxyCA = c.PKI.TrustBundle(pki.KubernetesFrontProxyCAName)
 	}
 
 	co.KubernetesCACertificate = co.KubernetesCACertificate.Pick(c.Common.KubernetesCACertificate, pkiCA)
@@ -148,7 +170,7 @@ func (c *Controlplane) propagateCommon(co *Common) {
 }
 
 // buildKubeScheduler fills KubeSheduler struct with all default values.
-func (c *Controlplane) buildKubeScheduler() {
+func (c *Controlplane) buildKubeScheduler() error {
 	k := &c.KubeScheduler
 
 	c.propagateKubeconfig(&k.Kubeconfig)
@@ -157,15 +179,22 @@ func (c *Controlplane) buildKubeScheduler() {
 
 	// TODO: can be moved to function, which takes Kubeconfig and *pki.Certificate as an input
 	if c.PKI != nil && c.PKI.Kubernetes != nil && c.PKI.Kubernetes.KubeSchedulerCertificate != nil {
-		k.Kubeconfig.ClientCertificate = k.Kubeconfig.ClientCertificate.Pick(c.PKI.Kubernetes.KubeSchedulerCertificate.X509Certificate)
+		k.Kubeconfig.ClientCertificate = k.Kubeconfig.ClientCertificate.Pick(c.PKI.CertificateChain(c.PKI.Kubernetes.KubeSchedulerCertificate, pki.KubernetesCAName))
 		k.Kubeconfig.ClientKey = k.Kubeconfig.ClientKey.Pick(c.PKI.Kubernetes.KubeSchedulerCertificate.PrivateKey)
 	}
 
-	k.Host = c.propagateHost(k.Host)
+	h, err := c.propagateHost(k.Host)
+	if err != nil {
+		return err
+	}
+
+	k.Host = h
+
+	return nil
 }
 
 // buildKubeControllerManager fills KubeControllerManager with all default values.
-func (c *Controlplane) buildKubeControllerManager() {
+func (c *Controlplane) buildKubeControllerManager() error {
 	k := &c.KubeControllerManager
 
 	c.propagateKubeconfig(&k.Kubeconfig)
@@ -174,7 +203,7 @@ func (c *Controlplane) buildKubeControllerManager() {
 
 	if c.PKI != nil && c.PKI.Kubernetes != nil {
 		if c.PKI.Kubernetes.KubeControllerManagerCertificate != nil {
-			k.Kubeconfig.ClientCertificate = k.Kubeconfig.ClientCertificate.Pick(c.PKI.Kubernetes.KubeControllerManagerCertificate.X509Certificate)
+			k.Kubeconfig.ClientCertificate = k.Kubeconfig.ClientCertificate.Pick(c.PKI.CertificateChain(c.PKI.Kubernetes.KubeControllerManagerCertificate, pki.KubernetesCAName))
 			k.Kubeconfig.ClientKey = k.Kubeconfig.ClientKey.Pick(c.PKI.Kubernetes.KubeControllerManagerCertificate.PrivateKey)
 		}
 
@@ -182,18 +211,23 @@ func (c *Controlplane) buildKubeControllerManager() {
 			k.KubernetesCAKey = k.KubernetesCAKey.Pick(c.PKI.Kubernetes.CA.PrivateKey)
 		}
 
-		if c.PKI.RootCA != nil {
-			k.RootCACertificate = k.RootCACertificate.Pick(c.PKI.RootCA.X509Certificate)
-		}
+		k.RootCACertificate = k.RootCACertificate.Pick(c.PKI.TrustBundle(pki.RootCAName))
 
 		if c.PKI.Kubernetes.ServiceAccountCertificate != nil {
 			k.ServiceAccountPrivateKey = k.ServiceAccountPrivateKey.Pick(c.PKI.Kubernetes.ServiceAccountCertificate.PrivateKey)
 		}
 	}
 
-	k.Host = c.propagateHost(k.Host)
-
 	k.FlexVolumePluginDir = util.PickString(k.FlexVolumePluginDir, defaults.VolumePluginDir)
+
+	h, err := c.propagateHost(k.Host)
+	if err != nil {
+		return err
+	}
+
+	k.Host = h
+
+	return nil
 }
 
 // kubeAPIServerPKIIntegration injects missing certificates and keys from PKI object
@@ -206,9 +240,7 @@ func (c *Controlplane) kubeAPIServerPKIIntegration() {
 	k := &c.KubeAPIServer
 
 	if p := c.PKI.Etcd; p != nil {
-		if p.CA != nil {
-			k.EtcdCACertificate = k.EtcdCACertificate.Pick(p.CA.X509Certificate)
-		}
+		k.EtcdCACertificate = k.EtcdCACertificate.Pick(c.PKI.TrustBundle(pki.EtcdCAName))
 
 		// "root" and "kube-apiserver" are common CNs for etcd client certificate for kube-apiserver.
 		for _, cn := range []string{"root", "kube-apiserver"} {
@@ -223,18 +255,16 @@ func (c *Controlplane) kubeAPIServerPKIIntegration() {
 		return
 	}
 
-	if p := c.PKI.Kubernetes.ServiceAccountCertificate; p != nil {
-		k.ServiceAccountPublicKey = util.PickString(k.ServiceAccountPublicKey, p.PublicKey)
-	}
+	k.ServiceAccountPublicKey = util.PickString(k.ServiceAccountPublicKey, c.PKI.ServiceAccountVerificationKeys())
 
 	p := c.PKI.Kubernetes.KubeAPIServer
 	if p == nil {
 		return
 	}
 
-	if c := p.ServerCertificate; c != nil {
-		k.APIServerCertificate = k.APIServerCertificate.Pick(c.X509Certificate)
-		k.APIServerKey = k.APIServerKey.Pick(c.PrivateKey)
+	if sc := p.ServerCertificate; sc != nil {
+		k.APIServerCertificate = k.APIServerCertificate.Pick(c.PKI.CertificateChain(sc, pki.KubernetesCAName))
+		k.APIServerKey = k.APIServerKey.Pick(sc.PrivateKey)
 	}
 
 	if c := p.FrontProxyClientCertificate; c != nil {
@@ -249,7 +279,7 @@ func (c *Controlplane) kubeAPIServerPKIIntegration() {
 }
 
 // buildKubeAPIServer fills KubeAPIServer with all default values.
-func (c *Controlplane) buildKubeAPIServer() {
+func (c *Controlplane) buildKubeAPIServer() error {
 	k := &c.KubeAPIServer
 
 	if k.BindAddress == "" && c.APIServerAddress != "" {
@@ -268,7 +298,14 @@ func (c *Controlplane) buildKubeAPIServer() {
 
 	c.kubeAPIServerPKIIntegration()
 
-	k.Host = c.propagateHost(k.Host)
+	h, err := c.propagateHost(k.Host)
+	if err != nil {
+		return err
+	}
+
+	k.Host = h
+
+	return nil
 }
 
 // New validates Controlplane configuration and fills populates all values provided by the users
@@ -285,8 +322,8 @@ func (c *Controlplane) New() (types.Resource, error) {
 		return controlplane, nil
 	}
 
-	// Make sure all values are filled.
-	c.buildComponents()
+	// Make sure all values are filled. Validate already checks for errors, so we can skip checking here.
+	_ = c.buildComponents()
 
 	// Skip error checking, as it's done in Verify().
 	kas, _ := c.KubeAPIServer.New()
@@ -307,16 +344,29 @@ func (c *Controlplane) New() (types.Resource, error) {
 	co, _ := cc.New()
 
 	controlplane.containers = co
+	controlplane.securePort = c.KubeAPIServer.SecurePort
 
 	return controlplane, nil
 }
 
 // buildComponents fills controlplane component structs with default values inherited
 // from controlplane struct.
-func (c *Controlplane) buildComponents() {
-	c.buildKubeAPIServer()
-	c.buildKubeControllerManager()
-	c.buildKubeScheduler()
+func (c *Controlplane) buildComponents() error {
+	var errors util.ValidateError
+
+	if err := c.buildKubeAPIServer(); err != nil {
+		errors = append(errors, fmt.Errorf("building kube-apiserver configuration: %w", err))
+	}
+
+	if err := c.buildKubeControllerManager(); err != nil {
+		errors = append(errors, fmt.Errorf("building kube-controller-manager configuration: %w", err))
+	}
+
+	if err := c.buildKubeScheduler(); err != nil {
+		errors = append(errors, fmt.Errorf("building kube-scheduler configuration: %w", err))
+	}
+
+	return errors.Return()
 }
 
 func (c *Controlplane) containersWithState() (*controlplane, *container.Containers, error) {
@@ -365,10 +415,12 @@ func validateControlplaneComponent(ccc controlplaneComponentConfiguration, name
 
 // Validate validates Controlplane configuration.
 func (c *Controlplane) Validate() error {
-	c.buildComponents()
-
 	var errors util.ValidateError
 
+	if err := c.buildComponents(); err != nil {
+		errors = append(errors, fmt.Errorf("building components configuration: %w", err))
+	}
+
 	if c.Destroy && (c.State == nil || len(*c.State) == 0) {
 		errors = append(errors, fmt.Errorf("can't destroy non-existent controlplane"))
 	}
@@ -426,16 +478,40 @@ func (c *controlplane) StateToYaml() ([]byte, error) {
 	return yaml.Marshal(Controlplane{State: &c.containers.ToExported().PreviousState})
 }
 
-func (c *controlplane) CheckCurrentState() error {
-	return c.containers.CheckCurrentState()
+func (c *controlplane) CheckCurrentState(ctx context.Context) error {
+	return c.containers.CheckCurrentState(ctx)
 }
 
 // Deploy checks the status of the control plane and deploys configuration updates.
-func (c *controlplane) Deploy() error {
-	return c.containers.Deploy()
+func (c *controlplane) Deploy(ctx context.Context) error {
+	return c.containers.Deploy(ctx)
 }
 
 // Containers implement types.Resource interface.
 func (c *controlplane) Containers() container.ContainersInterface {
 	return c.containers
 }
+
+// PreflightChecks implements types.PreflightChecker interface.
+//
+// Controlplane components use certificates, so clocks must be synchronized. For new
+// components, ports they listen on must be free.
+func (c *controlplane) PreflightChecks() []preflight.HostChecks {
+	ports := map[string]int{
+		"kube-apiserver":          c.securePort,
+		"kube-controller-manager": kubeControllerManagerPort,
+		"kube-scheduler":          kubeSchedulerPort,
+	}
+
+	return container.PreflightChecks(c.containers, func(name string, isNew bool) []preflight.Check {
+		checks := []preflight.Check{
+			preflight.TimeSync(preflight.DefaultMaxClockSkew),
+		}
+
+		if port, ok := ports[name]; ok && isNew && port != 0 {
+			checks = append(checks, preflight.PortFree(port))
+		}
+
+		return checks
+	})
+}
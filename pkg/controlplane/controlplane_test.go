@@ -2,6 +2,7 @@ package controlplane
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"testing"
 	"text/template"
@@ -111,11 +112,11 @@ func TestControlplaneFromYaml(t *testing.T) {
 		t.Fatalf("Dumping state to YAML should work, got: %v", err)
 	}
 
-	if err := co.CheckCurrentState(); err != nil {
+	if err := co.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("Checking current state of empty controlplane should work, got: %v", err)
 	}
 
-	if err := co.Deploy(); err == nil {
+	if err := co.Deploy(context.Background()); err == nil {
 		t.Fatalf("Deploying in testing environment should fail")
 	}
 }
@@ -200,3 +201,31 @@ func TestControlplaneNewPKIIntegration(t *testing.T) {
 		t.Fatalf("creating new controlplane with valid PKI should succeed, got: %v", err)
 	}
 }
+
+// PreflightChecks() tests.
+func TestControlplanePreflightChecks(t *testing.T) {
+	co, err := FromYaml([]byte(controlplaneYAML(t)))
+	if err != nil {
+		t.Fatalf("Creating controlplane from YAML should succeed, got: %v", err)
+	}
+
+	hc := co.(*controlplane).PreflightChecks()
+
+	if len(hc) != 3 {
+		t.Fatalf("Expected checks for 3 components, got %d", len(hc))
+	}
+
+	expected := map[string]string{
+		"kube-apiserver":          "TCP port 6443 is free",
+		"kube-controller-manager": "TCP port 10257 is free",
+		"kube-scheduler":          "TCP port 10259 is free",
+	}
+
+	for _, h := range hc {
+		last := h.Checks[len(h.Checks)-1]
+
+		if e := expected[h.Target]; last.Name != e {
+			t.Errorf("Expected check %q for %q, got %q", e, h.Target, last.Name)
+		}
+	}
+}
@@ -31,7 +31,8 @@ type KubeAPIServer struct {
 	APIServerKey types.PrivateKey `json:"apiServerKey"`
 
 	// ServiceAccountPublicKey stores PEM encoded public certificate, which will be used
-	// to validate service account tokens.
+	// to validate service account tokens. It may contain multiple public keys, e.g. during
+	// service account key rotation.
 	ServiceAccountPublicKey string `json:"serviceAccountPublicKey"`
 
 	// BindAddress defines IP address where kube-apiserver process should listen for
@@ -230,6 +231,7 @@ func (k *kubeAPIServer) ToHostConfiguredContainer() (*container.HostConfiguredCo
 			Config: containertypes.ContainerConfig{
 				Name:        containerName,
 				Image:       util.PickString(k.common.Image, defaults.KubeAPIServerImage),
+				ImageSource: k.common.ImageSource,
 				NetworkMode: "host",
 				Mounts: []containertypes.Mount{
 					{
@@ -115,8 +115,9 @@ func (k *kubeControllerManager) ToHostConfiguredContainer() (*container.HostConf
 			Docker: docker.DefaultConfig(),
 		},
 		Config: containertypes.ContainerConfig{
-			Name:  "kube-controller-manager",
-			Image: util.PickString(k.common.Image, defaults.KubeControllerManagerImage),
+			Name:        "kube-controller-manager",
+			Image:       util.PickString(k.common.Image, defaults.KubeControllerManagerImage),
+			ImageSource: k.common.ImageSource,
 			Mounts: []containertypes.Mount{
 				{
 					Source: "/etc/kubernetes/kube-controller-manager/",
@@ -51,8 +51,9 @@ clientConnection:
 			Docker: docker.DefaultConfig(),
 		},
 		Config: containertypes.ContainerConfig{
-			Name:  "kube-scheduler",
-			Image: util.PickString(k.common.Image, defaults.KubeSchedulerImage),
+			Name:        "kube-scheduler",
+			Image:       util.PickString(k.common.Image, defaults.KubeSchedulerImage),
+			ImageSource: k.common.ImageSource,
 			Mounts: []containertypes.Mount{
 				{
 					Source: "/etc/kubernetes/kube-scheduler/",
@@ -24,7 +24,12 @@ const (
 	// HAProxyImage is a default container image for APILoadBalancer.
 	HAProxyImage = "haproxy:2.2.4-alpine"
 
-	// DockerAPIVersion is a default API version used when talking to Docker runtime.
+	// RelayImage is a default container image for helper pods used by Kubernetes transport.
+	// It must provide 'socat' and 'sh' binaries.
+	RelayImage = "alpine/socat:1.7.3.4-r0"
+
+	// DockerAPIVersion is a minimal API version supported when talking to Docker runtime.
+	// The API version used is negotiated with the Docker daemon.
 	DockerAPIVersion = "v1.38"
 
 	// VolumePluginDir is a default flex volume plugin directory configured for kubelet
@@ -16,6 +16,7 @@ import (
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
@@ -24,6 +25,17 @@ import (
 // defaultDialTimeout is default timeout value for etcd client.
 const defaultDialTimeout = 5 * time.Second
 
+const (
+	// clientPort is a port, where etcd listens for client connections.
+	clientPort = 2379
+
+	// peerPort is a port, where etcd listens for peer connections.
+	peerPort = 2380
+
+	// minDataDirFreeSpace is a minimum free disk space in bytes required for etcd data directory.
+	minDataDirFreeSpace = 1 << 30
+)
+
 // Cluster represents etcd cluster configuration and state from the user.
 //
 // It implements types.ResourceConfig interface and via types.Resource interface
@@ -39,6 +51,14 @@ type Cluster struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by members. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// If specified, this value will be used for all members, which do not have it defined.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// SSH stores common SSH configuration for all members and will be merged with members
 	// SSH configuration. If member has some SSH fields defined, they take precedence over
 	// this block.
@@ -49,6 +69,18 @@ type Cluster struct {
 	// This field is optional.
 	SSH *ssh.Config `json:"ssh,omitempty"`
 
+	// Host stores common host configuration for all members and will be merged with members
+	// host configuration, e.g. to run all members locally using direct transport.
+	//
+	// This field is optional and it is mutually exclusive with SSH field.
+	Host *host.Host `json:"host,omitempty"`
+
+	// Runtime stores common container runtime configuration for all members, e.g. Docker
+	// address or TLS certificates. It will be merged with members runtime configuration.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// CACertificate should contain etcd CA X.509 certificate in PEM format. It will be added
 	// to members configuration if they don't have it defined.
 	//
@@ -97,7 +129,7 @@ type cluster struct {
 }
 
 // propagateMember fills given Member's empty fields with fields from Cluster.
-func (c *Cluster) propagateMember(i string, m *Member) {
+func (c *Cluster) propagateMember(i string, m *Member) error {
 	initialClusterArr := []string{}
 	peerCertAllowedCNArr := []string{}
 
@@ -114,19 +146,16 @@ func (c *Cluster) propagateMember(i string, m *Member) {
 
 	m.Name = util.PickString(m.Name, i)
 	m.Image = util.PickString(m.Image, c.Image, defaults.EtcdImage)
+	m.ImageSource = util.PickString(m.ImageSource, c.ImageSource)
 	m.InitialCluster = util.PickString(m.InitialCluster, strings.Join(initialClusterArr, ","))
 	m.PeerCertAllowedCN = util.PickString(m.PeerCertAllowedCN, c.PeerCertAllowedCN)
 	m.CACertificate = util.PickString(m.CACertificate, c.CACertificate)
 
-	if len(m.ExtraMounts) == 0 {
-		m.ExtraMounts = c.ExtraMounts
-	}
-
 	// PKI integration.
 	if c.PKI != nil && c.PKI.Etcd != nil {
 		e := c.PKI.Etcd
 
-		m.CACertificate = util.PickString(m.CACertificate, c.CACertificate, string(e.CA.X509Certificate))
+		m.CACertificate = util.PickString(m.CACertificate, c.CACertificate, string(c.PKI.TrustBundle(pki.EtcdCAName)))
 
 		if c, ok := e.PeerCertificates[m.Name]; ok {
 			m.PeerCertificate = util.PickString(m.PeerCertificate, string(c.X509Certificate))
@@ -141,13 +170,25 @@ func (c *Cluster) propagateMember(i string, m *Member) {
 
 	m.ServerAddress = util.PickString(m.ServerAddress, m.PeerAddress)
 
-	m.Host = host.BuildConfig(m.Host, host.Host{
-		SSHConfig: c.SSH,
-	})
+	d := container.PoolDefaults(c.Host, c.SSH, c.Runtime)
+	d.ExtraMounts = c.ExtraMounts
+
+	ic, err := container.BuildInstanceConfig(container.InstanceConfig{
+		Host:        m.Host,
+		Runtime:     m.Runtime,
+		ExtraMounts: m.ExtraMounts,
+	}, d)
+	if err != nil {
+		return fmt.Errorf("building instance configuration: %w", err)
+	}
+
+	m.Host, m.Runtime, m.ExtraMounts = ic.Host, ic.Runtime, ic.ExtraMounts
 
 	if len(c.State) == 0 {
 		m.NewCluster = true
 	}
+
+	return nil
 }
 
 // New validates etcd cluster configuration and fills members with default and computed values.
@@ -167,7 +208,9 @@ func (c *Cluster) New() (types.Resource, error) {
 
 	for n, m := range c.Members {
 		m := m
-		c.propagateMember(n, &m)
+
+		// Validate already checks for errors, so we can skip checking here.
+		_ = c.propagateMember(n, &m)
 
 		mem, _ := m.New()
 		hcc, _ := mem.ToHostConfiguredContainer()
@@ -192,6 +235,10 @@ func (c *Cluster) Validate() error {
 
 	var errors util.ValidateError
 
+	if err := container.ValidatePoolDefaults(c.Host, c.SSH); err != nil {
+		errors = append(errors, fmt.Errorf("validating defaults: %w", err))
+	}
+
 	if c.CACertificate != "" {
 		caCert := &pki.Certificate{
 			X509Certificate: types.Certificate(c.CACertificate),
@@ -209,7 +256,12 @@ func (c *Cluster) Validate() error {
 
 	for n, m := range c.Members {
 		m := m
-		c.propagateMember(n, &m)
+
+		if err := c.propagateMember(n, &m); err != nil {
+			errors = append(errors, fmt.Errorf("failed to build member '%s' configuration: %w", n, err))
+
+			continue
+		}
 
 		mem, err := m.New()
 		if err != nil {
@@ -246,8 +298,8 @@ func (c *cluster) StateToYaml() ([]byte, error) {
 }
 
 // CheckCurrentState refreshes current state of the cluster.
-func (c *cluster) CheckCurrentState() error {
-	if err := c.containers.CheckCurrentState(); err != nil {
+func (c *cluster) CheckCurrentState(ctx context.Context) error {
+	if err := c.containers.CheckCurrentState(ctx); err != nil {
 		return fmt.Errorf("failed checking current state of etcd cluster: %w", err)
 	}
 
@@ -285,13 +337,13 @@ func (c *cluster) firstMember() (*member, error) {
 	return m, nil
 }
 
-func (c *cluster) getClient() (etcdClient, error) {
+func (c *cluster) getClient(ctx context.Context, p *host.ConnectionPool) (etcdClient, error) {
 	m, err := c.firstMember()
 	if err != nil {
 		return nil, fmt.Errorf("failed getting member object: %w", err)
 	}
 
-	endpoints, err := m.forwardEndpoints(c.getExistingEndpoints())
+	endpoints, err := m.forwardEndpoints(ctx, p, c.getExistingEndpoints())
 	if err != nil {
 		return nil, fmt.Errorf("failed forwarding endpoints: %w", err)
 	}
@@ -335,7 +387,7 @@ func (c *cluster) membersToAdd() []string {
 }
 
 // updateMembers adds and remove members from the cluster according to the configuration.
-func (c *cluster) updateMembers(cli etcdClient) error {
+func (c *cluster) updateMembers(ctx context.Context, cli etcdClient) error {
 	for _, name := range c.membersToRemove() {
 		m := &member{
 			config: &Member{
@@ -343,13 +395,13 @@ func (c *cluster) updateMembers(cli etcdClient) error {
 			},
 		}
 
-		if err := m.remove(cli); err != nil {
+		if err := m.remove(ctx, cli); err != nil {
 			return fmt.Errorf("failed removing member: %w", err)
 		}
 	}
 
 	for _, m := range c.membersToAdd() {
-		if err := c.members[m].add(cli); err != nil {
+		if err := c.members[m].add(ctx, cli); err != nil {
 			return fmt.Errorf("failed adding member: %w", err)
 		}
 	}
@@ -358,18 +410,29 @@ func (c *cluster) updateMembers(cli etcdClient) error {
 }
 
 // Deploy refreshes current state of the cluster and deploys detected changes.
-func (c *cluster) Deploy() error {
+func (c *cluster) Deploy(ctx context.Context) (err error) {
 	e := c.containers.ToExported()
 
 	// If we create new cluster or destroy entire cluster, just start deploying.
 	if len(e.PreviousState) != 0 && len(e.DesiredState) != 0 {
+		// Reuse connections of the containers, so each member is only dialed once. Connections
+		// are closed when deploying the containers finishes, but make sure they are also closed,
+		// if updating members fails.
+		p := c.containers.Connections()
+
+		defer func() {
+			if cerr := p.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("closing connections to members: %w", cerr)
+			}
+		}()
+
 		// Build client, so we can pass it around.
-		cli, err := c.getClient()
+		cli, err := c.getClient(ctx, p)
 		if err != nil {
 			return fmt.Errorf("failed getting etcd client: %w", err)
 		}
 
-		if err := c.updateMembers(cli); err != nil {
+		if err := c.updateMembers(ctx, cli); err != nil {
 			return fmt.Errorf("failed to update members before deploying: %w", err)
 		}
 
@@ -378,10 +441,32 @@ func (c *cluster) Deploy() error {
 		}
 	}
 
-	return c.containers.Deploy()
+	return c.containers.Deploy(ctx)
 }
 
 // Containers implement types.Resource interface.
 func (c *cluster) Containers() container.ContainersInterface {
 	return c.containers
 }
+
+// PreflightChecks implements types.PreflightChecker interface.
+//
+// Members are checked to have enough disk space for the data directory and synchronized
+// clocks, as certificates are used for authentication. For new members, etcd ports must be free.
+func (c *cluster) PreflightChecks() []preflight.HostChecks {
+	return container.PreflightChecks(c.containers, func(name string, isNew bool) []preflight.Check {
+		checks := []preflight.Check{
+			preflight.TimeSync(preflight.DefaultMaxClockSkew),
+		}
+
+		if m, ok := c.members[name]; ok {
+			checks = append(checks, preflight.DiskSpace(m.dataDir(), minDataDirFreeSpace))
+		}
+
+		if isNew {
+			checks = append(checks, preflight.PortFree(clientPort), preflight.PortFree(peerPort))
+		}
+
+		return checks
+	})
+}
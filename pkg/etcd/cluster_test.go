@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -74,7 +75,7 @@ members:
 		t.Fatalf("Creating etcd cluster from YAML should succeed, got: %v", err)
 	}
 
-	if err := cluster.CheckCurrentState(); err != nil {
+	if err := cluster.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("Checking current state for empty cluster should work, got: %v", err)
 	}
 
@@ -131,6 +132,94 @@ func TestValidateValidatePass(t *testing.T) {
 	}
 }
 
+func TestValidateHostAndSSH(t *testing.T) {
+	cert := utiltest.GenerateX509Certificate(t)
+	key := utiltest.GenerateRSAPrivateKey(t)
+
+	config := &Cluster{
+		Host: &host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		SSH: &ssh.Config{},
+		Members: map[string]Member{
+			"foo": {
+				PeerCertificate:   cert,
+				PeerKey:           key,
+				ServerCertificate: cert,
+				ServerKey:         key,
+				PeerAddress:       "1",
+				CACertificate:     cert,
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Fatalf("Setting both host and SSH fields should fail")
+	}
+}
+
+func TestValidateBadOpenSSHConfig(t *testing.T) {
+	cert := utiltest.GenerateX509Certificate(t)
+	key := utiltest.GenerateRSAPrivateKey(t)
+
+	config := &Cluster{
+		SSH: &ssh.Config{
+			OpenSSHConfigFile: filepath.Join(t.TempDir(), "config"),
+			Password:          "foo",
+		},
+		Members: map[string]Member{
+			"foo": {
+				PeerCertificate:   cert,
+				PeerKey:           key,
+				ServerCertificate: cert,
+				ServerKey:         key,
+				PeerAddress:       "1",
+				CACertificate:     cert,
+				Host: host.Host{
+					SSHConfig: &ssh.Config{
+						HostAlias: "foo",
+					},
+				},
+			},
+		},
+	}
+
+	if err := config.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when OpenSSH configuration can't be read")
+	}
+}
+
+// propagateMember() tests.
+func TestPropagateMemberHostAndRuntime(t *testing.T) {
+	c := &Cluster{
+		Host: &host.Host{
+			DirectConfig: &direct.Config{},
+		},
+		Runtime: &container.RuntimeConfig{
+			Docker: &docker.Config{
+				Host: "tcp://foo:2376",
+			},
+		},
+		Members: map[string]Member{
+			"foo": {},
+		},
+	}
+
+	m := &Member{}
+
+	if err := c.propagateMember("foo", m); err != nil {
+		t.Fatalf("Propagating member should succeed, got: %v", err)
+	}
+
+	if m.Host.DirectConfig == nil {
+		t.Fatalf("Member should use host configuration from the cluster, got: %+v", m.Host)
+	}
+
+	if m.Runtime == nil || m.Runtime.Docker.Host != "tcp://foo:2376" {
+		t.Fatalf("Member should use runtime configuration from the cluster, got: %+v", m.Runtime)
+	}
+}
+
 func TestValidateValidateBadCACertificate(t *testing.T) {
 	cert := utiltest.GenerateX509Certificate(t)
 	key := utiltest.GenerateRSAPrivateKey(t)
@@ -242,32 +331,37 @@ func getFakeHostConfiguredContainer() *container.HostConfiguredContainer {
 // getClient() tests.
 func TestGetClientEmptyCluster(t *testing.T) {
 	c := &cluster{}
-	if _, err := c.getClient(); err == nil {
+	if _, err := c.getClient(context.Background(), host.NewConnectionPool()); err == nil {
 		t.Fatalf("Getting client on empty cluster should fail")
 	}
 }
 
 func TestGetClientForwardFail(t *testing.T) {
+	sshConfig, err := ssh.BuildConfig(&ssh.Config{
+		Address:           "localhost",
+		Password:          "foo",
+		ConnectionTimeout: "1ms",
+		RetryTimeout:      "1ms",
+		RetryInterval:     "1ms",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Building SSH configuration should succeed, got: %v", err)
+	}
+
 	c := &cluster{
 		containers: getContainers(t),
 		members: map[string]*member{
 			"foo": {
 				config: &Member{
 					Host: host.Host{
-						SSHConfig: ssh.BuildConfig(&ssh.Config{
-							Address:           "localhost",
-							Password:          "foo",
-							ConnectionTimeout: "1ms",
-							RetryTimeout:      "1ms",
-							RetryInterval:     "1ms",
-						}, nil),
+						SSHConfig: sshConfig,
 					},
 				},
 			},
 		},
 	}
 
-	if _, err := c.getClient(); err == nil {
+	if _, err := c.getClient(context.Background(), host.NewConnectionPool()); err == nil {
 		t.Fatalf("Getting client on empty cluster should fail")
 	}
 }
@@ -289,7 +383,7 @@ func TestGetClient(t *testing.T) {
 		},
 	}
 
-	if _, err := c.getClient(); err != nil {
+	if _, err := c.getClient(context.Background(), host.NewConnectionPool()); err != nil {
 		t.Fatalf("Getting client should succeed, got: %v", err)
 	}
 }
@@ -384,7 +478,7 @@ func TestUpdateMembersNoUpdates(t *testing.T) {
 
 	f := &fakeClient{}
 
-	if err := c.updateMembers(f); err != nil {
+	if err := c.updateMembers(context.Background(), f); err != nil {
 		t.Fatalf("Updating members without any pending updates should succeed, got: %v", err)
 	}
 }
@@ -424,7 +518,7 @@ func TestUpdateMembersRemoveMember(t *testing.T) {
 		},
 	}
 
-	if err := c.updateMembers(f); err == nil {
+	if err := c.updateMembers(context.Background(), f); err == nil {
 		t.Fatalf("Removing member should fail")
 	}
 }
@@ -469,7 +563,7 @@ func TestUpdateMembersAddMember(t *testing.T) {
 		},
 	}
 
-	if err := c.updateMembers(f); err == nil {
+	if err := c.updateMembers(context.Background(), f); err == nil {
 		t.Fatalf("Adding member should fail")
 	}
 }
@@ -492,7 +586,7 @@ func TestDeploy(t *testing.T) {
 		members:    map[string]*member{},
 	}
 
-	err = c.Deploy()
+	err = c.Deploy(context.Background())
 	if err == nil {
 		t.Fatalf("Deploying bad containers should fail")
 	}
@@ -522,7 +616,7 @@ func TestDeployUpdateMembers(t *testing.T) {
 		members:    map[string]*member{},
 	}
 
-	err = c.Deploy()
+	err = c.Deploy(context.Background())
 	if err == nil {
 		t.Fatalf("Deploying should trigger updateMembers and fail")
 	}
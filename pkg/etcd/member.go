@@ -11,7 +11,6 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/host"
 	"github.com/flexkube/libflexkube/pkg/pki"
@@ -34,11 +33,23 @@ type Member struct {
 	// This field is optional if user together with Cluster struct.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by member container. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// Host describes on which machine member container should be created.
 	//
 	// This field is required.
 	Host host.Host `json:"host,omitempty"`
 
+	// Runtime stores configuration of container runtime used for running member container.
+	// If empty, Docker with default configuration is used.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// CACertificate is a etcd CA X.509 certificate used to verify peers and client
 	// certificates. It is used for --peer-trusted-ca-file and --trusted-ca-file flags.
 	//
@@ -182,23 +193,26 @@ func (m *member) args() []string {
 	return flags
 }
 
+// dataDir returns path to the member data directory on the host.
+func (m *member) dataDir() string {
+	return fmt.Sprintf("/var/lib/etcd/%s.etcd/", m.config.Name)
+}
+
 // ToHostConfiguredContainer takes configured member and converts it to generic HostConfiguredContainer.
 func (m *member) ToHostConfiguredContainer() (*container.HostConfiguredContainer, error) {
 	c := container.Container{
-		// TODO: This is weird. This sets docker as default runtime config.
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: *container.BuildRuntimeConfig(m.config.Runtime, nil),
 		Config: containertypes.ContainerConfig{
-			Name:       fmt.Sprintf("etcd-%s", m.config.Name),
-			Image:      m.config.Image,
-			Entrypoint: []string{"/usr/local/bin/etcd"},
+			Name:        fmt.Sprintf("etcd-%s", m.config.Name),
+			Image:       m.config.Image,
+			ImageSource: m.config.ImageSource,
+			Entrypoint:  []string{"/usr/local/bin/etcd"},
 			Mounts: append(
 				[]containertypes.Mount{
 					{
 						// TODO: Between /var/lib/etcd and data dir we should probably put cluster name, to group them.
 						// TODO: Make data dir configurable.
-						Source: fmt.Sprintf("/var/lib/etcd/%s.etcd/", m.config.Name),
+						Source: m.dataDir(),
 						Target: fmt.Sprintf("/%s.etcd", m.config.Name),
 					},
 					{
@@ -298,18 +312,17 @@ func (m *member) peerURLs() []string {
 
 // forwardEndpoints opens forwarding connection for each endpoint
 // and then returns new list of endpoints. If forwarding fails, error is returned.
-func (m *member) forwardEndpoints(endpoints []string) ([]string, error) {
+// Forwarding is stopped, when given connection pool is closed.
+func (m *member) forwardEndpoints(ctx context.Context, p *host.ConnectionPool, endpoints []string) ([]string, error) {
 	newEndpoints := []string{}
 
-	h, _ := m.config.Host.New()
-
-	hc, err := h.Connect()
+	hc, err := p.Connect(ctx, m.config.Host)
 	if err != nil {
 		return nil, fmt.Errorf("failed opening forwarding connection to host: %w", err)
 	}
 
 	for _, e := range endpoints {
-		e, err := hc.ForwardTCP(e)
+		e, err := hc.ForwardTCP(ctx, e)
 		if err != nil {
 			return nil, fmt.Errorf("failed opening forwarding to member: %w", err)
 		}
@@ -322,9 +335,9 @@ func (m *member) forwardEndpoints(endpoints []string) ([]string, error) {
 
 // getID returns etcd cluster member ID, based on either member name on the cluster or matching
 // peer URL.
-func (m *member) getID(cli etcdClient) (uint64, error) {
+func (m *member) getID(ctx context.Context, cli etcdClient) (uint64, error) {
 	// Get actual list of members.
-	resp, err := cli.MemberList(context.Background())
+	resp, err := cli.MemberList(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to list existing cluster members: %w", err)
 	}
@@ -376,8 +389,8 @@ func (m *member) getEtcdClient(endpoints []string) (etcdClient, error) {
 // add uses given etcd client to add member into the cluster.
 //
 // If member is part of the cluster already, no error is returned.
-func (m *member) add(cli etcdClient) error {
-	id, err := m.getID(cli)
+func (m *member) add(ctx context.Context, cli etcdClient) error {
+	id, err := m.getID(ctx, cli)
 	if err != nil {
 		return fmt.Errorf("failed getting member ID: %w", err)
 	}
@@ -387,7 +400,7 @@ func (m *member) add(cli etcdClient) error {
 		return nil
 	}
 
-	if _, err := cli.MemberAdd(context.Background(), m.peerURLs()); err != nil {
+	if _, err := cli.MemberAdd(ctx, m.peerURLs()); err != nil {
 		return fmt.Errorf("failed adding new member to the cluster: %w", err)
 	}
 
@@ -397,8 +410,8 @@ func (m *member) add(cli etcdClient) error {
 // remove uses given etcd client to remove it from the cluster.
 //
 // If member is not part of the cluster anymore, no error is returned.
-func (m *member) remove(cli etcdClient) error {
-	id, err := m.getID(cli)
+func (m *member) remove(ctx context.Context, cli etcdClient) error {
+	id, err := m.getID(ctx, cli)
 	if err != nil {
 		return fmt.Errorf("failed getting member ID: %w", err)
 	}
@@ -408,7 +421,7 @@ func (m *member) remove(cli etcdClient) error {
 		return nil
 	}
 
-	if _, err = cli.MemberRemove(context.Background(), id); err != nil {
+	if _, err = cli.MemberRemove(ctx, id); err != nil {
 		return fmt.Errorf("failed removing member: %w", err)
 	}
 
@@ -93,7 +93,7 @@ func TestForwardEndpoints(t *testing.T) {
 		},
 	}
 
-	fe, err := m.forwardEndpoints([]string{"127.0.0.1:2379"})
+	fe, err := m.forwardEndpoints(context.Background(), host.NewConnectionPool(), []string{"127.0.0.1:2379"})
 	if err != nil {
 		t.Fatalf("Forwarding should succeed, got: %v", err)
 	}
@@ -113,7 +113,7 @@ func TestForwardEndpointsFail(t *testing.T) {
 		},
 	}
 
-	if _, err := m.forwardEndpoints([]string{"127.0.0.1"}); err == nil {
+	if _, err := m.forwardEndpoints(context.Background(), host.NewConnectionPool(), []string{"127.0.0.1"}); err == nil {
 		t.Fatalf("Forwarding bad address should fail")
 	}
 }
@@ -128,7 +128,7 @@ func TestGetIDFailToListMembers(t *testing.T) {
 
 	m := &member{}
 
-	if _, err := m.getID(f); err == nil {
+	if _, err := m.getID(context.Background(), f); err == nil {
 		t.Fatalf("Should return error when listing members fails")
 	}
 }
@@ -142,7 +142,7 @@ func TestGetIDNotFound(t *testing.T) {
 
 	m := &member{}
 
-	id, err := m.getID(f)
+	id, err := m.getID(context.Background(), f)
 	if err != nil {
 		t.Fatalf("Getting member ID should work, got: %v", err)
 	}
@@ -172,7 +172,7 @@ func TestGetIDByName(t *testing.T) {
 		},
 	}
 
-	id, err := m.getID(f)
+	id, err := m.getID(context.Background(), f)
 	if err != nil {
 		t.Fatalf("Getting member ID should work, got: %v", err)
 	}
@@ -203,7 +203,7 @@ func TestGetIDByPeerURL(t *testing.T) {
 		},
 	}
 
-	id, err := m.getID(f)
+	id, err := m.getID(context.Background(), f)
 	if err != nil {
 		t.Fatalf("Getting member ID should work, got: %v", err)
 	}
@@ -270,7 +270,7 @@ func TestRemove(t *testing.T) {
 		},
 	}
 
-	if err := m.remove(f); err != nil {
+	if err := m.remove(context.Background(), f); err != nil {
 		t.Fatalf("Removing member should work, got: %v", err)
 	}
 }
@@ -289,7 +289,7 @@ func TestRemoveNonExistent(t *testing.T) {
 
 	m := &member{}
 
-	if err := m.remove(f); err != nil {
+	if err := m.remove(context.Background(), f); err != nil {
 		t.Fatalf("Removing non-existing member shouldn't return error, got: %v", err)
 	}
 }
@@ -318,7 +318,7 @@ func TestRemoveMemberFail(t *testing.T) {
 		},
 	}
 
-	if err := m.remove(f); err == nil {
+	if err := m.remove(context.Background(), f); err == nil {
 		t.Fatalf("Removing member should check for removal errors")
 	}
 
@@ -336,7 +336,7 @@ func TestRemoveGetIDFail(t *testing.T) {
 
 	m := &member{}
 
-	if err := m.remove(f); err == nil {
+	if err := m.remove(context.Background(), f); err == nil {
 		t.Fatalf("Removing member should fail, when getting member id fails")
 	}
 }
@@ -364,7 +364,7 @@ func TestAddMember(t *testing.T) {
 		config: &Member{},
 	}
 
-	if err := m.add(f); err != nil {
+	if err := m.add(context.Background(), f); err != nil {
 		t.Fatalf("Adding member should work, got: %v", err)
 	}
 }
@@ -393,7 +393,7 @@ func TestAddMemberAlreadyExists(t *testing.T) {
 		},
 	}
 
-	if err := m.add(f); err != nil {
+	if err := m.add(context.Background(), f); err != nil {
 		t.Fatalf("Adding already existing member shouldn't trigger adding, got error: %v", err)
 	}
 }
@@ -420,7 +420,7 @@ func TestAddMemberFail(t *testing.T) {
 		config: &Member{},
 	}
 
-	if err := m.add(f); err == nil {
+	if err := m.add(context.Background(), f); err == nil {
 		t.Fatalf("Adding member should check for adding errors")
 	}
 }
@@ -434,7 +434,7 @@ func TestAddGetIDFail(t *testing.T) {
 
 	m := &member{}
 
-	if err := m.add(f); err == nil {
+	if err := m.add(context.Background(), f); err == nil {
 		t.Fatalf("Adding member should fail, when getting member id fails")
 	}
 }
@@ -3,11 +3,14 @@
 package host
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/host/transport"
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/kubernetes"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 )
 
@@ -22,6 +25,10 @@ type Host struct {
 
 	// SSHConfig configures given addresses to be forwarded using SSH tunneling.
 	SSHConfig *ssh.Config `json:"ssh,omitempty"`
+
+	// KubernetesConfig configures given addresses to be forwarded using Kubernetes
+	// port-forwarding into a helper pod running on the node.
+	KubernetesConfig *kubernetes.Config `json:"kubernetes,omitempty"`
 }
 
 type host struct {
@@ -49,6 +56,10 @@ func (h *Host) New() (transport.Interface, error) {
 		t, _ = h.SSHConfig.New()
 	}
 
+	if h.KubernetesConfig != nil {
+		t, _ = h.KubernetesConfig.New()
+	}
+
 	return &host{
 		transport: t,
 	}, nil
@@ -62,12 +73,12 @@ func (h *Host) Validate() error {
 		errors = append(errors, fmt.Errorf("direct config validation failed: %w", err))
 	}
 
-	if h.DirectConfig != nil && h.SSHConfig != nil {
-		errors = append(errors, fmt.Errorf("host must have only one transport method defined"))
-	}
-
-	if h.DirectConfig == nil && h.SSHConfig == nil {
+	switch h.transports() {
+	case 0:
 		errors = append(errors, fmt.Errorf("host must have transport method defined"))
+	case 1:
+	default:
+		errors = append(errors, fmt.Errorf("host must have only one transport method defined"))
 	}
 
 	if h.SSHConfig != nil {
@@ -76,14 +87,33 @@ func (h *Host) Validate() error {
 		}
 	}
 
+	if h.KubernetesConfig != nil {
+		if err := h.KubernetesConfig.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("host kubernetes config invalid: %w", err))
+		}
+	}
+
 	return errors.Return()
 }
 
+// transports returns number of configured transport methods.
+func (h *Host) transports() int {
+	n := 0
+
+	for _, configured := range []bool{h.DirectConfig != nil, h.SSHConfig != nil, h.KubernetesConfig != nil} {
+		if configured {
+			n++
+		}
+	}
+
+	return n
+}
+
 // selectTransport returns transport protocol configured for container.
 //
 // It returns error if transport protocol configuration is invalid.
-func (h *host) Connect() (transport.Connected, error) {
-	c, err := h.transport.Connect()
+func (h *host) Connect(ctx context.Context) (transport.Connected, error) {
+	c, err := h.transport.Connect(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("connecting failed: %w", err)
 	}
@@ -95,31 +125,72 @@ func (h *host) Connect() (transport.Connected, error) {
 
 // ForwardUnixSocket forwards given unix socket path using configured transport method and returns
 // local unix socket address.
-func (h *hostConnected) ForwardUnixSocket(path string) (string, error) {
-	return h.transport.ForwardUnixSocket(path)
+func (h *hostConnected) ForwardUnixSocket(ctx context.Context, path string) (string, error) {
+	return h.transport.ForwardUnixSocket(ctx, path)
 }
 
 // ForwardTCP forwards given TCP address using configured transport method and returns local
 // address with port.
-func (h *hostConnected) ForwardTCP(address string) (string, error) {
-	return h.transport.ForwardTCP(address)
+func (h *hostConnected) ForwardTCP(ctx context.Context, address string) (string, error) {
+	return h.transport.ForwardTCP(ctx, address)
+}
+
+// Close stops all forwarding and closes the connection using configured transport method.
+func (h *hostConnected) Close() error {
+	return h.transport.Close()
+}
+
+// Exec executes given command on the host using configured transport method, if it
+// supports executing commands.
+func (h *hostConnected) Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	return transport.Exec(ctx, h.transport, command, stdin)
 }
 
 // BuildConfig merges values from both host objects. This is a helper method used for building hierarchical
 // configuration.
-func BuildConfig(config, defaults Host) Host {
-	// If config has no direct config configured or has SSH config configured, build SSH configuration.
-	if (config.DirectConfig == nil && defaults.SSHConfig != nil) || config.SSHConfig != nil {
-		config.SSHConfig = ssh.BuildConfig(config.SSHConfig, defaults.SSHConfig)
+//
+// If config has no transport configured, transport configured in defaults is used. If defaults have
+// no transport configured either, direct transport is used.
+func BuildConfig(config, defaults Host) (Host, error) {
+	if config.DirectConfig == nil && config.SSHConfig == nil && config.KubernetesConfig == nil {
+		switch {
+		case defaults.DirectConfig != nil:
+			d := *defaults.DirectConfig
+
+			return Host{
+				DirectConfig: &d,
+			}, nil
+		case defaults.KubernetesConfig != nil:
+			config.KubernetesConfig = &kubernetes.Config{}
+		case defaults.SSHConfig != nil:
+			config.SSHConfig = &ssh.Config{}
+		default:
+			return Host{
+				DirectConfig: &direct.Config{},
+			}, nil
+		}
+	}
+
+	// Kubernetes configuration is only merged with Kubernetes defaults, as it has no fields
+	// in common with other transports.
+	if config.KubernetesConfig != nil {
+		config.KubernetesConfig = kubernetes.BuildConfig(config.KubernetesConfig, defaults.KubernetesConfig)
 	}
 
-	// If config has nothing configured and default has no SSH configuration configured,
-	// return direct config as a default.
-	if config.DirectConfig == nil && config.SSHConfig == nil && defaults.SSHConfig == nil {
-		return Host{
-			DirectConfig: &direct.Config{},
+	if config.SSHConfig != nil {
+		sshConfig, err := ssh.BuildConfig(config.SSHConfig, defaults.SSHConfig)
+		if err != nil {
+			return Host{}, fmt.Errorf("building SSH configuration: %w", err)
 		}
+
+		config.SSHConfig = sshConfig
 	}
 
-	return config
+	return config, nil
+}
+
+// ForwardingErrors returns errors, which occurred while forwarding connections using
+// configured transport method, if it reports them.
+func (h *hostConnected) ForwardingErrors() error {
+	return transport.ForwardingErrors(h.transport)
 }
@@ -1,21 +1,26 @@
 package host
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/kubernetes"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 )
 
 // New() tests.
 func TestNew(t *testing.T) {
-	h := BuildConfig(Host{
+	h, err := BuildConfig(Host{
 		SSHConfig: &ssh.Config{
 			Address:  "localhost",
 			Password: "foo",
 		},
 	}, Host{})
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
 
 	if _, err := h.New(); err != nil {
 		t.Fatalf("Built config should be valid, got: %v", err)
@@ -57,6 +62,21 @@ func TestValidate(t *testing.T) {
 			"Validate must validate ssh configuration",
 			true,
 		},
+		{
+			&Host{
+				SSHConfig:        &ssh.Config{},
+				KubernetesConfig: &kubernetes.Config{},
+			},
+			"Validate should reject ambiguous configuration with kubernetes transport",
+			true,
+		},
+		{
+			&Host{
+				KubernetesConfig: &kubernetes.Config{},
+			},
+			"Validate must validate kubernetes configuration",
+			true,
+		},
 	}
 
 	for n, c := range cases {
@@ -85,7 +105,7 @@ func TestConnect(t *testing.T) {
 		t.Fatalf("Config should be valid, got: %v", err)
 	}
 
-	if _, err := c.Connect(); err != nil {
+	if _, err := c.Connect(context.Background()); err != nil {
 		t.Fatalf("Direct config should always connect, got: %v", err)
 	}
 }
@@ -101,12 +121,12 @@ func TestForwardUnixSocket(t *testing.T) {
 		t.Fatalf("Config should be valid, got: %v", err)
 	}
 
-	hc, err := c.Connect()
+	hc, err := c.Connect(context.Background())
 	if err != nil {
 		t.Fatalf("Direct config should always connect, got: %v", err)
 	}
 
-	if _, err := hc.ForwardUnixSocket("unix:///nonexisting"); err != nil {
+	if _, err := hc.ForwardUnixSocket(context.Background(), "unix:///nonexisting"); err != nil {
 		t.Fatalf("Forwarding shouldn't fail, got: %v", err)
 	}
 }
@@ -122,19 +142,23 @@ func TestForwardTCP(t *testing.T) {
 		t.Fatalf("Config should be valid, got: %v", err)
 	}
 
-	hc, err := c.Connect()
+	hc, err := c.Connect(context.Background())
 	if err != nil {
 		t.Fatalf("Direct config should always connect, got: %v", err)
 	}
 
-	if _, err := hc.ForwardTCP("localhost:80"); err != nil {
+	if _, err := hc.ForwardTCP(context.Background(), "localhost:80"); err != nil {
 		t.Fatalf("Forwarding shouldn't fail, got: %v", err)
 	}
 }
 
 // BuildConfig() tests.
 func TestBuildConfigDirectByDefault(t *testing.T) {
-	h := BuildConfig(Host{}, Host{})
+	h, err := BuildConfig(Host{}, Host{})
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
 	if err := h.Validate(); err != nil {
 		t.Errorf("Config returned by default should be valid, got: %v", err)
 	}
@@ -153,7 +177,11 @@ func TestBuildConfigFirstPriotityDirect(t *testing.T) {
 		SSHConfig: &ssh.Config{},
 	}
 
-	h := BuildConfig(c, d)
+	h, err := BuildConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
 	if err := h.Validate(); err != nil {
 		t.Errorf("Config returned by default should be valid, got: %v", err)
 	}
@@ -175,7 +203,11 @@ func TestBuildConfigFirstPriotitySSH(t *testing.T) {
 		DirectConfig: &direct.Config{},
 	}
 
-	h := BuildConfig(c, d)
+	h, err := BuildConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
 	if err := h.Validate(); err != nil {
 		t.Errorf("Config returned should be valid, got: %v", err)
 	}
@@ -185,6 +217,94 @@ func TestBuildConfigFirstPriotitySSH(t *testing.T) {
 	}
 }
 
+func TestBuildConfigKubernetes(t *testing.T) {
+	c := Host{
+		KubernetesConfig: &kubernetes.Config{
+			NodeName: "foo",
+		},
+	}
+
+	d := Host{
+		SSHConfig: &ssh.Config{
+			Port: 33,
+		},
+	}
+
+	h, err := BuildConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if h.SSHConfig != nil || h.DirectConfig != nil {
+		t.Fatalf("BuildConfig should not add other transports, when kubernetes transport is configured")
+	}
+}
+
+func TestBuildConfigKubernetesDefaults(t *testing.T) {
+	c := Host{
+		KubernetesConfig: &kubernetes.Config{
+			NodeName: "foo",
+		},
+	}
+
+	d := Host{
+		KubernetesConfig: &kubernetes.Config{
+			Kubeconfig: "bar",
+		},
+	}
+
+	h, err := BuildConfig(c, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if k := h.KubernetesConfig; k == nil || k.NodeName != "foo" || k.Kubeconfig != "bar" {
+		t.Fatalf("BuildConfig should merge kubernetes config, got: %+v", h.KubernetesConfig)
+	}
+}
+
+func TestBuildConfigEmptyWithKubernetesDefaults(t *testing.T) {
+	d := Host{
+		KubernetesConfig: &kubernetes.Config{
+			Kubeconfig: "bar",
+		},
+	}
+
+	h, err := BuildConfig(Host{}, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if h.DirectConfig != nil || h.SSHConfig != nil {
+		t.Fatalf("BuildConfig should not add other transports, when defaults use kubernetes transport, got: %+v", h)
+	}
+
+	if h.KubernetesConfig == nil || h.KubernetesConfig.Kubeconfig != "bar" {
+		t.Fatalf("BuildConfig should use kubernetes config from defaults, got: %+v", h.KubernetesConfig)
+	}
+}
+
+func TestBuildConfigEmptyWithDirectDefaults(t *testing.T) {
+	d := Host{
+		DirectConfig: &direct.Config{
+			Dummy: "foo",
+		},
+	}
+
+	h, err := BuildConfig(Host{}, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if h.DirectConfig == nil || h.DirectConfig.Dummy != "foo" {
+		t.Fatalf("BuildConfig should use direct config from defaults, got: %+v", h)
+	}
+
+	if h.DirectConfig == d.DirectConfig {
+		t.Fatalf("BuildConfig should copy direct config from defaults")
+	}
+}
+
 func TestBuildConfigSSH(t *testing.T) {
 	u := Host{
 		SSHConfig: &ssh.Config{
@@ -198,9 +318,25 @@ func TestBuildConfigSSH(t *testing.T) {
 		},
 	}
 
-	h := BuildConfig(u, d)
+	h, err := BuildConfig(u, d)
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
 
 	if h.SSHConfig.Port != 33 || h.SSHConfig.Address != "foo" {
 		t.Fatalf("BuildConfig should merge ssh config, got: %+v", h)
 	}
 }
+
+func TestBuildConfigSSHBadOpenSSHConfig(t *testing.T) {
+	c := Host{
+		SSHConfig: &ssh.Config{
+			HostAlias:         "foo",
+			OpenSSHConfigFile: "/nonexistent",
+		},
+	}
+
+	if _, err := BuildConfig(c, Host{}); err == nil {
+		t.Fatalf("BuildConfig should fail, when OpenSSH configuration can't be read")
+	}
+}
@@ -0,0 +1,210 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+// ConnectionPool allows to reuse connections and forwarded addresses to the hosts
+// with the same configuration, so multiple operations on the same host do not
+// need to open new connection each time.
+//
+// ConnectionPool is safe for concurrent use.
+type ConnectionPool struct {
+	connections map[string]*pooledConnection
+	mutex       sync.Mutex
+
+	// ctx is used for all forwarding opened using pool connections, so forwarding
+	// can be stopped when the pool is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// pooledConnection is a transport.Connected implementation, which reuses already
+// forwarded addresses.
+type pooledConnection struct {
+	ctx       context.Context
+	connected transport.Connected
+	forwards  map[string]string
+	mutex     sync.Mutex
+}
+
+// NewConnectionPool creates new, empty connection pool.
+func NewConnectionPool() *ConnectionPool {
+	p := &ConnectionPool{}
+
+	p.reset()
+
+	return p
+}
+
+// reset initializes pool fields.
+func (p *ConnectionPool) reset() {
+	p.connections = map[string]*pooledConnection{}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+}
+
+// Connect returns connection to the given host. If the pool already has the connection
+// to the host with the same configuration, it is returned instead of opening new one.
+//
+// Returned connection is owned by the pool, so calling Close() on it has no effect.
+// Forwarding opened using the returned connection is stopped, when the pool is closed.
+func (p *ConnectionPool) Connect(ctx context.Context, h Host) (transport.Connected, error) {
+	k, err := poolKey(h)
+	if err != nil {
+		return nil, fmt.Errorf("building connection key: %w", err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if c, ok := p.connections[k]; ok {
+		return c, nil
+	}
+
+	t, err := h.New()
+	if err != nil {
+		return nil, fmt.Errorf("initializing host: %w", err)
+	}
+
+	hc, err := t.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	c := &pooledConnection{
+		ctx:       p.ctx,
+		connected: hc,
+		forwards:  map[string]string{},
+	}
+
+	p.connections[k] = c
+
+	return c, nil
+}
+
+// Close stops all forwarding and closes all connections in the pool. Pool can be
+// used again after closing.
+func (p *ConnectionPool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.cancel()
+
+	var errors util.ValidateError
+
+	for _, c := range p.connections {
+		if err := c.connected.Close(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	p.reset()
+
+	return errors.Return()
+}
+
+// poolKey returns key identifying the connection to given host. Learned SSH host keys
+// are not included, as they may be set by connecting to the host.
+func poolKey(h Host) (string, error) {
+	if h.SSHConfig != nil {
+		h.SSHConfig = withoutLearnedHostKeys(h.SSHConfig)
+	}
+
+	k, err := json.Marshal(h)
+	if err != nil {
+		return "", fmt.Errorf("serializing host configuration: %w", err)
+	}
+
+	return string(k), nil
+}
+
+// withoutLearnedHostKeys returns copy of given SSH configuration without learned host keys.
+func withoutLearnedHostKeys(c *ssh.Config) *ssh.Config {
+	nc := *c
+	nc.LearnedHostKey = ""
+	nc.JumpHosts = nil
+
+	for _, j := range c.JumpHosts {
+		if j != nil {
+			j = withoutLearnedHostKeys(j)
+		}
+
+		nc.JumpHosts = append(nc.JumpHosts, j)
+	}
+
+	return &nc
+}
+
+// ForwardUnixSocket forwards given UNIX socket, unless it has been forwarded already.
+//
+// Forwarding is stopped when the pool is closed, so given context is not used.
+func (c *pooledConnection) ForwardUnixSocket(_ context.Context, path string) (string, error) {
+	return c.forward("unix:"+path, func() (string, error) {
+		return c.connected.ForwardUnixSocket(c.ctx, path)
+	})
+}
+
+// ForwardTCP forwards given TCP address, unless it has been forwarded already.
+//
+// Forwarding is stopped when the pool is closed, so given context is not used.
+func (c *pooledConnection) ForwardTCP(_ context.Context, address string) (string, error) {
+	return c.forward("tcp:"+address, func() (string, error) {
+		return c.connected.ForwardTCP(c.ctx, address)
+	})
+}
+
+// forward returns already forwarded address stored under given key or calls
+// forward function and stores the result.
+func (c *pooledConnection) forward(key string, forward func() (string, error)) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if a, ok := c.forwards[key]; ok {
+		return a, nil
+	}
+
+	a, err := forward()
+	if err != nil {
+		return "", err
+	}
+
+	c.forwards[key] = a
+
+	return a, nil
+}
+
+// Exec executes given command on the host using pooled connection.
+func (c *pooledConnection) Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	return transport.Exec(ctx, c.connected, command, stdin)
+}
+
+// Exec connects to given host using the pool and executes given command on it.
+// See transport.Executor for details.
+func (p *ConnectionPool) Exec(ctx context.Context, h Host, command string, stdin io.Reader) ([]byte, error) {
+	c, err := p.Connect(ctx, h)
+	if err != nil {
+		return nil, fmt.Errorf("connecting: %w", err)
+	}
+
+	return transport.Exec(ctx, c, command, stdin)
+}
+
+// ForwardingErrors returns errors, which occurred while forwarding connections using
+// pooled connection.
+func (c *pooledConnection) ForwardingErrors() error {
+	return transport.ForwardingErrors(c.connected)
+}
+
+// Close does nothing, as connection is owned by the pool. Use ConnectionPool.Close()
+// to close the connection.
+func (c *pooledConnection) Close() error {
+	return nil
+}
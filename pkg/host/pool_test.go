@@ -0,0 +1,236 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+type fakeConnected struct {
+	forwards int
+	closed   bool
+}
+
+func (f *fakeConnected) ForwardUnixSocket(_ context.Context, path string) (string, error) {
+	f.forwards++
+
+	return fmt.Sprintf("%s-%d", path, f.forwards), nil
+}
+
+func (f *fakeConnected) ForwardTCP(_ context.Context, address string) (string, error) {
+	f.forwards++
+
+	return fmt.Sprintf("%s-%d", address, f.forwards), nil
+}
+
+func (f *fakeConnected) Close() error {
+	f.closed = true
+
+	return nil
+}
+
+// Connect() tests.
+func TestConnectionPoolConnectReuse(t *testing.T) {
+	p := NewConnectionPool()
+
+	h := Host{
+		DirectConfig: &direct.Config{},
+	}
+
+	a, err := p.Connect(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	b, err := p.Connect(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Connecting second time should succeed, got: %v", err)
+	}
+
+	if a != b {
+		t.Fatalf("Connection to the same host should be reused")
+	}
+}
+
+func TestConnectionPoolConnectValidate(t *testing.T) {
+	p := NewConnectionPool()
+
+	if _, err := p.Connect(context.Background(), Host{}); err == nil {
+		t.Fatalf("Connecting to invalid host should fail")
+	}
+}
+
+// poolKey() tests.
+func TestPoolKeyDifferentHosts(t *testing.T) {
+	hostConfig := func(address string) Host {
+		h, err := BuildConfig(Host{
+			SSHConfig: &ssh.Config{
+				Address:  address,
+				Password: "foo",
+			},
+		}, Host{})
+		if err != nil {
+			t.Fatalf("Building configuration should succeed, got: %v", err)
+		}
+
+		return h
+	}
+
+	a, err := poolKey(hostConfig("foo"))
+	if err != nil {
+		t.Fatalf("Building pool key should succeed, got: %v", err)
+	}
+
+	b, err := poolKey(hostConfig("bar"))
+	if err != nil {
+		t.Fatalf("Building pool key should succeed, got: %v", err)
+	}
+
+	if a == b {
+		t.Fatalf("Hosts with different configuration should have different keys")
+	}
+}
+
+func TestPoolKeyIgnoreLearnedHostKey(t *testing.T) {
+	h := Host{
+		SSHConfig: &ssh.Config{
+			Address: "foo",
+			JumpHosts: []*ssh.Config{
+				{
+					Address: "bar",
+				},
+			},
+		},
+	}
+
+	a, err := poolKey(h)
+	if err != nil {
+		t.Fatalf("Building pool key should succeed, got: %v", err)
+	}
+
+	h.SSHConfig.LearnedHostKey = "foo"
+	h.SSHConfig.JumpHosts[0].LearnedHostKey = "bar"
+
+	b, err := poolKey(h)
+	if err != nil {
+		t.Fatalf("Building pool key should succeed, got: %v", err)
+	}
+
+	if a != b {
+		t.Fatalf("Learned host keys should not be part of the pool key")
+	}
+
+	if h.SSHConfig.LearnedHostKey != "foo" || h.SSHConfig.JumpHosts[0].LearnedHostKey != "bar" {
+		t.Fatalf("Building pool key should not modify host configuration")
+	}
+}
+
+// ForwardUnixSocket() tests.
+func TestPooledConnectionForwardUnixSocketReuse(t *testing.T) {
+	c := &pooledConnection{
+		ctx:       context.Background(),
+		connected: &fakeConnected{},
+		forwards:  map[string]string{},
+	}
+
+	a, err := c.ForwardUnixSocket(context.Background(), "/foo")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	b, err := c.ForwardUnixSocket(context.Background(), "/foo")
+	if err != nil {
+		t.Fatalf("Forwarding second time should succeed, got: %v", err)
+	}
+
+	if a != b {
+		t.Fatalf("Forwarded address should be reused, got %q and %q", a, b)
+	}
+
+	d, err := c.ForwardTCP(context.Background(), "/foo")
+	if err != nil {
+		t.Fatalf("Forwarding TCP should succeed, got: %v", err)
+	}
+
+	if a == d {
+		t.Fatalf("Forwarding TCP address should not reuse UNIX socket forwarding")
+	}
+}
+
+// Close() tests.
+func TestConnectionPoolClose(t *testing.T) {
+	p := NewConnectionPool()
+
+	f := &fakeConnected{}
+
+	p.connections["foo"] = &pooledConnection{
+		ctx:       p.ctx,
+		connected: f,
+		forwards:  map[string]string{},
+	}
+
+	ctx := p.ctx
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Closing should succeed, got: %v", err)
+	}
+
+	if !f.closed {
+		t.Fatalf("Closing pool should close all connections")
+	}
+
+	if ctx.Err() == nil {
+		t.Fatalf("Closing pool should stop forwarding")
+	}
+
+	if len(p.connections) != 0 {
+		t.Fatalf("Closed pool should have no connections")
+	}
+
+	if p.ctx.Err() != nil {
+		t.Fatalf("Closed pool should be usable again")
+	}
+}
+
+func TestPooledConnectionClose(t *testing.T) {
+	f := &fakeConnected{}
+
+	c := &pooledConnection{
+		connected: f,
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Closing pooled connection should succeed, got: %v", err)
+	}
+
+	if f.closed {
+		t.Fatalf("Closing pooled connection should not close underlying connection")
+	}
+}
+
+// Exec() tests.
+func TestConnectionPoolExec(t *testing.T) {
+	p := NewConnectionPool()
+
+	out, err := p.Exec(context.Background(), Host{DirectConfig: &direct.Config{}}, "echo foo", nil)
+	if err != nil {
+		t.Fatalf("Executing command should succeed, got: %v", err)
+	}
+
+	if string(out) != "foo\n" {
+		t.Fatalf("Expected output %q, got %q", "foo\n", string(out))
+	}
+}
+
+func TestPooledConnectionExecNotSupported(t *testing.T) {
+	c := &pooledConnection{
+		connected: &fakeConnected{},
+	}
+
+	if _, err := c.Exec(context.Background(), "foo", nil); err == nil {
+		t.Fatalf("Executing command should fail, when connection does not support it")
+	}
+}
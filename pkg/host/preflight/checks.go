@@ -0,0 +1,276 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+)
+
+const (
+	// DefaultMaxClockSkew is a default maximum allowed difference between the clock on the host
+	// and the local clock. Bigger skew may cause certificates to be considered not yet valid
+	// or expired.
+	DefaultMaxClockSkew = 30 * time.Second
+
+	// listenState is a state of listening sockets in /proc/net/tcp.
+	listenState = "0A"
+
+	// unixScheme is a prefix of UNIX socket addresses.
+	unixScheme = "unix://"
+
+	// tcpScheme is a prefix of TCP addresses.
+	tcpScheme = "tcp://"
+
+	// bytesInKiB is a number of bytes in KiB.
+	bytesInKiB = 1024
+)
+
+// PortFree returns check verifying, that nothing listens on given TCP port on the host.
+func PortFree(port int) Check {
+	return Check{
+		Name: fmt.Sprintf("TCP port %d is free", port),
+		Run: func(ctx context.Context, c transport.Connected) error {
+			ports, err := listeningPorts(ctx, c)
+			if err != nil {
+				return err
+			}
+
+			if ports[port] {
+				return fmt.Errorf("port is already in use")
+			}
+
+			return nil
+		},
+	}
+}
+
+// KernelModules returns check verifying, that given kernel modules are loaded or built
+// into the kernel on the host.
+func KernelModules(modules ...string) Check {
+	return Check{
+		Name: fmt.Sprintf("kernel modules %s are loaded", strings.Join(modules, ", ")),
+		Run: func(ctx context.Context, c transport.Connected) error {
+			missing := []string{}
+
+			for _, m := range modules {
+				_, err := transport.Exec(ctx, c, fmt.Sprintf("test -d %s", shellQuote("/sys/module/"+m)), nil)
+
+				var exitErr *transport.ExitError
+
+				if errors.As(err, &exitErr) {
+					missing = append(missing, m)
+
+					continue
+				}
+
+				if err != nil {
+					return fmt.Errorf("checking module %q: %w", m, err)
+				}
+			}
+
+			if len(missing) > 0 {
+				return fmt.Errorf("modules %s are not loaded", strings.Join(missing, ", "))
+			}
+
+			return nil
+		},
+	}
+}
+
+// TimeSync returns check verifying, that the clock on the host does not differ from the
+// local clock more than given duration.
+func TimeSync(maxSkew time.Duration) Check {
+	return Check{
+		Name: fmt.Sprintf("clock skew is below %s", maxSkew),
+		Run: func(ctx context.Context, c transport.Connected) error {
+			before := time.Now()
+
+			out, err := transport.Exec(ctx, c, "date +%s", nil)
+			if err != nil {
+				return fmt.Errorf("reading time: %w", err)
+			}
+
+			// Compare with the middle of the execution, to compensate the latency.
+			local := before.Add(time.Since(before) / 2)
+
+			s, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("parsing time %q: %w", strings.TrimSpace(string(out)), err)
+			}
+
+			// Remote time has only second precision.
+			skew := time.Unix(s, 0).Sub(local.Truncate(time.Second))
+
+			if skew < 0 {
+				skew = -skew
+			}
+
+			if skew > maxSkew {
+				return fmt.Errorf("clock differs by %s", skew)
+			}
+
+			return nil
+		},
+	}
+}
+
+// DiskSpace returns check verifying, that file system holding given path has at least given
+// number of bytes available. If path does not exist yet, the closest existing parent directory
+// is checked.
+func DiskSpace(path string, minFree uint64) Check {
+	return Check{
+		Name: fmt.Sprintf("at least %d MiB available for %s", minFree/bytesInKiB/bytesInKiB, path),
+		Run: func(ctx context.Context, c transport.Connected) error {
+			cmd := fmt.Sprintf(`p=%s; while [ ! -e "$p" ]; do p=$(dirname "$p"); done; df -Pk "$p"`, shellQuote(path))
+
+			out, err := transport.Exec(ctx, c, cmd, nil)
+			if err != nil {
+				return fmt.Errorf("checking disk space: %w", err)
+			}
+
+			free, err := parseDFAvailable(string(out))
+			if err != nil {
+				return fmt.Errorf("parsing disk space: %w", err)
+			}
+
+			if free < minFree {
+				return fmt.Errorf("only %d MiB available", free/bytesInKiB/bytesInKiB)
+			}
+
+			return nil
+		},
+	}
+}
+
+// SwapDisabled returns check verifying, that there is no swap enabled on the host.
+func SwapDisabled() Check {
+	return Check{
+		Name: "swap is disabled",
+		Run: func(ctx context.Context, c transport.Connected) error {
+			out, err := transport.Exec(ctx, c, "cat /proc/swaps", nil)
+			if err != nil {
+				return fmt.Errorf("reading swaps: %w", err)
+			}
+
+			// First line is a header.
+			if lines := strings.Split(strings.TrimSpace(string(out)), "\n"); len(lines) > 1 {
+				return fmt.Errorf("%d swap devices enabled", len(lines)-1)
+			}
+
+			return nil
+		},
+	}
+}
+
+// RuntimeReachable returns check verifying, that container runtime with given address is
+// available on the host. For UNIX sockets, socket file must exist and for TCP addresses,
+// something must be listening on the port on the host.
+func RuntimeReachable(address string) Check {
+	return Check{
+		Name: fmt.Sprintf("container runtime is available at %s", address),
+		Run: func(ctx context.Context, c transport.Connected) error {
+			switch {
+			case strings.HasPrefix(address, unixScheme):
+				if _, err := transport.Exec(ctx, c, fmt.Sprintf("test -S %s", shellQuote(strings.TrimPrefix(address, unixScheme))), nil); err != nil {
+					return fmt.Errorf("socket does not exist: %w", err)
+				}
+
+				return nil
+			case strings.HasPrefix(address, tcpScheme):
+				return tcpListening(ctx, c, strings.TrimPrefix(address, tcpScheme))
+			default:
+				return fmt.Errorf("unsupported address scheme")
+			}
+		},
+	}
+}
+
+// tcpListening checks, if given TCP port on given address is in listening state on the host.
+func tcpListening(ctx context.Context, c transport.Connected, address string) error {
+	i := strings.LastIndex(address, ":")
+	if i == -1 {
+		return fmt.Errorf("address %q has no port", address)
+	}
+
+	port, err := strconv.Atoi(address[i+1:])
+	if err != nil {
+		return fmt.Errorf("parsing port: %w", err)
+	}
+
+	ports, err := listeningPorts(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if !ports[port] {
+		return fmt.Errorf("nothing listens on port %d", port)
+	}
+
+	return nil
+}
+
+// listeningPorts returns TCP ports in listening state on the host.
+func listeningPorts(ctx context.Context, c transport.Connected) (map[int]bool, error) {
+	out, err := transport.Exec(ctx, c, "cat /proc/net/tcp; cat /proc/net/tcp6 2>/dev/null || true", nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading listening ports: %w", err)
+	}
+
+	return parseListeningPorts(string(out))
+}
+
+// parseListeningPorts parses content of /proc/net/tcp and returns ports in listening state.
+func parseListeningPorts(s string) (map[int]bool, error) {
+	ports := map[int]bool{}
+
+	for _, l := range strings.Split(s, "\n") {
+		f := strings.Fields(l)
+
+		// Skip headers and empty lines.
+		if len(f) < 4 || f[0] == "sl" || f[3] != listenState {
+			continue
+		}
+
+		i := strings.LastIndex(f[1], ":")
+		if i == -1 {
+			return nil, fmt.Errorf("malformed local address %q", f[1])
+		}
+
+		p, err := strconv.ParseUint(f[1][i+1:], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("parsing port of local address %q: %w", f[1], err)
+		}
+
+		ports[int(p)] = true
+	}
+
+	return ports, nil
+}
+
+// parseDFAvailable parses 'df -Pk' output and returns available space in bytes.
+func parseDFAvailable(s string) (uint64, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+
+	// Available space is 4th column of the last line, after the header.
+	f := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(f) < 4 {
+		return 0, fmt.Errorf("unexpected output %q", s)
+	}
+
+	a, err := strconv.ParseUint(f[3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing available space %q: %w", f[3], err)
+	}
+
+	return a * bytesInKiB, nil
+}
+
+// shellQuote quotes given string, so it can be safely used as a shell argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
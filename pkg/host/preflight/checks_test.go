@@ -0,0 +1,124 @@
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+)
+
+const testProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0CEB 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 20 4 30 10 -1
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:2712 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 3 1 0000000000000000 100 0 0 10 0
+`
+
+func directConnected(t *testing.T) transport.Connected {
+	t.Helper()
+
+	d, err := (&direct.Config{}).New()
+	if err != nil {
+		t.Fatalf("Creating direct transport should succeed, got: %v", err)
+	}
+
+	c, err := d.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	return c
+}
+
+// parseListeningPorts() tests.
+func TestParseListeningPorts(t *testing.T) {
+	ports, err := parseListeningPorts(testProcNetTCP)
+	if err != nil {
+		t.Fatalf("Parsing should succeed, got: %v", err)
+	}
+
+	if !ports[3306] || !ports[10002] {
+		t.Errorf("Ports in listening state should be returned, got %v", ports)
+	}
+
+	if ports[3307] {
+		t.Errorf("Ports not in listening state should not be returned")
+	}
+}
+
+func TestParseListeningPortsMalformed(t *testing.T) {
+	if _, err := parseListeningPorts("0: foo 00000000:0000 0A"); err == nil {
+		t.Fatalf("Parsing malformed address should fail")
+	}
+}
+
+// parseDFAvailable() tests.
+func TestParseDFAvailable(t *testing.T) {
+	out := `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         10000000  5000000      2048      50% /
+`
+
+	a, err := parseDFAvailable(out)
+	if err != nil {
+		t.Fatalf("Parsing should succeed, got: %v", err)
+	}
+
+	if a != 2048*1024 {
+		t.Fatalf("Expected %d bytes, got %d", 2048*1024, a)
+	}
+}
+
+func TestParseDFAvailableMalformed(t *testing.T) {
+	if _, err := parseDFAvailable("foo"); err == nil {
+		t.Fatalf("Parsing output without header should fail")
+	}
+}
+
+// shellQuote() tests.
+func TestShellQuote(t *testing.T) {
+	if q := shellQuote("it's"); q != `'it'\''s'` {
+		t.Fatalf("Unexpected quoting result %q", q)
+	}
+}
+
+// TimeSync() tests.
+func TestTimeSync(t *testing.T) {
+	if err := TimeSync(DefaultMaxClockSkew).Run(context.Background(), directConnected(t)); err != nil {
+		t.Fatalf("Local clock should be in sync, got: %v", err)
+	}
+}
+
+// DiskSpace() tests.
+func TestDiskSpace(t *testing.T) {
+	c := directConnected(t)
+	p := t.TempDir() + "/not/existing"
+
+	if err := DiskSpace(p, 1).Run(context.Background(), c); err != nil {
+		t.Fatalf("Checking disk space should succeed, got: %v", err)
+	}
+
+	if err := DiskSpace(p, 1<<62).Run(context.Background(), c); err == nil {
+		t.Fatalf("Checking for more disk space than available should fail")
+	}
+}
+
+// RuntimeReachable() tests.
+func TestRuntimeReachableUnsupportedScheme(t *testing.T) {
+	if err := RuntimeReachable("foo://bar").Run(context.Background(), directConnected(t)); err == nil {
+		t.Fatalf("Unsupported address scheme should fail")
+	}
+}
+
+func TestRuntimeReachableMissingSocket(t *testing.T) {
+	if err := RuntimeReachable("unix://"+t.TempDir()+"/foo.sock").Run(context.Background(), directConnected(t)); err == nil {
+		t.Fatalf("Missing socket should fail")
+	}
+}
+
+// KernelModules() tests.
+func TestKernelModulesMissing(t *testing.T) {
+	if err := KernelModules("this_module_does_not_exist").Run(context.Background(), directConnected(t)); err == nil {
+		t.Fatalf("Missing module should fail")
+	}
+}
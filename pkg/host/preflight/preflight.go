@@ -0,0 +1,166 @@
+// Package preflight allows to verify, that hosts are ready for deploying containers on them,
+// before any changes are made.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+)
+
+// Check is a single verification executed on the host.
+type Check struct {
+	// Name describes what is being verified, e.g. 'port 2379 is free'.
+	Name string
+
+	// Run executes the check using given connection to the host. Returned error
+	// should describe, why the check failed.
+	Run func(ctx context.Context, c transport.Connected) error
+}
+
+// HostChecks groups checks, which should be executed on a single host.
+type HostChecks struct {
+	// Target identifies, for what the checks are executed, e.g. the container name.
+	Target string
+
+	// Host is a host, where checks will be executed.
+	Host host.Host
+
+	// Checks is a list of checks to execute.
+	Checks []Check
+}
+
+// Result is a result of a single check.
+type Result struct {
+	// Target is a target, for which the check has been executed.
+	Target string `json:"target"`
+
+	// Check is a name of executed check.
+	Check string `json:"check"`
+
+	// Passed indicates, if check has passed.
+	Passed bool `json:"passed"`
+
+	// Skipped indicates, that check could not be executed, because the transport used
+	// for connecting to the host does not support executing commands. Skipped checks
+	// are not considered failed.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Error describes why check failed or has been skipped.
+	Error string `json:"error,omitempty"`
+}
+
+// Report contains results of all executed checks.
+type Report struct {
+	// Results is a list of results of executed checks, in execution order.
+	Results []Result `json:"results"`
+}
+
+// Run executes given checks and returns report with the results. Failing checks do not stop
+// the execution, so the report contains results of all checks. Checks executed on the same
+// host share the connection.
+//
+// Checks requiring executing commands on hosts, which transport does not support it, are
+// reported as skipped.
+func Run(ctx context.Context, hostChecks []HostChecks) *Report {
+	p := host.NewConnectionPool()
+
+	defer func() {
+		if err := p.Close(); err != nil {
+			fmt.Printf("Failed closing connections used for preflight checks: %v\n", err)
+		}
+	}()
+
+	r := &Report{
+		Results: []Result{},
+	}
+
+	for _, hc := range hostChecks {
+		c, connectErr := p.Connect(ctx, hc.Host)
+		if connectErr != nil {
+			connectErr = fmt.Errorf("connecting to host: %w", connectErr)
+		}
+
+		for _, check := range hc.Checks {
+			// If connecting failed, all checks for the host fail with the same error.
+			err := connectErr
+			if err == nil {
+				err = check.Run(ctx, c)
+			}
+
+			r.Results = append(r.Results, result(hc.Target, check.Name, err))
+		}
+	}
+
+	return r
+}
+
+// result builds check result from given error.
+func result(target, check string, err error) Result {
+	r := Result{
+		Target:  target,
+		Check:   check,
+		Passed:  err == nil,
+		Skipped: errors.Is(err, transport.ErrExecNotSupported),
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// Failed returns results of failed checks. Skipped checks are not included.
+func (r *Report) Failed() []Result {
+	f := []Result{}
+
+	for _, res := range r.Results {
+		if !res.Passed && !res.Skipped {
+			f = append(f, res)
+		}
+	}
+
+	return f
+}
+
+// Err returns error describing failed checks or nil, if all checks passed.
+func (r *Report) Err() error {
+	f := r.Failed()
+
+	if len(f) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d preflight checks failed", len(f), len(r.Results))
+}
+
+// String returns human readable form of the report.
+func (r *Report) String() string {
+	var sb strings.Builder
+
+	for _, res := range r.Results {
+		status := "PASS"
+
+		switch {
+		case res.Skipped:
+			status = "SKIP"
+		case !res.Passed:
+			status = "FAIL"
+		}
+
+		fmt.Fprintf(&sb, "[%s] %s: %s", status, res.Target, res.Check)
+
+		if res.Error != "" {
+			fmt.Fprintf(&sb, ": %s", res.Error)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
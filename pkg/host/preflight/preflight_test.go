@@ -0,0 +1,160 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/host/transport/direct"
+	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
+)
+
+func passingCheck() Check {
+	return Check{
+		Name: "passing",
+		Run: func(ctx context.Context, c transport.Connected) error {
+			return nil
+		},
+	}
+}
+
+func failingCheck() Check {
+	return Check{
+		Name: "failing",
+		Run: func(ctx context.Context, c transport.Connected) error {
+			return fmt.Errorf("bad")
+		},
+	}
+}
+
+func execCheck() Check {
+	return Check{
+		Name: "exec",
+		Run: func(ctx context.Context, c transport.Connected) error {
+			if _, err := transport.Exec(ctx, c, "true", nil); err != nil {
+				return fmt.Errorf("executing: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+// noExecConnection is a connection, which does not support executing commands.
+type noExecConnection struct {
+	transport.Connected
+}
+
+func directHost() host.Host {
+	return host.Host{
+		DirectConfig: &direct.Config{},
+	}
+}
+
+// Run() tests.
+func TestRun(t *testing.T) {
+	r := Run(context.Background(), []HostChecks{
+		{
+			Target: "foo",
+			Host:   directHost(),
+			Checks: []Check{passingCheck(), failingCheck()},
+		},
+	})
+
+	expected := []Result{
+		{Target: "foo", Check: "passing", Passed: true},
+		{Target: "foo", Check: "failing", Error: "bad"},
+	}
+
+	if len(r.Results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(r.Results))
+	}
+
+	for i, e := range expected {
+		if r.Results[i] != e {
+			t.Errorf("Expected result %+v, got %+v", e, r.Results[i])
+		}
+	}
+}
+
+func TestRunConnectFail(t *testing.T) {
+	h := host.Host{
+		SSHConfig: &ssh.Config{},
+	}
+
+	r := Run(context.Background(), []HostChecks{
+		{
+			Target: "foo",
+			Host:   h,
+			Checks: []Check{passingCheck(), passingCheck()},
+		},
+	})
+
+	if f := r.Failed(); len(f) != 2 {
+		t.Fatalf("All checks should fail, when connecting to the host fails, got %+v", r.Results)
+	}
+}
+
+// result() tests.
+func TestResultSkipped(t *testing.T) {
+	c := execCheck()
+
+	r := result("foo", c.Name, c.Run(context.Background(), &noExecConnection{}))
+
+	if !r.Skipped || r.Passed {
+		t.Fatalf("Check should be skipped, when connection does not support executing commands, got %+v", r)
+	}
+
+	report := &Report{
+		Results: []Result{r},
+	}
+
+	if err := report.Err(); err != nil {
+		t.Fatalf("Skipped checks should not be considered failed, got: %v", err)
+	}
+}
+
+// Err() tests.
+func TestReportErr(t *testing.T) {
+	r := &Report{
+		Results: []Result{
+			{Passed: true},
+			{Passed: false, Error: "bad"},
+		},
+	}
+
+	if err := r.Err(); err == nil {
+		t.Fatalf("Report with failed checks should return error")
+	}
+}
+
+func TestReportErrAllPassed(t *testing.T) {
+	r := &Report{
+		Results: []Result{
+			{Passed: true},
+		},
+	}
+
+	if err := r.Err(); err != nil {
+		t.Fatalf("Report with all checks passed should not return error, got: %v", err)
+	}
+}
+
+// String() tests.
+func TestReportString(t *testing.T) {
+	r := &Report{
+		Results: []Result{
+			{Target: "foo", Check: "passing", Passed: true},
+			{Target: "bar", Check: "failing", Error: "bad"},
+			{Target: "baz", Check: "exec", Skipped: true, Error: "unsupported"},
+		},
+	}
+
+	expected := "[PASS] foo: passing\n[FAIL] bar: failing: bad\n[SKIP] baz: exec: unsupported\n"
+
+	if s := r.String(); s != expected {
+		t.Fatalf("Expected %q, got %q", expected, s)
+	}
+}
@@ -3,8 +3,13 @@
 package direct
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os/exec"
 
 	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
@@ -39,19 +44,59 @@ func (c *Config) Validate() error {
 //
 // TODO perhaps try to connect to given socket to see if it exists, we have permissions
 // etc to fail early?
-func (d *direct) ForwardUnixSocket(path string) (string, error) {
+func (d *direct) ForwardUnixSocket(ctx context.Context, path string) (string, error) {
 	return path, nil
 }
 
 // Connect implements Transport interface.
-func (d *direct) Connect() (transport.Connected, error) {
+func (d *direct) Connect(ctx context.Context) (transport.Connected, error) {
 	return d, nil
 }
 
-func (d *direct) ForwardTCP(address string) (string, error) {
+// ForwardTCP returns forwarded TCP address.
+//
+// Given that direct operates on local network, it simply returns given address.
+func (d *direct) ForwardTCP(ctx context.Context, address string) (string, error) {
 	if _, _, err := net.SplitHostPort(address); err != nil {
 		return "", fmt.Errorf("failed to validate address '%s': %w", address, err)
 	}
 
 	return address, nil
 }
+
+// Close implements transport.Connected interface.
+//
+// Given that direct does not open any connections, it does nothing.
+func (d *direct) Close() error {
+	return nil
+}
+
+// Exec implements transport.Executor interface.
+//
+// Given that direct operates on local machine, command is executed locally using 'sh'.
+func (d *direct) Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) //nolint:gosec
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return stdout.Bytes(), &transport.ExitError{
+			Command:  command,
+			ExitCode: exitErr.ExitCode(),
+			Stderr:   stderr.String(),
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("running command %q: %w", command, err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -1,8 +1,13 @@
 package direct
 
 import (
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
 
 func TestNew(t *testing.T) {
@@ -30,14 +35,14 @@ func TestForwardUnixSocket(t *testing.T) {
 	d := &direct{}
 	p := "/foo"
 
-	if fp, _ := d.ForwardUnixSocket(p); fp != p {
+	if fp, _ := d.ForwardUnixSocket(context.Background(), p); fp != p {
 		t.Fatalf("expected '%s', got '%s'", p, fp)
 	}
 }
 
 func TestConnect(t *testing.T) {
 	d := &direct{}
-	if _, err := d.Connect(); err != nil {
+	if _, err := d.Connect(context.Background()); err != nil {
 		t.Fatalf("Connect should always work, got: %v", err)
 	}
 }
@@ -46,7 +51,7 @@ func TestForwardTCP(t *testing.T) {
 	d := &direct{}
 	a := "localhost:80"
 
-	if fa, _ := d.ForwardTCP(a); fa != a {
+	if fa, _ := d.ForwardTCP(context.Background(), a); fa != a {
 		t.Fatalf("expected '%s', got '%s'", a, fa)
 	}
 }
@@ -55,7 +60,59 @@ func TestForwardTCPBadAddress(t *testing.T) {
 	d := &direct{}
 	a := "localhost"
 
-	if _, err := d.ForwardTCP(a); err == nil {
+	if _, err := d.ForwardTCP(context.Background(), a); err == nil {
 		t.Fatalf("TCP forwarding should fail when forwarding bad address")
 	}
 }
+
+// Exec() tests.
+func TestExec(t *testing.T) {
+	d := &direct{}
+
+	out, err := d.Exec(context.Background(), "cat", strings.NewReader("foo"))
+	if err != nil {
+		t.Fatalf("Executing command should succeed, got: %v", err)
+	}
+
+	if string(out) != "foo" {
+		t.Fatalf("Expected output %q, got %q", "foo", string(out))
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	d := &direct{}
+
+	_, err := d.Exec(context.Background(), "echo bar >&2; exit 3", nil)
+
+	var exitErr *transport.ExitError
+
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected exit error, got: %v", err)
+	}
+
+	if exitErr.ExitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %d", exitErr.ExitCode)
+	}
+
+	if exitErr.Stderr != "bar\n" {
+		t.Fatalf("Expected stderr to be captured, got %q", exitErr.Stderr)
+	}
+}
+
+func TestExecCancel(t *testing.T) {
+	d := &direct{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.Exec(ctx, "sleep 10", nil)
+	if err == nil {
+		t.Fatalf("Executing command with cancelled context should fail")
+	}
+
+	var exitErr *transport.ExitError
+
+	if errors.As(err, &exitErr) {
+		t.Fatalf("Cancelled command should not return exit error, got: %v", err)
+	}
+}
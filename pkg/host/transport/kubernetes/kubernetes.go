@@ -0,0 +1,781 @@
+// Package kubernetes is a transport.Interface implementation, which forwards given addresses
+// using Kubernetes port-forwarding into a helper pod running on the target node.
+//
+// It allows managing nodes, which are only reachable via the Kubernetes API, e.g. nodes in
+// isolated network, which are registered to the management cluster.
+//
+// Helper pod runs in its own network namespace, so relay in it is only reachable using
+// port-forwarding. TCP addresses are reached from the pod network, so loopback addresses
+// of the node can't be forwarded. UNIX sockets are reached using read-only mount of the node
+// root filesystem.
+//
+// Executing commands is not supported, as helper pod does not share network and process
+// namespaces with the node and can't modify it's filesystem, so results would not reflect
+// the node state.
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	"github.com/flexkube/libflexkube/internal/util"
+	"github.com/flexkube/libflexkube/pkg/defaults"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+	"github.com/flexkube/libflexkube/pkg/kubernetes/client/getter"
+)
+
+const (
+	// DefaultNamespace is a default namespace, where helper pods are created.
+	DefaultNamespace = "kube-system"
+
+	// DefaultRelayPort is a default port, on which relay in the helper pod listens. Relay
+	// listens on localhost in the network namespace of the helper pod, so the port does
+	// not need to be free on the node.
+	DefaultRelayPort = 23512
+
+	// DefaultPodReadyTimeout is a default time to wait for helper pod to become running.
+	DefaultPodReadyTimeout = "1m"
+
+	// hostMountPath is a path in the helper pod, where host root filesystem is mounted.
+	hostMountPath = "/host"
+
+	// relayContainerName is a name of the container running the relay in the helper pod.
+	relayContainerName = "relay"
+
+	// relayHandlerEnv is a name of environment variable, which passes relayHandler
+	// script to the relay container.
+	relayHandlerEnv = "RELAY_HANDLER"
+
+	// relayHandlerPath is a path in the relay container, where relayHandler script
+	// is written to.
+	relayHandlerPath = "/tmp/relay-handler"
+
+	// relayHandler is a script executed by the relay for each incoming connection. It reads
+	// the first line, which contains type and address of the target, validates it and forwards
+	// the rest of the connection to it. Validation must be kept in sync with tcpTarget() and
+	// unixTarget() functions.
+	relayHandler = `#!/bin/sh
+set -f
+
+read -r kind address || exit 1
+
+case "$kind" in
+TCP)
+  case "$address" in
+  ""|*[!]A-Za-z0-9._:[-]*)
+    echo "invalid TCP address" >&2
+    exit 1
+    ;;
+  esac
+
+  exec socat STDIO "TCP:$address"
+  ;;
+UNIX)
+  case "$address" in
+  /*) ;;
+  *)
+    echo "UNIX socket path must be absolute" >&2
+    exit 1
+    ;;
+  esac
+
+  case "$address" in
+  *[!A-Za-z0-9._/-]*|*..*)
+    echo "invalid UNIX socket path" >&2
+    exit 1
+    ;;
+  esac
+
+  exec socat STDIO "UNIX-CONNECT:` + hostMountPath + `$address"
+  ;;
+*)
+  echo "unsupported target type" >&2
+  exit 1
+  ;;
+esac
+`
+
+	// tcpTargetType is a type of TCP targets sent to the relay.
+	tcpTargetType = "TCP"
+
+	// unixTargetType is a type of UNIX socket targets sent to the relay.
+	unixTargetType = "UNIX"
+
+	// podNamePrefix is a prefix of generated helper pod names.
+	podNamePrefix = "flexkube-transport-"
+
+	// podPollInterval defines how often helper pod status is checked while waiting for it.
+	podPollInterval = time.Second
+
+	// podDeleteTimeout is a time given for removing helper pod, when connection is closed.
+	podDeleteTimeout = 30 * time.Second
+)
+
+// Config represents host configuration for communicating via Kubernetes API.
+type Config struct {
+	// Kubeconfig is a content of kubeconfig file, which will be used to talk to the
+	// Kubernetes API server, where the target node is registered.
+	//
+	// This field is required.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// NodeName is a name of the Node object, where helper pod will be scheduled.
+	//
+	// This field is required.
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Namespace is a namespace, where helper pod will be created.
+	//
+	// This field is optional. If empty, value from DefaultNamespace constant will be used.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Image is a container image used by the helper pod.
+	//
+	// This field is optional. If empty, value from defaults.RelayImage constant will be used.
+	Image string `json:"image,omitempty"`
+
+	// RelayPort is a port, where relay will be listening on localhost in the network
+	// namespace of the helper pod.
+	//
+	// This field is optional. If empty, value from DefaultRelayPort constant will be used.
+	RelayPort int `json:"relayPort,omitempty"`
+
+	// PodReadyTimeout is a maximum time to wait for helper pod to become running, e.g. '5m'.
+	//
+	// This field is optional. If empty, value from DefaultPodReadyTimeout constant will be used.
+	PodReadyTimeout string `json:"podReadyTimeout,omitempty"`
+}
+
+// kubernetes is an implementation of transport.Interface using Kubernetes port-forwarding.
+type kubernetes struct {
+	clientset       k8s.Interface
+	restConfig      *rest.Config
+	nodeName        string
+	namespace       string
+	image           string
+	relayPort       int
+	podReadyTimeout time.Duration
+	pollInterval    time.Duration
+}
+
+// kubernetesConnected is a connection to running helper pod.
+type kubernetesConnected struct {
+	clientset  k8s.Interface
+	restConfig *rest.Config
+	namespace  string
+	podName    string
+	relayPort  int
+	dialer     httpstream.Dialer
+	uuid       func() (uuid.UUID, error)
+
+	// connection is an established port-forwarding connection, which is re-created
+	// when it gets closed.
+	connection httpstream.Connection
+	requestID  int
+	mutex      sync.Mutex
+
+	// listeners stores all listeners opened for forwarding, so they can be
+	// closed together with the connection.
+	listeners []net.Listener
+
+	// forwardingErrors stores errors, which occurred while forwarding connections
+	// in the background, until they are collected.
+	forwardingErrors      util.ValidateError
+	forwardingErrorsMutex sync.Mutex
+}
+
+// BuildConfig merges given configuration with given default values, e.g. configured for all
+// hosts in the pool, and returns new configuration. Values set in the configuration take
+// precedence. Global default values are applied when creating the transport, so they are
+// not persisted in the state.
+func BuildConfig(config, defaults *Config) *Config {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if defaults == nil {
+		defaults = &Config{}
+	}
+
+	return &Config{
+		Kubeconfig:      util.PickString(config.Kubeconfig, defaults.Kubeconfig),
+		NodeName:        util.PickString(config.NodeName, defaults.NodeName),
+		Namespace:       util.PickString(config.Namespace, defaults.Namespace),
+		Image:           util.PickString(config.Image, defaults.Image),
+		RelayPort:       util.PickInt(config.RelayPort, defaults.RelayPort),
+		PodReadyTimeout: util.PickString(config.PodReadyTimeout, defaults.PodReadyTimeout),
+	}
+}
+
+// New validates Kubernetes transport configuration and returns new instance of transport interface.
+func (c *Config) New() (transport.Interface, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("kubernetes transport validation failed: %w", err)
+	}
+
+	// Validate checks building the REST config and parsing timeout, so we can skip error checking here.
+	rc, _ := restConfig(c.Kubeconfig)
+	t, _ := time.ParseDuration(util.PickString(c.PodReadyTimeout, DefaultPodReadyTimeout))
+
+	cs, err := k8s.NewForConfig(rc)
+	if err != nil {
+		return nil, fmt.Errorf("creating kubernetes clientset: %w", err)
+	}
+
+	return &kubernetes{
+		clientset:       cs,
+		restConfig:      rc,
+		nodeName:        c.NodeName,
+		namespace:       util.PickString(c.Namespace, DefaultNamespace),
+		image:           util.PickString(c.Image, defaults.RelayImage),
+		relayPort:       util.PickInt(c.RelayPort, DefaultRelayPort),
+		podReadyTimeout: t,
+		pollInterval:    podPollInterval,
+	}, nil
+}
+
+// Validate validates Config struct.
+func (c *Config) Validate() error {
+	var errors util.ValidateError
+
+	if c.Kubeconfig == "" {
+		errors = append(errors, fmt.Errorf("kubeconfig must be set"))
+	}
+
+	if c.Kubeconfig != "" {
+		if _, err := restConfig(c.Kubeconfig); err != nil {
+			errors = append(errors, fmt.Errorf("invalid kubeconfig: %w", err))
+		}
+	}
+
+	if c.NodeName == "" {
+		errors = append(errors, fmt.Errorf("node name must be set"))
+	}
+
+	if c.RelayPort < 0 || c.RelayPort > 65535 {
+		errors = append(errors, fmt.Errorf("relay port must be a valid port number, got %d", c.RelayPort))
+	}
+
+	if c.PodReadyTimeout != "" {
+		if _, err := time.ParseDuration(c.PodReadyTimeout); err != nil {
+			errors = append(errors, fmt.Errorf("unable to parse pod ready timeout: %w", err))
+		}
+	}
+
+	return errors.Return()
+}
+
+// restConfig builds Kubernetes REST client configuration from given kubeconfig content.
+func restConfig(kubeconfig string) (*rest.Config, error) {
+	g, err := getter.New([]byte(kubeconfig))
+	if err != nil {
+		return nil, fmt.Errorf("creating client getter: %w", err)
+	}
+
+	rc, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("creating REST config: %w", err)
+	}
+
+	return rc, nil
+}
+
+// Connect creates helper pod on the target node, waits until it is running and
+// returns connection, which can be used for forwarding.
+func (k *kubernetes) Connect(ctx context.Context) (transport.Connected, error) {
+	pod, err := k.clientset.CoreV1().Pods(k.namespace).Create(ctx, k.pod(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("creating helper pod: %w", err)
+	}
+
+	c := &kubernetesConnected{
+		clientset:  k.clientset,
+		restConfig: k.restConfig,
+		namespace:  k.namespace,
+		podName:    pod.Name,
+		relayPort:  k.relayPort,
+		uuid:       uuid.NewRandom,
+	}
+
+	if err := k.waitForPod(ctx, pod.Name); err != nil {
+		return nil, fmt.Errorf("waiting for helper pod %q: %w", pod.Name, c.withPodRemoved(err))
+	}
+
+	d, err := k.portForwardDialer(pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("creating port-forward dialer: %w", c.withPodRemoved(err))
+	}
+
+	c.dialer = d
+
+	return c, nil
+}
+
+// withPodRemoved removes helper pod after failed connection attempt and returns given error,
+// extended with removal error, if any.
+func (d *kubernetesConnected) withPodRemoved(err error) error {
+	if deleteErr := d.deletePod(); deleteErr != nil {
+		return fmt.Errorf("%w, additionally removing helper pod failed: %v", err, deleteErr)
+	}
+
+	return err
+}
+
+// pod returns helper pod definition. Helper pod runs in its own network namespace, so relay
+// is only reachable using port-forwarding. Host root filesystem is mounted read-only, so UNIX
+// sockets on the host are reachable.
+//
+// Relay runs as root, as sockets of container runtimes are usually only accessible by root, but
+// it is not privileged and has all capabilities dropped.
+func (k *kubernetes) pod() *corev1.Pod {
+	root := int64(0)
+	allowPrivilegeEscalation := false
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: podNamePrefix,
+			Namespace:    k.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "flexkube-transport",
+				"app.kubernetes.io/managed-by": "flexkube",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      k.nodeName,
+			RestartPolicy: corev1.RestartPolicyNever,
+			// Helper pod must be able to run on any node, including tainted ones.
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    relayContainerName,
+					Image:   k.image,
+					Command: relayCommand(k.relayPort),
+					Env: []corev1.EnvVar{
+						{
+							Name:  relayHandlerEnv,
+							Value: relayHandler,
+						},
+					},
+					SecurityContext: &corev1.SecurityContext{
+						RunAsUser:                &root,
+						AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+						Capabilities: &corev1.Capabilities{
+							Drop: []corev1.Capability{"ALL"},
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "host",
+							MountPath: hostMountPath,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "host",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{
+							Path: "/",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// relayCommand returns command for relay container. Relay listens on localhost on given port
+// and executes relayHandler script for each incoming connection.
+func relayCommand(port int) []string {
+	return []string{
+		"sh",
+		"-c",
+		fmt.Sprintf(`printf '%%s' "$%s" > %s && chmod 0700 %s && exec socat TCP-LISTEN:%d,bind=127.0.0.1,fork,reuseaddr EXEC:%s`,
+			relayHandlerEnv, relayHandlerPath, relayHandlerPath, port, relayHandlerPath),
+	}
+}
+
+var (
+	// tcpTargetRegexp matches TCP addresses accepted by relayHandler.
+	tcpTargetRegexp = regexp.MustCompile(`^[A-Za-z0-9._:\[\]-]+$`)
+
+	// unixTargetRegexp matches UNIX socket paths accepted by relayHandler.
+	unixTargetRegexp = regexp.MustCompile(`^/[A-Za-z0-9._/-]*$`)
+)
+
+// tcpTarget validates given TCP address and returns relay target for it. Loopback addresses
+// are rejected, as they are not reachable from the helper pod network.
+func tcpTarget(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("failed to validate address '%s': %w", address, err)
+	}
+
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "", fmt.Errorf("loopback address %q of the node is not reachable from helper pod", address)
+	}
+
+	a := net.JoinHostPort(host, port)
+
+	if !tcpTargetRegexp.MatchString(a) {
+		return "", fmt.Errorf("address %q contains unsupported characters", address)
+	}
+
+	return fmt.Sprintf("%s %s", tcpTargetType, a), nil
+}
+
+// unixTarget validates given UNIX socket path and returns relay target for it.
+func unixTarget(p string) (string, error) {
+	if !unixTargetRegexp.MatchString(p) || strings.Contains(p, "..") || path.Clean(p) != p {
+		return "", fmt.Errorf("path %q must be clean absolute path without special characters", p)
+	}
+
+	return fmt.Sprintf("%s %s", unixTargetType, p), nil
+}
+
+// waitForPod waits until helper pod with given name is running.
+func (k *kubernetes) waitForPod(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, k.podReadyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(k.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		pod, err := k.clientset.CoreV1().Pods(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting pod: %w", err)
+		}
+
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return fmt.Errorf("pod terminated with phase %q", pod.Status.Phase)
+		case corev1.PodPending, corev1.PodUnknown:
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("pod did not become running: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// portForwardDialer returns dialer, which opens port-forwarding connections to given pod.
+func (k *kubernetes) portForwardDialer(name string) (httpstream.Dialer, error) {
+	rt, upgrader, err := spdy.RoundTripperFor(k.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating round tripper: %w", err)
+	}
+
+	u := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(k.namespace).
+		Name(name).
+		SubResource("portforward").
+		URL()
+
+	return spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u), nil
+}
+
+// streamConnection returns established port-forwarding connection or establishes a new one,
+// if there is no connection or it has been closed.
+func (d *kubernetesConnected) streamConnection() (httpstream.Connection, int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.connection != nil {
+		select {
+		case <-d.connection.CloseChan():
+			d.connection = nil
+		default:
+		}
+	}
+
+	if d.connection == nil {
+		c, _, err := d.dialer.Dial(portforward.PortForwardProtocolV1Name)
+		if err != nil {
+			return nil, 0, fmt.Errorf("dialing: %w", err)
+		}
+
+		d.connection = c
+	}
+
+	d.requestID++
+
+	return d.connection, d.requestID, nil
+}
+
+// dial opens new port-forwarding stream to the relay in the helper pod and requests
+// forwarding to given relay target.
+func (d *kubernetesConnected) dial(target string) (httpstream.Stream, error) {
+	c, requestID, err := d.streamConnection()
+	if err != nil {
+		return nil, fmt.Errorf("opening port-forward connection: %w", err)
+	}
+
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(d.relayPort))
+	headers.Set(corev1.PortForwardRequestIDHeader, strconv.Itoa(requestID))
+
+	errorStream, err := c.CreateStream(headers)
+	if err != nil {
+		return nil, fmt.Errorf("creating error stream: %w", err)
+	}
+
+	// Error stream is only used for reading errors.
+	if err := errorStream.Close(); err != nil {
+		return nil, fmt.Errorf("closing error stream for writing: %w", err)
+	}
+
+	go func() {
+		message, err := ioutil.ReadAll(errorStream)
+
+		switch {
+		case err != nil:
+			d.reportError(fmt.Errorf("reading error stream for %q: %w", target, err))
+		case len(message) > 0:
+			d.reportError(fmt.Errorf("forwarding to %q failed: %s", target, message))
+		}
+	}()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+
+	dataStream, err := c.CreateStream(headers)
+	if err != nil {
+		return nil, fmt.Errorf("creating data stream: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(dataStream, "%s\n", target); err != nil {
+		return nil, fmt.Errorf("sending target address: %w", err)
+	}
+
+	return dataStream, nil
+}
+
+// ForwardUnixSocket starts listening on random local UNIX socket and forwards all incoming
+// connections to given UNIX socket on the node.
+func (d *kubernetesConnected) ForwardUnixSocket(ctx context.Context, path string) (string, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse path %s: %w", path, err)
+	}
+
+	if u.Scheme != "unix" {
+		return "", fmt.Errorf("forwarding non-unix socket paths is not supported")
+	}
+
+	target, err := unixTarget(u.Path)
+	if err != nil {
+		return "", fmt.Errorf("validating path: %w", err)
+	}
+
+	id, err := d.uuid()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate random UUID for abstract UNIX socket: %w", err)
+	}
+
+	unixAddr := &net.UnixAddr{
+		Name: fmt.Sprintf("@%s-%s", d.podName, id),
+		Net:  "unix",
+	}
+
+	l, err := net.Listen("unix", unixAddr.String())
+	if err != nil {
+		return "", fmt.Errorf("unable to listen on address '%s': %w", unixAddr, err)
+	}
+
+	d.forward(ctx, l, target)
+
+	return fmt.Sprintf("unix://%s", unixAddr.String()), nil
+}
+
+// ForwardTCP starts listening on random local TCP port and forwards all incoming connections
+// to given TCP address, as seen from the helper pod network.
+func (d *kubernetesConnected) ForwardTCP(ctx context.Context, address string) (string, error) {
+	target, err := tcpTarget(address)
+	if err != nil {
+		return "", err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("unable to listen on random TCP port: %w", err)
+	}
+
+	d.forward(ctx, l, target)
+
+	return l.Addr().String(), nil
+}
+
+// forward tracks given listener and schedules accepting connections on it, which will
+// be forwarded to given target.
+func (d *kubernetesConnected) forward(ctx context.Context, l net.Listener, target string) {
+	d.mutex.Lock()
+	d.listeners = append(d.listeners, l)
+	d.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		// Listener might be closed already, if connection has been closed.
+		_ = l.Close()
+	}()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				// Listener gets closed when context is cancelled or connection is closed,
+				// so this is expected.
+				if ctx.Err() == nil && !d.closed(l) {
+					d.reportError(fmt.Errorf("accepting connection for %q: %w", target, err))
+				}
+
+				return
+			}
+
+			go d.handleClient(c, target)
+		}
+	}()
+}
+
+// handleClient forwards given local connection to given target until one of the sides
+// closes the connection. Errors are reported and can be collected using ForwardingErrors.
+func (d *kubernetesConnected) handleClient(c net.Conn, target string) {
+	defer func() {
+		_ = c.Close()
+	}()
+
+	s, err := d.dial(target)
+	if err != nil {
+		d.reportError(fmt.Errorf("opening remote connection to %q: %w", target, err))
+
+		return
+	}
+
+	defer s.Reset() //nolint:errcheck
+
+	remoteDone := make(chan struct{})
+
+	// Start remote -> local data transfer.
+	go func() {
+		if _, err := io.Copy(c, s); err != nil {
+			d.reportError(fmt.Errorf("copying data from %q: %w", target, err))
+		}
+
+		close(remoteDone)
+	}()
+
+	// Start local -> remote data transfer. Closing the stream informs the
+	// relay, that no more data will be sent.
+	go func() {
+		// Local connection is closed, when remote side finishes, so errors here are expected.
+		_, _ = io.Copy(s, c)
+
+		_ = s.Close()
+	}()
+
+	<-remoteDone
+}
+
+// closed checks, if given listener has been closed together with the connection.
+func (d *kubernetesConnected) closed(l net.Listener) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, tl := range d.listeners {
+		if tl == l {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reportError stores given error, which occurred while forwarding, so it can be collected
+// using ForwardingErrors.
+func (d *kubernetesConnected) reportError(err error) {
+	d.forwardingErrorsMutex.Lock()
+	defer d.forwardingErrorsMutex.Unlock()
+
+	d.forwardingErrors = append(d.forwardingErrors, err)
+}
+
+// ForwardingErrors implements transport.ErrorReporter interface.
+func (d *kubernetesConnected) ForwardingErrors() error {
+	d.forwardingErrorsMutex.Lock()
+	defer d.forwardingErrorsMutex.Unlock()
+
+	err := d.forwardingErrors.Return()
+
+	d.forwardingErrors = nil
+
+	return err
+}
+
+// deletePod removes helper pod.
+func (d *kubernetesConnected) deletePod() error {
+	ctx, cancel := context.WithTimeout(context.Background(), podDeleteTimeout)
+	defer cancel()
+
+	return d.clientset.CoreV1().Pods(d.namespace).Delete(ctx, d.podName, metav1.DeleteOptions{})
+}
+
+// Close stops all forwarding, closes port-forwarding connection and removes the helper pod.
+func (d *kubernetesConnected) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, l := range d.listeners {
+		// Listener might be closed already, if forwarding has been stopped, so ignore the error.
+		_ = l.Close()
+	}
+
+	d.listeners = nil
+
+	var errors util.ValidateError
+
+	if d.connection != nil {
+		if err := d.connection.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing port-forward connection: %w", err))
+		}
+
+		d.connection = nil
+	}
+
+	if err := d.deletePod(); err != nil {
+		errors = append(errors, fmt.Errorf("removing helper pod %q: %w", d.podName, err))
+	}
+
+	return errors.Return()
+}
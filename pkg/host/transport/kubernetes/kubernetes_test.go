@@ -0,0 +1,533 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/tools/portforward"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
+)
+
+const (
+	testPodName  = podNamePrefix + "test"
+	testPodsPath = "/api/v1/namespaces/" + DefaultNamespace + "/pods"
+	testNodeName = "node1"
+)
+
+// fakeAPIServer emulates subset of Kubernetes API used by the transport. Port-forwarded
+// data streams behave like relay in the helper pod, which echoes the target address
+// and then all received data.
+type fakeAPIServer struct {
+	t      *testing.T
+	server *httptest.Server
+	phase  corev1.PodPhase
+
+	mutex        sync.Mutex
+	pod          *corev1.Pod
+	deleted      bool
+	relayPorts   []string
+	forwardError string
+}
+
+func newFakeAPIServer(t *testing.T, phase corev1.PodPhase) *fakeAPIServer {
+	t.Helper()
+
+	f := &fakeAPIServer{
+		t:     t,
+		phase: phase,
+	}
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeAPIServer) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == testPodsPath:
+		f.createPod(w, r)
+	case r.URL.Path == testPodsPath+"/"+testPodName+"/portforward":
+		f.portForward(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == testPodsPath+"/"+testPodName:
+		f.writeJSON(w, http.StatusOK, f.pod)
+	case r.Method == http.MethodDelete && r.URL.Path == testPodsPath+"/"+testPodName:
+		f.mutex.Lock()
+		f.deleted = true
+		f.mutex.Unlock()
+
+		f.writeJSON(w, http.StatusOK, &metav1.Status{Status: metav1.StatusSuccess})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPIServer) writeJSON(w http.ResponseWriter, code int, o interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(o); err != nil {
+		f.t.Errorf("Encoding response should succeed, got: %v", err)
+	}
+}
+
+func (f *fakeAPIServer) createPod(w http.ResponseWriter, r *http.Request) {
+	pod := &corev1.Pod{}
+
+	if err := json.NewDecoder(r.Body).Decode(pod); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	pod.Name = pod.GenerateName + "test"
+	pod.Status.Phase = f.phase
+
+	f.mutex.Lock()
+	f.pod = pod
+	f.mutex.Unlock()
+
+	f.writeJSON(w, http.StatusCreated, pod)
+}
+
+func (f *fakeAPIServer) portForward(w http.ResponseWriter, r *http.Request) {
+	if _, err := httpstream.Handshake(r, w, []string{portforward.PortForwardProtocolV1Name}); err != nil {
+		return
+	}
+
+	spdy.NewResponseUpgrader().UpgradeResponse(w, r, func(s httpstream.Stream, replySent <-chan struct{}) error {
+		go f.handleStream(s, replySent)
+
+		return nil
+	})
+}
+
+func (f *fakeAPIServer) handleStream(s httpstream.Stream, replySent <-chan struct{}) {
+	<-replySent
+
+	defer s.Close() //nolint:errcheck
+
+	if s.Headers().Get(corev1.StreamType) == corev1.StreamTypeError {
+		f.mutex.Lock()
+		message := f.forwardError
+		f.mutex.Unlock()
+
+		_, _ = io.WriteString(s, message)
+
+		return
+	}
+
+	f.mutex.Lock()
+	f.relayPorts = append(f.relayPorts, s.Headers().Get(corev1.PortHeader))
+	f.mutex.Unlock()
+
+	r := bufio.NewReader(s)
+
+	target, err := r.ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	if _, err := io.WriteString(s, target); err != nil {
+		return
+	}
+
+	_, _ = io.Copy(s, r)
+}
+
+func (f *fakeAPIServer) kubeconfig() string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+users:
+- name: test
+  user:
+    token: foo
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`, f.server.URL)
+}
+
+func (f *fakeAPIServer) podDeleted() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.deleted
+}
+
+func (f *fakeAPIServer) transport(t *testing.T) *kubernetes {
+	t.Helper()
+
+	c := &Config{
+		Kubeconfig:      f.kubeconfig(),
+		NodeName:        testNodeName,
+		PodReadyTimeout: "1s",
+	}
+
+	k, err := c.New()
+	if err != nil {
+		t.Fatalf("Creating transport should succeed, got: %v", err)
+	}
+
+	kt := k.(*kubernetes)
+	kt.pollInterval = 10 * time.Millisecond
+
+	return kt
+}
+
+func (f *fakeAPIServer) connect(t *testing.T) transport.Connected {
+	t.Helper()
+
+	c, err := f.transport(t).Connect(context.Background())
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("Closing connection should succeed, got: %v", err)
+		}
+	})
+
+	return c
+}
+
+// sendAndReceive writes given message to given connection and returns received response
+// of given length.
+func sendAndReceive(t *testing.T, network, address, message string, length int) string {
+	t.Helper()
+
+	c, err := net.Dial(network, address)
+	if err != nil {
+		t.Fatalf("Dialing forwarded address should succeed, got: %v", err)
+	}
+
+	defer c.Close() //nolint:errcheck
+
+	if err := c.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("Setting deadline should succeed, got: %v", err)
+	}
+
+	if _, err := io.WriteString(c, message); err != nil {
+		t.Fatalf("Writing should succeed, got: %v", err)
+	}
+
+	buf := make([]byte, length)
+
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatalf("Reading response should succeed, got: %v", err)
+	}
+
+	return string(buf)
+}
+
+// Validate() tests.
+func TestValidate(t *testing.T) {
+	c := &Config{
+		Kubeconfig: newFakeAPIServer(t, corev1.PodRunning).kubeconfig(),
+		NodeName:   testNodeName,
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validation should succeed, got: %v", err)
+	}
+}
+
+func TestValidateRequiredFields(t *testing.T) {
+	c := &Config{}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatalf("Validation should fail with empty config")
+	}
+
+	for _, s := range []string{"kubeconfig", "node name"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Validation error should mention %q, got: %v", s, err)
+		}
+	}
+}
+
+func TestValidateBadFields(t *testing.T) {
+	c := &Config{
+		Kubeconfig:      "foo",
+		NodeName:        testNodeName,
+		RelayPort:       70000,
+		PodReadyTimeout: "foo",
+	}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatalf("Validation should fail")
+	}
+
+	for _, s := range []string{"kubeconfig", "relay port", "timeout"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Validation error should mention %q, got: %v", s, err)
+		}
+	}
+}
+
+// BuildConfig() tests.
+func TestBuildConfig(t *testing.T) {
+	c := &Config{
+		NodeName:  "foo",
+		Namespace: "bar",
+	}
+
+	d := &Config{
+		Kubeconfig: "baz",
+		NodeName:   "doh",
+		Namespace:  "kube-system",
+		RelayPort:  1234,
+	}
+
+	expected := &Config{
+		Kubeconfig: "baz",
+		NodeName:   "foo",
+		Namespace:  "bar",
+		RelayPort:  1234,
+	}
+
+	if bc := BuildConfig(c, d); !reflect.DeepEqual(bc, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, bc)
+	}
+
+	if c.Kubeconfig != "" {
+		t.Fatalf("Given configuration should not be modified")
+	}
+}
+
+func TestBuildConfigNoDefaults(t *testing.T) {
+	if bc := BuildConfig(nil, nil); !reflect.DeepEqual(bc, &Config{}) {
+		t.Fatalf("Empty configuration should be returned, got %+v", bc)
+	}
+}
+
+// New() tests.
+func TestNewDefaults(t *testing.T) {
+	k := newFakeAPIServer(t, corev1.PodRunning).transport(t)
+
+	if k.namespace != DefaultNamespace {
+		t.Errorf("Default namespace should be used, got %q", k.namespace)
+	}
+
+	if k.relayPort != DefaultRelayPort {
+		t.Errorf("Default relay port should be used, got %d", k.relayPort)
+	}
+}
+
+// pod() tests.
+func TestPod(t *testing.T) {
+	p := newFakeAPIServer(t, corev1.PodRunning).transport(t).pod()
+
+	if p.Spec.NodeName != testNodeName {
+		t.Errorf("Pod should be scheduled on configured node, got %q", p.Spec.NodeName)
+	}
+
+	if p.Spec.HostNetwork {
+		t.Errorf("Pod should not use host network")
+	}
+
+	sc := p.Spec.Containers[0].SecurityContext
+	if sc.Privileged != nil && *sc.Privileged {
+		t.Errorf("Pod should not be privileged")
+	}
+
+	if !p.Spec.Containers[0].VolumeMounts[0].ReadOnly {
+		t.Errorf("Host root filesystem should be mounted read-only")
+	}
+
+	if c := p.Spec.Containers[0].Command; !strings.Contains(strings.Join(c, " "), fmt.Sprintf("TCP-LISTEN:%d", DefaultRelayPort)) {
+		t.Errorf("Relay should listen on configured port, got command %v", c)
+	}
+}
+
+// Connect() tests.
+func TestConnectPodFailed(t *testing.T) {
+	f := newFakeAPIServer(t, corev1.PodFailed)
+
+	if _, err := f.transport(t).Connect(context.Background()); err == nil {
+		t.Fatalf("Connecting should fail, when helper pod fails")
+	}
+
+	if !f.podDeleted() {
+		t.Fatalf("Helper pod should be removed, when connecting fails")
+	}
+}
+
+func TestConnectPodNotRunning(t *testing.T) {
+	f := newFakeAPIServer(t, corev1.PodPending)
+
+	if _, err := f.transport(t).Connect(context.Background()); err == nil {
+		t.Fatalf("Connecting should fail, when helper pod does not become running")
+	}
+
+	if !f.podDeleted() {
+		t.Fatalf("Helper pod should be removed, when connecting fails")
+	}
+}
+
+// ForwardTCP() tests.
+func TestForwardTCP(t *testing.T) {
+	f := newFakeAPIServer(t, corev1.PodRunning)
+	c := f.connect(t)
+
+	a, err := c.ForwardTCP(context.Background(), "10.0.0.1:2379")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	expected := "TCP 10.0.0.1:2379\nhello"
+
+	if r := sendAndReceive(t, "tcp", a, "hello", len(expected)); r != expected {
+		t.Fatalf("Expected %q, got %q", expected, r)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.relayPorts) != 1 || f.relayPorts[0] != fmt.Sprintf("%d", DefaultRelayPort) {
+		t.Fatalf("Connection should be forwarded to relay port, got %v", f.relayPorts)
+	}
+}
+
+func TestForwardTCPBadAddress(t *testing.T) {
+	c := newFakeAPIServer(t, corev1.PodRunning).connect(t)
+
+	for _, a := range []string{"foo", "127.0.0.1:2379", "localhost:2379", "[::1]:2379", "foo,fork:22", "$(id):22"} {
+		if _, err := c.ForwardTCP(context.Background(), a); err == nil {
+			t.Errorf("Forwarding address %q should fail", a)
+		}
+	}
+}
+
+func TestForwardTCPForwardingErrors(t *testing.T) {
+	f := newFakeAPIServer(t, corev1.PodRunning)
+	f.forwardError = "connection refused"
+
+	c := f.connect(t)
+
+	a, err := c.ForwardTCP(context.Background(), "10.0.0.1:2379")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	expected := "TCP 10.0.0.1:2379\n"
+
+	if r := sendAndReceive(t, "tcp", a, "", len(expected)); r != expected {
+		t.Fatalf("Expected %q, got %q", expected, r)
+	}
+
+	var ferr error
+
+	// Error stream is read in the background.
+	for i := 0; i < 50 && ferr == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+
+		ferr = transport.ForwardingErrors(c)
+	}
+
+	if ferr == nil || !strings.Contains(ferr.Error(), "connection refused") {
+		t.Fatalf("Forwarding error should be reported, got: %v", ferr)
+	}
+
+	if err := transport.ForwardingErrors(c); err != nil {
+		t.Fatalf("Forwarding errors should be cleared after collecting, got: %v", err)
+	}
+}
+
+// ForwardUnixSocket() tests.
+func TestForwardUnixSocket(t *testing.T) {
+	c := newFakeAPIServer(t, corev1.PodRunning).connect(t)
+
+	a, err := c.ForwardUnixSocket(context.Background(), "unix:///run/docker.sock")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	expected := "UNIX /run/docker.sock\nhello"
+
+	if r := sendAndReceive(t, "unix", strings.TrimPrefix(a, "unix://"), "hello", len(expected)); r != expected {
+		t.Fatalf("Expected %q, got %q", expected, r)
+	}
+}
+
+func TestForwardUnixSocketBadScheme(t *testing.T) {
+	c := newFakeAPIServer(t, corev1.PodRunning).connect(t)
+
+	if _, err := c.ForwardUnixSocket(context.Background(), "tcp://foo"); err == nil {
+		t.Fatalf("Forwarding non-unix socket should fail")
+	}
+}
+
+func TestForwardUnixSocketBadPath(t *testing.T) {
+	c := newFakeAPIServer(t, corev1.PodRunning).connect(t)
+
+	for _, p := range []string{"unix:///run/../etc/foo.sock", "unix:///run/foo,fork.sock", "unix:///run/$(id).sock"} {
+		if _, err := c.ForwardUnixSocket(context.Background(), p); err == nil {
+			t.Errorf("Forwarding socket %q should fail", p)
+		}
+	}
+}
+
+// Exec() tests.
+func TestExecNotSupported(t *testing.T) {
+	c := newFakeAPIServer(t, corev1.PodRunning).connect(t)
+
+	if _, err := transport.Exec(context.Background(), c, "true", nil); !errors.Is(err, transport.ErrExecNotSupported) {
+		t.Fatalf("Executing commands should not be supported, got: %v", err)
+	}
+}
+
+// Close() tests.
+func TestClose(t *testing.T) {
+	f := newFakeAPIServer(t, corev1.PodRunning)
+
+	c, err := f.transport(t).Connect(context.Background())
+	if err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	a, err := c.ForwardTCP(context.Background(), "10.0.0.1:2379")
+	if err != nil {
+		t.Fatalf("Forwarding should succeed, got: %v", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Closing should succeed, got: %v", err)
+	}
+
+	if !f.podDeleted() {
+		t.Fatalf("Helper pod should be removed, when connection is closed")
+	}
+
+	if _, err := net.Dial("tcp", a); err == nil {
+		t.Fatalf("Forwarding should be stopped, when connection is closed")
+	}
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// parseCertificate parses given OpenSSH user certificate in authorized_keys format.
+func parseCertificate(c string) (*gossh.Certificate, error) {
+	pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(c)) //nolint:dogsled
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+
+	cert, ok := pk.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("key of type %q is not a certificate", pk.Type())
+	}
+
+	if cert.CertType != gossh.UserCert {
+		return nil, fmt.Errorf("certificate is not a user certificate")
+	}
+
+	return cert, nil
+}
+
+// privateKeySigners returns signers for given private key. If certificate is not empty,
+// signer using the certificate is returned first, so the certificate is offered to the
+// server before the plain key.
+func privateKeySigners(privateKey, certificate string) ([]gossh.Signer, error) {
+	signer, err := gossh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	if certificate == "" {
+		return []gossh.Signer{signer}, nil
+	}
+
+	cert, err := parseCertificate(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, fmt.Errorf("certificate does not match private key")
+	}
+
+	certSigner, err := gossh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate signer: %w", err)
+	}
+
+	return []gossh.Signer{certSigner, signer}, nil
+}
+
+// credentials returns private key and certificate configured for the host. If they
+// are configured using files, files are read.
+func (d *Config) credentials() (string, string, error) {
+	privateKey, certificate := d.PrivateKey, d.Certificate
+
+	var err error
+
+	if d.PrivateKeyFile != "" {
+		if privateKey, err = readKeyFile(d.PrivateKeyFile); err != nil {
+			return "", "", fmt.Errorf("reading private key file: %w", err)
+		}
+	}
+
+	if d.CertificateFile != "" {
+		if certificate, err = readKeyFile(d.CertificateFile); err != nil {
+			return "", "", fmt.Errorf("reading certificate file: %w", err)
+		}
+	}
+
+	return privateKey, certificate, nil
+}
+
+// validateCredentials validates configured private key and certificate.
+func (d *Config) validateCredentials() error {
+	if d.PrivateKey != "" && d.PrivateKeyFile != "" {
+		return fmt.Errorf("private key and private key file are mutually exclusive")
+	}
+
+	if d.Certificate != "" && d.CertificateFile != "" {
+		return fmt.Errorf("certificate and certificate file are mutually exclusive")
+	}
+
+	privateKey, certificate, err := d.credentials()
+	if err != nil {
+		return err
+	}
+
+	if _, err := gossh.ParsePrivateKey([]byte(privateKey)); privateKey != "" && err != nil {
+		return fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	if certificate != "" && privateKey == "" {
+		return fmt.Errorf("certificate requires private key to be set")
+	}
+
+	if _, err := privateKeySigners(privateKey, certificate); privateKey != "" && certificate != "" && err != nil {
+		return fmt.Errorf("unable to use certificate: %w", err)
+	}
+
+	return nil
+}
+
+// readKeyFile reads content of the private key or certificate file from given path.
+func readKeyFile(path string) (string, error) {
+	c, err := ioutil.ReadFile(expandHome(path)) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("reading file %q: %w", path, err)
+	}
+
+	return strings.TrimSpace(string(c)), nil
+}
@@ -0,0 +1,173 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// generateCertificate returns user certificate for given private key in authorized_keys format,
+// signed by newly generated CA.
+func generateCertificate(t *testing.T, privateKey string, certType uint32) string {
+	t.Helper()
+
+	signer, err := gossh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		t.Fatalf("Parsing private key should succeed, got: %v", err)
+	}
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating CA key should succeed, got: %v", err)
+	}
+
+	caSigner, err := gossh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatalf("Creating CA signer should succeed, got: %v", err)
+	}
+
+	cert := &gossh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        certType,
+		ValidPrincipals: []string{"root"},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatalf("Signing certificate should succeed, got: %v", err)
+	}
+
+	return marshalHostKey(cert)
+}
+
+// privateKeySigners() tests.
+func TestPrivateKeySignersNoCertificate(t *testing.T) {
+	signers, err := privateKeySigners(generateRSAPrivateKey(t), "")
+	if err != nil {
+		t.Fatalf("Getting signers should succeed, got: %v", err)
+	}
+
+	if len(signers) != 1 {
+		t.Fatalf("Expected 1 signer, got %d", len(signers))
+	}
+}
+
+func TestPrivateKeySignersCertificate(t *testing.T) {
+	k := generateRSAPrivateKey(t)
+
+	signers, err := privateKeySigners(k, generateCertificate(t, k, gossh.UserCert))
+	if err != nil {
+		t.Fatalf("Getting signers should succeed, got: %v", err)
+	}
+
+	if len(signers) != 2 {
+		t.Fatalf("Expected 2 signers, got %d", len(signers))
+	}
+
+	if _, ok := signers[0].PublicKey().(*gossh.Certificate); !ok {
+		t.Fatalf("Certificate signer should be offered first")
+	}
+}
+
+func TestPrivateKeySignersCertificateMismatch(t *testing.T) {
+	c := generateCertificate(t, generateRSAPrivateKey(t), gossh.UserCert)
+
+	if _, err := privateKeySigners(generateRSAPrivateKey(t), c); err == nil {
+		t.Fatalf("Certificate for different key should be rejected")
+	}
+}
+
+func TestPrivateKeySignersHostCertificate(t *testing.T) {
+	k := generateRSAPrivateKey(t)
+
+	if _, err := privateKeySigners(k, generateCertificate(t, k, gossh.HostCert)); err == nil {
+		t.Fatalf("Host certificate should be rejected")
+	}
+}
+
+func TestPrivateKeySignersNotCertificate(t *testing.T) {
+	if _, err := privateKeySigners(generateRSAPrivateKey(t), marshalHostKey(generateHostKey(t))); err == nil {
+		t.Fatalf("Plain public key should be rejected as certificate")
+	}
+}
+
+// Validate() tests.
+func TestValidateCertificateRequirePrivateKey(t *testing.T) {
+	c := testConfig(t)
+	c.Certificate = generateCertificate(t, c.PrivateKey, gossh.UserCert)
+	c.PrivateKey = ""
+	c.Password = "foo"
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when certificate is set without private key")
+	}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	c := testConfig(t)
+	c.Certificate = generateCertificate(t, c.PrivateKey, gossh.UserCert)
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validation should succeed, got: %v", err)
+	}
+
+	if _, err := c.New(); err != nil {
+		t.Fatalf("Creating transport with certificate should succeed, got: %v", err)
+	}
+}
+
+func TestValidateCertificateFiles(t *testing.T) {
+	c := testConfig(t)
+	d := t.TempDir()
+
+	files := map[string]string{
+		"id":          c.PrivateKey,
+		"id-cert.pub": generateCertificate(t, c.PrivateKey, gossh.UserCert),
+	}
+
+	for n, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(d, n), []byte(f), 0o600); err != nil {
+			t.Fatalf("Writing file %q should succeed, got: %v", n, err)
+		}
+	}
+
+	c.PrivateKey = ""
+	c.PrivateKeyFile = filepath.Join(d, "id")
+	c.CertificateFile = filepath.Join(d, "id-cert.pub")
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validation should succeed, got: %v", err)
+	}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("Creating transport with private key and certificate files should succeed, got: %v", err)
+	}
+
+	if len(s.(*ssh).auth) != 1 {
+		t.Fatalf("Private key from file should be used as authentication method")
+	}
+}
+
+func TestValidatePrivateKeyFileMissing(t *testing.T) {
+	c := testConfig(t)
+	c.PrivateKey = ""
+	c.PrivateKeyFile = filepath.Join(t.TempDir(), "id")
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when private key file can't be read")
+	}
+}
+
+func TestValidatePrivateKeyAndPrivateKeyFile(t *testing.T) {
+	c := testConfig(t)
+	c.PrivateKeyFile = filepath.Join(t.TempDir(), "id")
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when both private key and private key file are set")
+	}
+}
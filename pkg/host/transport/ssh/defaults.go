@@ -1,6 +1,8 @@
 package ssh
 
 import (
+	"fmt"
+
 	"github.com/flexkube/libflexkube/internal/util"
 )
 
@@ -19,11 +21,21 @@ const (
 
 	// Port is a default port used for SSH connections.
 	Port = 22
+
+	// KeepaliveInterval is a default interval between keepalive requests.
+	KeepaliveInterval = "30s"
+
+	// KeepaliveCountMax is a default number of unanswered keepalive requests, after which
+	// connection is considered dead.
+	KeepaliveCountMax = 3
 )
 
 // BuildConfig takes destination SSH configuration, struct with default values provided by the user
 // and merges it together with global SSH default values.
-func BuildConfig(sshConfig, defaults *Config) *Config {
+//
+// If host alias and OpenSSH configuration file are set, the file is read and error is returned
+// if it can't be read or parsed.
+func BuildConfig(sshConfig, defaults *Config) (*Config, error) {
 	if sshConfig == nil {
 		sshConfig = &Config{}
 	}
@@ -32,9 +44,25 @@ func BuildConfig(sshConfig, defaults *Config) *Config {
 		defaults = &Config{}
 	}
 
-	sshConfig.PrivateKey = util.PickString(sshConfig.PrivateKey, defaults.PrivateKey)
+	sshConfig.OpenSSHConfigFile = util.PickString(sshConfig.OpenSSHConfigFile, defaults.OpenSSHConfigFile)
 
-	sshConfig.User = util.PickString(sshConfig.User, defaults.User, User)
+	sshConfig.HostAlias = util.PickString(sshConfig.HostAlias, defaults.HostAlias)
+
+	fileConfig := &Config{}
+
+	// Missing OpenSSH configuration file for set host alias is reported when validating.
+	if sshConfig.HostAlias != "" && sshConfig.OpenSSHConfigFile != "" {
+		c, err := openSSHHostConfig(sshConfig.OpenSSHConfigFile, sshConfig.HostAlias)
+		if err != nil {
+			return nil, fmt.Errorf("reading OpenSSH configuration for host %q: %w", sshConfig.HostAlias, err)
+		}
+
+		fileConfig = c
+	}
+
+	buildCredentials(sshConfig, fileConfig, defaults)
+
+	sshConfig.User = util.PickString(sshConfig.User, fileConfig.User, defaults.User, User)
 
 	sshConfig.ConnectionTimeout = util.PickString(sshConfig.ConnectionTimeout, defaults.ConnectionTimeout, ConnectionTimeout)
 
@@ -42,11 +70,115 @@ func BuildConfig(sshConfig, defaults *Config) *Config {
 
 	sshConfig.RetryInterval = util.PickString(sshConfig.RetryInterval, defaults.RetryInterval, RetryInterval)
 
-	sshConfig.Port = util.PickInt(sshConfig.Port, defaults.Port, Port)
+	sshConfig.Port = util.PickInt(sshConfig.Port, fileConfig.Port, defaults.Port, Port)
+
+	// Global keepalive defaults are applied when creating the transport, so they are not
+	// persisted in the state.
+	sshConfig.KeepaliveInterval = util.PickString(sshConfig.KeepaliveInterval, defaults.KeepaliveInterval)
+
+	sshConfig.KeepaliveCountMax = util.PickInt(sshConfig.KeepaliveCountMax, defaults.KeepaliveCountMax)
 
-	sshConfig.Address = util.PickString(sshConfig.Address, defaults.Address)
+	sshConfig.Address = util.PickString(sshConfig.Address, fileConfig.Address, defaults.Address)
 
 	sshConfig.Password = util.PickString(sshConfig.Password, defaults.Password)
 
-	return sshConfig
+	buildHostKeyVerification(sshConfig, defaults)
+
+	sshConfig.ForwardAgent = sshConfig.ForwardAgent || defaults.ForwardAgent
+
+	if len(sshConfig.JumpHosts) == 0 {
+		sshConfig.JumpHosts = fileConfig.JumpHosts
+	}
+
+	jumpHosts, err := buildJumpHosts(sshConfig.JumpHosts, defaults, sshConfig.OpenSSHConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("building jump hosts: %w", err)
+	}
+
+	sshConfig.JumpHosts = jumpHosts
+
+	return sshConfig, nil
+}
+
+// buildHostKeyVerification copies host key verification settings from given defaults,
+// if the host has none of them set. Settings are never merged, so for example host keys
+// pinned for the host are not overridden by trust on first use or by ignoring host keys
+// configured in defaults.
+func buildHostKeyVerification(sshConfig, defaults *Config) {
+	if len(sshConfig.HostKeys) != 0 || sshConfig.KnownHostsFile != "" || sshConfig.TrustOnFirstUse || sshConfig.InsecureIgnoreHostKey {
+		return
+	}
+
+	sshConfig.HostKeys = defaults.HostKeys
+	sshConfig.KnownHostsFile = defaults.KnownHostsFile
+	sshConfig.TrustOnFirstUse = defaults.TrustOnFirstUse
+	sshConfig.InsecureIgnoreHostKey = defaults.InsecureIgnoreHostKey
+}
+
+// buildCredentials sets private key from the first configuration, which has it set, either
+// directly or as a file, together with the certificate from the same configuration, so
+// certificate is not paired with a private key from other source. Certificate set for the
+// host always takes precedence.
+func buildCredentials(sshConfig *Config, configs ...*Config) {
+	key := &Config{}
+
+	for _, c := range append([]*Config{sshConfig}, configs...) {
+		if c.PrivateKey != "" || c.PrivateKeyFile != "" {
+			key = c
+
+			break
+		}
+	}
+
+	if sshConfig.Certificate == "" && sshConfig.CertificateFile == "" {
+		sshConfig.Certificate, sshConfig.CertificateFile = key.Certificate, key.CertificateFile
+	}
+
+	sshConfig.PrivateKey, sshConfig.PrivateKeyFile = key.PrivateKey, key.PrivateKeyFile
+}
+
+// buildJumpHosts builds configuration of given jump hosts. If no jump hosts are given,
+// jump hosts from defaults are used. Jump hosts share the defaults with the host itself,
+// except address, host alias and host keys, which are specific to each host.
+//
+// Jump hosts use the same OpenSSH configuration file as the host, so jump hosts defined
+// using ProxyJump option can be read from it.
+func buildJumpHosts(jumpHosts []*Config, defaults *Config, openSSHConfigFile string) ([]*Config, error) {
+	if len(jumpHosts) == 0 {
+		jumpHosts = defaults.JumpHosts
+	}
+
+	if len(jumpHosts) == 0 {
+		return nil, nil
+	}
+
+	jumpDefaults := *defaults
+	jumpDefaults.Address = ""
+	jumpDefaults.HostAlias = ""
+	jumpDefaults.OpenSSHConfigFile = openSSHConfigFile
+	jumpDefaults.HostKeys = nil
+	jumpDefaults.LearnedHostKey = ""
+	jumpDefaults.JumpHosts = nil
+
+	r := []*Config{}
+
+	for i, j := range jumpHosts {
+		if j == nil {
+			r = append(r, nil)
+
+			continue
+		}
+
+		// Copy jump host configuration, as it may be shared between multiple hosts.
+		jc := *j
+
+		c, err := BuildConfig(&jc, &jumpDefaults)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %d: %w", i, err)
+		}
+
+		r = append(r, c)
+	}
+
+	return r, nil
 }
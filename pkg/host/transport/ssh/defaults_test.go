@@ -382,13 +382,241 @@ func TestBuildConfig(t *testing.T) { //nolint:funlen
 				Password:          "foo",
 			},
 		},
+
+		// Host key verification
+		{
+			&Config{
+				HostKeys: []string{"foo"},
+			},
+			&Config{
+				HostKeys:       []string{"bar"},
+				KnownHostsFile: "/root/.ssh/known_hosts",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				HostKeys:          []string{"foo"},
+			},
+		},
+		{
+			nil,
+			&Config{
+				HostKeys:       []string{"bar"},
+				KnownHostsFile: "/root/.ssh/known_hosts",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				HostKeys:          []string{"bar"},
+				KnownHostsFile:    "/root/.ssh/known_hosts",
+			},
+		},
+		{
+			&Config{
+				HostKeys: []string{"foo"},
+			},
+			&Config{
+				InsecureIgnoreHostKey: true,
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				HostKeys:          []string{"foo"},
+			},
+		},
+		{
+			&Config{
+				KnownHostsFile: "/root/.ssh/known_hosts",
+			},
+			&Config{
+				TrustOnFirstUse: true,
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				KnownHostsFile:    "/root/.ssh/known_hosts",
+			},
+		},
+		{
+			nil,
+			&Config{
+				TrustOnFirstUse: true,
+				LearnedHostKey:  "foo",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				TrustOnFirstUse:   true,
+			},
+		},
+
+		// Agent forwarding
+		{
+			nil,
+			&Config{
+				ForwardAgent: true,
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				ForwardAgent:      true,
+			},
+		},
+
+		// Jump hosts
+		{
+			&Config{
+				Address: "foo",
+			},
+			&Config{
+				Address:    "bar",
+				PrivateKey: "foo",
+				HostKeys:   []string{"foo"},
+				JumpHosts: []*Config{
+					{
+						Address: "bastion",
+						User:    "jump",
+					},
+				},
+			},
+			&Config{
+				Address:           "foo",
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKey:        "foo",
+				HostKeys:          []string{"foo"},
+				JumpHosts: []*Config{
+					{
+						Address:           "bastion",
+						ConnectionTimeout: ConnectionTimeout,
+						Port:              Port,
+						User:              "jump",
+						RetryTimeout:      RetryTimeout,
+						RetryInterval:     RetryInterval,
+						PrivateKey:        "foo",
+					},
+				},
+			},
+		},
+
+		// Certificate
+		{
+			&Config{},
+			&Config{
+				PrivateKey:  "foo",
+				Certificate: "bar",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKey:        "foo",
+				Certificate:       "bar",
+			},
+		},
+		{
+			&Config{
+				PrivateKey: "baz",
+			},
+			&Config{
+				PrivateKey:  "foo",
+				Certificate: "bar",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKey:        "baz",
+			},
+		},
+		{
+			&Config{
+				Certificate: "baz",
+			},
+			&Config{
+				PrivateKey:  "foo",
+				Certificate: "bar",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKey:        "foo",
+				Certificate:       "baz",
+			},
+		},
+		{
+			&Config{
+				PrivateKeyFile: "baz",
+			},
+			&Config{
+				PrivateKey:  "foo",
+				Certificate: "bar",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKeyFile:    "baz",
+			},
+		},
+		{
+			&Config{},
+			&Config{
+				PrivateKeyFile:  "foo",
+				CertificateFile: "bar",
+			},
+			&Config{
+				ConnectionTimeout: ConnectionTimeout,
+				Port:              Port,
+				User:              User,
+				RetryTimeout:      RetryTimeout,
+				RetryInterval:     RetryInterval,
+				PrivateKeyFile:    "foo",
+				CertificateFile:   "bar",
+			},
+		},
 	}
 
 	for i, c := range cases {
 		c := c
 
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			if nc := BuildConfig(c.config, c.defaults); !reflect.DeepEqual(nc, c.result) {
+			nc, err := BuildConfig(c.config, c.defaults)
+			if err != nil {
+				t.Fatalf("Building configuration should succeed, got: %v", err)
+			}
+
+			if !reflect.DeepEqual(nc, c.result) {
 				t.Fatalf("expected %+v, got %+v", c.result, nc)
 			}
 		})
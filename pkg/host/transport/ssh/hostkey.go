@@ -0,0 +1,153 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// fingerprintPrefix is a prefix of SHA256 public key fingerprints.
+	fingerprintPrefix = "SHA256:"
+)
+
+// validateHostKeys validates host key verification settings.
+func (d *Config) validateHostKeys() error {
+	if d.TrustOnFirstUse && (len(d.HostKeys) != 0 || d.KnownHostsFile != "") {
+		return fmt.Errorf("trust on first use can't be used together with host keys or known hosts file")
+	}
+
+	if d.InsecureIgnoreHostKey && (d.TrustOnFirstUse || len(d.HostKeys) != 0 || d.KnownHostsFile != "") {
+		return fmt.Errorf("ignoring host keys can't be used together with other host key verification methods")
+	}
+
+	for _, k := range d.HostKeys {
+		if _, err := hostKeyFingerprint(k); err != nil {
+			return fmt.Errorf("parsing host key %q: %w", k, err)
+		}
+	}
+
+	if d.KnownHostsFile != "" {
+		if _, err := knownhosts.New(d.KnownHostsFile); err != nil {
+			return fmt.Errorf("loading known hosts file %q: %w", d.KnownHostsFile, err)
+		}
+	}
+
+	if d.LearnedHostKey != "" {
+		if _, err := hostKeyFingerprint(d.LearnedHostKey); err != nil {
+			return fmt.Errorf("parsing learned host key: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// hostKeyFingerprint returns SHA256 fingerprint of given host key in authorized_keys format.
+// If given key is a fingerprint already, it is returned as is.
+func hostKeyFingerprint(k string) (string, error) {
+	if strings.HasPrefix(k, fingerprintPrefix) {
+		return k, nil
+	}
+
+	pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(k)) //nolint:dogsled
+	if err != nil {
+		return "", fmt.Errorf("parsing public key: %w", err)
+	}
+
+	return gossh.FingerprintSHA256(pk), nil
+}
+
+// marshalHostKey returns given host key in authorized_keys format.
+func marshalHostKey(key gossh.PublicKey) string {
+	return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key)))
+}
+
+// TrustsOnFirstUse returns true, if host keys are verified using trust on first use, either
+// because it is enabled explicitly or because no other verification method is configured.
+func (d *Config) TrustsOnFirstUse() bool {
+	return d.TrustOnFirstUse || (!d.InsecureIgnoreHostKey && len(d.HostKeys) == 0 && d.KnownHostsFile == "")
+}
+
+// hostKeyCallback returns function verifying host keys according to the configuration.
+//
+// Configuration must be validated before calling this function.
+func (d *Config) hostKeyCallback() gossh.HostKeyCallback {
+	if d.InsecureIgnoreHostKey {
+		// #nosec G106
+		return gossh.InsecureIgnoreHostKey()
+	}
+
+	if d.TrustsOnFirstUse() {
+		return d.trustOnFirstUse
+	}
+
+	callbacks := []gossh.HostKeyCallback{}
+
+	if len(d.HostKeys) != 0 {
+		callbacks = append(callbacks, pinnedHostKeys(d.HostKeys))
+	}
+
+	if d.KnownHostsFile != "" {
+		kh, _ := knownhosts.New(d.KnownHostsFile)
+
+		callbacks = append(callbacks, kh)
+	}
+
+	return anyHostKeyCallback(callbacks)
+}
+
+// pinnedHostKeys returns function, which accepts only given host keys.
+func pinnedHostKeys(keys []string) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		f := gossh.FingerprintSHA256(key)
+
+		for _, k := range keys {
+			if pf, _ := hostKeyFingerprint(k); pf == f {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("host key %s of %q is not one of the configured host keys", f, hostname)
+	}
+}
+
+// anyHostKeyCallback returns function, which accepts host key if any of given
+// callbacks accepts it.
+func anyHostKeyCallback(callbacks []gossh.HostKeyCallback) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		errors := []string{}
+
+		for _, c := range callbacks {
+			err := c(hostname, remote, key)
+			if err == nil {
+				return nil
+			}
+
+			errors = append(errors, err.Error())
+		}
+
+		return fmt.Errorf("host key not accepted: %s", strings.Join(errors, ", "))
+	}
+}
+
+// trustOnFirstUse accepts and stores the host key, if no key has been learned yet.
+// Otherwise it only accepts previously learned key.
+func (d *Config) trustOnFirstUse(hostname string, remote net.Addr, key gossh.PublicKey) error {
+	if d.LearnedHostKey == "" {
+		d.LearnedHostKey = marshalHostKey(key)
+
+		return nil
+	}
+
+	expected, _ := hostKeyFingerprint(d.LearnedHostKey)
+
+	if got := gossh.FingerprintSHA256(key); got != expected {
+		return fmt.Errorf("host key of %q has changed, expected %s learned on first connection, got %s; "+
+			"if the change is expected, remove learned host key from the state", hostname, expected, got)
+	}
+
+	return nil
+}
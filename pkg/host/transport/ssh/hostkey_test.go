@@ -0,0 +1,282 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func generateHostKey(t *testing.T) gossh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating key should succeed, got: %v", err)
+	}
+
+	k, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Creating public key should succeed, got: %v", err)
+	}
+
+	return k
+}
+
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+
+	return &Config{
+		Address:           "localhost",
+		User:              "root",
+		ConnectionTimeout: "1s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		PrivateKey:        generateRSAPrivateKey(t),
+	}
+}
+
+// validateHostKeys() tests.
+func TestValidateHostKeysTrustOnFirstUseWithHostKeys(t *testing.T) {
+	c := testConfig(t)
+	c.TrustOnFirstUse = true
+	c.HostKeys = []string{marshalHostKey(generateHostKey(t))}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when both trust on first use and host keys are set")
+	}
+}
+
+func TestValidateHostKeysInsecureIgnoreHostKeyWithTrustOnFirstUse(t *testing.T) {
+	c := testConfig(t)
+	c.TrustOnFirstUse = true
+	c.InsecureIgnoreHostKey = true
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when both ignoring host keys and trust on first use are set")
+	}
+}
+
+func TestValidateHostKeysBadKey(t *testing.T) {
+	c := testConfig(t)
+	c.HostKeys = []string{"ssh-ed25519 foo"}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when host key is not valid")
+	}
+}
+
+func TestValidateHostKeysFingerprint(t *testing.T) {
+	c := testConfig(t)
+	c.HostKeys = []string{gossh.FingerprintSHA256(generateHostKey(t))}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validation should succeed, got: %v", err)
+	}
+}
+
+func TestValidateHostKeysMissingKnownHostsFile(t *testing.T) {
+	c := testConfig(t)
+	c.KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when known hosts file does not exist")
+	}
+}
+
+// hostKeyCallback() tests.
+func TestHostKeyCallbackPinnedKey(t *testing.T) {
+	k := generateHostKey(t)
+
+	c := testConfig(t)
+	c.HostKeys = []string{marshalHostKey(k)}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, k); err != nil {
+		t.Fatalf("Pinned key should be accepted, got: %v", err)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t)); err == nil {
+		t.Fatalf("Not pinned key should be rejected")
+	}
+}
+
+func TestHostKeyCallbackPinnedFingerprint(t *testing.T) {
+	k := generateHostKey(t)
+
+	c := testConfig(t)
+	c.HostKeys = []string{gossh.FingerprintSHA256(k)}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, k); err != nil {
+		t.Fatalf("Key with pinned fingerprint should be accepted, got: %v", err)
+	}
+}
+
+func TestHostKeyCallbackKnownHostsFile(t *testing.T) {
+	k := generateHostKey(t)
+
+	p := filepath.Join(t.TempDir(), "known_hosts")
+
+	if err := ioutil.WriteFile(p, []byte(knownhosts.Line([]string{"localhost"}, k)+"\n"), 0o600); err != nil {
+		t.Fatalf("Writing known hosts file should succeed, got: %v", err)
+	}
+
+	c := testConfig(t)
+	c.KnownHostsFile = p
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validation should succeed, got: %v", err)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", &fakeAddr{}, k); err != nil {
+		t.Fatalf("Key from known hosts file should be accepted, got: %v", err)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", &fakeAddr{}, generateHostKey(t)); err == nil {
+		t.Fatalf("Key not present in known hosts file should be rejected")
+	}
+}
+
+func TestHostKeyCallbackTrustOnFirstUse(t *testing.T) {
+	k := generateHostKey(t)
+
+	c := testConfig(t)
+	c.TrustOnFirstUse = true
+
+	if err := c.hostKeyCallback()("localhost:22", nil, k); err != nil {
+		t.Fatalf("Key should be accepted on first use, got: %v", err)
+	}
+
+	if c.LearnedHostKey != marshalHostKey(k) {
+		t.Fatalf("Accepted key should be stored, got: %q", c.LearnedHostKey)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, k); err != nil {
+		t.Fatalf("Learned key should be accepted, got: %v", err)
+	}
+
+	err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t))
+	if err == nil {
+		t.Fatalf("Different key should be rejected after learning the key")
+	}
+
+	if !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("Error should clearly state, that host key has changed, got: %v", err)
+	}
+}
+
+func TestHostKeyCallbackDefaultTrustOnFirstUse(t *testing.T) {
+	k := generateHostKey(t)
+
+	c := testConfig(t)
+
+	if err := c.hostKeyCallback()("localhost:22", nil, k); err != nil {
+		t.Fatalf("Key should be accepted on first use by default, got: %v", err)
+	}
+
+	if c.LearnedHostKey != marshalHostKey(k) {
+		t.Fatalf("Accepted key should be stored, got: %q", c.LearnedHostKey)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t)); err == nil {
+		t.Fatalf("Different key should be rejected by default after learning the key")
+	}
+}
+
+func TestHostKeyCallbackInsecureIgnoreHostKey(t *testing.T) {
+	c := testConfig(t)
+	c.InsecureIgnoreHostKey = true
+
+	for i := 0; i < 2; i++ {
+		if err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t)); err != nil {
+			t.Fatalf("Any key should be accepted, when ignoring host keys, got: %v", err)
+		}
+	}
+
+	if c.LearnedHostKey != "" {
+		t.Fatalf("No key should be learned, when ignoring host keys, got: %q", c.LearnedHostKey)
+	}
+}
+
+func TestHostKeyCallbackPinnedKeyWithInsecureDefaults(t *testing.T) {
+	k := generateHostKey(t)
+
+	c, err := BuildConfig(&Config{
+		HostKeys: []string{marshalHostKey(k)},
+	}, &Config{
+		InsecureIgnoreHostKey: true,
+	})
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t)); err == nil {
+		t.Fatalf("Not pinned key should be rejected, when defaults ignore host keys")
+	}
+}
+
+func TestHostKeyCallbackPinnedKeyWithTrustOnFirstUseDefaults(t *testing.T) {
+	k := generateHostKey(t)
+
+	c, err := BuildConfig(&Config{
+		HostKeys: []string{marshalHostKey(k)},
+	}, &Config{
+		TrustOnFirstUse: true,
+	})
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if err := c.hostKeyCallback()("localhost:22", nil, generateHostKey(t)); err == nil {
+		t.Fatalf("Not pinned key should be rejected, when defaults use trust on first use")
+	}
+}
+
+// Connect() tests.
+func TestConnectRejectedHostKey(t *testing.T) {
+	c := testConfig(t)
+	c.HostKeys = []string{marshalHostKey(generateHostKey(t))}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("Creating new SSH object should succeed, got: %v", err)
+	}
+
+	ss := s.(*ssh)
+
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		if err := config.HostKeyCallback(a, nil, generateHostKey(t)); err != nil {
+			return nil, fmt.Errorf("handshake failed: %v", err)
+		}
+
+		return nil, nil
+	}
+
+	start := time.Now()
+
+	if _, err := ss.Connect(context.Background()); err == nil {
+		t.Fatalf("Connecting should fail, when host key is rejected")
+	}
+
+	if time.Since(start) > 30*time.Second {
+		t.Fatalf("Connecting should not be retried, when host key is rejected")
+	}
+}
+
+type fakeAddr struct{}
+
+func (f *fakeAddr) Network() string {
+	return "tcp"
+}
+
+func (f *fakeAddr) String() string {
+	return "127.0.0.1:22"
+}
@@ -0,0 +1,149 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	sshconfig "github.com/kevinburke/ssh_config"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// proxyJumpNone is a ProxyJump value, which disables jump hosts.
+	proxyJumpNone = "none"
+)
+
+// validateOpenSSHConfig validates, that OpenSSH configuration file can be used
+// for configured host alias.
+//
+// OpenSSH configuration file may be set without host alias, e.g. in default settings
+// shared by multiple hosts, in which case it is not used.
+func (d *Config) validateOpenSSHConfig() error {
+	if d.HostAlias == "" {
+		return nil
+	}
+
+	if d.OpenSSHConfigFile == "" {
+		return fmt.Errorf("host alias requires OpenSSH configuration file to be set")
+	}
+
+	if _, err := openSSHHostConfig(d.OpenSSHConfigFile, d.HostAlias); err != nil {
+		return fmt.Errorf("reading configuration for host %q: %w", d.HostAlias, err)
+	}
+
+	return nil
+}
+
+// openSSHHostConfig reads configuration for given host alias from OpenSSH
+// configuration file.
+//
+// Jump hosts defined using ProxyJump option have only host alias, user and port set,
+// so their configuration can be read from the same file when building the configuration.
+func openSSHHostConfig(path, alias string) (*Config, error) {
+	c, err := loadOpenSSHConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validation of the file ensures, that Get does not fail.
+	get := func(key string) string {
+		v, _ := c.Get(alias, key)
+
+		return v
+	}
+
+	hc := &Config{
+		Address: util.PickString(strings.ReplaceAll(get("HostName"), "%h", alias), alias),
+		User:    get("User"),
+	}
+
+	if p := get("Port"); p != "" {
+		if hc.Port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("parsing port %q: %w", p, err)
+		}
+	}
+
+	// Only paths are stored, so keys are not persisted in the state. Files are read
+	// when validating and connecting.
+	hc.PrivateKeyFile = get("IdentityFile")
+	hc.CertificateFile = get("CertificateFile")
+
+	if hc.JumpHosts, err = parseProxyJump(get("ProxyJump")); err != nil {
+		return nil, fmt.Errorf("parsing ProxyJump: %w", err)
+	}
+
+	return hc, nil
+}
+
+// loadOpenSSHConfig parses OpenSSH configuration file from given path.
+func loadOpenSSHConfig(path string) (*sshconfig.Config, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	c, err := sshconfig.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing file: %w", err)
+	}
+
+	return c, nil
+}
+
+// parseProxyJump parses ProxyJump option value in '[user@]host[:port][,...]' format into
+// list of jump hosts.
+func parseProxyJump(proxyJump string) ([]*Config, error) {
+	if proxyJump == "" || proxyJump == proxyJumpNone {
+		return nil, nil
+	}
+
+	jumpHosts := []*Config{}
+
+	for _, j := range strings.Split(proxyJump, ",") {
+		jc := &Config{}
+
+		if i := strings.LastIndex(j, "@"); i != -1 {
+			jc.User = j[:i]
+			j = j[i+1:]
+		}
+
+		jc.HostAlias = j
+
+		if h, p, err := net.SplitHostPort(j); err == nil {
+			jc.HostAlias = h
+
+			if jc.Port, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("parsing port of jump host %q: %w", j, err)
+			}
+		}
+
+		if jc.HostAlias == "" {
+			return nil, fmt.Errorf("jump host %q has no host defined", j)
+		}
+
+		jumpHosts = append(jumpHosts, jc)
+	}
+
+	return jumpHosts, nil
+}
+
+// expandHome replaces leading '~' in given path with user home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
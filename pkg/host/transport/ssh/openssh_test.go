@@ -0,0 +1,289 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	testOpenSSHConfig = `Host target
+  HostName 10.0.0.10
+  User core
+  Port 2222
+  IdentityFile %[1]s/id
+  CertificateFile %[1]s/id-cert.pub
+  ProxyJump jump@bastion:2200,other
+
+Host bastion
+  HostName 10.0.0.1
+  User admin
+
+Host broken
+  Port foo
+`
+)
+
+// writeOpenSSHConfig writes OpenSSH configuration with identity and certificate files
+// into temporary directory and returns path to the configuration file.
+func writeOpenSSHConfig(t *testing.T, privateKey, certificate string) string {
+	t.Helper()
+
+	d := t.TempDir()
+
+	files := map[string]string{
+		"config":      fmt.Sprintf(testOpenSSHConfig, d),
+		"id":          privateKey,
+		"id-cert.pub": certificate,
+	}
+
+	for n, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(d, n), []byte(c), 0o600); err != nil {
+			t.Fatalf("Writing file %q should succeed, got: %v", n, err)
+		}
+	}
+
+	return filepath.Join(d, "config")
+}
+
+// openSSHHostConfig() tests.
+func TestOpenSSHHostConfig(t *testing.T) {
+	k := generateRSAPrivateKey(t)
+	c := generateCertificate(t, k, gossh.UserCert)
+
+	p := writeOpenSSHConfig(t, k, c)
+
+	hc, err := openSSHHostConfig(p, "target")
+	if err != nil {
+		t.Fatalf("Reading host configuration should succeed, got: %v", err)
+	}
+
+	expected := &Config{
+		Address:         "10.0.0.10",
+		User:            "core",
+		Port:            2222,
+		PrivateKeyFile:  filepath.Join(filepath.Dir(p), "id"),
+		CertificateFile: filepath.Join(filepath.Dir(p), "id-cert.pub"),
+		JumpHosts: []*Config{
+			{
+				HostAlias: "bastion",
+				User:      "jump",
+				Port:      2200,
+			},
+			{
+				HostAlias: "other",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(hc, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, hc)
+	}
+}
+
+func TestOpenSSHHostConfigUnknownHost(t *testing.T) {
+	hc, err := openSSHHostConfig(writeOpenSSHConfig(t, "", ""), "unknown")
+	if err != nil {
+		t.Fatalf("Reading unknown host configuration should succeed, got: %v", err)
+	}
+
+	if hc.Address != "unknown" {
+		t.Fatalf("Host alias should be used as address, when HostName is not set, got %q", hc.Address)
+	}
+}
+
+func TestOpenSSHHostConfigBadPort(t *testing.T) {
+	if _, err := openSSHHostConfig(writeOpenSSHConfig(t, "", ""), "broken"); err == nil {
+		t.Fatalf("Reading host configuration with bad port should fail")
+	}
+}
+
+func TestBuildConfigOpenSSHConfigMissingIdentityFile(t *testing.T) {
+	p := writeOpenSSHConfig(t, "", "")
+
+	if err := os.Remove(filepath.Join(filepath.Dir(p), "id")); err != nil {
+		t.Fatalf("Removing identity file should succeed, got: %v", err)
+	}
+
+	hc, err := BuildConfig(&Config{
+		HostAlias:         "target",
+		OpenSSHConfigFile: p,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Identity file should not be read when building the configuration, got: %v", err)
+	}
+
+	if err := hc.Validate(); err == nil {
+		t.Fatalf("Validating host configuration with missing identity file should fail")
+	}
+}
+
+func TestOpenSSHHostConfigMissingFile(t *testing.T) {
+	if _, err := openSSHHostConfig(filepath.Join(t.TempDir(), "config"), "target"); err == nil {
+		t.Fatalf("Reading host configuration from missing file should fail")
+	}
+}
+
+// parseProxyJump() tests.
+func TestParseProxyJumpNone(t *testing.T) {
+	j, err := parseProxyJump(proxyJumpNone)
+	if err != nil {
+		t.Fatalf("Parsing should succeed, got: %v", err)
+	}
+
+	if j != nil {
+		t.Fatalf("No jump hosts should be returned, got: %v", j)
+	}
+}
+
+func TestParseProxyJumpNoHost(t *testing.T) {
+	if _, err := parseProxyJump("foo@"); err == nil {
+		t.Fatalf("Parsing jump host without host should fail")
+	}
+}
+
+func TestParseProxyJumpBadPort(t *testing.T) {
+	if _, err := parseProxyJump("foo:bar"); err == nil {
+		t.Fatalf("Parsing jump host with bad port should fail")
+	}
+}
+
+// Validate() tests.
+func TestValidateHostAliasRequireOpenSSHConfigFile(t *testing.T) {
+	c := testConfig(t)
+	c.HostAlias = "target"
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when host alias is set without OpenSSH configuration file")
+	}
+}
+
+func TestValidateOpenSSHConfigFileWithoutHostAlias(t *testing.T) {
+	c := testConfig(t)
+	c.OpenSSHConfigFile = filepath.Join(t.TempDir(), "config")
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("OpenSSH configuration file should be ignored without host alias, got: %v", err)
+	}
+}
+
+func TestValidateOpenSSHConfigBadHost(t *testing.T) {
+	c := testConfig(t)
+	c.OpenSSHConfigFile = writeOpenSSHConfig(t, "", "")
+	c.HostAlias = "broken"
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Validation should fail, when host configuration can't be read")
+	}
+}
+
+// BuildConfig() tests.
+func TestBuildConfigOpenSSHConfig(t *testing.T) {
+	k := generateRSAPrivateKey(t)
+	c := generateCertificate(t, k, gossh.UserCert)
+
+	p := writeOpenSSHConfig(t, k, c)
+
+	hc, err := BuildConfig(&Config{
+		HostAlias: "target",
+		Port:      customPort,
+	}, &Config{
+		OpenSSHConfigFile: p,
+		User:              "foo",
+		Password:          "bar",
+	})
+	if err != nil {
+		t.Fatalf("Building configuration should succeed, got: %v", err)
+	}
+
+	if hc.Address != "10.0.0.10" {
+		t.Errorf("Address should be read from OpenSSH configuration, got %q", hc.Address)
+	}
+
+	if hc.Port != customPort {
+		t.Errorf("Explicitly set port should take precedence, got %d", hc.Port)
+	}
+
+	if hc.User != "core" {
+		t.Errorf("User from OpenSSH configuration should take precedence over defaults, got %q", hc.User)
+	}
+
+	if hc.PrivateKey != "" || hc.PrivateKeyFile != filepath.Join(filepath.Dir(p), "id") {
+		t.Errorf("Only path to the identity file should be stored, got key %q and path %q", hc.PrivateKey, hc.PrivateKeyFile)
+	}
+
+	if hc.Certificate != "" || hc.CertificateFile != filepath.Join(filepath.Dir(p), "id-cert.pub") {
+		t.Errorf("Only path to the certificate file should be stored, got certificate %q and path %q", hc.Certificate, hc.CertificateFile)
+	}
+
+	if len(hc.JumpHosts) != 2 {
+		t.Fatalf("Jump hosts should be read from OpenSSH configuration, got: %v", hc.JumpHosts)
+	}
+
+	j := hc.JumpHosts[0]
+
+	if j.Address != "10.0.0.1" || j.User != "jump" || j.Port != 2200 {
+		t.Errorf("Jump host should be read from OpenSSH configuration, got %+v", j)
+	}
+
+	if o := hc.JumpHosts[1]; o.Address != "other" || o.User != "foo" || o.Port != Port {
+		t.Errorf("Jump host not defined in OpenSSH configuration should use defaults, got %+v", o)
+	}
+
+	if err := hc.Validate(); err != nil {
+		t.Fatalf("Built configuration should be valid, got: %v", err)
+	}
+}
+
+func TestBuildConfigOpenSSHConfigBadHost(t *testing.T) {
+	if _, err := BuildConfig(&Config{
+		HostAlias:         "broken",
+		OpenSSHConfigFile: writeOpenSSHConfig(t, "", ""),
+	}, nil); err == nil {
+		t.Fatalf("Building configuration should fail, when host configuration can't be parsed")
+	}
+}
+
+func TestBuildConfigOpenSSHConfigMissingFile(t *testing.T) {
+	if _, err := BuildConfig(&Config{
+		HostAlias:         "target",
+		OpenSSHConfigFile: filepath.Join(t.TempDir(), "config"),
+	}, nil); err == nil {
+		t.Fatalf("Building configuration should fail, when OpenSSH configuration file can't be read")
+	}
+}
+
+func TestBuildConfigOpenSSHConfigJumpHostBadHost(t *testing.T) {
+	if _, err := BuildConfig(&Config{
+		Address:           "localhost",
+		OpenSSHConfigFile: writeOpenSSHConfig(t, "", ""),
+		JumpHosts: []*Config{
+			{
+				HostAlias: "broken",
+			},
+		},
+	}, nil); err == nil {
+		t.Fatalf("Building configuration should fail, when jump host configuration can't be parsed")
+	}
+}
+
+// expandHome() tests.
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("Home directory not available: %v", err)
+	}
+
+	if p := expandHome("~/.ssh/config"); p != filepath.Join(home, ".ssh", "config") {
+		t.Fatalf("Home directory should be expanded, got %q", p)
+	}
+
+	if p := expandHome("/etc/ssh/ssh_config"); p != "/etc/ssh/ssh_config" {
+		t.Fatalf("Absolute path should not be modified, got %q", p)
+	}
+}
@@ -3,11 +3,15 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -52,6 +56,85 @@ type Config struct {
 	// PrivateKey adds private key as authentication method.
 	// It must be defined as valid SSH private key in PEM format.
 	PrivateKey string `json:"privateKey,omitempty"`
+
+	// Certificate is an OpenSSH user certificate in authorized_keys format, e.g.
+	// 'ssh-ed25519-cert-v01@openssh.com AAAA...', signed for the public key of
+	// PrivateKey. If set, certificate is offered to the server before the plain key.
+	Certificate string `json:"certificate,omitempty"`
+
+	// PrivateKeyFile is a path to the file with SSH private key, which will be used as
+	// authentication method, e.g. '~/.ssh/id_ed25519'. The file is read when connecting,
+	// so the private key is not stored in the state. It is mutually exclusive with PrivateKey.
+	PrivateKeyFile string `json:"privateKeyFile,omitempty"`
+
+	// CertificateFile is a path to the file with OpenSSH user certificate for the private key.
+	// It is mutually exclusive with Certificate.
+	CertificateFile string `json:"certificateFile,omitempty"`
+
+	// OpenSSHConfigFile is a path to OpenSSH client configuration file, e.g. '~/.ssh/config'.
+	// If set together with HostAlias, HostName, User, Port, IdentityFile, CertificateFile and
+	// ProxyJump options for the alias are read from the file. IdentityFile and CertificateFile
+	// are used as PrivateKeyFile and CertificateFile. Values set explicitly in the
+	// configuration take precedence over values from the file.
+	OpenSSHConfigFile string `json:"openSSHConfigFile,omitempty"`
+
+	// HostAlias is a name of the host in OpenSSH configuration file, which should be used
+	// for reading the configuration.
+	HostAlias string `json:"hostAlias,omitempty"`
+
+	// HostKeys is a list of host public keys, which will be accepted when connecting.
+	// Each entry must be either a public key in authorized_keys format, e.g.
+	// 'ssh-ed25519 AAAA...' or SHA256 fingerprint of the key, e.g. 'SHA256:...'.
+	HostKeys []string `json:"hostKeys,omitempty"`
+
+	// KnownHostsFile is a path to OpenSSH known_hosts file, which will be used to
+	// verify host keys.
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+
+	// TrustOnFirstUse accepts host key presented on the first connection and stores it
+	// in LearnedHostKey field. All subsequent connections must present the same key.
+	//
+	// Trust on first use is also used, when no other host key verification method is
+	// configured.
+	//
+	// It can't be used together with HostKeys or KnownHostsFile.
+	TrustOnFirstUse bool `json:"trustOnFirstUse,omitempty"`
+
+	// LearnedHostKey stores host key in authorized_keys format learned on first connection,
+	// when trust on first use is used. It is managed automatically and persisted in the state.
+	LearnedHostKey string `json:"learnedHostKey,omitempty"`
+
+	// InsecureIgnoreHostKey disables verification of host keys, which makes connections
+	// vulnerable to man-in-the-middle attacks. It should only be used for testing.
+	//
+	// It can't be used together with other host key verification methods.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty"`
+
+	// JumpHosts is a list of bastion hosts, which will be used to reach the host, similar
+	// to OpenSSH ProxyJump option. The first jump host is dialed directly and each next host,
+	// including the final one, is dialed through the previous one.
+	//
+	// Jump hosts can't define their own jump hosts.
+	JumpHosts []*Config `json:"jumpHosts,omitempty"`
+
+	// KeepaliveInterval defines how often keepalive requests are sent to the server to detect
+	// dead connections, e.g. '30s'. Dead connection is re-established automatically when
+	// it is used next time. Set to '0' to disable keepalives.
+	//
+	// This field is optional. If empty, value from KeepaliveInterval constant will be used.
+	KeepaliveInterval string `json:"keepaliveInterval,omitempty"`
+
+	// KeepaliveCountMax defines how many keepalive requests in a row may be left unanswered,
+	// before the connection is considered dead.
+	//
+	// This field is optional. If empty, value from KeepaliveCountMax constant will be used.
+	KeepaliveCountMax int `json:"keepaliveCountMax,omitempty"`
+
+	// ForwardAgent exposes local SSH agent from SSH_AUTH_SOCK environment variable to
+	// commands executed on the host, e.g. so hooks can use git or ssh with user's keys.
+	// It is similar to OpenSSH ForwardAgent option and it is disabled by default, as
+	// anyone with root access to the host can use forwarded agent while connected.
+	ForwardAgent bool `json:"forwardAgent,omitempty"`
 }
 
 // ssh is an implementation of Transport interface over SSH protocol.
@@ -62,20 +145,65 @@ type ssh struct {
 	retryTimeout      time.Duration
 	retryInterval     time.Duration
 	auth              []gossh.AuthMethod
-	sshClientGetter   func(network, address string, config *gossh.ClientConfig) (*gossh.Client, error)
+	hostKeyCallback   gossh.HostKeyCallback
+	jumpHosts         []*ssh
+	keepaliveInterval time.Duration
+	keepaliveCountMax int
+	agentSocket       string
+	forwardAgent      bool
+	sshClientGetter   func(ctx context.Context, via dialer, network, address string, config *gossh.ClientConfig) (*gossh.Client, error)
 }
 
 type sshConnected struct {
-	client   dialer
 	address  string
 	uuid     func() (uuid.UUID, error)
 	listener func(string, string) (net.Listener, error)
+
+	// client is a connection to the host. It is set to nil, when connection is detected
+	// to be dead and re-established on next use, if reconnect is set.
+	client dialer
+
+	// closers are closed in order when the connection is closed.
+	closers     []io.Closer
+	closed      bool
+	clientMutex sync.Mutex
+
+	// reconnect establishes new connection to the host, including jump hosts. If nil,
+	// dead connection is not re-established.
+	reconnect func(ctx context.Context) (dialer, []io.Closer, error)
+
+	keepaliveInterval time.Duration
+	keepaliveCountMax int
+
+	// forwardAgent requests SSH agent forwarding for executed commands.
+	forwardAgent bool
+
+	// listeners stores all listeners opened for forwarding, so they can be
+	// closed together with the connection.
+	listeners      []net.Listener
+	listenersMutex sync.Mutex
+
+	// forwardingErrors stores errors, which occurred while forwarding connections
+	// in the background, until they are collected.
+	forwardingErrors      util.ValidateError
+	forwardingErrorsMutex sync.Mutex
 }
 
 type dialer interface {
 	Dial(network, address string) (net.Conn, error)
 }
 
+// keepaliver is implemented by SSH clients, which can be checked for being alive.
+type keepaliver interface {
+	SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error)
+	Wait() error
+}
+
+// sessionOpener is implemented by SSH clients, which can open sessions for executing commands.
+type sessionOpener interface {
+	NewSession() (*gossh.Session, error)
+}
+
 // New validates SSH configuration and returns new instance of transport interface.
 func (d *Config) New() (transport.Interface, error) {
 	if err := d.Validate(); err != nil {
@@ -86,6 +214,7 @@ func (d *Config) New() (transport.Interface, error) {
 	ct, _ := time.ParseDuration(d.ConnectionTimeout)
 	rt, _ := time.ParseDuration(d.RetryTimeout)
 	ri, _ := time.ParseDuration(d.RetryInterval)
+	ki, _ := time.ParseDuration(util.PickString(d.KeepaliveInterval, KeepaliveInterval))
 
 	s := &ssh{
 		address:           fmt.Sprintf("%s:%d", d.Address, d.Port),
@@ -93,38 +222,33 @@ func (d *Config) New() (transport.Interface, error) {
 		connectionTimeout: ct,
 		retryTimeout:      rt,
 		retryInterval:     ri,
+		keepaliveInterval: ki,
+		keepaliveCountMax: util.PickInt(d.KeepaliveCountMax, KeepaliveCountMax),
+		agentSocket:       os.Getenv(SSHAuthSockEnv),
+		forwardAgent:      d.ForwardAgent,
 		auth:              []gossh.AuthMethod{},
-		sshClientGetter:   gossh.Dial,
+		hostKeyCallback:   d.hostKeyCallback(),
+		sshClientGetter:   dialContext,
 	}
 
 	if d.Password != "" {
 		s.auth = append(s.auth, gossh.Password(d.Password))
 	}
 
-	if signer, _ := gossh.ParsePrivateKey([]byte(d.PrivateKey)); d.PrivateKey != "" {
-		s.auth = append(s.auth, gossh.PublicKeys(signer))
-	}
+	// Validate checks, that credentials can be read and parsed, so we can skip error checking here.
+	privateKey, certificate, _ := d.credentials()
 
-	// Multiple auth methods might be used, so if SSH_AUTH_SOCK is defined, try to use it
-	// automatically. That gives nice user experience, when user don't have to specify any
-	// authentication information explicitly.
-	if authSock := os.Getenv(SSHAuthSockEnv); authSock != "" {
-		authConn, err := net.Dial("unix", authSock)
-		if err != nil {
-			return nil, fmt.Errorf("dialing SSH agent failed: %w", err)
-		}
-		// TODO: We should close the authSock with Close() after we finish using it,
-		// but it is not trivial at the moment, so we just let the dying process to
-		// close it.
-		//
-		// defer authConn.Close()
+	if signers, _ := privateKeySigners(privateKey, certificate); privateKey != "" {
+		s.auth = append(s.auth, gossh.PublicKeys(signers...))
+	}
 
-		signers, err := agent.NewClient(authConn).Signers()
+	for i, j := range d.JumpHosts {
+		jt, err := j.New()
 		if err != nil {
-			return nil, fmt.Errorf("getting public keys from SSH agent failed: %w", err)
+			return nil, fmt.Errorf("initializing jump host %d: %w", i, err)
 		}
 
-		s.auth = append(s.auth, gossh.PublicKeys(signers...))
+		s.jumpHosts = append(s.jumpHosts, jt.(*ssh))
 	}
 
 	return s, nil
@@ -142,7 +266,7 @@ func (d *Config) Validate() error {
 		errors = append(errors, fmt.Errorf("user must be set"))
 	}
 
-	if d.Password == "" && d.PrivateKey == "" && os.Getenv(SSHAuthSockEnv) == "" {
+	if d.Password == "" && d.PrivateKey == "" && d.PrivateKeyFile == "" && os.Getenv(SSHAuthSockEnv) == "" {
 		errors = append(errors, fmt.Errorf("at least one authentication method must be available"))
 	}
 
@@ -163,25 +287,308 @@ func (d *Config) Validate() error {
 		errors = append(errors, fmt.Errorf("unable to parse retry interval: %w", err))
 	}
 
-	if _, err := gossh.ParsePrivateKey([]byte(d.PrivateKey)); d.PrivateKey != "" && err != nil {
-		errors = append(errors, fmt.Errorf("unable to parse private key: %w", err))
+	if _, err := time.ParseDuration(d.KeepaliveInterval); d.KeepaliveInterval != "" && err != nil {
+		errors = append(errors, fmt.Errorf("unable to parse keepalive interval: %w", err))
+	}
+
+	if d.KeepaliveCountMax < 0 {
+		errors = append(errors, fmt.Errorf("keepalive count max can't be negative"))
+	}
+
+	if d.ForwardAgent && os.Getenv(SSHAuthSockEnv) == "" {
+		errors = append(errors, fmt.Errorf("forwarding agent requires %s environment variable to be set", SSHAuthSockEnv))
+	}
+
+	if err := d.validateCredentials(); err != nil {
+		errors = append(errors, fmt.Errorf("validating credentials: %w", err))
+	}
+
+	if err := d.validateOpenSSHConfig(); err != nil {
+		errors = append(errors, fmt.Errorf("validating OpenSSH configuration: %w", err))
+	}
+
+	if err := d.validateHostKeys(); err != nil {
+		errors = append(errors, fmt.Errorf("validating host key verification: %w", err))
+	}
+
+	for i, j := range d.JumpHosts {
+		if j == nil {
+			errors = append(errors, fmt.Errorf("jump host %d must be defined", i))
+
+			continue
+		}
+
+		if len(j.JumpHosts) != 0 {
+			errors = append(errors, fmt.Errorf("jump host %d can't define jump hosts", i))
+		}
+
+		if err := j.Validate(); err != nil {
+			errors = append(errors, fmt.Errorf("validating jump host %d: %w", i, err))
+		}
 	}
 
 	return errors.Return()
 }
 
-// Connect opens SSH connection to configured host.
-func (d *ssh) Connect() (transport.Connected, error) {
+// dialContext opens SSH connection to given address. It is similar to gossh.Dial, but
+// allows to cancel the dialing using given context. If via is not nil, connection is
+// dialed through it, e.g. through SSH client connected to jump host.
+//
+// Neither dialing through jump host nor SSH handshake support context, so the deadline
+// of given context is set on the connection and the connection is closed when the context
+// is cancelled, to not block on unresponsive hosts.
+func dialContext(ctx context.Context, via dialer, network, address string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	var conn net.Conn
+
+	var err error
+
+	if via != nil {
+		conn, err = dialVia(ctx, via, network, address)
+	} else {
+		d := &net.Dialer{
+			Timeout: config.Timeout,
+		}
+
+		conn, err = d.DialContext(ctx, network, address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("dialing: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			closeConn(conn)
+
+			return nil, fmt.Errorf("setting connection deadline: %w", err)
+		}
+	}
+
+	stop := closeOnDone(ctx, conn)
+
+	c, chans, reqs, err := gossh.NewClientConn(conn, address, config)
+
+	if closed := stop(); closed || ctx.Err() != nil {
+		if !closed {
+			closeConn(conn)
+		}
+
+		return nil, fmt.Errorf("establishing SSH connection: %w", ctx.Err())
+	}
+
+	if err != nil {
+		closeConn(conn)
+
+		return nil, fmt.Errorf("establishing SSH connection: %w", err)
+	}
+
+	// Established connection should not be affected by the context deadline.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		closeConn(conn)
+
+		return nil, fmt.Errorf("clearing connection deadline: %w", err)
+	}
+
+	return gossh.NewClient(c, chans, reqs), nil
+}
+
+// dialVia dials given address through given dialer, returning early when given context
+// is cancelled. Connection established after cancellation is closed.
+func dialVia(ctx context.Context, via dialer, network, address string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		conn, err := via.Dial(network, address)
+		results <- result{conn, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-results; r.conn != nil {
+				closeConn(r.conn)
+			}
+		}()
+
+		return nil, ctx.Err()
+	}
+}
+
+// closeOnDone closes given connection when given context is cancelled. Returned function
+// stops watching the context and reports, if the connection has been closed. It must be
+// called once the connection is no longer at risk of blocking.
+func closeOnDone(ctx context.Context, conn net.Conn) func() bool {
+	done := make(chan struct{})
+	closed := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeConn(conn)
+			closed <- true
+		case <-done:
+			closed <- false
+		}
+	}()
+
+	return func() bool {
+		close(done)
+
+		return <-closed
+	}
+}
+
+// closeConn closes given connection, printing an error if closing fails.
+func closeConn(conn net.Conn) {
+	if err := conn.Close(); err != nil {
+		fmt.Printf("failed closing connection: %v\n", err)
+	}
+}
+
+// Connect opens SSH connection to configured host, going through configured jump hosts.
+// Retrying the connection stops when given context is cancelled.
+//
+// If keepalives are enabled, returned connection is monitored and when it is detected
+// to be dead, it is re-established on next use.
+func (d *ssh) Connect(ctx context.Context) (transport.Connected, error) {
+	connection, closers, err := d.connectChain(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConnected(d.address, connection).(*sshConnected)
+	c.closers = closers
+	c.reconnect = d.connectChain
+	c.keepaliveInterval = d.keepaliveInterval
+	c.keepaliveCountMax = d.keepaliveCountMax
+	c.forwardAgent = d.forwardAgent
+
+	c.watch(connection)
+
+	return c, nil
+}
+
+// connectChain opens SSH connection to configured host through configured jump hosts and
+// returns the connection together with all opened clients, which must be closed in given order.
+//
+// If SSH agent is available, connection to it is opened as well and it is closed as the last one,
+// as it is used for authenticating to all hosts in the chain.
+func (d *ssh) connectChain(ctx context.Context) (dialer, []io.Closer, error) {
+	agentConn, err := d.dialAgent()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var via dialer
+
+	clients := []*gossh.Client{}
+
+	for i, j := range d.jumpHosts {
+		c, err := j.connect(ctx, via, agentConn)
+		if err != nil {
+			closeClients(clients, agentConn)
+
+			return nil, nil, fmt.Errorf("connecting to jump host %d: %w", i, err)
+		}
+
+		clients = append(clients, c)
+		via = c
+	}
+
+	connection, err := d.connect(ctx, via, agentConn)
+	if err != nil {
+		closeClients(clients, agentConn)
+
+		return nil, nil, err
+	}
+
+	if d.forwardAgent {
+		if err := agent.ForwardToRemote(connection, d.agentSocket); err != nil {
+			closeClients(append(clients, connection), agentConn)
+
+			return nil, nil, fmt.Errorf("forwarding SSH agent: %w", err)
+		}
+	}
+
+	// Close connection to the host first and then to jump hosts in reverse order.
+	closers := []io.Closer{connection}
+
+	for i := len(clients) - 1; i >= 0; i-- {
+		closers = append(closers, clients[i])
+	}
+
+	if agentConn != nil {
+		closers = append(closers, agentConn)
+	}
+
+	return connection, closers, nil
+}
+
+// dialAgent opens connection to SSH agent, if it is available. If it is not, nil
+// connection is returned.
+func (d *ssh) dialAgent() (net.Conn, error) {
+	if d.agentSocket == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("unix", d.agentSocket)
+	if err != nil {
+		return nil, fmt.Errorf("dialing SSH agent failed: %w", err)
+	}
+
+	return conn, nil
+}
+
+// closeClients closes given SSH clients in reverse order and then given connection to
+// SSH agent, if it is not nil. Errors are only logged, as this is used for cleanup after
+// other failure.
+func closeClients(clients []*gossh.Client, agentConn net.Conn) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		if err := clients[i].Close(); err != nil {
+			fmt.Printf("failed closing SSH connection: %v\n", err)
+		}
+	}
+
+	if agentConn == nil {
+		return
+	}
+
+	if err := agentConn.Close(); err != nil {
+		fmt.Printf("failed closing SSH agent connection: %v\n", err)
+	}
+}
+
+// connect opens SSH connection to configured host, optionally through given dialer
+// and retries until retry timeout is reached or given context is cancelled.
+//
+// If given connection to SSH agent is not nil, keys from the agent are used as one of
+// the authentication methods. That gives nice user experience, when user don't have to
+// specify any authentication information explicitly.
+func (d *ssh) connect(ctx context.Context, via dialer, agentConn net.Conn) (*gossh.Client, error) {
+	var hostKeyErr error
+
+	auth := append([]gossh.AuthMethod{}, d.auth...)
+
+	if agentConn != nil {
+		auth = append(auth, gossh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
 	sshConfig := &gossh.ClientConfig{
-		Auth:    d.auth,
+		Auth:    auth,
 		Timeout: d.connectionTimeout,
 		User:    d.user,
-		// TODO: Add possibility to specify host keys, which should be accepted.
-		// Since user may not know the public keys of their server, for convenience,
-		// allow insecure host keys.
-		//
-		// #nosec G106
-		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		HostKeyCallback: func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+			hostKeyErr = d.hostKeyCallback(hostname, remote, key)
+
+			return hostKeyErr
+		},
 	}
 
 	var connection *gossh.Client
@@ -192,11 +599,20 @@ func (d *ssh) Connect() (transport.Connected, error) {
 
 	// Try until we timeout.
 	for time.Since(start) < d.retryTimeout {
-		if connection, err = d.sshClientGetter("tcp", d.address, sshConfig); err == nil {
-			return newConnected(d.address, connection), nil
+		if connection, err = d.sshClientGetter(ctx, via, "tcp", d.address, sshConfig); err == nil {
+			return connection, nil
+		}
+
+		// Rejected host key won't change between the attempts, so don't retry.
+		if hostKeyErr != nil {
+			return nil, fmt.Errorf("verifying host key: %w", hostKeyErr)
 		}
 
-		time.Sleep(d.retryInterval)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connecting cancelled, last error: %v: %w", err, ctx.Err())
+		case <-time.After(d.retryInterval):
+		}
 	}
 
 	return nil, err
@@ -211,9 +627,191 @@ func newConnected(address string, connection dialer) transport.Connected {
 	}
 }
 
+// watch monitors given client in the background and marks it as dead, when the
+// underlying connection gets closed or when it stops responding to keepalive requests.
+func (d *sshConnected) watch(client dialer) {
+	k, ok := client.(keepaliver)
+	if !ok {
+		return
+	}
+
+	// There is nothing to monitor without the underlying connection.
+	if c, ok := client.(*gossh.Client); ok && c == nil {
+		return
+	}
+
+	closed := make(chan struct{})
+
+	go func() {
+		// Wait returns error describing why the connection has been closed, which
+		// is not interesting, as dead connection is re-established anyway.
+		_ = k.Wait()
+
+		close(closed)
+	}()
+
+	go func() {
+		if d.keepaliveInterval > 0 {
+			d.keepalive(k, closed)
+		} else {
+			<-closed
+		}
+
+		d.markDead(client)
+	}()
+}
+
+// keepalive sends keepalive requests using given client in configured interval and returns,
+// when too many requests in a row are not answered or when given channel gets closed.
+//
+// New request is not sent while previous one is still waiting for a reply, so hanging
+// connection does not accumulate goroutines blocked on sending requests. Each interval
+// without a reply is counted as a missed request.
+func (d *sshConnected) keepalive(k keepaliver, closed <-chan struct{}) {
+	ticker := time.NewTicker(d.keepaliveInterval)
+	defer ticker.Stop()
+
+	missed := 0
+
+	// replied is set while keepalive request is in progress.
+	var replied chan error
+
+	for missed < d.keepaliveCountMax {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		if replied == nil {
+			replied = make(chan error, 1)
+
+			go func(replied chan<- error) {
+				// Servers reply with failure to unknown requests, which still proves the connection is alive.
+				_, _, err := k.SendRequest("keepalive@openssh.com", true, nil)
+				replied <- err
+			}(replied)
+		}
+
+		select {
+		case <-closed:
+			return
+		case err := <-replied:
+			replied = nil
+
+			if err != nil {
+				missed++
+
+				continue
+			}
+
+			missed = 0
+		case <-time.After(d.keepaliveInterval):
+			missed++
+		}
+	}
+
+	d.reportError(fmt.Errorf("connection to %s is dead: %d keepalive requests in a row left unanswered", d.address, missed))
+}
+
+// markDead closes given client, if it is the current connection to the host, so new
+// connection will be established on next use.
+func (d *sshConnected) markDead(client dialer) {
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	if d.client != client || d.closed {
+		return
+	}
+
+	// Connection is dead already, so errors from closing it are not interesting.
+	for _, c := range d.closers {
+		_ = c.Close()
+	}
+
+	d.client = nil
+	d.closers = nil
+}
+
+// getClient returns current connection to the host. If connection has been detected to be dead,
+// new connection is established.
+func (d *sshConnected) getClient(ctx context.Context) (dialer, error) {
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	if d.closed || d.reconnect == nil {
+		return nil, fmt.Errorf("connection to %s is closed", d.address)
+	}
+
+	client, closers, err := d.reconnect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reconnecting to %s: %w", d.address, err)
+	}
+
+	d.client = client
+	d.closers = closers
+
+	d.watch(client)
+
+	return client, nil
+}
+
+// dial opens connection to given remote address using current connection to the host.
+//
+// If opening fails because of the connection to the host is broken, it is re-established
+// and opening is retried once.
+func (d *sshConnected) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.Dial(network, address)
+
+	var openErr *gossh.OpenChannelError
+
+	// If server rejected the request, connection is fine, so don't retry.
+	if err == nil || errors.As(err, &openErr) || d.reconnect == nil {
+		return conn, err
+	}
+
+	d.markDead(client)
+
+	if client, err = d.getClient(ctx); err != nil {
+		return nil, err
+	}
+
+	return client.Dial(network, address)
+}
+
+// reportError stores given error, which occurred while forwarding, so it can be collected
+// using ForwardingErrors.
+func (d *sshConnected) reportError(err error) {
+	d.forwardingErrorsMutex.Lock()
+	defer d.forwardingErrorsMutex.Unlock()
+
+	d.forwardingErrors = append(d.forwardingErrors, err)
+}
+
+// ForwardingErrors implements transport.ErrorReporter interface.
+func (d *sshConnected) ForwardingErrors() error {
+	d.forwardingErrorsMutex.Lock()
+	defer d.forwardingErrorsMutex.Unlock()
+
+	err := d.forwardingErrors.Return()
+
+	d.forwardingErrors = nil
+
+	return err
+}
+
 // ForwardUnixSocket takes remote UNIX socket path as an argument and forwards
 // it to the local socket.
-func (d *sshConnected) ForwardUnixSocket(path string) (string, error) {
+func (d *sshConnected) ForwardUnixSocket(ctx context.Context, path string) (string, error) {
 	unixAddr, err := d.randomUnixSocket()
 	if err != nil {
 		return "", fmt.Errorf("failed generating random socket to listen: %w", err)
@@ -229,71 +827,110 @@ func (d *sshConnected) ForwardUnixSocket(path string) (string, error) {
 		return "", fmt.Errorf("failed parsing path %s: %w", path, err)
 	}
 
+	d.trackListener(localSock)
+
 	// Schedule accepting connections and return.
-	go forwardConnection(localSock, d.client, path, "unix")
+	go d.forwardConnection(ctx, localSock, path, "unix")
 
 	return fmt.Sprintf("unix://%s", unixAddr.String()), nil
 }
 
 // handleClient is responsible for copying incoming and outgoing data going
-// through the forwarded connection.
-func handleClient(client net.Conn, remote io.ReadWriter) {
+// through the forwarded connection. It returns the first error, which occurred
+// while copying.
+func handleClient(client net.Conn, remote io.ReadWriter) error {
 	defer func() {
-		if err := client.Close(); err != nil {
-			fmt.Printf("failed closing client connection: %v", err)
-		}
+		// Connection might be closed already by the other side.
+		_ = client.Close()
 	}()
 
-	chDone := make(chan bool)
+	chDone := make(chan error, 2)
 
 	// Start remote -> local data transfer.
 	go func() {
-		if _, err := io.Copy(client, remote); err != nil {
-			fmt.Printf("error while copy remote->local: %s\n", err)
-		}
-		chDone <- true
+		_, err := io.Copy(client, remote)
+		chDone <- copyError("remote->local", err)
 	}()
 
 	// Start local -> remote data transfer.
 	go func() {
-		if _, err := io.Copy(remote, client); err != nil {
-			fmt.Printf("error while copy local->remote: %s\n", err)
-		}
-		chDone <- true
+		_, err := io.Copy(remote, client)
+		chDone <- copyError("local->remote", err)
 	}()
 
-	<-chDone
+	return <-chDone
 }
 
-// forwardConnection accepts local connections, and forwards them to remote address.
+// copyError wraps given error from copying data in given direction.
 //
-// TODO: Should we do some error handling here?
-func forwardConnection(l net.Listener, connection dialer, remoteAddress, connectionType string) {
-	defer func() {
-		if err := l.Close(); err != nil {
-			fmt.Printf("failed closing listener: %v", err)
+// Connections are only closed by handleClient after the first copy finishes, so errors
+// caused by closing the connection locally are never returned and there is no need to
+// filter them.
+func copyError(direction string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("copying data %s: %w", direction, err)
+}
+
+// forwardConnection accepts local connections, and forwards them to remote address
+// until given context is cancelled.
+//
+// If opening remote connection fails, local connection is closed and error is reported, but
+// accepting connections continues, as connection to the host might be re-established.
+func (d *sshConnected) forwardConnection(ctx context.Context, l net.Listener, remoteAddress, connectionType string) {
+	done := make(chan struct{})
+
+	defer close(done)
+
+	// Close the listener either when the context is cancelled or when accepting
+	// connections fails, which also unblocks pending Accept() call.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
 		}
+
+		// Listener might be closed already together with the connection.
+		_ = l.Close()
 	}()
 
 	for {
 		// Accept connection from the client.
 		c, err := l.Accept()
 		if err != nil {
-			fmt.Printf("failed to accept connection: %v\n", err)
-			// Handle error (and then for example indicate acceptor is down).
+			// Listener gets closed when context is cancelled or connection is closed,
+			// so this is expected.
+			if ctx.Err() == nil && d.isTracked(l) {
+				d.reportError(fmt.Errorf("accepting connection for %s: %w", remoteAddress, err))
+			}
+
 			return
 		}
 
-		// Open remote connection.
-		remoteSock, err := connection.Dial(connectionType, remoteAddress)
-		if err != nil {
-			fmt.Printf("failed to open remote connection: %v\n", err)
+		go d.handleConnection(ctx, c, remoteAddress, connectionType)
+	}
+}
 
-			return
-		}
+// handleConnection opens remote connection for given local connection and copies the data
+// between them, reporting all errors.
+func (d *sshConnected) handleConnection(ctx context.Context, c net.Conn, remoteAddress, connectionType string) {
+	remote, err := d.dial(ctx, connectionType, remoteAddress)
+	if err != nil {
+		d.reportError(fmt.Errorf("opening remote connection to %s: %w", remoteAddress, err))
+
+		_ = c.Close()
+
+		return
+	}
+
+	defer func() {
+		_ = remote.Close()
+	}()
 
-		// Schedule data transfers.
-		go handleClient(c, remoteSock)
+	if err := handleClient(c, remote); err != nil {
+		d.reportError(fmt.Errorf("forwarding connection to %s: %w", remoteAddress, err))
 	}
 }
 
@@ -330,7 +967,7 @@ func (d *sshConnected) randomUnixSocket() (*net.UnixAddr, error) {
 
 // ForwardTCP takes remote TCP address, starts listening on local port and forwards all incoming
 // connections to local address to remote address using estabilshed SSH tunnel.
-func (d *sshConnected) ForwardTCP(address string) (string, error) {
+func (d *sshConnected) ForwardTCP(ctx context.Context, address string) (string, error) {
 	if _, _, err := net.SplitHostPort(address); err != nil {
 		return "", fmt.Errorf("failed to validate address '%s': %w", address, err)
 	}
@@ -340,8 +977,128 @@ func (d *sshConnected) ForwardTCP(address string) (string, error) {
 		return "", fmt.Errorf("unable to listen on random TCP port: %w", err)
 	}
 
+	d.trackListener(localConn)
+
 	// Schedule accepting connections and return.
-	go forwardConnection(localConn, d.client, address, "tcp")
+	go d.forwardConnection(ctx, localConn, address, "tcp")
 
 	return localConn.Addr().String(), nil
 }
+
+// trackListener stores given listener, so it gets closed when connection is closed.
+func (d *sshConnected) trackListener(l net.Listener) {
+	d.listenersMutex.Lock()
+	defer d.listenersMutex.Unlock()
+
+	d.listeners = append(d.listeners, l)
+}
+
+// isTracked returns true, if given listener is still tracked, which means it has not been
+// closed together with the connection.
+func (d *sshConnected) isTracked(l net.Listener) bool {
+	d.listenersMutex.Lock()
+	defer d.listenersMutex.Unlock()
+
+	for _, tl := range d.listeners {
+		if tl == l {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Exec executes given command on the host using new SSH session. If context is cancelled,
+// the session is closed.
+func (d *sshConnected) Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error) {
+	client, err := d.getClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting connection: %w", err)
+	}
+
+	c, ok := client.(sessionOpener)
+	if !ok {
+		return nil, fmt.Errorf("connection does not support opening sessions")
+	}
+
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("opening session: %w", err)
+	}
+
+	defer session.Close() //nolint:errcheck
+
+	if d.forwardAgent {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return nil, fmt.Errorf("requesting SSH agent forwarding: %w", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("running command %q: %w", command, ctx.Err())
+	case err = <-done:
+	}
+
+	var exitErr *gossh.ExitError
+
+	if errors.As(err, &exitErr) {
+		return stdout.Bytes(), &transport.ExitError{
+			Command:  command,
+			ExitCode: exitErr.ExitStatus(),
+			Stderr:   stderr.String(),
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("running command %q: %w", command, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Close stops all forwarding and closes the SSH connection, including connections
+// to jump hosts.
+func (d *sshConnected) Close() error {
+	d.listenersMutex.Lock()
+
+	for _, l := range d.listeners {
+		// Listener might be closed already, if forwarding has been stopped, so ignore the error.
+		_ = l.Close()
+	}
+
+	d.listeners = nil
+
+	d.listenersMutex.Unlock()
+
+	d.clientMutex.Lock()
+	defer d.clientMutex.Unlock()
+
+	// Make sure connection is not re-established after closing.
+	d.closed = true
+
+	var errors util.ValidateError
+
+	for _, c := range d.closers {
+		if err := c.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing SSH connection: %w", err))
+		}
+	}
+
+	d.closers = nil
+	d.client = nil
+
+	return errors.Return()
+}
@@ -3,6 +3,7 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -39,7 +40,7 @@ func TestPasswordAuth(t *testing.T) {
 		t.Fatalf("creating new SSH object should succeed, got: %v", err)
 	}
 
-	if _, err := s.Connect(); err != nil {
+	if _, err := s.Connect(context.Background()); err != nil {
 		t.Fatalf("connecting should succeed, got: %v", err)
 	}
 }
@@ -60,7 +61,7 @@ func TestPasswordAuthFail(t *testing.T) {
 		t.Fatalf("creating new SSH object should succeed, got: %v", err)
 	}
 
-	if _, err := s.Connect(); err == nil {
+	if _, err := s.Connect(context.Background()); err == nil {
 		t.Fatalf("connecting with bad password should fail")
 	}
 }
@@ -68,7 +69,7 @@ func TestPasswordAuthFail(t *testing.T) {
 func TestPrivateKeyAuth(t *testing.T) {
 	s := withPrivateKey(t)
 
-	if _, err := s.Connect(); err != nil {
+	if _, err := s.Connect(context.Background()); err != nil {
 		t.Fatalf("connecting should succeed, got: %v", err)
 	}
 }
@@ -102,12 +103,12 @@ func TestForwardUnixSocketFull(t *testing.T) {
 	expectedMessage := "foo"
 	expectedResponse := "bar"
 
-	c, err := ssh.Connect()
+	c, err := ssh.Connect(context.Background())
 	if err != nil {
 		t.Fatalf("Connecting should succeed, got: %v", err)
 	}
 
-	s, err := c.ForwardUnixSocket(fmt.Sprintf("unix://%s", testServerAddr))
+	s, err := c.ForwardUnixSocket(context.Background(), fmt.Sprintf("unix://%s", testServerAddr))
 	if err != nil {
 		t.Fatalf("forwarding should succeed, got: %v", err)
 	}
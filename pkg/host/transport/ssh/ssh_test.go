@@ -1,15 +1,21 @@
 package ssh
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +23,8 @@ import (
 	"github.com/google/uuid"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 )
 
 const (
@@ -267,6 +275,50 @@ func TestValidateParsePrivateKey(t *testing.T) {
 	}
 }
 
+func TestValidateJumpHosts(t *testing.T) {
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		ConnectionTimeout: "30s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		Password:          "foo",
+		JumpHosts: []*Config{
+			{
+				Address: "bastion",
+			},
+		},
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("validating SSH configuration should validate jump hosts")
+	}
+}
+
+func TestValidateNestedJumpHosts(t *testing.T) {
+	j := &Config{
+		Address:           "bastion",
+		User:              "root",
+		ConnectionTimeout: "30s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		Password:          "foo",
+	}
+
+	nj := *j
+	nj.JumpHosts = []*Config{j}
+
+	c := *j
+	c.Address = "localhost"
+	c.JumpHosts = []*Config{&nj}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("validating SSH configuration should reject jump hosts with own jump hosts")
+	}
+}
+
 func generateRSAPrivateKey(t *testing.T) string {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -421,7 +473,7 @@ func TestForwardConnection(t *testing.T) {
 		t.Fatalf("unable to listen on random TCP port: %v", err)
 	}
 
-	go forwardConnection(l, &net.Dialer{}, r.Addr().String(), "tcp")
+	go newConnected("localhost:80", &net.Dialer{}).(*sshConnected).forwardConnection(context.Background(), l, r.Addr().String(), "tcp")
 
 	conn, err := net.Dial("tcp", l.Addr().String())
 	if err != nil {
@@ -461,17 +513,23 @@ func TestForwardConnectionBadType(t *testing.T) {
 		t.Fatalf("unable to listen on random TCP port: %v", err)
 	}
 
-	go forwardConnection(l, &net.Dialer{}, r.Addr().String(), "doh")
+	d := newConnected("localhost:80", &net.Dialer{}).(*sshConnected)
+
+	go d.forwardConnection(context.Background(), l, r.Addr().String(), "doh")
 
-	// Try to open connection, so forwarding loop breaks.
 	if _, err := net.Dial("tcp", l.Addr().String()); err != nil {
-		t.Logf("Opening first connection should succeed, got: %v", err)
+		t.Fatalf("Opening first connection should succeed, got: %v", err)
 	}
 
 	time.Sleep(time.Second)
 
-	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
-		t.Fatalf("Opening connection to bad type should fail")
+	if err := d.ForwardingErrors(); err == nil {
+		t.Fatalf("Failed dial should be reported as forwarding error")
+	}
+
+	// Failing to dial remote address should not stop forwarding other connections.
+	if _, err := net.Dial("tcp", l.Addr().String()); err != nil {
+		t.Fatalf("Opening connection after failed dial should succeed, got: %v", err)
 	}
 }
 
@@ -490,13 +548,41 @@ func TestForwardConnectionClosedListener(t *testing.T) {
 		t.Fatalf("unable to listen on random TCP port: %v", err)
 	}
 
-	go forwardConnection(l, &net.Dialer{}, r.Addr().String(), "tcp")
+	go newConnected("localhost:80", &net.Dialer{}).(*sshConnected).forwardConnection(context.Background(), l, r.Addr().String(), "tcp")
 
 	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
 		t.Fatalf("Opening connection to closed listener should fail")
 	}
 }
 
+func TestForwardConnectionCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen on random TCP port: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+
+	go func() {
+		newConnected("localhost:80", &net.Dialer{}).(*sshConnected).forwardConnection(ctx, l, "127.0.0.1:0", "tcp")
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Forwarding should stop when context is cancelled")
+	}
+
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatalf("Listener should be closed when context is cancelled")
+	}
+}
+
 // Connect() tests.
 func TestConnect(t *testing.T) {
 	c := &Config{
@@ -517,11 +603,11 @@ func TestConnect(t *testing.T) {
 
 	ss := s.(*ssh)
 
-	ss.sshClientGetter = func(n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
 		return nil, nil
 	}
 
-	if _, err := ss.Connect(); err != nil {
+	if _, err := ss.Connect(context.Background()); err != nil {
 		t.Fatalf("Connecting should succeed, got: %v", err)
 	}
 }
@@ -545,11 +631,143 @@ func TestConnectFail(t *testing.T) {
 
 	ss := s.(*ssh)
 
-	ss.sshClientGetter = func(n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
 		return nil, fmt.Errorf("expected")
 	}
 
-	if _, err := ss.Connect(); err == nil {
+	if _, err := ss.Connect(context.Background()); err == nil {
+		t.Fatalf("Connecting should fail")
+	}
+}
+
+func TestConnectCancel(t *testing.T) {
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		Password:          "foo",
+		ConnectionTimeout: "1s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		PrivateKey:        generateRSAPrivateKey(t),
+	}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("creating new SSH object should succeed, got: %s", err)
+	}
+
+	ss := s.(*ssh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		cancel()
+
+		return nil, fmt.Errorf("expected")
+	}
+
+	start := time.Now()
+
+	if _, err := ss.Connect(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Connecting should fail with cancelled error, got: %v", err)
+	}
+
+	if time.Since(start) > 30*time.Second {
+		t.Fatalf("Connecting should not retry after context is cancelled")
+	}
+}
+
+func TestConnectJumpHosts(t *testing.T) {
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		ConnectionTimeout: "1s",
+		RetryTimeout:      "1s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		PrivateKey:        generateRSAPrivateKey(t),
+	}
+
+	j := *c
+	j.Address = "bastion"
+	j.User = "jump"
+
+	c.JumpHosts = []*Config{&j}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("creating new SSH object should succeed, got: %s", err)
+	}
+
+	ss := s.(*ssh)
+
+	dialed := []string{}
+
+	getter := func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		if len(dialed) == 0 && via != nil {
+			t.Errorf("First jump host should be dialed directly")
+		}
+
+		if len(dialed) == 1 && via == nil {
+			t.Errorf("Host should be dialed through the jump host")
+		}
+
+		dialed = append(dialed, config.User+"@"+a)
+
+		return testSSHClient(t, func(string, gossh.Channel) uint32 {
+			return 0
+		}), nil
+	}
+
+	ss.sshClientGetter = getter
+	ss.jumpHosts[0].sshClientGetter = getter
+
+	if _, err := ss.Connect(context.Background()); err != nil {
+		t.Fatalf("Connecting should succeed, got: %v", err)
+	}
+
+	expected := []string{"jump@bastion:22", "root@localhost:22"}
+
+	if diff := cmp.Diff(expected, dialed); diff != "" {
+		t.Fatalf("Unexpected dialing order: %s", diff)
+	}
+}
+
+func TestConnectJumpHostFail(t *testing.T) {
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		ConnectionTimeout: "1s",
+		RetryTimeout:      "1s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		PrivateKey:        generateRSAPrivateKey(t),
+	}
+
+	j := *c
+	j.Address = "bastion"
+
+	c.JumpHosts = []*Config{&j}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("creating new SSH object should succeed, got: %s", err)
+	}
+
+	ss := s.(*ssh)
+
+	ss.jumpHosts[0].sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		return nil, fmt.Errorf("expected")
+	}
+
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		t.Fatalf("Host should not be dialed, when connecting to jump host fails")
+
+		return nil, nil
+	}
+
+	if _, err := ss.Connect(context.Background()); err == nil {
 		t.Fatalf("Connecting should fail")
 	}
 }
@@ -567,7 +785,7 @@ func TestForwardTCP(t *testing.T) {
 		return l, nil
 	}
 
-	if _, err := d.ForwardTCP("localhost:90"); err != nil {
+	if _, err := d.ForwardTCP(context.Background(), "localhost:90"); err != nil {
 		t.Fatalf("Forwarding TCP shouldn't fail, got: %v", err)
 	}
 }
@@ -579,7 +797,7 @@ func TestForwardTCPFailListen(t *testing.T) {
 		return nil, fmt.Errorf("expected")
 	}
 
-	if _, err := d.ForwardTCP("localhost:90"); err == nil {
+	if _, err := d.ForwardTCP(context.Background(), "localhost:90"); err == nil {
 		t.Fatalf("Forwarding TCP should fail")
 	}
 }
@@ -591,7 +809,7 @@ func TestForwardTCPValidateAddress(t *testing.T) {
 		return nil, fmt.Errorf("expected")
 	}
 
-	if _, err := d.ForwardTCP("localhost"); err == nil {
+	if _, err := d.ForwardTCP(context.Background(), "localhost"); err == nil {
 		t.Fatalf("Forwarding TCP should fail when forwarding bad address")
 	}
 }
@@ -604,7 +822,7 @@ func TestForwardUnixSocketNoRandomUnixSocket(t *testing.T) {
 		return uuid.UUID{}, fmt.Errorf("happened")
 	}
 
-	if _, err := d.ForwardUnixSocket("foo"); err == nil {
+	if _, err := d.ForwardUnixSocket(context.Background(), "foo"); err == nil {
 		t.Fatalf("Forwarding with bad unix socket should fail")
 	}
 }
@@ -616,7 +834,7 @@ func TestForwardUnixSocketCantListen(t *testing.T) {
 		return nil, fmt.Errorf("expected")
 	}
 
-	if _, err := d.ForwardUnixSocket("foo"); err == nil {
+	if _, err := d.ForwardUnixSocket(context.Background(), "foo"); err == nil {
 		t.Fatalf("Forwarding with failed listening should fail")
 	}
 }
@@ -624,7 +842,7 @@ func TestForwardUnixSocketCantListen(t *testing.T) {
 func TestForwardUnixSocketBadPath(t *testing.T) {
 	d := newConnected("localhost:80", nil).(*sshConnected)
 
-	if _, err := d.ForwardUnixSocket("foo\t"); err == nil {
+	if _, err := d.ForwardUnixSocket(context.Background(), "foo\t"); err == nil {
 		t.Fatalf("Forwarding with invalid unix socket name should fail")
 	}
 }
@@ -632,7 +850,7 @@ func TestForwardUnixSocketBadPath(t *testing.T) {
 func TestForwardUnixSocket(t *testing.T) {
 	d := newConnected("localhost:80", nil).(*sshConnected)
 
-	if _, err := d.ForwardUnixSocket("unix:///foo"); err != nil {
+	if _, err := d.ForwardUnixSocket(context.Background(), "unix:///foo"); err != nil {
 		t.Fatalf("Forwarding should succeed, got: %v", err)
 	}
 }
@@ -640,12 +858,12 @@ func TestForwardUnixSocket(t *testing.T) {
 func TestForwardUnixSocketEnsureUnique(t *testing.T) {
 	d := newConnected("localhost:80", nil).(*sshConnected)
 
-	a, err := d.ForwardUnixSocket("unix:///foo")
+	a, err := d.ForwardUnixSocket(context.Background(), "unix:///foo")
 	if err != nil {
 		t.Fatalf("forwarding unix socket should succeed, got: %v", err)
 	}
 
-	b, err := d.ForwardUnixSocket("unix:///foo")
+	b, err := d.ForwardUnixSocket(context.Background(), "unix:///foo")
 	if err != nil {
 		t.Fatalf("forwarding 2nd random unix socket should succeed, got: %v", err)
 	}
@@ -655,11 +873,247 @@ func TestForwardUnixSocketEnsureUnique(t *testing.T) {
 	}
 }
 
-func TestNewBadSSHAgentEnv(t *testing.T) {
-	if err := os.Setenv(SSHAuthSockEnv, "foo"); err != nil {
+// testSSHClient starts SSH server listening on random local port, which executes commands
+// using given handler and returns client connected to it.
+func testSSHClient(t *testing.T, handler func(command string, ch gossh.Channel) uint32) *gossh.Client {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating host key should succeed, got: %v", err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("Creating host key signer should succeed, got: %v", err)
+	}
+
+	serverConfig := &gossh.ServerConfig{
+		NoClientAuth: true,
+	}
+
+	serverConfig.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listening on random TCP port should succeed, got: %v", err)
+	}
+
+	t.Cleanup(func() {
+		l.Close() //nolint:errcheck
+	})
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		_, chans, reqs, err := gossh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+
+		go gossh.DiscardRequests(reqs)
+
+		for nc := range chans {
+			ch, requests, err := nc.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				for req := range requests {
+					if req.Type != "exec" {
+						req.Reply(false, nil) //nolint:errcheck
+
+						continue
+					}
+
+					req.Reply(true, nil) //nolint:errcheck
+
+					payload := struct{ Command string }{}
+
+					gossh.Unmarshal(req.Payload, &payload) //nolint:errcheck
+
+					status := struct{ Status uint32 }{handler(payload.Command, ch)}
+
+					ch.SendRequest("exit-status", false, gossh.Marshal(&status)) //nolint:errcheck
+					ch.Close()                                                   //nolint:errcheck
+				}
+			}()
+		}
+	}()
+
+	c, err := gossh.Dial("tcp", l.Addr().String(), &gossh.ClientConfig{
+		User:            "root",
+		HostKeyCallback: gossh.FixedHostKey(signer.PublicKey()),
+	})
+	if err != nil {
+		t.Fatalf("Connecting to test SSH server should succeed, got: %v", err)
+	}
+
+	t.Cleanup(func() {
+		c.Close() //nolint:errcheck
+	})
+
+	return c
+}
+
+// Exec() tests.
+func TestExec(t *testing.T) {
+	c := testSSHClient(t, func(command string, ch gossh.Channel) uint32 {
+		in, _ := ioutil.ReadAll(ch)
+
+		fmt.Fprintf(ch, "%s %s", command, in)
+
+		return 0
+	})
+
+	d := newConnected("localhost:22", c).(*sshConnected)
+
+	out, err := d.Exec(context.Background(), "foo", strings.NewReader("bar"))
+	if err != nil {
+		t.Fatalf("Executing command should succeed, got: %v", err)
+	}
+
+	if string(out) != "foo bar" {
+		t.Fatalf("Expected output %q, got %q", "foo bar", string(out))
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	c := testSSHClient(t, func(command string, ch gossh.Channel) uint32 {
+		fmt.Fprintf(ch.Stderr(), "failed")
+
+		return 3
+	})
+
+	d := newConnected("localhost:22", c).(*sshConnected)
+
+	_, err := d.Exec(context.Background(), "foo", nil)
+
+	var exitErr *transport.ExitError
+
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected exit error, got: %v", err)
+	}
+
+	if exitErr.ExitCode != 3 || exitErr.Stderr != "failed" {
+		t.Fatalf("Expected exit code 3 and stderr to be captured, got: %+v", exitErr)
+	}
+}
+
+func TestExecCancel(t *testing.T) {
+	c := testSSHClient(t, func(command string, ch gossh.Channel) uint32 {
+		ioutil.ReadAll(ch) //nolint:errcheck
+
+		return 0
+	})
+
+	d := newConnected("localhost:22", c).(*sshConnected)
+
+	r, w := io.Pipe()
+
+	t.Cleanup(func() {
+		w.Close() //nolint:errcheck
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	if _, err := d.Exec(ctx, "foo", r); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Executing command should be cancelled, got: %v", err)
+	}
+}
+
+func TestExecNoSessions(t *testing.T) {
+	d := newConnected("localhost:22", nil).(*sshConnected)
+
+	if _, err := d.Exec(context.Background(), "foo", nil); err == nil {
+		t.Fatalf("Executing command without SSH client should fail")
+	}
+}
+
+// Close() tests.
+type fakeCloser struct {
+	closed bool
+	err    error
+}
+
+func (f *fakeCloser) Close() error {
+	f.closed = true
+
+	return f.err
+}
+
+func TestCloseListeners(t *testing.T) {
+	d := newConnected("localhost:80", nil).(*sshConnected)
+
+	a, err := d.ForwardTCP(context.Background(), "localhost:90")
+	if err != nil {
+		t.Fatalf("Forwarding TCP shouldn't fail, got: %v", err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Closing should succeed, got: %v", err)
+	}
+
+	if c, err := net.Dial("tcp", a); err == nil {
+		c.Close() //nolint:errcheck
+
+		t.Fatalf("Forwarded address should not accept connections after closing")
+	}
+}
+
+func TestCloseClosers(t *testing.T) {
+	d := newConnected("localhost:80", nil).(*sshConnected)
+
+	f := &fakeCloser{}
+	fe := &fakeCloser{
+		err: fmt.Errorf("expected"),
+	}
+
+	d.closers = []io.Closer{fe, f}
+
+	if err := d.Close(); err == nil {
+		t.Fatalf("Closing should return error from closers")
+	}
+
+	if !f.closed || !fe.closed {
+		t.Fatalf("All closers should be closed, even if some of them fail")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Closing already closed connection should succeed, got: %v", err)
+	}
+}
+
+// setSSHAuthSock sets SSH_AUTH_SOCK environment variable to given value for the duration of the test.
+func setSSHAuthSock(t *testing.T, value string) {
+	t.Helper()
+
+	old, ok := os.LookupEnv(SSHAuthSockEnv)
+
+	if err := os.Setenv(SSHAuthSockEnv, value); err != nil {
 		t.Fatalf("failed setting environment variable %q: %v", SSHAuthSockEnv, err)
 	}
 
+	t.Cleanup(func() {
+		if !ok {
+			os.Unsetenv(SSHAuthSockEnv) //nolint:errcheck
+
+			return
+		}
+
+		os.Setenv(SSHAuthSockEnv, old) //nolint:errcheck
+	})
+}
+
+func TestNewSSHAgentNotDialed(t *testing.T) {
+	setSSHAuthSock(t, "foo")
+
 	c := &Config{
 		Address:           "localhost",
 		User:              "root",
@@ -669,14 +1123,34 @@ func TestNewBadSSHAgentEnv(t *testing.T) {
 		Port:              Port,
 	}
 
-	if _, err := c.New(); err == nil {
-		t.Fatalf("creating new SSH object with bad ssh-agent environment variable should fail")
+	if _, err := c.New(); err != nil {
+		t.Fatalf("creating new SSH object should not connect to SSH agent, got: %v", err)
 	}
 }
 
-func TestNewSSHAgent(t *testing.T) {
-	a := agent.NewKeyring()
+func TestConnectBadSSHAgentEnv(t *testing.T) {
+	setSSHAuthSock(t, "foo")
 
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		ConnectionTimeout: "30s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+	}
+
+	s, err := c.New()
+	if err != nil {
+		t.Fatalf("creating new SSH object should succeed, got: %v", err)
+	}
+
+	if _, err := s.Connect(context.Background()); err == nil {
+		t.Fatalf("connecting with bad ssh-agent environment variable should fail")
+	}
+}
+
+func TestConnectSSHAgent(t *testing.T) {
 	addr := &net.UnixAddr{
 		Name: "@foo",
 		Net:  "unix",
@@ -687,24 +1161,27 @@ func TestNewSSHAgent(t *testing.T) {
 		t.Fatalf("failed to listen on address %q: %v", addr.String(), err)
 	}
 
+	t.Cleanup(func() {
+		l.Close() //nolint:errcheck
+	})
+
+	served := make(chan struct{})
+
 	go func() {
+		defer close(served)
+
 		c, err := l.Accept()
 		if err != nil {
 			t.Logf("accepting connection failed: %v", err)
-		}
 
-		if err := agent.ServeAgent(a, c); err != nil {
-			t.Logf("serving agent failed: %v", err)
+			return
 		}
 
-		if err := l.Close(); err != nil {
-			t.Logf("closing listener failed: %v", err)
-		}
+		// Serving returns when client closes the connection.
+		agent.ServeAgent(agent.NewKeyring(), c) //nolint:errcheck
 	}()
 
-	if err := os.Setenv(SSHAuthSockEnv, addr.String()); err != nil {
-		t.Fatalf("failed setting environment variable %q: %v", SSHAuthSockEnv, err)
-	}
+	setSSHAuthSock(t, addr.String())
 
 	c := &Config{
 		Address:           "localhost",
@@ -715,47 +1192,450 @@ func TestNewSSHAgent(t *testing.T) {
 		Port:              Port,
 	}
 
-	if _, err := c.New(); err != nil {
+	s, err := c.New()
+	if err != nil {
 		t.Fatalf("creating new SSH object with good ssh-agent should work, got: %v", err)
 	}
-}
 
-func TestNewSSHAgentWrongSocket(t *testing.T) {
-	addr := &net.UnixAddr{
-		Name: "@bar",
-		Net:  "unix",
+	ss := s.(*ssh)
+
+	ss.sshClientGetter = func(ctx context.Context, via dialer, n, a string, config *gossh.ClientConfig) (*gossh.Client, error) {
+		if len(config.Auth) != 1 {
+			t.Errorf("SSH agent should be used as authentication method, got %d methods", len(config.Auth))
+		}
+
+		return testSSHClient(t, func(string, gossh.Channel) uint32 {
+			return 0
+		}), nil
 	}
 
-	l, err := net.Listen("unix", addr.String())
+	d, err := ss.Connect(context.Background())
 	if err != nil {
-		t.Fatalf("failed to listen on address %q: %v", addr.String(), err)
+		t.Fatalf("Connecting should succeed, got: %v", err)
 	}
 
-	go func() {
-		c, err := l.Accept()
-		if err != nil {
-			t.Logf("accepting connection failed: %v", err)
-		}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Closing connection should succeed, got: %v", err)
+	}
 
-		if err := c.Close(); err != nil {
-			t.Logf("closing connection failed: %v", err)
-		}
-	}()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Connection to SSH agent should be closed together with the connection")
+	}
+}
 
-	if err := os.Setenv(SSHAuthSockEnv, addr.String()); err != nil {
-		t.Fatalf("failed setting environment variable %q: %v", SSHAuthSockEnv, err)
+func TestValidateForwardAgentRequireSSHAgent(t *testing.T) {
+	setSSHAuthSock(t, "")
+
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		Password:          "foo",
+		ConnectionTimeout: "30s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		ForwardAgent:      true,
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("validating SSH configuration should require SSH agent when forwarding agent")
+	}
+}
+
+func TestExecForwardAgent(t *testing.T) {
+	// Test server rejects all requests other than 'exec', including agent forwarding.
+	c := testSSHClient(t, func(command string, ch gossh.Channel) uint32 {
+		t.Errorf("Command should not be executed when agent forwarding is rejected")
+
+		return 0
+	})
+
+	d := newConnected("localhost:22", c).(*sshConnected)
+	d.forwardAgent = true
+
+	if _, err := d.Exec(context.Background(), "foo", nil); err == nil {
+		t.Fatalf("Executing command should fail when agent forwarding is rejected")
 	}
+}
 
+func TestValidateParseKeepaliveInterval(t *testing.T) {
 	c := &Config{
 		Address:           "localhost",
 		User:              "root",
+		Password:          "foo",
 		ConnectionTimeout: "30s",
 		RetryTimeout:      "60s",
 		RetryInterval:     "1s",
 		Port:              Port,
+		KeepaliveInterval: "doh",
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("validating SSH configuration should parse keepalive interval")
 	}
+}
 
-	if _, err := c.New(); err == nil {
-		t.Fatalf("creating new SSH object with bad ssh-agent socket should fail")
+func TestValidateNegativeKeepaliveCountMax(t *testing.T) {
+	c := &Config{
+		Address:           "localhost",
+		User:              "root",
+		Password:          "foo",
+		ConnectionTimeout: "30s",
+		RetryTimeout:      "60s",
+		RetryInterval:     "1s",
+		Port:              Port,
+		KeepaliveCountMax: -1,
+	}
+	if err := c.Validate(); err == nil {
+		t.Fatalf("validating SSH configuration should reject negative keepalive count")
+	}
+}
+
+// keepalive() tests.
+type fakeKeepaliver struct {
+	sendRequestF func() error
+	wait         chan struct{}
+}
+
+func (f *fakeKeepaliver) Dial(network, address string) (net.Conn, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeKeepaliver) SendRequest(name string, wantReply bool, payload []byte) (bool, []byte, error) {
+	return false, nil, f.sendRequestF()
+}
+
+func (f *fakeKeepaliver) Wait() error {
+	<-f.wait
+
+	return nil
+}
+
+func TestKeepaliveMarksDeadConnection(t *testing.T) {
+	k := &fakeKeepaliver{
+		sendRequestF: func() error {
+			return fmt.Errorf("no reply")
+		},
+		wait: make(chan struct{}),
+	}
+
+	d := newConnected("localhost:22", k).(*sshConnected)
+	d.keepaliveInterval = 10 * time.Millisecond
+	d.keepaliveCountMax = 2
+
+	d.watch(k)
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		d.clientMutex.Lock()
+		c := d.client
+		d.clientMutex.Unlock()
+
+		if c == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Connection not answering keepalive requests should be marked as dead")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := d.ForwardingErrors(); err == nil {
+		t.Fatalf("Dead connection should be reported")
+	}
+}
+
+func TestKeepaliveHangingRequest(t *testing.T) {
+	var requests int32
+
+	hang := make(chan struct{})
+
+	k := &fakeKeepaliver{
+		sendRequestF: func() error {
+			atomic.AddInt32(&requests, 1)
+			<-hang
+
+			return fmt.Errorf("closed")
+		},
+		wait: make(chan struct{}),
+	}
+
+	d := newConnected("localhost:22", k).(*sshConnected)
+	d.keepaliveInterval = 10 * time.Millisecond
+	d.keepaliveCountMax = 5
+
+	d.keepalive(k, make(chan struct{}))
+
+	close(hang)
+
+	if r := atomic.LoadInt32(&requests); r != 1 {
+		t.Fatalf("Only one keepalive request should be sent while it is unanswered, got %d", r)
+	}
+
+	if err := d.ForwardingErrors(); err == nil {
+		t.Fatalf("Dead connection should be reported")
+	}
+}
+
+func TestKeepaliveAliveConnection(t *testing.T) {
+	k := &fakeKeepaliver{
+		sendRequestF: func() error {
+			return nil
+		},
+		wait: make(chan struct{}),
+	}
+
+	d := newConnected("localhost:22", k).(*sshConnected)
+	d.keepaliveInterval = 10 * time.Millisecond
+	d.keepaliveCountMax = 1
+
+	d.watch(k)
+
+	time.Sleep(100 * time.Millisecond)
+
+	d.clientMutex.Lock()
+	c := d.client
+	d.clientMutex.Unlock()
+
+	if c == nil {
+		t.Fatalf("Connection answering keepalive requests should not be marked as dead")
+	}
+
+	close(k.wait)
+}
+
+func TestWatchClosedConnection(t *testing.T) {
+	k := &fakeKeepaliver{
+		wait: make(chan struct{}),
+	}
+
+	d := newConnected("localhost:22", k).(*sshConnected)
+
+	d.watch(k)
+
+	close(k.wait)
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		d.clientMutex.Lock()
+		c := d.client
+		d.clientMutex.Unlock()
+
+		if c == nil {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Closed connection should be marked as dead")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// getClient() tests.
+func TestGetClientReconnect(t *testing.T) {
+	d := newConnected("localhost:22", nil).(*sshConnected)
+
+	reconnects := 0
+
+	d.reconnect = func(ctx context.Context) (dialer, []io.Closer, error) {
+		reconnects++
+
+		return &net.Dialer{}, nil, nil
+	}
+
+	if _, err := d.getClient(context.Background()); err != nil {
+		t.Fatalf("Getting client should reconnect, got: %v", err)
+	}
+
+	if _, err := d.getClient(context.Background()); err != nil {
+		t.Fatalf("Getting client again should succeed, got: %v", err)
+	}
+
+	if reconnects != 1 {
+		t.Fatalf("Expected exactly one reconnect, got %d", reconnects)
+	}
+}
+
+func TestGetClientReconnectFail(t *testing.T) {
+	d := newConnected("localhost:22", nil).(*sshConnected)
+
+	d.reconnect = func(ctx context.Context) (dialer, []io.Closer, error) {
+		return nil, nil, fmt.Errorf("foo")
+	}
+
+	if _, err := d.getClient(context.Background()); err == nil {
+		t.Fatalf("Getting client should fail when reconnecting fails")
+	}
+}
+
+func TestGetClientClosed(t *testing.T) {
+	d := newConnected("localhost:22", nil).(*sshConnected)
+
+	d.reconnect = func(ctx context.Context) (dialer, []io.Closer, error) {
+		t.Fatalf("Closed connection should not be re-established")
+
+		return nil, nil, nil
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Closing should succeed, got: %v", err)
+	}
+
+	if _, err := d.getClient(context.Background()); err == nil {
+		t.Fatalf("Getting client of closed connection should fail")
+	}
+}
+
+// dial() tests.
+type fakeDialer struct {
+	dialF func(network, address string) (net.Conn, error)
+}
+
+func (f *fakeDialer) Dial(network, address string) (net.Conn, error) {
+	return f.dialF(network, address)
+}
+
+func TestDialRetryAfterReconnect(t *testing.T) {
+	broken := &fakeDialer{
+		dialF: func(network, address string) (net.Conn, error) {
+			return nil, io.EOF
+		},
+	}
+
+	d := newConnected("localhost:22", broken).(*sshConnected)
+
+	d.reconnect = func(ctx context.Context) (dialer, []io.Closer, error) {
+		return &fakeDialer{
+			dialF: func(network, address string) (net.Conn, error) {
+				c, _ := net.Pipe()
+
+				return c, nil
+			},
+		}, nil, nil
+	}
+
+	if _, err := d.dial(context.Background(), "tcp", "localhost:80"); err != nil {
+		t.Fatalf("Dialing should succeed after reconnecting, got: %v", err)
+	}
+}
+
+func TestDialNoRetryOnRejectedChannel(t *testing.T) {
+	rejected := &fakeDialer{
+		dialF: func(network, address string) (net.Conn, error) {
+			return nil, &gossh.OpenChannelError{Reason: gossh.ConnectionFailed}
+		},
+	}
+
+	d := newConnected("localhost:22", rejected).(*sshConnected)
+
+	d.reconnect = func(ctx context.Context) (dialer, []io.Closer, error) {
+		t.Fatalf("Connection should not be re-established when server rejects the channel")
+
+		return nil, nil, nil
+	}
+
+	if _, err := d.dial(context.Background(), "tcp", "localhost:80"); err == nil {
+		t.Fatalf("Dialing should fail when server rejects the channel")
+	}
+}
+
+// ForwardingErrors() tests.
+func TestForwardingErrors(t *testing.T) {
+	d := newConnected("localhost:22", nil).(*sshConnected)
+
+	if err := d.ForwardingErrors(); err != nil {
+		t.Fatalf("There should be no forwarding errors initially, got: %v", err)
+	}
+
+	d.reportError(fmt.Errorf("foo"))
+
+	if err := d.ForwardingErrors(); err == nil {
+		t.Fatalf("Reported error should be returned")
+	}
+
+	if err := d.ForwardingErrors(); err != nil {
+		t.Fatalf("Forwarding errors should be cleared after collecting, got: %v", err)
+	}
+}
+
+// blockingDialer is a dialer, which blocks until the test finishes.
+type blockingDialer struct {
+	unblock chan struct{}
+}
+
+func (b *blockingDialer) Dial(network, address string) (net.Conn, error) {
+	<-b.unblock
+
+	return nil, fmt.Errorf("unblocked")
+}
+
+// pipeDialer is a dialer, which returns connection never responding to SSH handshake.
+type pipeDialer struct{}
+
+func (p *pipeDialer) Dial(network, address string) (net.Conn, error) {
+	c, peer := net.Pipe()
+
+	go func() {
+		_, _ = io.Copy(ioutil.Discard, peer)
+	}()
+
+	return c, nil
+}
+
+func TestDialContextCancelledDial(t *testing.T) {
+	t.Parallel()
+
+	d := &blockingDialer{
+		unblock: make(chan struct{}),
+	}
+
+	defer close(d.unblock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go cancel()
+
+	if _, err := dialContext(ctx, d, "tcp", "foo:22", &gossh.ClientConfig{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Dialing should be cancelled, got: %v", err)
+	}
+}
+
+func TestDialContextHandshakeDeadline(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	config := &gossh.ClientConfig{
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(), //nolint:gosec // Handshake never finishes.
+	}
+
+	if _, err := dialContext(ctx, &pipeDialer{}, "tcp", "foo:22", config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Handshake should be interrupted by context deadline, got: %v", err)
+	}
+}
+
+func TestDialContextHandshakeCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	config := &gossh.ClientConfig{
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(), //nolint:gosec // Handshake never finishes.
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := dialContext(ctx, &pipeDialer{}, "tcp", "foo:22", config); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Handshake should be interrupted by context cancellation, got: %v", err)
 	}
 }
@@ -1,22 +1,105 @@
 // Package transport provides interfaces for forwarding connections.
 package transport
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrExecNotSupported is returned by Exec, when given connection does not support
+// executing commands.
+var ErrExecNotSupported = errors.New("connection does not support executing commands")
+
 // Interface Transport should be a valid object, which is ready to open connection.
 type Interface interface {
 	// Connect initializes the connection with transport method. For example, if transport method
 	// requires initial authentication, it should happen at this point, so further forward errors
 	// are more specific.
-	Connect() (Connected, error)
+	//
+	// Given context is used only while establishing the connection.
+	Connect(ctx context.Context) (Connected, error)
 }
 
 // Connected interface describes universal way of communicating with remote hosts
 // using different transport protocols.
 type Connected interface {
 	// ForwardUnixSocket forwards unix socket to local machine to make it available for the process.
-	ForwardUnixSocket(remotePath string) (localPath string, err error)
+	// Forwarding is stopped, when given context is cancelled.
+	ForwardUnixSocket(ctx context.Context, remotePath string) (localPath string, err error)
 
 	// ForwardTCP listens on random local port and forwards incoming connections to given remote address.
-	ForwardTCP(remoteAddr string) (localAddr string, err error)
+	// Forwarding is stopped, when given context is cancelled.
+	ForwardTCP(ctx context.Context, remoteAddr string) (localAddr string, err error)
+
+	// Close stops all forwarding and closes the connection.
+	Close() error
+}
+
+// Executor is an optional capability of Connected, which allows executing commands
+// on the host without involving container runtime.
+type Executor interface {
+	// Exec executes given command using shell on the host and returns its standard output.
+	// If stdin is not nil, it is used as a standard input for the command. Command is
+	// interrupted, when given context is cancelled.
+	//
+	// If command exits with non-zero exit code, *ExitError is returned.
+	Exec(ctx context.Context, command string, stdin io.Reader) ([]byte, error)
+}
+
+// ExitError is returned by Executor, when executed command exits with non-zero exit code.
+type ExitError struct {
+	// Command is the executed command.
+	Command string
+
+	// ExitCode is the exit code of the command.
+	ExitCode int
+
+	// Stderr is the standard error output of the command.
+	Stderr string
+}
+
+// Error implements error interface.
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("command %q exited with code %d", e.Command, e.ExitCode)
+
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		msg = fmt.Sprintf("%s: %s", msg, s)
+	}
+
+	return msg
+}
+
+// Exec executes given command using given connection, if it implements Executor interface.
+// Otherwise ErrExecNotSupported is returned.
+func Exec(ctx context.Context, c Connected, command string, stdin io.Reader) ([]byte, error) {
+	e, ok := c.(Executor)
+	if !ok {
+		return nil, ErrExecNotSupported
+	}
+
+	return e.Exec(ctx, command, stdin)
+}
+
+// ErrorReporter is an optional capability of Connected, which forwards connections in the
+// background and collects errors, which occurred while doing so.
+type ErrorReporter interface {
+	// ForwardingErrors returns errors, which occurred while forwarding connections since
+	// the last call or nil, if there were no errors.
+	ForwardingErrors() error
+}
+
+// ForwardingErrors returns errors, which occurred while forwarding connections using given
+// connection, if it implements ErrorReporter interface. Otherwise nil is returned.
+func ForwardingErrors(c Connected) error {
+	r, ok := c.(ErrorReporter)
+	if !ok {
+		return nil
+	}
+
+	return r.ForwardingErrors()
 }
 
 // Config describes how Transport interface should be created.
@@ -1,6 +1,7 @@
 package kubelet
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 
@@ -10,10 +11,10 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/pkg/container"
-	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/transport"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
@@ -21,6 +22,12 @@ import (
 const (
 	// KubenetNetworkPlugin is the name of kubenet network plugin.
 	KubenetNetworkPlugin = "kubenet"
+
+	// defaultCgroupDriver is a cgroup driver used by the kubelet, if none is configured.
+	defaultCgroupDriver = "cgroupfs"
+
+	// bootstrapKubeconfigPath is a path on the host, where bootstrap kubeconfig is stored.
+	bootstrapKubeconfigPath = "/etc/kubernetes/kubelet/bootstrap-kubeconfig"
 )
 
 // Kubelet represents configuration of single kubelet instance.
@@ -38,17 +45,55 @@ type Kubelet struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by kubelet. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// Host describes on which machine kubelet container should be created.
 	//
 	// This field is required.
 	Host host.Host `json:"host,omitempty"`
 
+	// Runtime stores configuration of container runtime used for running kubelet container.
+	// If empty, Docker with default configuration is used.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// BootstrapConfig contains kubelet bootstrap kubeconfig configuration, including
 	// bootstrap token and Kubernetes API server address.
 	//
+	// If ClientCertificate is set, only Server and CACertificate fields are used.
+	//
 	// This field is required.
 	BootstrapConfig *client.Config `json:"bootstrapConfig,omitempty"`
 
+	// ClientCertificate stores X.509 client certificate, PEM encoded, which will be used by
+	// kubelet to talk to Kubernetes API server. If set, TLS bootstrapping is disabled and
+	// the certificate is not rotated by the kubelet.
+	//
+	// This field is optional.
+	ClientCertificate types.Certificate `json:"clientCertificate,omitempty"`
+
+	// ClientKey is a PEM encoded, private key in either PKCS1, PKCS8 or EC format.
+	//
+	// It must match certificate defined in ClientCertificate field.
+	ClientKey types.PrivateKey `json:"clientKey,omitempty"`
+
+	// ServerCertificate stores X.509 certificate, PEM encoded, which will be used by kubelet
+	// for serving its API. If set, kubelet won't request serving certificate from Kubernetes
+	// API, so no CSR approver is required.
+	//
+	// This field is optional.
+	ServerCertificate types.Certificate `json:"serverCertificate,omitempty"`
+
+	// ServerKey is a PEM encoded, private key in either PKCS1, PKCS8 or EC format.
+	//
+	// It must match certificate defined in ServerCertificate field.
+	ServerKey types.PrivateKey `json:"serverKey,omitempty"`
+
 	// KubernetesCACertificate holds Kubernetes X.509 CA certificate, PEM encoded, which will
 	// be used by kubelet to verify Kubernetes API server they talk to.
 	KubernetesCACertificate types.Certificate `json:"kubernetesCACertificate,omitempty"`
@@ -120,6 +165,20 @@ type kubelet struct {
 	config Kubelet
 }
 
+// validateCgroupDriver checks, if configured cgroup driver matches the one reported
+// by the container runtime, as otherwise kubelet won't be able to start.
+func (k *kubelet) validateCgroupDriver(info containertypes.RuntimeInfo) error {
+	if info.CgroupDriver == "" {
+		return nil
+	}
+
+	if cd := util.PickString(k.config.CgroupDriver, defaultCgroupDriver); cd != info.CgroupDriver {
+		return fmt.Errorf("configured cgroup driver %q does not match cgroup driver %q used by %s runtime", cd, info.CgroupDriver, info.Name)
+	}
+
+	return nil
+}
+
 // New validates Kubelet configuration and returns it's usable version.
 func (k *Kubelet) New() (container.ResourceInstance, error) {
 	// TODO: When creating kubelet, also pull pause image using configured Container Runtime to speed up later start of pods?
@@ -144,6 +203,14 @@ func (k *Kubelet) validateBootstrapConfig() error {
 		return fmt.Errorf("bootstrapConfig must be set")
 	}
 
+	if k.ClientCertificate != "" || k.ClientKey != "" {
+		if _, err := k.kubeconfig().ToYAMLString(); err != nil {
+			return fmt.Errorf("failed to generate kubeconfig: %w", err)
+		}
+
+		return nil
+	}
+
 	if err := k.BootstrapConfig.Validate(); err != nil {
 		return fmt.Errorf("failed validating bootstrap config: %w", err)
 	}
@@ -155,6 +222,17 @@ func (k *Kubelet) validateBootstrapConfig() error {
 	return nil
 }
 
+// kubeconfig returns kubelet kubeconfig configuration with client certificate, which is
+// used instead of TLS bootstrapping.
+func (k *Kubelet) kubeconfig() *client.Config {
+	return &client.Config{
+		Server:            k.BootstrapConfig.Server,
+		CACertificate:     k.BootstrapConfig.CACertificate,
+		ClientCertificate: k.ClientCertificate,
+		ClientKey:         k.ClientKey,
+	}
+}
+
 // validateAdminConfig validates admin config and related parameters.
 func (k *Kubelet) validateAdminConfig() error {
 	var errors util.ValidateError
@@ -207,6 +285,10 @@ func (k *Kubelet) Validate() error {
 		errors = append(errors, err)
 	}
 
+	if (k.ServerCertificate == "") != (k.ServerKey == "") {
+		errors = append(errors, fmt.Errorf("serverCertificate and serverKey must be set together"))
+	}
+
 	if k.VolumePluginDir == "" {
 		errors = append(errors, fmt.Errorf("volumePluginDir can't be empty"))
 	}
@@ -246,10 +328,12 @@ func (k *kubelet) configFile() (string, error) {
 			Kind:       "KubeletConfiguration",
 			APIVersion: kubeletconfig.SchemeGroupVersion.String(),
 		},
-		// Enables TLS certificate rotation, which is good from security point of view.
-		RotateCertificates: true,
-		// Request HTTPS server certs from API as well, so kubelet does not generate self-signed certificates.
-		ServerTLSBootstrap: true,
+		// Enables TLS certificate rotation, which is good from security point of view. Pre-issued client
+		// certificate is renewed by the PKI instead.
+		RotateCertificates: k.config.ClientCertificate == "",
+		// Request HTTPS server certs from API as well, so kubelet does not generate self-signed certificates,
+		// unless serving certificate is provided.
+		ServerTLSBootstrap: k.config.ServerCertificate == "",
 		// If Docker is configured to use systemd as a cgroup driver and Docker is used as container
 		// runtime, this needs to be set to match Docker.
 		// TODO pull that information dynamically based on what container runtime is configured.
@@ -288,6 +372,11 @@ func (k *kubelet) configFile() (string, error) {
 		HairpinMode: k.config.HairpinMode,
 	}
 
+	if k.config.ServerCertificate != "" {
+		config.TLSCertFile = "/etc/kubernetes/pki/kubelet.crt"
+		config.TLSPrivateKeyFile = "/etc/kubernetes/pki/kubelet.key"
+	}
+
 	if k.config.NetworkPlugin == KubenetNetworkPlugin {
 		// CIDR for pods IP addresses. Needed when using 'kubenet' network plugin and manager-controller is not assigning those.
 		config.PodCIDR = k.config.PodCIDR
@@ -307,14 +396,24 @@ func (k *kubelet) configFiles() (map[string]string, error) {
 		return nil, fmt.Errorf("failed building kubelet configuration: %w", err)
 	}
 
-	bootstrapKubeconfig, _ := k.config.BootstrapConfig.ToYAMLString()
-
-	return map[string]string{
+	files := map[string]string{
 		// kubelet.yaml file is a recommended way to configure the kubelet.
-		"/etc/kubernetes/kubelet/kubelet.yaml":         config,
-		"/etc/kubernetes/kubelet/bootstrap-kubeconfig": bootstrapKubeconfig,
-		"/etc/kubernetes/kubelet/pki/ca.crt":           string(k.config.KubernetesCACertificate),
-	}, nil
+		"/etc/kubernetes/kubelet/kubelet.yaml": config,
+		"/etc/kubernetes/kubelet/pki/ca.crt":   string(k.config.KubernetesCACertificate),
+	}
+
+	if k.config.ClientCertificate != "" {
+		files["/etc/kubernetes/kubelet/kubeconfig"], _ = k.config.kubeconfig().ToYAMLString()
+	} else {
+		files[bootstrapKubeconfigPath], _ = k.config.BootstrapConfig.ToYAMLString()
+	}
+
+	if k.config.ServerCertificate != "" {
+		files["/etc/kubernetes/kubelet/pki/kubelet.crt"] = string(k.config.ServerCertificate)
+		files["/etc/kubernetes/kubelet/pki/kubelet.key"] = string(k.config.ServerKey)
+	}
+
+	return files, nil
 }
 
 // mounts returns kubelet's host mounts.
@@ -428,11 +527,22 @@ func (k *kubelet) args() []string {
 	a := []string{
 		// Tell kubelet to use config file.
 		"--config=/etc/kubernetes/kubelet.yaml",
-		// Specify kubeconfig file for kubelet. This enabled API server mode and
-		// specifies when kubelet will write kubeconfig file after TLS bootstrapping.
-		"--kubeconfig=/var/lib/kubelet/kubeconfig",
-		// kubeconfig with access token for TLS bootstrapping.
-		"--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubeconfig",
+	}
+
+	if k.config.ClientCertificate != "" {
+		// Use kubeconfig with pre-issued client certificate.
+		a = append(a, "--kubeconfig=/etc/kubernetes/kubeconfig")
+	} else {
+		a = append(a,
+			// Specify kubeconfig file for kubelet. This enabled API server mode and
+			// specifies when kubelet will write kubeconfig file after TLS bootstrapping.
+			"--kubeconfig=/var/lib/kubelet/kubeconfig",
+			// kubeconfig with access token for TLS bootstrapping.
+			"--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubeconfig",
+		)
+	}
+
+	a = append(a,
 		// Set which network plugin to use.
 		fmt.Sprintf("--network-plugin=%s", k.config.NetworkPlugin),
 		// https://alexbrand.dev/post/why-is-my-kubelet-listening-on-a-random-port-a-closer-look-at-cri-and-the-docker-cri-shim/
@@ -448,7 +558,7 @@ func (k *kubelet) args() []string {
 		//
 		// TODO This flag should only be set if Docker is used as container runtime.
 		"--cni-bin-dir=/host/opt/cni/bin,/opt/cni/bin",
-	}
+	)
 
 	if len(k.config.Labels) > 0 {
 		a = append(a, fmt.Sprintf("--node-labels=%s", util.JoinSorted(k.config.Labels, "=", ",")))
@@ -469,14 +579,12 @@ func (k *kubelet) ToHostConfiguredContainer() (*container.HostConfiguredContaine
 	}
 
 	c := container.Container{
-		// TODO this is weird. This sets docker as default runtime config
-		Runtime: container.RuntimeConfig{
-			Docker: docker.DefaultConfig(),
-		},
+		Runtime: *container.BuildRuntimeConfig(k.config.Runtime, nil),
 		Config: containertypes.ContainerConfig{
 			// TODO make it configurable?
-			Name:  "kubelet",
-			Image: k.config.Image,
+			Name:        "kubelet",
+			Image:       k.config.Image,
+			ImageSource: k.config.ImageSource,
 			// When kubelet runs as a container, it should be privileged, so it can adjust it's OOM settings.
 			// Without this, you get following errors:
 			// failed to set "/proc/self/oom_score_adj" to "-999": write /proc/self/oom_score_adj: permission denied
@@ -533,7 +641,7 @@ func (k *kubelet) waitForNodeReady() error {
 
 // postStartHook defines actions which will be executed after new kubelet instance is created.
 func (k *kubelet) postStartHook() *container.Hook {
-	f := container.Hook(func() error {
+	f := container.Hook(func(context.Context, transport.Executor) error {
 		if len(k.config.PrivilegedLabels) > 0 {
 			if err := k.applyPrivilegedLabels(); err != nil {
 				return fmt.Errorf("failed applying privileged labels: %w", err)
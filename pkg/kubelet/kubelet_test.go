@@ -117,6 +117,22 @@ func TestKubeletValidate(t *testing.T) { //nolint:funlen
 				}
 			},
 		},
+		{
+			MutationF: func(k *Kubelet) { k.ClientCertificate = k.KubernetesCACertificate },
+			TestF: func(t *testing.T, err error) {
+				if err == nil {
+					t.Fatalf("validation of kubelet should fail when client certificate is set without client key")
+				}
+			},
+		},
+		{
+			MutationF: func(k *Kubelet) { k.ServerCertificate = k.KubernetesCACertificate },
+			TestF: func(t *testing.T, err error) {
+				if err == nil {
+					t.Fatalf("validation of kubelet should fail when server certificate is set without server key")
+				}
+			},
+		},
 		{
 			MutationF: func(k *Kubelet) { k.VolumePluginDir = "" },
 			TestF: func(t *testing.T, err error) {
@@ -276,3 +292,36 @@ func TestKubeletIncludeExtraMounts(t *testing.T) {
 		t.Fatalf("extra mount should be included in generated mounts")
 	}
 }
+
+// validateCgroupDriver() tests.
+func TestValidateCgroupDriverDefault(t *testing.T) {
+	k := &kubelet{}
+
+	if err := k.validateCgroupDriver(containertypes.RuntimeInfo{CgroupDriver: "cgroupfs"}); err != nil {
+		t.Fatalf("Default cgroup driver should match 'cgroupfs', got: %v", err)
+	}
+}
+
+func TestValidateCgroupDriverMismatch(t *testing.T) {
+	k := &kubelet{
+		config: Kubelet{
+			CgroupDriver: "systemd",
+		},
+	}
+
+	if err := k.validateCgroupDriver(containertypes.RuntimeInfo{CgroupDriver: "cgroupfs"}); err == nil {
+		t.Fatalf("Mismatched cgroup driver should return error")
+	}
+}
+
+func TestValidateCgroupDriverUnknown(t *testing.T) {
+	k := &kubelet{
+		config: Kubelet{
+			CgroupDriver: "systemd",
+		},
+	}
+
+	if err := k.validateCgroupDriver(containertypes.RuntimeInfo{}); err != nil {
+		t.Fatalf("Unknown runtime cgroup driver should not return error, got: %v", err)
+	}
+}
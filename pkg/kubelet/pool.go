@@ -3,9 +3,12 @@
 package kubelet
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
+	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/yaml"
 
 	"github.com/flexkube/libflexkube/internal/util"
@@ -13,6 +16,7 @@ import (
 	containertypes "github.com/flexkube/libflexkube/pkg/container/types"
 	"github.com/flexkube/libflexkube/pkg/defaults"
 	"github.com/flexkube/libflexkube/pkg/host"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 	"github.com/flexkube/libflexkube/pkg/host/transport/ssh"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
@@ -24,6 +28,9 @@ const (
 	DefaultNetworkPlugin = "cni"
 	// DefaultHairpinMode is a default HairpinMode configured for kubelets.
 	DefaultHairpinMode = "hairpin-veth"
+
+	// kubeletPort is a port, where kubelet serves its API.
+	kubeletPort = 10250
 )
 
 // Pool represents group of kubelet instances and their configuration.
@@ -44,6 +51,14 @@ type Pool struct {
 	// This field is optional.
 	Image string `json:"image,omitempty"`
 
+	// ImageSource is a source of the image used by kubelets. See types.ContainerConfig.ImageSource
+	// for supported formats.
+	//
+	// If specified, this value will be used for all kubelets, which do not have it defined.
+	//
+	// This field is optional.
+	ImageSource string `json:"imageSource,omitempty"`
+
 	// SSH stores common SSH configuration for all kubelets and will be merged with kubelets
 	// SSH configuration. If kubelet has some SSH fields defined, they take precedence over
 	// this block.
@@ -54,12 +69,32 @@ type Pool struct {
 	// This field is optional.
 	SSH *ssh.Config `json:"ssh,omitempty"`
 
+	// Host stores common host configuration for all kubelets and will be merged with kubelets
+	// host configuration, e.g. to run all kubelets locally using direct transport.
+	//
+	// This field is optional and it is mutually exclusive with SSH field.
+	Host *host.Host `json:"host,omitempty"`
+
+	// Runtime stores common container runtime configuration for all kubelets, e.g. Docker
+	// address or TLS certificates. It will be merged with kubelets runtime configuration.
+	//
+	// This field is optional.
+	Runtime *container.RuntimeConfig `json:"runtime,omitempty"`
+
 	// BootstrapConfig contains kubelet bootstrap kubeconfig configuration, including
 	// bootstrap token and Kubernetes API server address.
 	//
 	// This field is optional, if each kubelet instance has this field set.
 	BootstrapConfig *client.Config `json:"bootstrapConfig,omitempty"`
 
+	// BootstrapToken is a bootstrap token, which will be used by kubelets, which have no token
+	// set in their bootstrap configuration. Kubelets already present in the state keep the token
+	// from their bootstrap kubeconfig, so changing this field, e.g. when the token is rotated,
+	// does not restart running kubelets, which do not use the token after bootstrapping anyway.
+	//
+	// This field is optional.
+	BootstrapToken string `json:"bootstrapToken,omitempty"`
+
 	// Kubelets holds a list of kubelet instances to create.
 	Kubelets []Kubelet `json:"kubelets,omitempty"`
 
@@ -120,6 +155,11 @@ type Pool struct {
 
 	// PKI field allows to use PKI resource for managing all kubernetes certificates. It will be
 	// used for kubelets configuration, if they don't have certificates defined.
+	//
+	// Client and serving certificates are picked from the PKI by kubelet name, so kubelets must
+	// be listed under the same names in Kubernetes PKI kubelets to join the cluster without TLS
+	// bootstrapping. If only some kubelets of the pool have certificates, validation fails, so
+	// kubelets missing in the PKI do not silently fall back to TLS bootstrapping.
 	PKI *pki.PKI `json:"pki,omitempty"`
 
 	// Serializable fields.
@@ -132,16 +172,17 @@ type Pool struct {
 // pool is a validated version of Pool.
 type pool struct {
 	containers container.ContainersInterface
+	kubelets   []*kubelet
 }
 
 func (p *Pool) pkiIntegration() {
 	if p.PKI != nil && p.PKI.Kubernetes != nil {
-		if p.PKI.Kubernetes.CA != nil && p.KubernetesCACertificate == "" {
-			p.KubernetesCACertificate = p.PKI.Kubernetes.CA.X509Certificate
+		if p.KubernetesCACertificate == "" {
+			p.KubernetesCACertificate = p.PKI.TrustBundle(pki.KubernetesCAName)
 		}
 
 		if p.AdminConfig != nil && p.AdminConfig.ClientCertificate == "" && p.PKI.Kubernetes.AdminCertificate != nil {
-			p.AdminConfig.ClientCertificate = p.PKI.Kubernetes.AdminCertificate.X509Certificate
+			p.AdminConfig.ClientCertificate = p.PKI.CertificateChain(p.PKI.Kubernetes.AdminCertificate, pki.KubernetesCAName)
 		}
 
 		if p.AdminConfig != nil && p.AdminConfig.ClientKey == "" && p.PKI.Kubernetes.AdminCertificate != nil {
@@ -168,13 +209,102 @@ func (p *Pool) kubeletPKIIntegration(k *Kubelet) {
 	if k.AdminConfig != nil && k.AdminConfig.CACertificate == "" && p.KubernetesCACertificate != "" {
 		k.AdminConfig.CACertificate = p.KubernetesCACertificate
 	}
+
+	if p.PKI == nil || p.PKI.Kubernetes == nil {
+		return
+	}
+
+	if c := p.PKI.Kubernetes.KubeletClientCertificates[k.Name]; c != nil && k.ClientCertificate == "" {
+		k.ClientCertificate = p.PKI.CertificateChain(c, pki.KubernetesCAName)
+		k.ClientKey = types.PrivateKey(util.PickString(string(k.ClientKey), string(c.PrivateKey)))
+	}
+
+	if c := p.PKI.Kubernetes.KubeletServerCertificates[k.Name]; c != nil && k.ServerCertificate == "" {
+		k.ServerCertificate = p.PKI.CertificateChain(c, pki.KubernetesCAName)
+		k.ServerKey = types.PrivateKey(util.PickString(string(k.ServerKey), string(c.PrivateKey)))
+	}
+}
+
+// validateKubeletCertificates checks, that either all or none of the kubelets in the pool have
+// client certificates, either configured or available in the PKI.
+func (p *Pool) validateKubeletCertificates() error {
+	if p.PKI == nil || p.PKI.Kubernetes == nil {
+		return nil
+	}
+
+	withCertificates := 0
+	withoutCertificates := []string{}
+
+	for _, k := range p.Kubelets {
+		_, configured := p.PKI.Kubernetes.Kubelets[k.Name]
+		_, generated := p.PKI.Kubernetes.KubeletClientCertificates[k.Name]
+
+		if k.ClientCertificate != "" || configured || generated {
+			withCertificates++
+
+			continue
+		}
+
+		withoutCertificates = append(withoutCertificates, k.Name)
+	}
+
+	if withCertificates == 0 || len(withoutCertificates) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("kubelets %s have no certificates in PKI, while other kubelets in the pool have, "+
+		"add them to Kubernetes PKI kubelets", strings.Join(withoutCertificates, ", "))
+}
+
+// kubeletBootstrapToken sets bootstrap token for given kubelet with given name in the state,
+// if kubelet has no token configured.
+func (p *Pool) kubeletBootstrapToken(k *Kubelet, name string) {
+	if k.BootstrapConfig == nil || k.BootstrapConfig.Token != "" || p.BootstrapToken == "" {
+		return
+	}
+
+	token := p.BootstrapToken
+
+	if s, ok := p.State[name]; ok && s != nil {
+		token = util.PickString(kubeconfigToken(s.ConfigFiles[bootstrapKubeconfigPath]), token)
+	}
+
+	// Bootstrap configuration may be shared with the pool and other kubelets, so copy it
+	// before setting the token.
+	c := *k.BootstrapConfig
+	c.Token = token
+	k.BootstrapConfig = &c
+}
+
+// kubeconfigToken returns token used by the current context of given kubeconfig. If kubeconfig
+// can't be parsed or has no token, empty string is returned.
+func kubeconfigToken(kubeconfig string) string {
+	if kubeconfig == "" {
+		return ""
+	}
+
+	c, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return ""
+	}
+
+	ctx, ok := c.Contexts[c.CurrentContext]
+	if !ok {
+		return ""
+	}
+
+	if a, ok := c.AuthInfos[ctx.AuthInfo]; ok {
+		return a.Token
+	}
+
+	return ""
 }
 
-// propagateKubelet fills given kubelet with values from Pool object.
-func (p *Pool) propagateKubelet(k *Kubelet) {
+// propagateKubelet fills given kubelet with given name in the state with values from Pool object.
+func (p *Pool) propagateKubelet(k *Kubelet, name string) error {
 	k.Image = util.PickString(k.Image, p.Image)
+	k.ImageSource = util.PickString(k.ImageSource, p.ImageSource)
 	k.ClusterDNSIPs = util.PickStringSlice(k.ClusterDNSIPs, p.ClusterDNSIPs)
-	k.Labels = util.PickStringMap(k.Labels, p.Labels)
 	k.PrivilegedLabels = util.PickStringMap(k.PrivilegedLabels, p.PrivilegedLabels)
 	k.Taints = util.PickStringMap(k.Taints, p.Taints)
 	k.CgroupDriver = util.PickString(k.CgroupDriver, p.CgroupDriver)
@@ -184,21 +314,33 @@ func (p *Pool) propagateKubelet(k *Kubelet) {
 	k.HairpinMode = util.PickString(k.HairpinMode, p.HairpinMode, DefaultHairpinMode)
 	k.VolumePluginDir = util.PickString(k.VolumePluginDir, p.VolumePluginDir, defaults.VolumePluginDir)
 
-	if len(k.ExtraMounts) == 0 {
-		k.ExtraMounts = p.ExtraMounts
+	d := container.PoolDefaults(p.Host, p.SSH, p.Runtime)
+	d.Labels = p.Labels
+	d.ExtraMounts = p.ExtraMounts
+
+	i, err := container.BuildInstanceConfig(container.InstanceConfig{
+		Host:        k.Host,
+		Runtime:     k.Runtime,
+		Labels:      k.Labels,
+		ExtraMounts: k.ExtraMounts,
+	}, d)
+	if err != nil {
+		return fmt.Errorf("building instance configuration: %w", err)
 	}
 
-	k.Host = host.BuildConfig(k.Host, host.Host{
-		SSHConfig: p.SSH,
-	})
+	k.Host, k.Runtime, k.Labels, k.ExtraMounts = i.Host, i.Runtime, i.Labels, i.ExtraMounts
 
 	p.pkiIntegration()
 
 	p.kubeletPKIIntegration(k)
 
+	p.kubeletBootstrapToken(k, name)
+
 	if !k.WaitForNodeReady && p.WaitForNodeReady {
 		k.WaitForNodeReady = p.WaitForNodeReady
 	}
+
+	return nil
 }
 
 // New validates kubelet pool configuration and fills all members with configured values.
@@ -212,21 +354,27 @@ func (p *Pool) New() (types.Resource, error) {
 		DesiredState:  make(container.ContainersState),
 	}
 
+	kubelets := []*kubelet{}
+
 	for i := range p.Kubelets {
 		k := &p.Kubelets[i]
 
-		p.propagateKubelet(k)
+		// Validate already checks for errors, so we can skip checking here.
+		_ = p.propagateKubelet(k, strconv.Itoa(i))
 
-		kubelet, _ := k.New()
-		kubeletHcc, _ := kubelet.ToHostConfiguredContainer()
+		ki, _ := k.New()
+		kubeletHcc, _ := ki.ToHostConfiguredContainer()
 
 		cc.DesiredState[strconv.Itoa(i)] = kubeletHcc
+
+		kubelets = append(kubelets, ki.(*kubelet))
 	}
 
 	c, _ := cc.New()
 
 	return &pool{
 		containers: c,
+		kubelets:   kubelets,
 	}, nil
 }
 
@@ -234,6 +382,14 @@ func (p *Pool) New() (types.Resource, error) {
 func (p *Pool) Validate() error {
 	var errors util.ValidateError
 
+	if err := container.ValidatePoolDefaults(p.Host, p.SSH); err != nil {
+		errors = append(errors, fmt.Errorf("validating defaults: %w", err))
+	}
+
+	if err := p.validateKubeletCertificates(); err != nil {
+		errors = append(errors, fmt.Errorf("validating kubelet certificates: %w", err))
+	}
+
 	cc := &container.Containers{
 		PreviousState: p.State,
 		DesiredState:  make(container.ContainersState),
@@ -243,7 +399,11 @@ func (p *Pool) Validate() error {
 		// Make a copy of Kubelet struct to avoid modifying original one.
 		k := p.Kubelets[i]
 
-		p.propagateKubelet(&k)
+		if err := p.propagateKubelet(&k, strconv.Itoa(i)); err != nil {
+			errors = append(errors, fmt.Errorf("failed to build kubelet %q configuration: %w", i, err))
+
+			continue
+		}
 
 		kubelet, err := k.New()
 		if err != nil {
@@ -285,16 +445,73 @@ func (p *pool) StateToYaml() ([]byte, error) {
 }
 
 // CheckCurrentState refreshes state of configured instances.
-func (p *pool) CheckCurrentState() error {
-	return p.containers.CheckCurrentState()
+func (p *pool) CheckCurrentState(ctx context.Context) error {
+	return p.containers.CheckCurrentState(ctx)
 }
 
 // Deploy checks current status of the pool and deploy configuration changes.
-func (p *pool) Deploy() error {
-	return p.containers.Deploy()
+func (p *pool) Deploy(ctx context.Context) (err error) {
+	// Runtime information is read using connections shared by the containers. They are
+	// closed when deploying the containers finishes, but make sure they are also closed,
+	// if validation fails.
+	c := p.containers.Connections()
+
+	defer func() {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("closing connections to kubelets: %w", cerr)
+		}
+	}()
+
+	if err := p.validateCgroupDrivers(ctx); err != nil {
+		return fmt.Errorf("validating cgroup drivers: %w", err)
+	}
+
+	return p.containers.Deploy(ctx)
+}
+
+// validateCgroupDrivers checks, that all kubelets have cgroup driver configured
+// matching the one used by container runtime on their hosts, so deployment can
+// fail early instead of leaving not starting kubelets behind.
+func (p *pool) validateCgroupDrivers(ctx context.Context) error {
+	var errors util.ValidateError
+
+	for i, k := range p.kubelets {
+		info, err := p.containers.RuntimeInfo(ctx, strconv.Itoa(i))
+		if err != nil {
+			errors = append(errors, fmt.Errorf("getting runtime information for kubelet %d: %w", i, err))
+
+			continue
+		}
+
+		if err := k.validateCgroupDriver(info); err != nil {
+			errors = append(errors, fmt.Errorf("kubelet %d: %w", i, err))
+		}
+	}
+
+	return errors.Return()
 }
 
 // Containers implement types.Resource interface.
 func (p *pool) Containers() container.ContainersInterface {
 	return p.containers
 }
+
+// PreflightChecks implements types.PreflightChecker interface.
+//
+// Kubelets refuse to start with swap enabled and require kernel modules for pod networking
+// and storage. For new kubelets, kubelet port must be free.
+func (p *pool) PreflightChecks() []preflight.HostChecks {
+	return container.PreflightChecks(p.containers, func(name string, isNew bool) []preflight.Check {
+		checks := []preflight.Check{
+			preflight.TimeSync(preflight.DefaultMaxClockSkew),
+			preflight.SwapDisabled(),
+			preflight.KernelModules("br_netfilter", "overlay"),
+		}
+
+		if isNew {
+			checks = append(checks, preflight.PortFree(kubeletPort))
+		}
+
+		return checks
+	})
+}
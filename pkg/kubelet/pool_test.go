@@ -2,12 +2,15 @@ package kubelet
 
 import (
 	"bytes"
+	"context"
+	"strconv"
 	"strings"
 	"testing"
 	"text/template"
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/internal/utiltest"
+	"github.com/flexkube/libflexkube/pkg/container"
 	"github.com/flexkube/libflexkube/pkg/kubernetes/client"
 	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
@@ -94,7 +97,7 @@ func TestPoolStateToYAML(t *testing.T) {
 func TestPoolCheckCurrentState(t *testing.T) {
 	p := GetPool(t)
 
-	if err := p.CheckCurrentState(); err != nil {
+	if err := p.CheckCurrentState(context.Background()); err != nil {
 		t.Fatalf("Checking current state of empty pool should work, got: %v", err)
 	}
 }
@@ -112,7 +115,7 @@ func TestPoolContainers(t *testing.T) {
 func TestPoolDeploy(t *testing.T) {
 	p := GetPool(t)
 
-	if err := p.Deploy(); err == nil {
+	if err := p.Deploy(context.Background()); err == nil {
 		t.Fatalf("Deploying in testing environment should fail")
 	}
 }
@@ -185,6 +188,181 @@ func TestPoolPKIIntegration(t *testing.T) {
 	}
 }
 
+func TestPoolPKIIntegrationCARotation(t *testing.T) {
+	pk := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := pk.Generate(); err != nil {
+		t.Fatalf("generating PKI: %v", err)
+	}
+
+	if _, err := pk.RotateCA(pki.KubernetesCAName); err != nil {
+		t.Fatalf("rotating Kubernetes CA: %v", err)
+	}
+
+	p := &Pool{
+		PKI: pk,
+	}
+
+	p.pkiIntegration()
+
+	if p.KubernetesCACertificate == pk.Kubernetes.CA.X509Certificate {
+		t.Fatalf("kubelets should trust both CA certificates during CA rotation")
+	}
+
+	if p.KubernetesCACertificate != pk.TrustBundle(pki.KubernetesCAName) {
+		t.Fatalf("kubelets should use Kubernetes CA trust bundle")
+	}
+}
+
+func TestPoolPKIIntegrationKubeletCertificates(t *testing.T) {
+	pk := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Kubelets: map[string][]string{
+				"foo": {"10.0.0.1"},
+			},
+		},
+	}
+
+	if err := pk.Generate(); err != nil {
+		t.Fatalf("generating PKI: %v", err)
+	}
+
+	p := &Pool{
+		PKI: pk,
+		BootstrapConfig: &client.Config{
+			Server: "bar",
+		},
+		Kubelets: []Kubelet{
+			{
+				Name:            "foo",
+				VolumePluginDir: "foo",
+				NetworkPlugin:   "cni",
+			},
+		},
+	}
+
+	r, err := p.New()
+	if err != nil {
+		t.Fatalf("creating kubelet pool with kubelet certificates should work, got: %v", err)
+	}
+
+	k := r.(*pool).kubelets[0]
+
+	if k.config.ServerKey != pk.Kubernetes.KubeletServerCertificates["foo"].PrivateKey {
+		t.Fatalf("kubelet should use serving certificate from PKI")
+	}
+
+	files, err := k.configFiles()
+	if err != nil {
+		t.Fatalf("building config files should succeed, got: %v", err)
+	}
+
+	for _, f := range []string{"kubeconfig", "pki/kubelet.crt", "pki/kubelet.key"} {
+		if _, ok := files["/etc/kubernetes/kubelet/"+f]; !ok {
+			t.Errorf("file %q should be created for kubelet with certificates", f)
+		}
+	}
+
+	if _, ok := files["/etc/kubernetes/kubelet/bootstrap-kubeconfig"]; ok {
+		t.Errorf("bootstrap kubeconfig should not be created for kubelet with client certificate")
+	}
+
+	for _, a := range k.args() {
+		if strings.HasPrefix(a, "--bootstrap-kubeconfig") {
+			t.Fatalf("kubelet with client certificate should not use TLS bootstrapping")
+		}
+	}
+}
+
+func TestPoolPKIIntegrationKubeletWithoutCertificates(t *testing.T) {
+	pk := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Kubelets: map[string][]string{
+				"foo": {"10.0.0.1"},
+			},
+		},
+	}
+
+	if err := pk.Generate(); err != nil {
+		t.Fatalf("generating PKI: %v", err)
+	}
+
+	p := &Pool{
+		PKI: pk,
+		BootstrapConfig: &client.Config{
+			Server: "bar",
+			Token:  "bar",
+		},
+		Kubelets: []Kubelet{
+			{
+				Name:            "foo",
+				VolumePluginDir: "foo",
+				NetworkPlugin:   "cni",
+			},
+			{
+				Name:            "bar",
+				VolumePluginDir: "foo",
+				NetworkPlugin:   "cni",
+			},
+		},
+	}
+
+	err := p.Validate()
+	if err == nil {
+		t.Fatalf("validating pool with kubelet missing in PKI should fail")
+	}
+
+	if !strings.Contains(err.Error(), "bar") {
+		t.Fatalf("error should name kubelet missing in PKI, got: %v", err)
+	}
+}
+
+func TestPoolBootstrapTokenDeployedKubelets(t *testing.T) {
+	ca := types.Certificate(utiltest.GenerateX509Certificate(t))
+
+	deployed, err := (&client.Config{
+		Server:        "bar",
+		CACertificate: ca,
+		Token:         "abcdef.0123456789abcdef",
+	}).ToYAMLString()
+	if err != nil {
+		t.Fatalf("rendering bootstrap kubeconfig should succeed, got: %v", err)
+	}
+
+	p := &Pool{
+		BootstrapConfig: &client.Config{
+			Server: "bar",
+		},
+		BootstrapToken:          "ghijkl.0123456789abcdef",
+		KubernetesCACertificate: ca,
+		State: container.ContainersState{
+			"0": &container.HostConfiguredContainer{
+				ConfigFiles: map[string]string{
+					bootstrapKubeconfigPath: deployed,
+				},
+			},
+		},
+	}
+
+	kubelets := []Kubelet{{Name: "foo"}, {Name: "bar"}}
+
+	for i, expected := range []string{"abcdef.0123456789abcdef", "ghijkl.0123456789abcdef"} {
+		if err := p.propagateKubelet(&kubelets[i], strconv.Itoa(i)); err != nil {
+			t.Fatalf("propagating kubelet should succeed, got: %v", err)
+		}
+
+		if token := kubelets[i].BootstrapConfig.Token; token != expected {
+			t.Errorf("kubelet %d should use token %q, got %q", i, expected, token)
+		}
+	}
+
+	if p.BootstrapConfig.Token != "" {
+		t.Fatalf("bootstrap token should not be set in pool bootstrap configuration")
+	}
+}
+
 func TestPoolNoKubelets(t *testing.T) {
 	pk := &pki.PKI{
 		Kubernetes: &pki.Kubernetes{},
@@ -0,0 +1,249 @@
+// Package bootstraptoken implements generating Kubernetes bootstrap tokens, which are used
+// by kubelets for TLS bootstrapping.
+package bootstraptoken
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/flexkube/libflexkube/internal/util"
+)
+
+const (
+	// SecretType is a type of Secret storing bootstrap token.
+	SecretType = "bootstrap.kubernetes.io/token"
+
+	// Namespace is a namespace, where bootstrap token Secrets must be created.
+	Namespace = "kube-system"
+
+	// SecretNamePrefix is a prefix of bootstrap token Secret name, followed by token ID.
+	SecretNamePrefix = "bootstrap-token-"
+
+	// UsageAuthentication allows to use the token for authentication to Kubernetes API.
+	UsageAuthentication = "authentication"
+
+	// UsageSigning allows to use the token for signing cluster-info ConfigMap.
+	UsageSigning = "signing"
+
+	// GroupPrefix is a required prefix for extra groups of bootstrap token.
+	GroupPrefix = "system:bootstrappers:"
+
+	// idLength is a length of token ID.
+	idLength = 6
+
+	// secretLength is a length of token secret.
+	secretLength = 16
+
+	// charset contains characters allowed in token ID and secret.
+	charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// Config describes bootstrap token to generate.
+type Config struct {
+	// Description is a human readable description of the token.
+	//
+	// This field is optional.
+	Description string `json:"description,omitempty"`
+
+	// TTL defines, for how long generated token is valid, e.g. '24h'. If empty, token never expires.
+	//
+	// This field is optional.
+	TTL string `json:"ttl,omitempty"`
+
+	// Usages is a list of ways the token can be used, either 'authentication' or 'signing'.
+	//
+	// If empty, 'authentication' is used.
+	Usages []string `json:"usages,omitempty"`
+
+	// Groups is a list of extra groups, which the token will authenticate as, in addition
+	// to 'system:bootstrappers' group. Each group must start with 'system:bootstrappers:'.
+	//
+	// This field is optional.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Token is a generated bootstrap token.
+type Token struct {
+	// ID is a public part of the token.
+	ID string `json:"id"`
+
+	// Secret is a private part of the token.
+	Secret string `json:"secret"`
+
+	// Description is a human readable description of the token.
+	Description string `json:"description,omitempty"`
+
+	// TTL is a TTL from configuration used to generate the token. It is stored to be able to
+	// detect configuration changes, as it cannot be recovered from expiration time.
+	TTL string `json:"ttl,omitempty"`
+
+	// Expiration is a time, when the token expires. If not set, token never expires.
+	Expiration *time.Time `json:"expiration,omitempty"`
+
+	// Usages is a list of ways the token can be used.
+	Usages []string `json:"usages,omitempty"`
+
+	// Groups is a list of extra groups, which the token authenticates as.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Validate validates bootstrap token configuration.
+func (c *Config) Validate() error {
+	var errors util.ValidateError
+
+	if c.TTL != "" {
+		ttl, err := time.ParseDuration(c.TTL)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("parsing TTL %q: %w", c.TTL, err))
+		}
+
+		if err == nil && ttl <= 0 {
+			errors = append(errors, fmt.Errorf("TTL must be positive"))
+		}
+	}
+
+	for _, u := range c.Usages {
+		if u != UsageAuthentication && u != UsageSigning {
+			errors = append(errors, fmt.Errorf("unknown usage %q, must be either %q or %q", u, UsageAuthentication, UsageSigning))
+		}
+	}
+
+	for _, g := range c.Groups {
+		if !strings.HasPrefix(g, GroupPrefix) || g == GroupPrefix {
+			errors = append(errors, fmt.Errorf("group %q must start with %q", g, GroupPrefix))
+		}
+	}
+
+	return errors.Return()
+}
+
+// New validates bootstrap token configuration and generates new token using it.
+func (c *Config) New() (*Token, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to validate bootstrap token configuration: %w", err)
+	}
+
+	id, err := randomString(idLength)
+	if err != nil {
+		return nil, fmt.Errorf("generating token ID: %w", err)
+	}
+
+	secret, err := randomString(secretLength)
+	if err != nil {
+		return nil, fmt.Errorf("generating token secret: %w", err)
+	}
+
+	t := &Token{
+		ID:          id,
+		Secret:      secret,
+		Description: c.Description,
+		TTL:         c.TTL,
+		Usages:      util.PickStringSlice(c.Usages, []string{UsageAuthentication}),
+		Groups:      c.Groups,
+	}
+
+	if c.TTL != "" {
+		// TTL is validated already, so error can be ignored here.
+		ttl, _ := time.ParseDuration(c.TTL)
+		e := time.Now().Add(ttl).UTC().Truncate(time.Second)
+		t.Expiration = &e
+	}
+
+	return t, nil
+}
+
+// randomString returns random string of given length using characters allowed in the token.
+func randomString(length int) (string, error) {
+	max := big.NewInt(int64(len(charset)))
+
+	var sb strings.Builder
+
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("reading random data: %w", err)
+		}
+
+		sb.WriteByte(charset[n.Int64()])
+	}
+
+	return sb.String(), nil
+}
+
+// String returns the token in format used by kubelet, e.g. 'abcdef.0123456789abcdef'.
+func (t *Token) String() string {
+	return fmt.Sprintf("%s.%s", t.ID, t.Secret)
+}
+
+// Expired checks, if the token has expired.
+func (t *Token) Expired() bool {
+	return t.Expiration != nil && !time.Now().Before(*t.Expiration)
+}
+
+// Matches checks, if the token has been generated using given configuration. If it does
+// not, the token should be generated again to apply the configuration changes.
+func (t *Token) Matches(c *Config) bool {
+	return t.Description == c.Description &&
+		t.TTL == c.TTL &&
+		stringSlicesEqual(t.Usages, util.PickStringSlice(c.Usages, []string{UsageAuthentication})) &&
+		stringSlicesEqual(t.Groups, c.Groups)
+}
+
+// stringSlicesEqual checks, if given slices contain the same elements in the same order.
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SecretName returns name of Secret storing the token.
+func (t *Token) SecretName() string {
+	return SecretNamePrefix + t.ID
+}
+
+// ToSecret returns Secret object, which makes the token valid for Kubernetes API.
+func (t *Token) ToSecret() *v1.Secret {
+	data := map[string]string{
+		"token-id":     t.ID,
+		"token-secret": t.Secret,
+	}
+
+	if t.Description != "" {
+		data["description"] = t.Description
+	}
+
+	if t.Expiration != nil {
+		data["expiration"] = t.Expiration.Format(time.RFC3339)
+	}
+
+	for _, u := range t.Usages {
+		data["usage-bootstrap-"+u] = "true"
+	}
+
+	if len(t.Groups) > 0 {
+		data["auth-extra-groups"] = strings.Join(t.Groups, ",")
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      t.SecretName(),
+			Namespace: Namespace,
+		},
+		Type:       SecretType,
+		StringData: data,
+	}
+}
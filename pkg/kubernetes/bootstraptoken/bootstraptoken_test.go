@@ -0,0 +1,245 @@
+package bootstraptoken_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/kubernetes/bootstraptoken"
+)
+
+// Validate() tests.
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]*bootstraptoken.Config{
+		"bad TTL": {
+			TTL: "foo",
+		},
+		"negative TTL": {
+			TTL: "-1h",
+		},
+		"unknown usage": {
+			Usages: []string{"foo"},
+		},
+		"group without prefix": {
+			Groups: []string{"system:nodes"},
+		},
+		"prefix only group": {
+			Groups: []string{bootstraptoken.GroupPrefix},
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			if err := c.Validate(); err == nil {
+				t.Fatalf("Validation should fail")
+			}
+		})
+	}
+}
+
+// New() tests.
+func TestConfigNew(t *testing.T) {
+	t.Parallel()
+
+	c := &bootstraptoken.Config{
+		Description: "foo",
+		TTL:         "24h",
+		Groups:      []string{"system:bootstrappers:foo"},
+	}
+
+	token, err := c.New()
+	if err != nil {
+		t.Fatalf("Generating token should succeed, got: %v", err)
+	}
+
+	if !regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`).MatchString(token.String()) {
+		t.Fatalf("Generated token %q has invalid format", token.String())
+	}
+
+	if diff := cmp.Diff([]string{bootstraptoken.UsageAuthentication}, token.Usages); diff != "" {
+		t.Fatalf("Unexpected default usages: %s", diff)
+	}
+
+	if token.Expiration == nil || token.Expiration.Before(time.Now().Add(23*time.Hour)) {
+		t.Fatalf("Token should expire after configured TTL, got: %v", token.Expiration)
+	}
+
+	if token.Expired() {
+		t.Fatalf("New token should not be expired")
+	}
+}
+
+func TestConfigNewUnique(t *testing.T) {
+	t.Parallel()
+
+	c := &bootstraptoken.Config{}
+
+	a, err := c.New()
+	if err != nil {
+		t.Fatalf("Generating token should succeed, got: %v", err)
+	}
+
+	b, err := c.New()
+	if err != nil {
+		t.Fatalf("Generating token should succeed, got: %v", err)
+	}
+
+	if a.String() == b.String() {
+		t.Fatalf("Generated tokens should be unique")
+	}
+
+	if a.Expiration != nil {
+		t.Fatalf("Token without TTL should never expire")
+	}
+}
+
+func TestConfigNewInvalid(t *testing.T) {
+	t.Parallel()
+
+	c := &bootstraptoken.Config{
+		TTL: "foo",
+	}
+
+	if _, err := c.New(); err == nil {
+		t.Fatalf("Generating token with invalid configuration should fail")
+	}
+}
+
+// Expired() tests.
+func TestTokenExpired(t *testing.T) {
+	t.Parallel()
+
+	e := time.Now().Add(-time.Minute)
+
+	token := &bootstraptoken.Token{
+		Expiration: &e,
+	}
+
+	if !token.Expired() {
+		t.Fatalf("Token should be expired")
+	}
+}
+
+// Matches() tests.
+func TestTokenMatches(t *testing.T) {
+	t.Parallel()
+
+	c := &bootstraptoken.Config{
+		Description: "foo",
+		TTL:         "24h",
+		Groups:      []string{"system:bootstrappers:foo"},
+	}
+
+	token, err := c.New()
+	if err != nil {
+		t.Fatalf("Generating token should succeed, got: %v", err)
+	}
+
+	if !token.Matches(c) {
+		t.Fatalf("Token should match configuration used to generate it")
+	}
+
+	cases := map[string]*bootstraptoken.Config{
+		"description changed": {
+			Description: "bar",
+			TTL:         "24h",
+			Groups:      []string{"system:bootstrappers:foo"},
+		},
+		"TTL changed": {
+			Description: "foo",
+			TTL:         "48h",
+			Groups:      []string{"system:bootstrappers:foo"},
+		},
+		"TTL removed": {
+			Description: "foo",
+			Groups:      []string{"system:bootstrappers:foo"},
+		},
+		"usages changed": {
+			Description: "foo",
+			TTL:         "24h",
+			Usages:      []string{bootstraptoken.UsageAuthentication, bootstraptoken.UsageSigning},
+			Groups:      []string{"system:bootstrappers:foo"},
+		},
+		"groups changed": {
+			Description: "foo",
+			TTL:         "24h",
+			Groups:      []string{"system:bootstrappers:bar"},
+		},
+		"groups removed": {
+			Description: "foo",
+			TTL:         "24h",
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			if token.Matches(c) {
+				t.Fatalf("Token should not match changed configuration")
+			}
+		})
+	}
+}
+
+func TestTokenMatchesDefaultUsages(t *testing.T) {
+	t.Parallel()
+
+	token := &bootstraptoken.Token{
+		Usages: []string{bootstraptoken.UsageAuthentication},
+	}
+
+	if !token.Matches(&bootstraptoken.Config{}) {
+		t.Fatalf("Token with default usages should match configuration without usages")
+	}
+}
+
+// ToSecret() tests.
+func TestTokenToSecret(t *testing.T) {
+	t.Parallel()
+
+	e := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	token := &bootstraptoken.Token{
+		ID:          "abcdef",
+		Secret:      "0123456789abcdef",
+		Description: "foo",
+		Expiration:  &e,
+		Usages:      []string{bootstraptoken.UsageAuthentication, bootstraptoken.UsageSigning},
+		Groups:      []string{"system:bootstrappers:foo", "system:bootstrappers:bar"},
+	}
+
+	s := token.ToSecret()
+
+	if s.Name != "bootstrap-token-abcdef" || s.Namespace != "kube-system" {
+		t.Fatalf("Unexpected secret name %s/%s", s.Namespace, s.Name)
+	}
+
+	if s.Type != bootstraptoken.SecretType {
+		t.Fatalf("Unexpected secret type %q", s.Type)
+	}
+
+	expected := map[string]string{
+		"token-id":                       "abcdef",
+		"token-secret":                   "0123456789abcdef",
+		"description":                    "foo",
+		"expiration":                     "2020-01-02T03:04:05Z",
+		"usage-bootstrap-authentication": "true",
+		"usage-bootstrap-signing":        "true",
+		"auth-extra-groups":              "system:bootstrappers:foo,system:bootstrappers:bar",
+	}
+
+	if diff := cmp.Diff(expected, s.StringData); diff != "" {
+		t.Fatalf("Unexpected secret data: %s", diff)
+	}
+}
@@ -43,6 +43,12 @@ type Client interface {
 
 	// PingWait waits until API server becomes available.
 	PingWait() error
+
+	// ApplySecret creates given Secret or replaces it, if it already exists.
+	ApplySecret(secret *v1.Secret) error
+
+	// DeleteSecret deletes Secret with given name from given namespace. Missing Secret is ignored.
+	DeleteSecret(namespace, name string) error
 }
 
 type client struct {
@@ -156,3 +162,34 @@ func (c *client) LabelNode(name string, labels map[string]string) error {
 
 	return nil
 }
+
+// ApplySecret creates given Secret. If Secret already exists, it is replaced.
+func (c *client) ApplySecret(secret *v1.Secret) error {
+	secrets := c.CoreV1().Secrets(secret.Namespace)
+
+	_, err := secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating secret %q: %w", secret.Name, err)
+	}
+
+	if _, err := secrets.Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating secret %q: %w", secret.Name, err)
+	}
+
+	return nil
+}
+
+// DeleteSecret deletes Secret with given name from given namespace. If Secret does not exist,
+// no error is returned.
+func (c *client) DeleteSecret(namespace, name string) error {
+	err := c.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deleting secret %q: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package getter
+
+import (
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: test
+  user:
+    token: foo
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`
+
+// New() tests.
+func TestNew(t *testing.T) {
+	g, err := New([]byte(testKubeconfig))
+	if err != nil {
+		t.Fatalf("Creating getter should work, got: %v", err)
+	}
+
+	rc, err := g.ToRESTConfig()
+	if err != nil {
+		t.Fatalf("Turning getter into REST config should work, got: %v", err)
+	}
+
+	if rc.Host != "https://127.0.0.1:6443" {
+		t.Fatalf("Unexpected host %q", rc.Host)
+	}
+}
+
+func TestNewBadKubeconfig(t *testing.T) {
+	if _, err := New([]byte("foo")); err == nil {
+		t.Fatalf("Creating getter from malformed kubeconfig should fail")
+	}
+}
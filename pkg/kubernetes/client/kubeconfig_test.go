@@ -9,6 +9,7 @@ import (
 
 	"github.com/flexkube/libflexkube/internal/util"
 	"github.com/flexkube/libflexkube/internal/utiltest"
+	"github.com/flexkube/libflexkube/pkg/pki"
 	"github.com/flexkube/libflexkube/pkg/types"
 )
 
@@ -261,3 +262,37 @@ func TestValidate(t *testing.T) { //nolint:funlen
 		})
 	}
 }
+
+func TestToYAMLStringKeyAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	for _, a := range []string{pki.KeyAlgorithmECDSAP256, pki.KeyAlgorithmECDSAP384, pki.KeyAlgorithmEd25519} {
+		a := a
+
+		t.Run(a, func(t *testing.T) {
+			t.Parallel()
+
+			p := &pki.PKI{
+				Certificate: pki.Certificate{
+					KeyAlgorithm: a,
+				},
+				Kubernetes: &pki.Kubernetes{},
+			}
+
+			if err := p.Generate(); err != nil {
+				t.Fatalf("Generating PKI should succeed, got: %v", err)
+			}
+
+			c := &Config{
+				Server:            "localhost",
+				CACertificate:     p.Kubernetes.CA.X509Certificate,
+				ClientCertificate: p.Kubernetes.AdminCertificate.X509Certificate,
+				ClientKey:         p.Kubernetes.AdminCertificate.PrivateKey,
+			}
+
+			if _, err := c.ToYAMLString(); err != nil {
+				t.Fatalf("Rendering kubeconfig should succeed, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// ensureCertificateSigningRequest ensures, that certificate signing request for the certificate
+// exists, if the certificate must be signed. When imported certificate is up to date, the request
+// is removed.
+func (c *Certificate) ensureCertificateSigningRequest(k crypto.Signer, ca *Certificate) error {
+	reasons, err := c.renewalReasonsFor(ca)
+	if err != nil {
+		return fmt.Errorf("failed checking if X.509 certificate is up to date: %w", err)
+	}
+
+	if len(reasons) == 0 {
+		c.CertificateSigningRequest = ""
+
+		return nil
+	}
+
+	upToDate, err := c.certificateSigningRequestUpToDate(k)
+	if err != nil {
+		return fmt.Errorf("failed checking if certificate signing request is up to date: %w", err)
+	}
+
+	if upToDate {
+		return nil
+	}
+
+	return c.generateCertificateSigningRequest(k)
+}
+
+// generateCertificateSigningRequest generates certificate signing request using given private key
+// and persists it in the certificate object.
+func (c *Certificate) generateCertificateSigningRequest(k crypto.Signer) error {
+	csr := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Organization: []string{c.Organization},
+			CommonName:   c.CommonName,
+		},
+		DNSNames: c.DNSNames,
+	}
+
+	for _, i := range c.IPAddresses {
+		csr.IPAddresses = append(csr.IPAddresses, net.ParseIP(i))
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, csr, k)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate signing request: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := pem.Encode(&buf, &pem.Block{Type: CertificateRequestPEMHeader, Bytes: der}); err != nil {
+		return fmt.Errorf("failed to encode certificate signing request: %w", err)
+	}
+
+	c.CertificateSigningRequest = buf.String()
+
+	return nil
+}
+
+// certificateSigningRequestUpToDate checks, if existing certificate signing request
+// matches the certificate configuration and given private key.
+func (c *Certificate) certificateSigningRequestUpToDate(k crypto.Signer) (bool, error) {
+	if c.CertificateSigningRequest == "" {
+		return false, nil
+	}
+
+	der, _ := pem.Decode([]byte(c.CertificateSigningRequest))
+	if der == nil {
+		return false, fmt.Errorf("certificate signing request is not defined in valid PEM format")
+	}
+
+	csr, err := x509.ParseCertificateRequest(der.Bytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate signing request: %w", err)
+	}
+
+	return publicKeysEqual(k.Public(), csr.PublicKey) &&
+		csr.Subject.CommonName == c.CommonName &&
+		strings.Join(csr.Subject.Organization, ",") == c.Organization &&
+		sameStrings(csr.DNSNames, c.DNSNames) &&
+		ipAddressesUpToDate(csr.IPAddresses, c.IPAddresses), nil
+}
+
+// CertificateSigningRequests returns PEM encoded certificate signing requests for all
+// certificates, which await external signing. Key is a name of the certificate in PKI,
+// e.g. 'etcd.peerCertificates.foo'.
+func (p *PKI) CertificateSigningRequests() map[string]string {
+	csrs := map[string]string{}
+
+	for n, c := range p.certificates() {
+		if c.CertificateSigningRequest != "" {
+			csrs[n] = c.CertificateSigningRequest
+		}
+	}
+
+	return csrs
+}
+
+// ImportCertificate imports externally signed X.509 certificate for certificate with given
+// name. Certificate must be issued by the configured CA, match the private key and configuration
+// of the certificate, like SANs and key usage, otherwise error is returned and the certificate
+// is not imported.
+func (p *PKI) ImportCertificate(name string, cert types.Certificate) error {
+	cr, err := p.certificateRequest(name)
+	if err != nil {
+		return fmt.Errorf("failed finding certificate: %w", err)
+	}
+
+	if cr.CA == nil || cr.CA.X509Certificate == "" {
+		return fmt.Errorf("CA certificate for certificate %q is not available", name)
+	}
+
+	r, err := buildCertificate(cr.Certificates...)
+	if err != nil {
+		return fmt.Errorf("failed to build certificate configuration: %w", err)
+	}
+
+	if r.PrivateKey == "" {
+		return fmt.Errorf("private key for certificate %q is not generated", name)
+	}
+
+	r.X509Certificate = cert
+
+	if err := r.validateImported(cr.CA); err != nil {
+		return fmt.Errorf("validating certificate %q: %w", name, err)
+	}
+
+	cr.Target.X509Certificate = cert
+	cr.Target.CertificateSigningRequest = ""
+
+	return nil
+}
+
+// validateImported validates, that imported X.509 certificate matches the private key and
+// the configuration and that it is issued by given CA.
+func (c *Certificate) validateImported(ca *Certificate) error {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	k, err := c.decodePrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	if !publicKeysEqual(k.Public(), cert.PublicKey) {
+		return fmt.Errorf("certificate does not match the private key")
+	}
+
+	reasons, err := c.renewalReasonsFor(ca)
+	if err != nil {
+		return fmt.Errorf("failed checking the certificate: %w", err)
+	}
+
+	if len(reasons) != 0 {
+		return fmt.Errorf("certificate does not match the configuration: %s", strings.Join(reasons, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package pki_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// signCSR signs given PEM encoded certificate signing request using given CA
+// as a client certificate.
+func signCSR(t *testing.T, csrPEM string, ca *pki.Certificate, eku x509.ExtKeyUsage) types.Certificate {
+	t.Helper()
+
+	der, _ := pem.Decode([]byte(csrPEM))
+	if der == nil {
+		t.Fatalf("Certificate signing request should be PEM encoded")
+	}
+
+	csr, err := x509.ParseCertificateRequest(der.Bytes)
+	if err != nil {
+		t.Fatalf("Parsing certificate signing request should succeed, got: %v", err)
+	}
+
+	caCert, err := ca.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding CA certificate should succeed, got: %v", err)
+	}
+
+	keyDER, _ := pem.Decode([]byte(ca.PrivateKey))
+
+	caKey, err := x509.ParsePKCS8PrivateKey(keyDER.Bytes)
+	if err != nil {
+		t.Fatalf("Parsing CA private key should succeed, got: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(8760 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{eku},
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caKey.(crypto.Signer))
+	if err != nil {
+		t.Fatalf("Signing certificate should succeed, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := pem.Encode(&buf, &pem.Block{Type: pki.X509CertificatePEMHeader, Bytes: cert}); err != nil {
+		t.Fatalf("Encoding certificate should succeed, got: %v", err)
+	}
+
+	return types.Certificate(buf.String())
+}
+
+func externallySignedPKI(t *testing.T, etcdCA *pki.Certificate) *pki.PKI {
+	t.Helper()
+
+	root := testCA(t, "corporate-root", nil)
+
+	return &pki.PKI{
+		Certificate: pki.Certificate{
+			ExternalSigning: true,
+		},
+		RootCA: &pki.Certificate{
+			External:        true,
+			X509Certificate: root.X509Certificate,
+		},
+		Etcd: &pki.Etcd{
+			CA: &pki.Certificate{
+				External:        true,
+				X509Certificate: etcdCA.X509Certificate,
+			},
+			ClientCNs: []string{"root"},
+		},
+	}
+}
+
+// ImportCertificate() tests.
+//
+//nolint:funlen // Test walks through whole external signing workflow.
+func TestExternalSigning(t *testing.T) {
+	t.Parallel()
+
+	etcdCA := testCA(t, "etcd-ca", nil)
+	p := externallySignedPKI(t, etcdCA)
+	name := "etcd.clientCertificates.root"
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with external signing should succeed, got: %v", err)
+	}
+
+	csrs := p.CertificateSigningRequests()
+	if len(csrs) != 1 || csrs[name] == "" {
+		t.Fatalf("Expected certificate signing request for %q, got: %v", name, csrs)
+	}
+
+	c := p.Etcd.ClientCertificates["root"]
+
+	if c.X509Certificate != "" || c.PrivateKey == "" {
+		t.Fatalf("Only private key should be generated for externally signed certificate")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Re-generating PKI should succeed, got: %v", err)
+	}
+
+	if c.CertificateSigningRequest != csrs[name] {
+		t.Fatalf("Certificate signing request should not be re-generated when up to date")
+	}
+
+	if err := p.ImportCertificate(name, signCSR(t, csrs[name], etcdCA, x509.ExtKeyUsageServerAuth)); err == nil {
+		t.Fatalf("Importing certificate with wrong key usage should fail")
+	}
+
+	if err := p.ImportCertificate(name, signCSR(t, csrs[name], testCA(t, "other", nil), x509.ExtKeyUsageClientAuth)); err == nil {
+		t.Fatalf("Importing certificate signed by other CA should fail")
+	}
+
+	if c.X509Certificate != "" {
+		t.Fatalf("Invalid certificate should not be imported")
+	}
+
+	if err := p.ImportCertificate(name, signCSR(t, csrs[name], etcdCA, x509.ExtKeyUsageClientAuth)); err != nil {
+		t.Fatalf("Importing valid certificate should succeed, got: %v", err)
+	}
+
+	if c.X509Certificate == "" || c.CertificateSigningRequest != "" {
+		t.Fatalf("Certificate should be imported and signing request removed")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI after import should succeed, got: %v", err)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 || len(p.CertificateSigningRequests()) != 0 {
+		t.Fatalf("All certificates should be up to date, got: %v", plan)
+	}
+}
+
+func TestExternalSigningConfigurationChange(t *testing.T) {
+	t.Parallel()
+
+	etcdCA := testCA(t, "etcd-ca", nil)
+	p := externallySignedPKI(t, etcdCA)
+	name := "etcd.clientCertificates.root"
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with external signing should succeed, got: %v", err)
+	}
+
+	if err := p.ImportCertificate(name, signCSR(t, p.CertificateSigningRequests()[name], etcdCA, x509.ExtKeyUsageClientAuth)); err != nil {
+		t.Fatalf("Importing valid certificate should succeed, got: %v", err)
+	}
+
+	c := p.Etcd.ClientCertificates["root"]
+	cert := c.X509Certificate
+
+	c.DNSNames = []string{"foo"}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	if c.CertificateSigningRequest == "" {
+		t.Fatalf("New certificate signing request should be generated when configuration changes")
+	}
+
+	if c.X509Certificate != cert {
+		t.Fatalf("Existing certificate should be kept until new one is imported")
+	}
+}
+
+func TestImportCertificateNotFound(t *testing.T) {
+	p := &pki.PKI{}
+
+	if err := p.ImportCertificate("etcd.clientCertificates.root", ""); err == nil {
+		t.Fatalf("Importing not existing certificate should fail")
+	}
+}
+
+func TestValidateExternalSigningCA(t *testing.T) {
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		RSABits:          2048,
+		CA:               true,
+		ExternalSigning:  true,
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Signing not external CA certificate externally should fail")
+	}
+}
@@ -0,0 +1,75 @@
+package pki
+
+const (
+	// DockerCACN is a default CN for Docker CA certificate.
+	DockerCACN = "docker-ca"
+
+	// DockerClientCN is a default CN for Docker client certificate.
+	DockerClientCN = "flexkube"
+)
+
+// Docker stores Docker daemon PKI and their settings. Generated certificates can be used
+// for exposing Docker daemons over TCP with mutual TLS authentication.
+type Docker struct {
+	// Inline Certificate struct, so some settings can be applied as defaults for all Docker certificates.
+	Certificate
+
+	// CA stores Docker CA certificate.
+	CA *Certificate `json:"ca,omitempty"`
+
+	// Servers is a map of server certificates to generate, where key is the CN of the server
+	// certificate and value is the IP address on which Docker daemon will be listening on.
+	Servers map[string]string `json:"servers,omitempty"`
+
+	// ServerCertificates defines and stores all server certificates.
+	ServerCertificates map[string]*Certificate `json:"serverCertificates,omitempty"`
+
+	// ClientCertificate defines and stores client certificate.
+	ClientCertificate *Certificate `json:"clientCertificate,omitempty"`
+}
+
+// Generate generates Docker PKI.
+func (d *Docker) Generate(rootCA *Certificate, defaultCertificate Certificate) error {
+	return buildAndGenerate(d.certificateRequests(rootCA, defaultCertificate)...)
+}
+
+// certificateRequests returns requests for Docker CA certificate and all certificates issued by it.
+func (d *Docker) certificateRequests(rootCA *Certificate, defaultCertificate Certificate) []*certificateRequest {
+	if d.CA == nil {
+		d.CA = &Certificate{}
+	}
+
+	if d.ServerCertificates == nil && len(d.Servers) != 0 {
+		d.ServerCertificates = map[string]*Certificate{}
+	}
+
+	if d.ClientCertificate == nil {
+		d.ClientCertificate = &Certificate{}
+	}
+
+	cr := &certificateRequest{
+		Target: d.CA,
+		CA:     rootCA,
+		Certificates: []*Certificate{
+			&defaultCertificate,
+			&d.Certificate,
+			caCertificate(DockerCACN),
+			d.CA,
+		},
+	}
+
+	defaultCertificates := []*Certificate{&defaultCertificate, &d.Certificate}
+
+	// Docker CA certificate must be generated first.
+	crs := append([]*certificateRequest{cr}, crsFromMap(d.CA, defaultCertificates, d.ServerCertificates, d.Servers, true)...)
+
+	return append(crs, &certificateRequest{
+		Target: d.ClientCertificate,
+		CA:     d.CA,
+		Certificates: append(
+			defaultCertificates,
+			certificateFromCNIPMap(DockerClientCN, "", false),
+			d.ClientCertificate,
+		),
+	})
+}
@@ -0,0 +1,39 @@
+package pki_test
+
+import (
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/container/runtime/docker"
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+func TestGenerateDockerCertificates(t *testing.T) {
+	t.Parallel()
+
+	pki := &pki.PKI{
+		Docker: &pki.Docker{
+			Servers: map[string]string{
+				"foo": "1.1.1.1",
+			},
+		},
+	}
+
+	if err := pki.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	if pki.Docker.ServerCertificates["foo"].X509Certificate == "" {
+		t.Fatalf("generated Docker server certificate should not be empty")
+	}
+
+	c := &docker.Config{
+		Host:              "tcp://1.1.1.1:2376",
+		CACertificate:     string(pki.Docker.CA.X509Certificate),
+		ClientCertificate: string(pki.Docker.ClientCertificate.X509Certificate),
+		ClientKey:         string(pki.Docker.ClientCertificate.PrivateKey),
+	}
+
+	if _, err := c.New(); err != nil {
+		t.Fatalf("generated certificates should be usable for Docker client, got: %v", err)
+	}
+}
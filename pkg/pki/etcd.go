@@ -1,9 +1,5 @@
 package pki
 
-import (
-	"fmt"
-)
-
 const (
 	// EtcdCACN is a default CN for etcd CA certificate, as recommended by
 	// https://kubernetes.io/docs/setup/best-practices/certificates/.
@@ -41,6 +37,11 @@ type Etcd struct {
 
 // Generate generates etcd PKI.
 func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) error {
+	return buildAndGenerate(e.certificateRequests(rootCA, defaultCertificate)...)
+}
+
+// certificateRequests returns requests for etcd CA certificate and all certificates issued by it.
+func (e *Etcd) certificateRequests(rootCA *Certificate, defaultCertificate Certificate) []*certificateRequest {
 	if e.CA == nil {
 		e.CA = &Certificate{}
 	}
@@ -75,12 +76,8 @@ func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) err
 		},
 	}
 
-	// etcd CA Certificate
-	if err := buildAndGenerate(cr); err != nil {
-		return fmt.Errorf("failed to generate etcd CA certificate: %w", err)
-	}
-
-	crs := []*certificateRequest{}
+	// etcd CA certificate must be generated first.
+	crs := []*certificateRequest{cr}
 
 	crs = append(crs, e.crsFromMap(&defaultCertificate, e.PeerCertificates, e.Peers, true)...)
 	crs = append(crs, e.crsFromMap(&defaultCertificate, e.ServerCertificates, servers, true)...)
@@ -90,9 +87,7 @@ func (e *Etcd) Generate(rootCA *Certificate, defaultCertificate Certificate) err
 		clientCNsMap[commonName] = ""
 	}
 
-	crs = append(crs, e.crsFromMap(&defaultCertificate, e.ClientCertificates, clientCNsMap, false)...)
-
-	return buildAndGenerate(crs...)
+	return append(crs, e.crsFromMap(&defaultCertificate, e.ClientCertificates, clientCNsMap, false)...)
 }
 
 // certificateFromCNIPMap produces a certificate from given common name and IP address.
@@ -114,10 +109,25 @@ func certificateFromCNIPMap(commonName string, ip string, server bool) *Certific
 	return c
 }
 
-// peerCRs builds list of certificate requests for peer certificates by combining
-// information from PeerCertificates and Peers fields, where PeerCertificates always
-// takes precedence.
+// crsFromMap builds list of certificate requests for etcd certificates by combining
+// information from given certificates map and common names map.
 func (e *Etcd) crsFromMap(defaultCertificate *Certificate, certs map[string]*Certificate, cnIPs map[string]string, server bool) []*certificateRequest {
+	return crsFromMap(e.CA, []*Certificate{defaultCertificate, &e.Certificate}, certs, cnIPs, server)
+}
+
+// crsFromMap builds list of certificate requests signed by given CA by combining
+// information from certs and cnIPs maps, where certs always takes precedence.
+func crsFromMap(ca *Certificate, defaultCertificates []*Certificate, certs map[string]*Certificate, cnIPs map[string]string, server bool) []*certificateRequest {
+	return crsFromTemplate(ca, defaultCertificates, certs, cnIPs, func(commonName, ip string) *Certificate {
+		return certificateFromCNIPMap(commonName, ip, server)
+	})
+}
+
+// crsFromTemplate builds list of certificate requests signed by given CA by combining
+// information from certs and names maps, where certs always takes precedence. Default
+// configuration for each certificate is produced by given template function from the
+// name and the IP address.
+func crsFromTemplate(ca *Certificate, defaultCertificates []*Certificate, certs map[string]*Certificate, names map[string]string, template func(name, ip string) *Certificate) []*certificateRequest {
 	// Store peer CRs in temporary map, so we can find them by common name.
 	crs := map[string]*certificateRequest{}
 
@@ -126,17 +136,16 @@ func (e *Etcd) crsFromMap(defaultCertificate *Certificate, certs map[string]*Cer
 	for commonName := range certs {
 		crs[commonName] = &certificateRequest{
 			Target: certs[commonName],
-			CA:     e.CA,
-			Certificates: []*Certificate{
-				defaultCertificate,
-				&e.Certificate,
-				certificateFromCNIPMap(commonName, cnIPs[commonName], server),
+			CA:     ca,
+			Certificates: append(
+				append([]*Certificate{}, defaultCertificates...),
+				template(commonName, names[commonName]),
 				certs[commonName],
-			},
+			),
 		}
 	}
 
-	for commonName, ip := range cnIPs {
+	for commonName, ip := range names {
 		// If certificate request is already created for a given common name, it will
 		// have peers information included, so we jump to another one.
 		if _, ok := crs[commonName]; ok {
@@ -150,12 +159,11 @@ func (e *Etcd) crsFromMap(defaultCertificate *Certificate, certs map[string]*Cer
 
 		crs[commonName] = &certificateRequest{
 			Target: certs[commonName],
-			CA:     e.CA,
-			Certificates: []*Certificate{
-				defaultCertificate,
-				&e.Certificate,
-				certificateFromCNIPMap(commonName, ip, server),
-			},
+			CA:     ca,
+			Certificates: append(
+				append([]*Certificate{}, defaultCertificates...),
+				template(commonName, ip),
+			),
 		}
 	}
 
@@ -0,0 +1,323 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+const (
+	// KubeadmServiceAccountPrivateKey is a path of service account private key in kubeadm
+	// PKI directory.
+	KubeadmServiceAccountPrivateKey = "sa.key"
+
+	// KubeadmServiceAccountPublicKey is a path of service account public key in kubeadm
+	// PKI directory.
+	KubeadmServiceAccountPublicKey = "sa.pub"
+
+	// KubeadmAPIServerEtcdClientCN is a CN of etcd client certificate used by kube-apiserver,
+	// stored as 'apiserver-etcd-client' in kubeadm PKI directory.
+	KubeadmAPIServerEtcdClientCN = "kube-apiserver"
+
+	// KubeadmEtcdHealthcheckClientCN is a CN of etcd client certificate used for health checking,
+	// stored as 'etcd/healthcheck-client' in kubeadm PKI directory.
+	KubeadmEtcdHealthcheckClientCN = "kube-etcd-healthcheck-client"
+)
+
+// kubeadmCertificate maps certificate in PKI to certificate and private key files in kubeadm
+// PKI directory.
+type kubeadmCertificate struct {
+	// name is a path of the certificate relative to kubeadm PKI directory, without extension.
+	name string
+
+	// certificate returns the certificate from PKI. If create is true, missing certificate
+	// is created, otherwise nil is returned.
+	certificate func(p *PKI, create bool) *Certificate
+}
+
+// kubeadmCertificates returns all certificates stored in kubeadm PKI directory. Server and
+// peer certificates of given etcd member are included, if member name is not empty.
+func kubeadmCertificates(etcdMember string) []kubeadmCertificate {
+	kcs := []kubeadmCertificate{
+		{"ca", func(p *PKI, create bool) *Certificate {
+			return field(&p.kubernetes(create).CA, create)
+		}},
+		{"apiserver", func(p *PKI, create bool) *Certificate {
+			return field(&p.kubeAPIServer(create).ServerCertificate, create)
+		}},
+		{"apiserver-kubelet-client", func(p *PKI, create bool) *Certificate {
+			return field(&p.kubeAPIServer(create).KubeletCertificate, create)
+		}},
+		{"front-proxy-ca", func(p *PKI, create bool) *Certificate {
+			return field(&p.kubernetes(create).FrontProxyCA, create)
+		}},
+		{"front-proxy-client", func(p *PKI, create bool) *Certificate {
+			return field(&p.kubeAPIServer(create).FrontProxyClientCertificate, create)
+		}},
+		{"etcd/ca", func(p *PKI, create bool) *Certificate {
+			return field(&p.etcd(create).CA, create)
+		}},
+		{"apiserver-etcd-client", func(p *PKI, create bool) *Certificate {
+			return mapField(&p.etcd(create).ClientCertificates, KubeadmAPIServerEtcdClientCN, create)
+		}},
+		{"etcd/healthcheck-client", func(p *PKI, create bool) *Certificate {
+			return mapField(&p.etcd(create).ClientCertificates, KubeadmEtcdHealthcheckClientCN, create)
+		}},
+	}
+
+	if etcdMember == "" {
+		return kcs
+	}
+
+	return append(kcs,
+		kubeadmCertificate{"etcd/server", func(p *PKI, create bool) *Certificate {
+			return mapField(&p.etcd(create).ServerCertificates, etcdMember, create)
+		}},
+		kubeadmCertificate{"etcd/peer", func(p *PKI, create bool) *Certificate {
+			return mapField(&p.etcd(create).PeerCertificates, etcdMember, create)
+		}},
+	)
+}
+
+// kubernetes returns Kubernetes PKI. If create is true, missing Kubernetes PKI is created,
+// otherwise empty one is returned.
+func (p *PKI) kubernetes(create bool) *Kubernetes {
+	if p.Kubernetes != nil {
+		return p.Kubernetes
+	}
+
+	k := &Kubernetes{}
+
+	if create {
+		p.Kubernetes = k
+	}
+
+	return k
+}
+
+// kubeAPIServer returns kube-apiserver PKI. If create is true, missing kube-apiserver PKI
+// is created, otherwise empty one is returned.
+func (p *PKI) kubeAPIServer(create bool) *KubeAPIServer {
+	k := p.kubernetes(create)
+
+	if k.KubeAPIServer != nil {
+		return k.KubeAPIServer
+	}
+
+	s := &KubeAPIServer{}
+
+	if create {
+		k.KubeAPIServer = s
+	}
+
+	return s
+}
+
+// etcd returns etcd PKI. If create is true, missing etcd PKI is created, otherwise empty
+// one is returned.
+func (p *PKI) etcd(create bool) *Etcd {
+	if p.Etcd != nil {
+		return p.Etcd
+	}
+
+	e := &Etcd{}
+
+	if create {
+		p.Etcd = e
+	}
+
+	return e
+}
+
+// field returns certificate stored in given field. If create is true, missing certificate is created.
+func field(c **Certificate, create bool) *Certificate {
+	if *c == nil && create {
+		*c = &Certificate{}
+	}
+
+	return *c
+}
+
+// mapField returns certificate stored in given map under given key. If create is true,
+// missing map and certificate are created.
+func mapField(m *map[string]*Certificate, key string, create bool) *Certificate {
+	if !create {
+		return (*m)[key]
+	}
+
+	if *m == nil {
+		*m = map[string]*Certificate{}
+	}
+
+	if (*m)[key] == nil {
+		(*m)[key] = &Certificate{}
+	}
+
+	return (*m)[key]
+}
+
+// ExportKubeadm returns generated certificates and private keys in kubeadm PKI directory
+// layout, where key is a path relative to the PKI directory, e.g. 'etcd/ca.crt', and value is
+// PEM encoded content of the file. Server and peer certificates of given etcd member are
+// included, if member name is not empty.
+func (p *PKI) ExportKubeadm(etcdMember string) (map[string]string, error) {
+	files := map[string]string{}
+
+	for _, kc := range kubeadmCertificates(etcdMember) {
+		c := kc.certificate(p, false)
+		if c == nil || c.X509Certificate == "" {
+			continue
+		}
+
+		files[kc.name+".crt"] = string(c.X509Certificate)
+
+		if c.PrivateKey != "" {
+			files[kc.name+".key"] = string(c.PrivateKey)
+		}
+	}
+
+	sa := p.kubernetes(false).ServiceAccountCertificate
+	if sa == nil || sa.PrivateKey == "" {
+		return files, nil
+	}
+
+	k, err := sa.decodePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode service account private key: %w", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(k.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed marshaling service account public key: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	// kubeadm expects public key in PKIX format, regardless of the key type.
+	if err := pem.Encode(&buf, &pem.Block{Type: PublicKeyPEMHeader, Bytes: der}); err != nil {
+		return nil, fmt.Errorf("failed to encode service account public key: %w", err)
+	}
+
+	files[KubeadmServiceAccountPrivateKey] = string(sa.PrivateKey)
+	files[KubeadmServiceAccountPublicKey] = buf.String()
+
+	return files, nil
+}
+
+// ImportKubeadm imports certificates and private keys from kubeadm PKI directory layout, as
+// returned by ExportKubeadm. Files not used by kubeadm are ignored. Server and peer certificates
+// are imported for given etcd member, if member name is not empty.
+//
+// Imported CA certificates are marked as external, so they are never regenerated. Other
+// certificates are marked as imported, so they keep subject, IP addresses, DNS names and key
+// usage set by kubeadm and are only renewed using imported CAs, when they are about to expire. Only service account private key is imported without certificate,
+// as kubeadm does not store certificate for it.
+// If any of the files is not valid, error is returned and PKI is not modified.
+func (p *PKI) ImportKubeadm(files map[string]string, etcdMember string) error {
+	kcs := kubeadmCertificates(etcdMember)
+
+	if err := validateKubeadm(files, kcs); err != nil {
+		return err
+	}
+
+	for _, kc := range kcs {
+		cert, ok := files[kc.name+".crt"]
+		if !ok {
+			continue
+		}
+
+		// Certificate has been validated already, so error can be ignored here.
+		x509Cert, _ := (&Certificate{X509Certificate: types.Certificate(cert)}).DecodeX509Certificate()
+
+		c := kc.certificate(p, true)
+		c.External = x509Cert.IsCA
+		c.Imported = !x509Cert.IsCA
+		c.X509Certificate = types.Certificate(cert)
+		c.PrivateKey = types.PrivateKey(files[kc.name+".key"])
+		c.PublicKey = ""
+		c.CertificateSigningRequest = ""
+	}
+
+	key, ok := files[KubeadmServiceAccountPrivateKey]
+	if !ok {
+		return nil
+	}
+
+	k := p.kubernetes(true)
+
+	sa := field(&k.ServiceAccountCertificate, true)
+	sa.PrivateKey = types.PrivateKey(key)
+	sa.PublicKey = ""
+
+	// Certificate will be generated from imported private key.
+	sa.X509Certificate = ""
+
+	return nil
+}
+
+// validateKubeadm validates, that all certificates have matching private keys.
+func validateKubeadm(files map[string]string, kcs []kubeadmCertificate) error {
+	for _, kc := range kcs {
+		cert, certOK := files[kc.name+".crt"]
+		key, keyOK := files[kc.name+".key"]
+
+		if !certOK {
+			if keyOK {
+				return fmt.Errorf("certificate for private key %q not found", kc.name+".key")
+			}
+
+			continue
+		}
+
+		c := &Certificate{
+			X509Certificate: types.Certificate(cert),
+			PrivateKey:      types.PrivateKey(key),
+		}
+
+		if err := c.validateKubeadmKeypair(); err != nil {
+			return fmt.Errorf("validating %q: %w", kc.name, err)
+		}
+	}
+
+	key, ok := files[KubeadmServiceAccountPrivateKey]
+	if !ok {
+		return nil
+	}
+
+	c := &Certificate{
+		PrivateKey: types.PrivateKey(key),
+	}
+
+	if _, err := c.decodePrivateKey(); err != nil {
+		return fmt.Errorf("failed to decode service account private key: %w", err)
+	}
+
+	return nil
+}
+
+// validateKubeadmKeypair validates, that X.509 certificate can be decoded and that it matches
+// the private key, if it's set.
+func (c *Certificate) validateKubeadmKeypair() error {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	if c.PrivateKey == "" {
+		return nil
+	}
+
+	k, err := c.decodePrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	if !publicKeysEqual(k.Public(), cert.PublicKey) {
+		return fmt.Errorf("certificate does not match the private key")
+	}
+
+	return nil
+}
@@ -0,0 +1,462 @@
+package pki_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+const kubeadmEtcdMember = "controller01"
+
+// kubeadmPKI returns PKI configuration, which includes all certificates used by kubeadm.
+func kubeadmPKI() *pki.PKI {
+	p := fullPKI()
+	p.Etcd.ClientCNs = []string{pki.KubeadmAPIServerEtcdClientCN, pki.KubeadmEtcdHealthcheckClientCN}
+
+	return p
+}
+
+func kubeadmFiles(t *testing.T) map[string]string {
+	t.Helper()
+
+	p := kubeadmPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	files, err := p.ExportKubeadm(kubeadmEtcdMember)
+	if err != nil {
+		t.Fatalf("Exporting PKI should succeed, got: %v", err)
+	}
+
+	return files
+}
+
+// kubeadmKeypair is a certificate and private key generated the same way as kubeadm does.
+type kubeadmKeypair struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// kubeadmGenerate generates certificate from given template, signed by given CA and stores it
+// in given files under given name. If CA is nil, certificate is self-signed.
+func kubeadmGenerate(t *testing.T, files map[string]string, name string, template *x509.Certificate, ca *kubeadmKeypair) *kubeadmKeypair {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating private key should succeed, got: %v", err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now()
+	template.NotAfter = time.Now().Add(365 * 24 * time.Hour)
+	template.BasicConstraintsValid = true
+
+	parent, signer := template, crypto.Signer(key)
+
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		t.Fatalf("Creating certificate %q should succeed, got: %v", name, err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Marshaling private key should succeed, got: %v", err)
+	}
+
+	files[name+".crt"] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	files[name+".key"] = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Parsing certificate %q should succeed, got: %v", name, err)
+	}
+
+	return &kubeadmKeypair{cert: cert, key: key}
+}
+
+// kubeadmGeneratedFiles returns kubeadm PKI directory with certificates generated the same way
+// as 'kubeadm init phase certs all' does, so subjects, SANs and key usages differ from the ones
+// generated by the PKI.
+func kubeadmGeneratedFiles(t *testing.T) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+
+	caUsage := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign
+	usage := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	server := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	client := []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	both := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	nodeIP := net.ParseIP("192.168.10.10")
+
+	ca := kubeadmGenerate(t, files, "ca", &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "kubernetes"},
+		KeyUsage: caUsage,
+		IsCA:     true,
+	}, nil)
+
+	kubeadmGenerate(t, files, "apiserver", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-apiserver"},
+		KeyUsage:    usage,
+		ExtKeyUsage: server,
+		DNSNames: []string{
+			kubeadmEtcdMember,
+			"kubernetes",
+			"kubernetes.default",
+			"kubernetes.default.svc",
+			"kubernetes.default.svc.cluster.local",
+		},
+		IPAddresses: []net.IP{net.ParseIP("10.96.0.1"), nodeIP},
+	}, ca)
+
+	kubeadmGenerate(t, files, "apiserver-kubelet-client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-apiserver-kubelet-client", Organization: []string{"kubeadm:cluster-admins"}},
+		KeyUsage:    usage,
+		ExtKeyUsage: client,
+	}, ca)
+
+	frontProxyCA := kubeadmGenerate(t, files, "front-proxy-ca", &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "front-proxy-ca"},
+		KeyUsage: caUsage,
+		IsCA:     true,
+	}, nil)
+
+	kubeadmGenerate(t, files, "front-proxy-client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "front-proxy-client"},
+		KeyUsage:    usage,
+		ExtKeyUsage: client,
+	}, frontProxyCA)
+
+	etcdCA := kubeadmGenerate(t, files, "etcd/ca", &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "etcd-ca"},
+		KeyUsage: caUsage,
+		IsCA:     true,
+	}, nil)
+
+	kubeadmGenerate(t, files, "apiserver-etcd-client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-apiserver-etcd-client"},
+		KeyUsage:    usage,
+		ExtKeyUsage: client,
+	}, etcdCA)
+
+	kubeadmGenerate(t, files, "etcd/healthcheck-client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-etcd-healthcheck-client"},
+		KeyUsage:    usage,
+		ExtKeyUsage: client,
+	}, etcdCA)
+
+	for _, name := range []string{"etcd/server", "etcd/peer"} {
+		kubeadmGenerate(t, files, name, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: kubeadmEtcdMember},
+			KeyUsage:    usage,
+			ExtKeyUsage: both,
+			DNSNames:    []string{kubeadmEtcdMember, "localhost"},
+			IPAddresses: []net.IP{nodeIP, net.ParseIP("127.0.0.1"), net.IPv6loopback},
+		}, etcdCA)
+	}
+
+	return files
+}
+
+// ExportKubeadm() tests.
+func TestExportKubeadm(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+
+	expected := []string{
+		"apiserver-etcd-client",
+		"apiserver-kubelet-client",
+		"apiserver",
+		"ca",
+		"etcd/ca",
+		"etcd/healthcheck-client",
+		"etcd/peer",
+		"etcd/server",
+		"front-proxy-ca",
+		"front-proxy-client",
+	}
+
+	for _, name := range expected {
+		for _, ext := range []string{".crt", ".key"} {
+			if files[name+ext] == "" {
+				t.Errorf("Expected file %q to be exported", name+ext)
+			}
+		}
+	}
+
+	for _, name := range []string{pki.KubeadmServiceAccountPrivateKey, pki.KubeadmServiceAccountPublicKey} {
+		if files[name] == "" {
+			t.Errorf("Expected file %q to be exported", name)
+		}
+	}
+
+	if len(files) != len(expected)*2+2 {
+		t.Fatalf("Expected %d files, got %d", len(expected)*2+2, len(files))
+	}
+}
+
+func TestExportKubeadmNoEtcdMember(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	files, err := p.ExportKubeadm("")
+	if err != nil {
+		t.Fatalf("Exporting PKI should succeed, got: %v", err)
+	}
+
+	if _, ok := files["etcd/peer.crt"]; ok {
+		t.Fatalf("Peer certificate should not be exported when etcd member is not specified")
+	}
+}
+
+func TestExportKubeadmEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{}
+
+	files, err := p.ExportKubeadm(kubeadmEtcdMember)
+	if err != nil {
+		t.Fatalf("Exporting empty PKI should succeed, got: %v", err)
+	}
+
+	if len(files) != 0 {
+		t.Fatalf("No files should be exported from empty PKI, got: %v", files)
+	}
+
+	if p.Kubernetes != nil || p.Etcd != nil {
+		t.Fatalf("Exporting should not modify the PKI")
+	}
+}
+
+// ImportKubeadm() tests.
+func TestImportKubeadm(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+
+	p := kubeadmPKI()
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err != nil {
+		t.Fatalf("Importing PKI should succeed, got: %v", err)
+	}
+
+	if !p.Kubernetes.CA.External {
+		t.Fatalf("Imported CA certificate should be external")
+	}
+
+	if p.Kubernetes.KubeAPIServer.ServerCertificate.External {
+		t.Fatalf("Imported non-CA certificate should not be external")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating imported PKI should succeed, got: %v", err)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("Imported certificates should not be regenerated, got: %v", plan)
+	}
+
+	exported, err := p.ExportKubeadm(kubeadmEtcdMember)
+	if err != nil {
+		t.Fatalf("Exporting imported PKI should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff(files, exported); diff != "" {
+		t.Fatalf("Exported files should be the same as imported: %s", diff)
+	}
+}
+
+func TestImportKubeadmRenewExpiring(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+
+	p := kubeadmPKI()
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err != nil {
+		t.Fatalf("Importing PKI should succeed, got: %v", err)
+	}
+
+	// Make imported certificate due for renewal.
+	p.Kubernetes.KubeAPIServer.FrontProxyClientCertificate.ValidityDuration = "10000h"
+	p.Kubernetes.KubeAPIServer.FrontProxyClientCertificate.RenewThreshold = "9000h"
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating imported PKI should succeed, got: %v", err)
+	}
+
+	renewed := string(p.Kubernetes.KubeAPIServer.FrontProxyClientCertificate.X509Certificate)
+
+	if renewed == files["front-proxy-client.crt"] {
+		t.Fatalf("Expiring imported certificate should be renewed")
+	}
+
+	if string(p.Kubernetes.FrontProxyCA.X509Certificate) != files["front-proxy-ca.crt"] {
+		t.Fatalf("Imported CA certificate should not be renewed")
+	}
+}
+
+func TestImportKubeadmGeneratedByKubeadm(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmGeneratedFiles(t)
+
+	p := kubeadmPKI()
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err != nil {
+		t.Fatalf("Importing PKI should succeed, got: %v", err)
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating imported PKI should succeed, got: %v", err)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("Certificates generated by kubeadm should not be regenerated, got: %v", plan)
+	}
+
+	exported, err := p.ExportKubeadm(kubeadmEtcdMember)
+	if err != nil {
+		t.Fatalf("Exporting imported PKI should succeed, got: %v", err)
+	}
+
+	for name, content := range files {
+		if diff := cmp.Diff(content, exported[name]); diff != "" {
+			t.Fatalf("Exported file %q should be the same as imported: %s", name, diff)
+		}
+	}
+}
+
+func TestImportKubeadmGeneratedByKubeadmRenewKeepsFields(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmGeneratedFiles(t)
+
+	p := kubeadmPKI()
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err != nil {
+		t.Fatalf("Importing PKI should succeed, got: %v", err)
+	}
+
+	s := p.Kubernetes.KubeAPIServer.ServerCertificate
+
+	imported, err := s.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding imported certificate should succeed, got: %v", err)
+	}
+
+	// Make imported certificate due for renewal.
+	s.ValidityDuration = "10000h"
+	s.RenewThreshold = "9000h"
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating imported PKI should succeed, got: %v", err)
+	}
+
+	renewed, err := s.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("Decoding renewed certificate should succeed, got: %v", err)
+	}
+
+	if renewed.SerialNumber.Cmp(imported.SerialNumber) == 0 {
+		t.Fatalf("Expiring imported certificate should be renewed")
+	}
+
+	if diff := cmp.Diff(imported.Subject.String(), renewed.Subject.String()); diff != "" {
+		t.Fatalf("Renewed certificate should keep the subject: %s", diff)
+	}
+
+	if diff := cmp.Diff(imported.DNSNames, renewed.DNSNames); diff != "" {
+		t.Fatalf("Renewed certificate should keep DNS names: %s", diff)
+	}
+
+	if diff := cmp.Diff(imported.IPAddresses, renewed.IPAddresses); diff != "" {
+		t.Fatalf("Renewed certificate should keep IP addresses: %s", diff)
+	}
+
+	if diff := cmp.Diff(imported.ExtKeyUsage, renewed.ExtKeyUsage); diff != "" {
+		t.Fatalf("Renewed certificate should keep extended key usage: %s", diff)
+	}
+
+	if imported.KeyUsage != renewed.KeyUsage {
+		t.Fatalf("Renewed certificate should keep key usage, expected %v, got %v", imported.KeyUsage, renewed.KeyUsage)
+	}
+}
+
+func TestImportKubeadmMismatchedKey(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+	files["apiserver.key"] = files["ca.key"]
+
+	p := &pki.PKI{}
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err == nil {
+		t.Fatalf("Importing certificate with not matching private key should fail")
+	}
+
+	if p.Kubernetes != nil || p.Etcd != nil {
+		t.Fatalf("Failed import should not modify the PKI")
+	}
+}
+
+func TestImportKubeadmKeyWithoutCertificate(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+	delete(files, "front-proxy-client.crt")
+
+	p := &pki.PKI{}
+
+	if err := p.ImportKubeadm(files, kubeadmEtcdMember); err == nil {
+		t.Fatalf("Importing private key without certificate should fail")
+	}
+}
+
+func TestImportKubeadmCAWithoutKey(t *testing.T) {
+	t.Parallel()
+
+	files := kubeadmFiles(t)
+	delete(files, "etcd/ca.key")
+
+	p := &pki.PKI{}
+
+	if err := p.ImportKubeadm(files, ""); err != nil {
+		t.Fatalf("Importing CA certificate without private key should succeed, got: %v", err)
+	}
+
+	if p.Etcd.CA.PrivateKey != "" {
+		t.Fatalf("Imported CA should have no private key")
+	}
+}
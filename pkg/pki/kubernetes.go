@@ -2,6 +2,7 @@ package pki
 
 import (
 	"fmt"
+	"net"
 )
 
 const (
@@ -12,6 +13,13 @@ const (
 	// KubernetesFrontProxyCACN is a default CN for Kubernetes front proxy CA certificate,
 	// as recommended by https://kubernetes.io/docs/setup/best-practices/certificates/.
 	KubernetesFrontProxyCACN = "kubernetes-front-proxy-ca"
+
+	// KubeletCNPrefix is a prefix of CN of kubelet certificates, followed by the node name,
+	// as required by Node authorizer.
+	KubeletCNPrefix = "system:node:"
+
+	// KubeletOrganization is an organization of kubelet certificates, as required by Node authorizer.
+	KubeletOrganization = "system:nodes"
 )
 
 // Kubernetes stores Kubernetes PKI and settings.
@@ -39,7 +47,32 @@ type Kubernetes struct {
 
 	// ServiceAccountCertificate stores public and private key used for signing and verifying
 	// service account tokens by kube-controller-manager and kube-apiserver.
+	//
+	// Only RSA and ECDSA keys are supported. If Ed25519 is set as a global key algorithm,
+	// RSA is used for this certificate.
 	ServiceAccountCertificate *Certificate `json:"serviceAccountCertificate,omitempty"`
+
+	// ServiceAccountKeys stores pending and retired service account keys, which are trusted
+	// for verifying service account tokens next to ServiceAccountCertificate, but are not
+	// used for signing them. See PKI.RotateServiceAccountKey for details.
+	ServiceAccountKeys []*ServiceAccountKey `json:"serviceAccountKeys,omitempty"`
+
+	// Kubelets is a map of kubelets to generate client and serving certificates for, where key
+	// is the node name and value is a list of IP addresses and hostnames of the node, which are
+	// added to the serving certificate. Certificates allow kubelets to join the cluster without
+	// TLS bootstrapping.
+	//
+	// Keys must match names of kubelets in kubelet pools, as certificates are picked by kubelet
+	// name. Kubelet pool rejects configuration, where only some of its kubelets have certificates.
+	Kubelets map[string][]string `json:"kubelets,omitempty"`
+
+	// KubeletClientCertificates defines and stores kubelet client certificates, where key is
+	// the node name.
+	KubeletClientCertificates map[string]*Certificate `json:"kubeletClientCertificates,omitempty"`
+
+	// KubeletServerCertificates defines and stores kubelet serving certificates, where key is
+	// the node name.
+	KubeletServerCertificates map[string]*Certificate `json:"kubeletServerCertificates,omitempty"`
 }
 
 // KubeAPIServer stores kube-apiserver certificates.
@@ -197,20 +230,24 @@ func (k *Kubernetes) kubeControllerManagerCR(defaultCertificate Certificate) *ce
 
 // Generate generates Kubernetes PKI.
 func (k *Kubernetes) Generate(rootCA *Certificate, defaultCertificate Certificate) error {
-	crs := []*certificateRequest{
-		k.kubernetesCACR(rootCA, defaultCertificate),
-		k.kubernetesFrontProxyCACR(rootCA, defaultCertificate),
+	if err := k.validateServiceAccountKeyAlgorithm(); err != nil {
+		return err
 	}
 
-	if err := buildAndGenerate(crs...); err != nil {
-		return fmt.Errorf("failed to generate kubernetes CA certificates: %w", err)
-	}
+	return buildAndGenerate(k.certificateRequests(rootCA, defaultCertificate)...)
+}
 
+// certificateRequests returns requests for Kubernetes CA certificates and all certificates
+// issued by them.
+func (k *Kubernetes) certificateRequests(rootCA *Certificate, defaultCertificate Certificate) []*certificateRequest {
 	if k.KubeAPIServer == nil {
 		k.KubeAPIServer = &KubeAPIServer{}
 	}
 
-	crs = []*certificateRequest{
+	// CA certificates must be generated first.
+	crs := []*certificateRequest{
+		k.kubernetesCACR(rootCA, defaultCertificate),
+		k.kubernetesFrontProxyCACR(rootCA, defaultCertificate),
 		k.kubeAPIServerServerCR(defaultCertificate),
 		k.kubeAPIServerKubeletCR(defaultCertificate),
 		k.kubeAPIServerFrontProxyClientCR(defaultCertificate),
@@ -220,23 +257,83 @@ func (k *Kubernetes) Generate(rootCA *Certificate, defaultCertificate Certificat
 		k.serviceAccountCR(defaultCertificate),
 	}
 
-	return buildAndGenerate(crs...)
+	return append(crs, k.kubeletCRs(defaultCertificate)...)
 }
 
+// kubeletCRs returns requests for client and serving certificates of all configured kubelets.
+func (k *Kubernetes) kubeletCRs(defaultCertificate Certificate) []*certificateRequest {
+	if k.KubeletClientCertificates == nil && len(k.Kubelets) != 0 {
+		k.KubeletClientCertificates = map[string]*Certificate{}
+	}
+
+	if k.KubeletServerCertificates == nil && len(k.Kubelets) != 0 {
+		k.KubeletServerCertificates = map[string]*Certificate{}
+	}
+
+	defaultCertificates := []*Certificate{&defaultCertificate, &k.Certificate}
+
+	names := map[string]string{}
+
+	for name := range k.Kubelets {
+		names[name] = ""
+	}
+
+	crs := crsFromTemplate(k.CA, defaultCertificates, k.KubeletClientCertificates, names, func(name, _ string) *Certificate {
+		return &Certificate{
+			CommonName:   KubeletCNPrefix + name,
+			Organization: KubeletOrganization,
+			KeyUsage:     clientUsage(),
+		}
+	})
+
+	return append(crs, crsFromTemplate(k.CA, defaultCertificates, k.KubeletServerCertificates, names, func(name, _ string) *Certificate {
+		return defaultKubeletServerCertificate(name, k.Kubelets[name])
+	})...)
+}
+
+// serviceAccountCR returns request for service account certificate. Kubernetes only supports
+// RSA and ECDSA keys for signing service account tokens, so Ed25519 key algorithm set in global
+// or Kubernetes settings is not inherited and RSA is used instead.
 func (k *Kubernetes) serviceAccountCR(defaultCertificate Certificate) *certificateRequest {
 	if k.ServiceAccountCertificate == nil {
 		k.ServiceAccountCertificate = &Certificate{}
 	}
 
+	certs := []*Certificate{
+		&defaultCertificate,
+		&k.Certificate,
+	}
+
+	for _, c := range []*Certificate{&k.Certificate, &defaultCertificate} {
+		if c.KeyAlgorithm == "" {
+			continue
+		}
+
+		if c.KeyAlgorithm == KeyAlgorithmEd25519 {
+			certs = append(certs, &Certificate{
+				KeyAlgorithm: KeyAlgorithmRSA,
+			})
+		}
+
+		break
+	}
+
 	return &certificateRequest{
-		Target: k.ServiceAccountCertificate,
-		CA:     k.CA,
-		Certificates: []*Certificate{
-			&defaultCertificate,
-			&k.Certificate,
-			k.ServiceAccountCertificate,
-		},
+		Target:       k.ServiceAccountCertificate,
+		CA:           k.CA,
+		Certificates: append(certs, k.ServiceAccountCertificate),
+	}
+}
+
+// validateServiceAccountKeyAlgorithm checks, that key algorithm configured for service account
+// certificate is supported by Kubernetes for signing service account tokens.
+func (k *Kubernetes) validateServiceAccountKeyAlgorithm() error {
+	if k.ServiceAccountCertificate != nil && k.ServiceAccountCertificate.KeyAlgorithm == KeyAlgorithmEd25519 {
+		return fmt.Errorf("key algorithm %q is not supported for service account certificate, "+
+			"use RSA or ECDSA instead", KeyAlgorithmEd25519)
 	}
+
+	return nil
 }
 
 func (k *Kubernetes) kubeSchedulerCR(defaultCertificate Certificate) *certificateRequest {
@@ -283,6 +380,30 @@ func defaultKubeAPIServerServerCertificate(k *KubeAPIServer) *Certificate {
 	return c
 }
 
+// defaultKubeletServerCertificate returns default configuration of kubelet serving certificate
+// for node with given name and IP address.
+// defaultKubeletServerCertificate returns serving certificate for kubelet with given name, valid
+// for given addresses, which may be both IP addresses and hostnames.
+func defaultKubeletServerCertificate(name string, addresses []string) *Certificate {
+	c := &Certificate{
+		CommonName:   KubeletCNPrefix + name,
+		Organization: KubeletOrganization,
+		DNSNames:     []string{name},
+		KeyUsage:     serverUsage(),
+	}
+
+	for _, a := range addresses {
+		switch {
+		case net.ParseIP(a) != nil:
+			c.IPAddresses = append(c.IPAddresses, a)
+		case a != name:
+			c.DNSNames = append(c.DNSNames, a)
+		}
+	}
+
+	return c
+}
+
 func defaultKubeAPIServerKubeletCertificate() *Certificate {
 	return &Certificate{
 		CommonName:   "kube-apiserver-kubelet-client",
@@ -0,0 +1,180 @@
+package pki_test
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+func TestGenerateKubeletCertificates(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Kubelets: map[string][]string{
+				"foo": {"10.0.0.1"},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	client, err := p.Kubernetes.KubeletClientCertificates["foo"].DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding kubelet client certificate should succeed, got: %v", err)
+	}
+
+	if client.Subject.CommonName != "system:node:foo" {
+		t.Fatalf("unexpected kubelet client certificate CN %q", client.Subject.CommonName)
+	}
+
+	if diff := cmp.Diff([]string{"system:nodes"}, client.Subject.Organization); diff != "" {
+		t.Fatalf("unexpected kubelet client certificate organization: %s", diff)
+	}
+
+	server, err := p.Kubernetes.KubeletServerCertificates["foo"].DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding kubelet serving certificate should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"foo"}, server.DNSNames); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate DNS names: %s", diff)
+	}
+
+	if diff := cmp.Diff([]net.IP{net.ParseIP("10.0.0.1").To4()}, server.IPAddresses); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate IP addresses: %s", diff)
+	}
+
+	if diff := cmp.Diff([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, server.ExtKeyUsage); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate extended key usage: %s", diff)
+	}
+}
+
+func TestGenerateKubeletServerCertificateAddresses(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Kubelets: map[string][]string{
+				"foo": {"10.0.0.1", "fd00::1", "foo", "foo.example.com"},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	server, err := p.Kubernetes.KubeletServerCertificates["foo"].DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding kubelet serving certificate should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"foo", "foo.example.com"}, server.DNSNames); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate DNS names: %s", diff)
+	}
+
+	expectedIPs := []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("fd00::1")}
+
+	if diff := cmp.Diff(expectedIPs, server.IPAddresses); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate IP addresses: %s", diff)
+	}
+}
+
+func TestGenerateKubeletServerCertificateOverride(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			Kubelets: map[string][]string{
+				"foo": {"10.0.0.1"},
+			},
+			KubeletServerCertificates: map[string]*pki.Certificate{
+				"foo": {
+					DNSNames: []string{"foo", "foo.example.com"},
+				},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	server, err := p.Kubernetes.KubeletServerCertificates["foo"].DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding kubelet serving certificate should succeed, got: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"foo", "foo.example.com"}, server.DNSNames); diff != "" {
+		t.Fatalf("unexpected kubelet serving certificate DNS names: %s", diff)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("generated kubelet certificates should be up to date, got: %v", plan)
+	}
+}
+
+func TestGenerateServiceAccountCertificateIgnoreGlobalEd25519(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Certificate: pki.Certificate{
+			KeyAlgorithm: pki.KeyAlgorithmEd25519,
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating PKI with global Ed25519 key algorithm should work, got: %v", err)
+	}
+
+	cert, err := p.Kubernetes.ServiceAccountCertificate.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding service account certificate should succeed, got: %v", err)
+	}
+
+	if cert.PublicKeyAlgorithm != x509.RSA {
+		t.Fatalf("service account certificate should use RSA key, got: %v", cert.PublicKeyAlgorithm)
+	}
+
+	admin, err := p.Kubernetes.AdminCertificate.DecodeX509Certificate()
+	if err != nil {
+		t.Fatalf("decoding admin certificate should succeed, got: %v", err)
+	}
+
+	if admin.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Fatalf("other certificates should use global key algorithm, got: %v", admin.PublicKeyAlgorithm)
+	}
+}
+
+func TestGenerateServiceAccountCertificateEd25519(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			ServiceAccountCertificate: &pki.Certificate{
+				KeyAlgorithm: pki.KeyAlgorithmEd25519,
+			},
+		},
+	}
+
+	if err := p.Generate(); err == nil {
+		t.Fatalf("generating service account certificate with Ed25519 key should fail")
+	}
+
+	if _, err := p.Plan(); err == nil {
+		t.Fatalf("planning service account certificate with Ed25519 key should fail")
+	}
+}
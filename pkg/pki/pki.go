@@ -3,6 +3,10 @@ package pki
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1" // #nosec G505
@@ -27,6 +31,21 @@ const (
 	// process is done in parallel, it should be increased.
 	RSABits = 2048
 
+	// KeyAlgorithmRSA generates RSA private keys with length defined by RSABits field.
+	KeyAlgorithmRSA = "rsa"
+
+	// KeyAlgorithmECDSAP256 generates ECDSA private keys using P-256 curve.
+	KeyAlgorithmECDSAP256 = "ecdsa-p256"
+
+	// KeyAlgorithmECDSAP384 generates ECDSA private keys using P-384 curve.
+	KeyAlgorithmECDSAP384 = "ecdsa-p384"
+
+	// KeyAlgorithmEd25519 generates Ed25519 private keys.
+	KeyAlgorithmEd25519 = "ed25519"
+
+	// KeyAlgorithm is a default algorithm used for generating private keys.
+	KeyAlgorithm = KeyAlgorithmRSA
+
 	// Organization is a default organization name in generated certificates.
 	Organization = "organization"
 
@@ -34,20 +53,53 @@ const (
 	ValidityDuration = "8760h"
 
 	// RenewThreshold defines minimum remaining validity time for the certificate, before
-	// is will be renewed.
+	// is will be renewed. It is used, when renew threshold is not configured for the certificate.
+	// For certificates with short validity duration, fraction of validity duration defined by
+	// RenewThresholdValidityDivisor is used instead, if it is shorter.
 	RenewThreshold = "720h"
 
+	// RenewThresholdValidityDivisor defines, which fraction of validity duration is used as
+	// a default renew threshold for certificates with short validity duration.
+	RenewThresholdValidityDivisor = 3
+
 	// X509CertificatePEMHeader is a PEM format header used while encoding X.509 certificates.
 	X509CertificatePEMHeader = "CERTIFICATE"
 
+	// CertificateRequestPEMHeader is a PEM format header used while encoding certificate signing requests.
+	CertificateRequestPEMHeader = "CERTIFICATE REQUEST"
+
 	// RSAPrivateKeyPEMHeader is a PEM format header user while encoding RSA private keys.
 	RSAPrivateKeyPEMHeader = "RSA PRIVATE KEY"
 
 	// RSAPublicKeyPEMHeader is a PEM format header user while encoding RSA public keys.
 	RSAPublicKeyPEMHeader = "RSA PUBLIC KEY"
 
+	// PrivateKeyPEMHeader is a PEM format header used while encoding private keys in PKCS8 format.
+	PrivateKeyPEMHeader = "PRIVATE KEY"
+
+	// PublicKeyPEMHeader is a PEM format header used while encoding non-RSA public keys.
+	PublicKeyPEMHeader = "PUBLIC KEY"
+
 	// RootCACN is a default CN for root CA certificate.
 	RootCACN = "root-ca"
+
+	// RootCAName is a name of root CA certificate in PKI.
+	RootCAName = "rootCA"
+
+	// EtcdCAName is a name of etcd CA certificate in PKI.
+	EtcdCAName = "etcd.ca"
+
+	// KubernetesCAName is a name of Kubernetes CA certificate in PKI.
+	KubernetesCAName = "kubernetes.ca"
+
+	// KubernetesFrontProxyCAName is a name of Kubernetes front proxy CA certificate in PKI.
+	KubernetesFrontProxyCAName = "kubernetes.frontProxyCA"
+
+	// DockerCAName is a name of Docker CA certificate in PKI.
+	DockerCAName = "docker.ca"
+
+	// ServiceAccountCertificateName is a name of service account certificate in PKI.
+	ServiceAccountCertificateName = "kubernetes.serviceAccountCertificate"
 )
 
 func keyUsage(k string) x509.KeyUsage {
@@ -91,6 +143,17 @@ type Certificate struct {
 	// Example value: '2048'.
 	RSABits int `json:"rsaBits,omitempty"`
 
+	// KeyAlgorithm defines algorithm used for generating private key. Valid values are:
+	// - "rsa"
+	// - "ecdsa-p256"
+	// - "ecdsa-p384"
+	// - "ed25519"
+	//
+	// Changing the algorithm does not affect already generated private keys.
+	//
+	// Example value: 'ecdsa-p256'.
+	KeyAlgorithm string `json:"keyAlgorithm,omitempty"`
+
 	// ValidityDuration defines how long generated certificates should be valid.
 	//
 	// Example value: '24h'.
@@ -106,6 +169,29 @@ type Certificate struct {
 	// CA controls if certificate should be self-signed while generated.
 	CA bool `json:"ca,omitempty"`
 
+	// External marks the certificate as provided by the user, e.g. intermediate CA signed
+	// by an offline root CA or certificate imported from kubeadm. External certificates are
+	// never generated or renewed. If private key of CA certificate is not set, certificate can
+	// only be used for trust and can't issue other certificates.
+	//
+	// Private key must be set for external certificates, which are not CA certificates.
+	External bool `json:"external,omitempty"`
+
+	// ExternalSigning controls, if certificate should be signed outside of the PKI. If enabled,
+	// only private key and certificate signing request are generated and signed certificate
+	// must be imported using PKI.ImportCertificate. This allows to keep CA private keys away
+	// from the PKI.
+	//
+	// CA certificates must be external, when this option is enabled.
+	ExternalSigning bool `json:"externalSigning,omitempty"`
+
+	// Imported marks the certificate as imported from existing PKI, e.g. from kubeadm. Subject,
+	// IP addresses, DNS names and key usage of imported certificates are not compared with the
+	// configuration, so imported certificates are only renewed, when they are about to expire or
+	// when they are not issued by the expected CA. Renewed certificate keeps those fields from the
+	// imported certificate.
+	Imported bool `json:"imported,omitempty"`
+
 	// KeyUsage is a list of key usages. Valid values are:
 	// - "digital_signature"
 	// - "content_commitment"
@@ -139,13 +225,28 @@ type Certificate struct {
 	// X509Certificate stores generated certificate in X.509 certificate format, PEM encoded.
 	X509Certificate types.Certificate `json:"x509Certificate,omitempty"`
 
-	// PublicKey stores generate RSA public key, PEM encoded.
+	// PublicKey stores generated public key, PEM encoded.
 	PublicKey string `json:"publicKey,omitempty"`
 
-	// PrivateKey stores generates RSA private key in PKCS1 format, PEM encoded.
+	// CertificateSigningRequest stores PEM encoded certificate signing request for the certificate,
+	// which awaits external signing.
+	CertificateSigningRequest string `json:"certificateSigningRequest,omitempty"`
+
+	// PrivateKey stores generated private key, PEM encoded. RSA keys are stored in PKCS1
+	// format, other keys in PKCS8 format.
 	PrivateKey types.PrivateKey `json:"privateKey,omitempty"`
 }
 
+// Renewal describes certificate, which will be generated or renewed, together with
+// reasons why.
+type Renewal struct {
+	// Name is a path of the certificate in PKI, e.g. 'kubernetes.ca'.
+	Name string
+
+	// Reasons is a list of reasons why the certificate will be renewed.
+	Reasons []string
+}
+
 // PKI contains configuration and all generated certificates and private keys required for running Kubernetes.
 type PKI struct {
 	// Certificate contains default settings for all certificates in PKI.
@@ -159,6 +260,12 @@ type PKI struct {
 
 	// Kubernetes contains configuration and generated all Kubernetes certificates and private keys.
 	Kubernetes *Kubernetes `json:"kubernetes,omitempty"`
+
+	// Docker contains configuration and generated all Docker daemon TLS certificates and private keys.
+	Docker *Docker `json:"docker,omitempty"`
+
+	// CARotation stores state of CA certificate rotation in progress. See RotateCA for details.
+	CARotation *CARotation `json:"caRotation,omitempty"`
 }
 
 func serverUsage() []string {
@@ -226,17 +333,18 @@ func buildAndGenerate(crs ...*certificateRequest) error {
 		cr.Target.X509Certificate = r.X509Certificate
 		cr.Target.PrivateKey = r.PrivateKey
 		cr.Target.PublicKey = r.PublicKey
+		cr.Target.CertificateSigningRequest = r.CertificateSigningRequest
 	}
 
 	return nil
 }
 
-func (p *PKI) generateRootCA() error {
+func (p *PKI) rootCACR() *certificateRequest {
 	if p.RootCA == nil {
 		p.RootCA = &Certificate{}
 	}
 
-	cr := &certificateRequest{
+	return &certificateRequest{
 		Target: p.RootCA,
 		Certificates: []*Certificate{
 			&p.Certificate,
@@ -244,8 +352,10 @@ func (p *PKI) generateRootCA() error {
 			p.RootCA,
 		},
 	}
+}
 
-	if err := buildAndGenerate(cr); err != nil {
+func (p *PKI) generateRootCA() error {
+	if err := buildAndGenerate(p.rootCACR()); err != nil {
 		return fmt.Errorf("failed to generate root CA certificate: %w", err)
 	}
 
@@ -274,17 +384,252 @@ func (p *PKI) Generate() error {
 		}
 	}
 
+	// If Docker field is set, generate certificates for talking to Docker daemons over TCP with TLS.
+	if p.Docker != nil {
+		if err := p.Docker.Generate(p.RootCA, p.Certificate); err != nil {
+			return fmt.Errorf("failed to generate Docker PKI: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// certificates returns all certificates stored in the PKI, where key is a path of the
+// certificate in PKI, e.g. 'etcd.peerCertificates.foo'.
+func (p *PKI) certificates() map[string]*Certificate {
+	certs := map[string]*Certificate{
+		RootCAName: p.RootCA,
+	}
+
+	if e := p.Etcd; e != nil {
+		certs[EtcdCAName] = e.CA
+
+		addCertificates(certs, "etcd.peerCertificates", e.PeerCertificates)
+		addCertificates(certs, "etcd.serverCertificates", e.ServerCertificates)
+		addCertificates(certs, "etcd.clientCertificates", e.ClientCertificates)
+	}
+
+	if k := p.Kubernetes; k != nil {
+		certs[KubernetesCAName] = k.CA
+		certs[KubernetesFrontProxyCAName] = k.FrontProxyCA
+		certs["kubernetes.adminCertificate"] = k.AdminCertificate
+		certs["kubernetes.kubeControllerManagerCertificate"] = k.KubeControllerManagerCertificate
+		certs["kubernetes.kubeSchedulerCertificate"] = k.KubeSchedulerCertificate
+		certs[ServiceAccountCertificateName] = k.ServiceAccountCertificate
+
+		addCertificates(certs, "kubernetes.kubeletClientCertificates", k.KubeletClientCertificates)
+		addCertificates(certs, "kubernetes.kubeletServerCertificates", k.KubeletServerCertificates)
+
+		if a := k.KubeAPIServer; a != nil {
+			certs["kubernetes.kubeAPIServer.serverCertificate"] = a.ServerCertificate
+			certs["kubernetes.kubeAPIServer.kubeletCertificate"] = a.KubeletCertificate
+			certs["kubernetes.kubeAPIServer.frontProxyClientCertificate"] = a.FrontProxyClientCertificate
+		}
+	}
+
+	if d := p.Docker; d != nil {
+		certs[DockerCAName] = d.CA
+		certs["docker.clientCertificate"] = d.ClientCertificate
+
+		addCertificates(certs, "docker.serverCertificates", d.ServerCertificates)
+	}
+
+	// Not all certificates are initialized before generation.
+	for n, c := range certs {
+		if c == nil {
+			delete(certs, n)
+		}
+	}
+
+	return certs
+}
+
+// CertificateChain returns PEM encoded X.509 certificate of given certificate followed by
+// certificates of CAs from the PKI, which issued it, up to the self-signed root CA, which is
+// not included. This allows to verify the certificate by clients trusting only the root CA,
+// e.g. when cluster CAs are intermediates of external root CA.
+//
+// Chain is only included, when one of the CAs in the path is external or when certificate
+// has not been issued by the CA with given name, e.g. 'kubernetes.ca', which consumers of the
+// certificate trust. Otherwise only the certificate is returned, as consumers can verify it
+// already, so configuration of existing clusters does not change.
+//
+// If certificate is not generated, empty string is returned.
+func (p *PKI) CertificateChain(c *Certificate, trustedCA string) types.Certificate {
+	if c == nil || c.X509Certificate == "" {
+		return ""
+	}
+
+	certs := p.certificates()
+	cas := []*Certificate{}
+
+	for _, n := range caNames() {
+		if ca, ok := certs[n]; ok && ca.X509Certificate != "" {
+			cas = append(cas, ca)
+		}
+	}
+
+	path := []*Certificate{}
+	external := false
+
+	// Each CA can appear in the chain only once, which also protects from loops.
+	for issued := c; len(path) < len(cas); {
+		issuer := issuerOf(issued, cas)
+		if issuer == nil {
+			break
+		}
+
+		cert, err := issuer.DecodeX509Certificate()
+		if err != nil || issuedBy(cert, cert) {
+			break
+		}
+
+		path = append(path, issuer)
+		external = external || issuer.External
+		issued = issuer
+	}
+
+	if len(path) == 0 || (!external && path[0] == certs[trustedCA]) {
+		return c.X509Certificate
+	}
+
+	chain := c.X509Certificate
+
+	for _, ca := range path {
+		chain += ca.X509Certificate
+	}
+
+	return chain
+}
+
+// issuerOf returns CA from given list, which issued given certificate, or nil, if the certificate
+// has not been issued by any of them.
+func issuerOf(c *Certificate, cas []*Certificate) *Certificate {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return nil
+	}
+
+	for _, ca := range cas {
+		caCert, err := ca.DecodeX509Certificate()
+		if err != nil || ca == c || ca.X509Certificate == c.X509Certificate {
+			continue
+		}
+
+		if issuedBy(cert, caCert) {
+			return ca
+		}
+	}
+
+	return nil
+}
+
+// addCertificates adds given certificates to given map, prefixing their names with given prefix.
+func addCertificates(certs map[string]*Certificate, prefix string, m map[string]*Certificate) {
+	for n, c := range m {
+		certs[fmt.Sprintf("%s.%s", prefix, n)] = c
+	}
+}
+
+// certificateRequests returns requests for all certificates in the PKI, ordered in a way,
+// that CA certificates are always before certificates issued by them.
+func (p *PKI) certificateRequests() []*certificateRequest {
+	crs := []*certificateRequest{p.rootCACR()}
+
+	if p.Etcd != nil {
+		crs = append(crs, p.Etcd.certificateRequests(p.RootCA, p.Certificate)...)
+	}
+
+	if p.Kubernetes != nil {
+		crs = append(crs, p.Kubernetes.certificateRequests(p.RootCA, p.Certificate)...)
+	}
+
+	if p.Docker != nil {
+		crs = append(crs, p.Docker.certificateRequests(p.RootCA, p.Certificate)...)
+	}
+
+	return crs
+}
+
+// certificateRequest returns certificate request for certificate with given name, e.g.
+// 'etcd.peerCertificates.foo'.
+func (p *PKI) certificateRequest(name string) (*certificateRequest, error) {
+	crs := p.certificateRequests()
+
+	c, ok := p.certificates()[name]
+	if !ok {
+		return nil, fmt.Errorf("certificate %q not found", name)
+	}
+
+	for _, cr := range crs {
+		if cr.Target == c {
+			return cr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no certificate request found for certificate %q", name)
+}
+
+// Plan returns list of certificates, which will be generated or renewed by Generate together
+// with reasons why, sorted by certificate name. PKI is not modified, except initializing empty
+// certificates.
+func (p *PKI) Plan() ([]Renewal, error) {
+	if p.Kubernetes != nil {
+		if err := p.Kubernetes.validateServiceAccountKeyAlgorithm(); err != nil {
+			return nil, err
+		}
+	}
+
+	crs := p.certificateRequests()
+
+	names := map[*Certificate]string{}
+
+	for n, c := range p.certificates() {
+		names[c] = n
+	}
+
+	renewals := []Renewal{}
+
+	for _, cr := range crs {
+		r, err := buildCertificate(cr.Certificates...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build certificate configuration: %w", err)
+		}
+
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("failed validating certificate %q: %w", names[cr.Target], err)
+		}
+
+		reasons, err := r.renewalReasonsFor(cr.CA)
+		if err != nil {
+			return nil, fmt.Errorf("failed checking if certificate %q is up to date: %w", names[cr.Target], err)
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		renewals = append(renewals, Renewal{
+			Name:    names[cr.Target],
+			Reasons: reasons,
+		})
+	}
+
+	sort.Slice(renewals, func(i, j int) bool {
+		return renewals[i].Name < renewals[j].Name
+	})
+
+	return renewals, nil
+}
+
 // buildCertificate merges N number of given certificates. Properties of last given certificate takes
 // precedence over previous ones.
 func buildCertificate(certs ...*Certificate) (*Certificate, error) {
 	r := &Certificate{
 		Organization:     Organization,
 		RSABits:          RSABits,
+		KeyAlgorithm:     KeyAlgorithm,
 		ValidityDuration: ValidityDuration,
-		RenewThreshold:   RenewThreshold,
 	}
 
 	for _, c := range certs {
@@ -301,18 +646,31 @@ func buildCertificate(certs ...*Certificate) (*Certificate, error) {
 	return r, nil
 }
 
-func (c *Certificate) decodePrivateKey() (*rsa.PrivateKey, error) {
+func (c *Certificate) decodePrivateKey() (crypto.Signer, error) {
 	der, _ := pem.Decode([]byte(c.PrivateKey))
 	if der == nil {
 		return nil, fmt.Errorf("private key is not defined in valid PEM format")
 	}
 
-	k, err := x509.ParsePKCS1PrivateKey(der.Bytes)
+	if k, err := x509.ParsePKCS1PrivateKey(der.Bytes); err == nil {
+		return k, nil
+	}
+
+	if k, err := x509.ParseECPrivateKey(der.Bytes); err == nil {
+		return k, nil
+	}
+
+	k, err := x509.ParsePKCS8PrivateKey(der.Bytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse private key to PKCS1 format: %w", err)
+		return nil, fmt.Errorf("failed to parse private key, tried PKCS1, EC and PKCS8 formats: %w", err)
 	}
 
-	return k, nil
+	signer, ok := k.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", k)
+	}
+
+	return signer, nil
 }
 
 // DecodeX509Certificate returns parsed version of X.509 certificate, so one can read
@@ -331,17 +689,23 @@ func (c *Certificate) DecodeX509Certificate() (*x509.Certificate, error) {
 	return cert, nil
 }
 
-// persistPublicKey persist given RSA public key into the certificate object.
-func (c *Certificate) persistPublicKey(k *rsa.PublicKey) error {
+// persistPublicKey persist given public key into the certificate object.
+func (c *Certificate) persistPublicKey(k crypto.PublicKey) error {
 	pubBytes, err := x509.MarshalPKIXPublicKey(k)
 	if err != nil {
-		return fmt.Errorf("failed marshaling RSA public key: %w", err)
+		return fmt.Errorf("failed marshaling public key: %w", err)
+	}
+
+	header := PublicKeyPEMHeader
+
+	if _, ok := k.(*rsa.PublicKey); ok {
+		header = RSAPublicKeyPEMHeader
 	}
 
 	var buf bytes.Buffer
 
-	if err := pem.Encode(&buf, &pem.Block{Type: RSAPublicKeyPEMHeader, Bytes: pubBytes}); err != nil {
-		return fmt.Errorf("failed to encode RSA public key: %w", err)
+	if err := pem.Encode(&buf, &pem.Block{Type: header, Bytes: pubBytes}); err != nil {
+		return fmt.Errorf("failed to encode public key: %w", err)
 	}
 
 	c.PublicKey = buf.String()
@@ -349,30 +713,73 @@ func (c *Certificate) persistPublicKey(k *rsa.PublicKey) error {
 	return nil
 }
 
-func (c *Certificate) generatePrivateKey() (*rsa.PrivateKey, error) {
-	// generate RSA private key.
-	k, err := rsa.GenerateKey(rand.Reader, c.RSABits)
-	if err != nil {
-		return nil, fmt.Errorf("failed generating RSA key: %w", err)
+// newPrivateKey generates new private key using configured algorithm.
+func (c *Certificate) newPrivateKey() (crypto.Signer, error) {
+	switch c.KeyAlgorithm {
+	case "", KeyAlgorithmRSA:
+		return rsa.GenerateKey(rand.Reader, c.RSABits)
+	case KeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyAlgorithmEd25519:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+
+		return k, err
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", c.KeyAlgorithm)
 	}
+}
 
-	privBytes := x509.MarshalPKCS1PrivateKey(k)
+// encodePrivateKey encodes given private key into PEM format. RSA private keys are
+// encoded in PKCS1 format to stay compatible with previously generated keys, other
+// keys are encoded in PKCS8 format.
+func encodePrivateKey(k crypto.Signer) (string, error) {
+	block := &pem.Block{
+		Type: PrivateKeyPEMHeader,
+	}
+
+	if rk, ok := k.(*rsa.PrivateKey); ok {
+		block.Type = RSAPrivateKeyPEMHeader
+		block.Bytes = x509.MarshalPKCS1PrivateKey(rk)
+	} else {
+		privBytes, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return "", fmt.Errorf("failed marshaling private key: %w", err)
+		}
+
+		block.Bytes = privBytes
+	}
 
 	var buf bytes.Buffer
-	if err := pem.Encode(&buf, &pem.Block{Type: RSAPrivateKeyPEMHeader, Bytes: privBytes}); err != nil {
-		return nil, fmt.Errorf("failed to encode RSA private key: %w", err)
+	if err := pem.Encode(&buf, block); err != nil {
+		return "", fmt.Errorf("failed to encode private key: %w", err)
 	}
 
-	c.PrivateKey = types.PrivateKey(buf.String())
+	return buf.String(), nil
+}
 
-	if err := c.persistPublicKey(k.Public().(*rsa.PublicKey)); err != nil {
-		return nil, fmt.Errorf("failed persisting RSA public key: %w", err)
+func (c *Certificate) generatePrivateKey() (crypto.Signer, error) {
+	k, err := c.newPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed generating private key: %w", err)
+	}
+
+	pk, err := encodePrivateKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding private key: %w", err)
+	}
+
+	c.PrivateKey = types.PrivateKey(pk)
+
+	if err := c.persistPublicKey(k.Public()); err != nil {
+		return nil, fmt.Errorf("failed persisting public key: %w", err)
 	}
 
 	return k, nil
 }
 
-func (c *Certificate) getPrivateKey() (*rsa.PrivateKey, error) {
+func (c *Certificate) getPrivateKey() (crypto.Signer, error) {
 	if c.PrivateKey != "" {
 		return c.decodePrivateKey()
 	}
@@ -382,23 +789,102 @@ func (c *Certificate) getPrivateKey() (*rsa.PrivateKey, error) {
 
 // Validate validates the certificate configuration.
 func (c *Certificate) Validate() error {
-	if _, err := time.ParseDuration(c.ValidityDuration); err != nil {
+	vd, err := time.ParseDuration(c.ValidityDuration)
+	if err != nil {
 		return fmt.Errorf("failed to parse validity duration %q for certificate: %w", c.ValidityDuration, err)
 	}
 
+	if c.RenewThreshold != "" {
+		rt, err := time.ParseDuration(c.RenewThreshold)
+		if err != nil {
+			return fmt.Errorf("failed to parse renew threshold %q for certificate: %w", c.RenewThreshold, err)
+		}
+
+		// Otherwise certificate would be renewed every time.
+		if rt >= vd {
+			return fmt.Errorf("renew threshold %q must be shorter than validity duration %q", c.RenewThreshold, c.ValidityDuration)
+		}
+	}
+
 	for _, i := range c.IPAddresses {
 		if ip := net.ParseIP(i); ip == nil {
 			return fmt.Errorf("failed parsing IP address %q", i)
 		}
 	}
 
-	if c.RSABits == 0 {
-		return fmt.Errorf("RSA bits can't be 0")
+	switch c.KeyAlgorithm {
+	case "", KeyAlgorithmRSA:
+		if c.RSABits == 0 {
+			return fmt.Errorf("RSA bits can't be 0")
+		}
+	case KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384, KeyAlgorithmEd25519:
+	default:
+		return fmt.Errorf("unsupported key algorithm %q", c.KeyAlgorithm)
+	}
+
+	if c.External {
+		if err := c.validateExternal(); err != nil {
+			return fmt.Errorf("failed validating external certificate: %w", err)
+		}
+	}
+
+	if c.ExternalSigning && c.CA && !c.External {
+		return fmt.Errorf("CA certificates must be external when external signing is enabled")
+	}
+
+	return nil
+}
+
+// validateExternal validates certificate and private key provided by the user.
+func (c *Certificate) validateExternal() error {
+	if c.X509Certificate == "" {
+		return fmt.Errorf("X.509 certificate must be set") //nolint:stylecheck
+	}
+
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	if c.CA && (!cert.BasicConstraintsValid || !cert.IsCA) {
+		return fmt.Errorf("certificate is not a CA certificate")
+	}
+
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("certificate expired at %s", cert.NotAfter.Format(time.RFC3339))
+	}
+
+	if c.PrivateKey == "" {
+		if !c.CA {
+			return fmt.Errorf("private key must be set")
+		}
+
+		return nil
+	}
+
+	if c.CA && cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("certificate key usage does not allow signing certificates")
+	}
+
+	k, err := c.decodePrivateKey()
+	if err != nil {
+		return fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	if !publicKeysEqual(k.Public(), cert.PublicKey) {
+		return fmt.Errorf("private key does not match the certificate")
 	}
 
 	return nil
 }
 
+// publicKeysEqual checks, if given public keys are equal.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+
+	return ok && k.Equal(b)
+}
+
 func (c *Certificate) decodeKeyUsage() (x509.KeyUsage, []x509.ExtKeyUsage) {
 	ku := 0
 	eku := []x509.ExtKeyUsage{}
@@ -419,7 +905,7 @@ func (c *Certificate) decodeKeyUsage() (x509.KeyUsage, []x509.ExtKeyUsage) {
 	return x509.KeyUsage(ku), eku
 }
 
-func (c *Certificate) generateX509Certificate(k *rsa.PrivateKey, ca *Certificate) error {
+func (c *Certificate) generateX509Certificate(k crypto.Signer, ca *Certificate) error {
 	// Generate serial number for X.509 certificate.
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 
@@ -452,10 +938,18 @@ func (c *Certificate) generateX509Certificate(k *rsa.PrivateKey, ca *Certificate
 		cert.IPAddresses = append(cert.IPAddresses, net.ParseIP(i))
 	}
 
+	if err := c.keepImportedFields(&cert); err != nil {
+		return fmt.Errorf("failed to copy fields of imported certificate: %w", err)
+	}
+
 	pk := k
 	caCert := &cert
 
 	if ca != nil {
+		if ca.PrivateKey == "" {
+			return fmt.Errorf("CA has no private key, so it can't issue certificates")
+		}
+
 		var err error
 
 		caCert, pk, err = ca.decodeKeypair()
@@ -464,7 +958,7 @@ func (c *Certificate) generateX509Certificate(k *rsa.PrivateKey, ca *Certificate
 		}
 	}
 
-	subjectKeyID, err := bigIntHash(pk.N)
+	subjectKeyID, err := publicKeyHash(pk.Public())
 	if err != nil {
 		return fmt.Errorf("failed generating certificate subject Key ID: %w", err)
 	}
@@ -474,8 +968,32 @@ func (c *Certificate) generateX509Certificate(k *rsa.PrivateKey, ca *Certificate
 	return c.createAndPersist(&cert, caCert, k, pk)
 }
 
-func (c *Certificate) createAndPersist(cert, caCert *x509.Certificate, k, pk *rsa.PrivateKey) error {
-	der, err := x509.CreateCertificate(rand.Reader, cert, caCert, &k.PublicKey, pk)
+// keepImportedFields copies subject, IP addresses, DNS names and key usage of imported X.509
+// certificate into given certificate template, so renewed certificate does not change.
+func (c *Certificate) keepImportedFields(cert *x509.Certificate) error {
+	if !c.Imported || c.X509Certificate == "" {
+		return nil
+	}
+
+	imported, err := c.DecodeX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	cert.Subject = pkix.Name{
+		Organization: imported.Subject.Organization,
+		CommonName:   imported.Subject.CommonName,
+	}
+	cert.IPAddresses = imported.IPAddresses
+	cert.DNSNames = imported.DNSNames
+	cert.KeyUsage = imported.KeyUsage
+	cert.ExtKeyUsage = imported.ExtKeyUsage
+
+	return nil
+}
+
+func (c *Certificate) createAndPersist(cert, caCert *x509.Certificate, k, pk crypto.Signer) error {
+	der, err := x509.CreateCertificate(rand.Reader, cert, caCert, k.Public(), pk)
 	if err != nil {
 		return fmt.Errorf("failed to create certificate: %w", err)
 	}
@@ -483,11 +1001,17 @@ func (c *Certificate) createAndPersist(cert, caCert *x509.Certificate, k, pk *rs
 	return c.persistX509Certificate(der)
 }
 
-// Taken from https://play.golang.org/p/tispiUVmdm.
-func bigIntHash(n *big.Int) ([]byte, error) {
+// publicKeyHash returns SHA1 hash of given public key, which can be used as certificate
+// subject key ID.
+func publicKeyHash(k crypto.PublicKey) ([]byte, error) {
+	b, err := x509.MarshalPKIXPublicKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling public key: %w", err)
+	}
+
 	h := sha1.New() // #nosec G401
 
-	if _, err := h.Write(n.Bytes()); err != nil {
+	if _, err := h.Write(b); err != nil {
 		return nil, fmt.Errorf("writing bytes to SHA1 function: %w", err)
 	}
 
@@ -495,7 +1019,7 @@ func bigIntHash(n *big.Int) ([]byte, error) {
 }
 
 // decodeKeypair decodes both X.509 certificate and private key.
-func (c *Certificate) decodeKeypair() (*x509.Certificate, *rsa.PrivateKey, error) {
+func (c *Certificate) decodeKeypair() (*x509.Certificate, crypto.Signer, error) {
 	pk, err := c.decodePrivateKey()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to decode private key: %w", err)
@@ -526,76 +1050,259 @@ func (c *Certificate) persistX509Certificate(der []byte) error {
 //
 // This function currently supports:
 //
-// - Generating new RSA private key and public key.
+// - Generating new RSA, ECDSA or Ed25519 private key and public key.
 //
 // - Generating new X.509 certificates.
 //
-// - Re-generating X.509 certificate if IP addresses changes.
+// - Re-generating X.509 certificate if it expires within renew threshold or if IP addresses,
+// DNS names, subject or key usage changes, unless certificate has been imported.
 //
-// NOT implemented functionality:
+// - Re-generating X.509 certificate if it is not issued by given CA, e.g. after CA private key
+// renewal.
 //
-// - Renewing certificates based on expiry time.
+// - Using external CA certificates provided by the user as they are.
 //
-// - Renewing X.509 certificate after RSA private key renewal.
+// - Generating certificate signing requests for certificates signed externally.
 //
-// - Renewing issued certificate during CA renewal.
+// NOT implemented functionality:
+//
+// - Renewing X.509 certificate after private key renewal.
 func (c *Certificate) Generate(ca *Certificate) error {
 	if err := c.Validate(); err != nil {
 		return fmt.Errorf("failed validating the certificate: %w", err)
 	}
 
+	if c.External {
+		return c.persistExternalPublicKey()
+	}
+
 	k, err := c.getPrivateKey()
 	if err != nil {
 		return fmt.Errorf("failed getting private key: %w", err)
 	}
 
+	if c.ExternalSigning {
+		return c.ensureCertificateSigningRequest(k, ca)
+	}
+
 	return c.ensureX509Certificate(k, ca)
 }
 
+// persistExternalPublicKey persists public key of external X.509 certificate, if it's not set.
+func (c *Certificate) persistExternalPublicKey() error {
+	if c.PublicKey != "" {
+		return nil
+	}
+
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	return c.persistPublicKey(cert.PublicKey)
+}
+
 // ensureX509Certificate checks if the certificate is up to date and if not, triggers
 // certificate generation.
-func (c *Certificate) ensureX509Certificate(k *rsa.PrivateKey, ca *Certificate) error {
-	upToDate, err := c.IsX509CertificateUpToDate()
+func (c *Certificate) ensureX509Certificate(k crypto.Signer, ca *Certificate) error {
+	reasons, err := c.renewalReasonsFor(ca)
 	if err != nil {
 		return fmt.Errorf("failed checking if X.509 certificate is up to date: %w", err)
 	}
 
-	if !upToDate {
+	if len(reasons) != 0 {
 		return c.generateX509Certificate(k, ca)
 	}
 
 	return nil
 }
 
-func ipAddressesUpToDate(cert *x509.Certificate, configuredIPs []string) bool {
+// sameStrings checks, if both given lists contains the same elements, ignoring the order.
+func sameStrings(a, b []string) bool {
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+
+	sort.Strings(a)
+	sort.Strings(b)
+
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func ipAddressesUpToDate(certIPs []net.IP, configuredIPs []string) bool {
 	ips := []string{}
 
-	for _, i := range cert.IPAddresses {
+	for _, i := range certIPs {
 		ips = append(ips, i.String())
 	}
 
-	sort.Strings(ips)
+	return sameStrings(ips, configuredIPs)
+}
+
+// keyUsageUpToDate checks, if key usage of given certificate matches configured one.
+func (c *Certificate) keyUsageUpToDate(cert *x509.Certificate) bool {
+	ku, eku := c.decodeKeyUsage()
+
+	if cert.KeyUsage != ku || cert.IsCA != c.CA || len(cert.ExtKeyUsage) != len(eku) {
+		return false
+	}
+
+	usages := map[x509.ExtKeyUsage]struct{}{}
 
-	sort.Strings(configuredIPs)
+	for _, u := range cert.ExtKeyUsage {
+		usages[u] = struct{}{}
+	}
 
-	return strings.Join(ips, ",") == strings.Join(configuredIPs, ",")
+	for _, u := range eku {
+		if _, ok := usages[u]; !ok {
+			return false
+		}
+	}
+
+	return true
 }
 
-// IsX509CertificateUpToDate checks, if generated X.509 certificate is up to date
-// with it's configuration.
-func (c *Certificate) IsX509CertificateUpToDate() (bool, error) {
+// renewThreshold returns configured renew threshold of the certificate. If threshold is not
+// configured, default value is returned, which is limited to a fraction of validity duration,
+// so certificates with short validity duration are not renewed every time.
+//
+// Certificate must be validated before calling this function.
+func (c *Certificate) renewThreshold() time.Duration {
+	if c.RenewThreshold != "" {
+		rt, _ := time.ParseDuration(c.RenewThreshold)
+
+		return rt
+	}
+
+	rt, _ := time.ParseDuration(RenewThreshold)
+
+	vd, err := time.ParseDuration(c.ValidityDuration)
+	if err != nil {
+		return rt
+	}
+
+	if max := vd / RenewThresholdValidityDivisor; max < rt {
+		return max
+	}
+
+	return rt
+}
+
+// expiryReason returns renewal reason, if given certificate expires within renew threshold.
+func (c *Certificate) expiryReason(cert *x509.Certificate) string {
+	rt := c.renewThreshold()
+
+	if time.Now().Add(rt).Before(cert.NotAfter) {
+		return ""
+	}
+
+	return fmt.Sprintf("expires at %s, which is within renew threshold of %s", cert.NotAfter.Format(time.RFC3339), rt)
+}
+
+// issuerReason returns renewal reason, if given certificate is not issued by given CA.
+//
+// If CA certificate is not generated yet, certificate is not checked, as CA certificate
+// with the same subject and private key will issue valid certificates.
+func issuerReason(cert *x509.Certificate, ca *Certificate) (string, error) {
+	if ca == nil || ca.X509Certificate == "" {
+		return "", nil
+	}
+
+	caCert, err := ca.DecodeX509Certificate()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode CA X.509 certificate: %w", err)
+	}
+
+	if !issuedBy(cert, caCert) {
+		return "not issued by current CA", nil
+	}
+
+	return "", nil
+}
+
+// issuedBy checks, if given certificate has been issued by given CA certificate.
+func issuedBy(cert, caCert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, caCert.RawSubject) && cert.CheckSignatureFrom(caCert) == nil
+}
+
+// renewalReasonsFor returns list of reasons, why X.509 certificate must be generated. If
+// given CA is not nil, certificate must also be issued by it. If certificate is up to date,
+// empty list is returned.
+func (c *Certificate) renewalReasonsFor(ca *Certificate) ([]string, error) {
+	// External certificates are managed by the user.
+	if c.External {
+		return []string{}, nil
+	}
+
 	if c.X509Certificate == "" {
-		return false, nil
+		return []string{"certificate not generated"}, nil
 	}
 
 	cert, err := c.DecodeX509Certificate()
 	if err != nil {
-		return true, fmt.Errorf("failed to decode X.509 certificate: %w", err)
+		return nil, fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	reasons := []string{}
+
+	if r := c.expiryReason(cert); r != "" {
+		reasons = append(reasons, r)
+	}
+
+	if !c.Imported {
+		reasons = append(reasons, c.configurationReasons(cert)...)
+	}
+
+	r, err := issuerReason(cert, ca)
+	if err != nil {
+		return nil, fmt.Errorf("checking certificate issuer: %w", err)
 	}
 
-	if !ipAddressesUpToDate(cert, c.IPAddresses) {
-		return false, nil
+	if r != "" {
+		reasons = append(reasons, r)
+	}
+
+	return reasons, nil
+}
+
+// configurationReasons returns list of reasons, why given X.509 certificate does not match
+// configuration of the certificate.
+func (c *Certificate) configurationReasons(cert *x509.Certificate) []string {
+	reasons := []string{}
+
+	if !ipAddressesUpToDate(cert.IPAddresses, c.IPAddresses) {
+		reasons = append(reasons, "IP addresses changed")
+	}
+
+	if !sameStrings(cert.DNSNames, c.DNSNames) {
+		reasons = append(reasons, "DNS names changed")
+	}
+
+	if cert.Subject.CommonName != c.CommonName || strings.Join(cert.Subject.Organization, ",") != c.Organization {
+		reasons = append(reasons, "subject changed")
+	}
+
+	if !c.keyUsageUpToDate(cert) {
+		reasons = append(reasons, "key usage changed")
+	}
+
+	return reasons
+}
+
+// RenewalReasons returns list of reasons, why generated X.509 certificate is not up to date
+// with it's configuration. If certificate is up to date, empty list is returned.
+//
+// Issuer of the certificate is not checked, as CA is not known.
+func (c *Certificate) RenewalReasons() ([]string, error) {
+	return c.renewalReasonsFor(nil)
+}
+
+// IsX509CertificateUpToDate checks, if generated X.509 certificate is up to date
+// with it's configuration.
+func (c *Certificate) IsX509CertificateUpToDate() (bool, error) {
+	reasons, err := c.RenewalReasons()
+	if err != nil {
+		return true, err
 	}
 
-	return true, nil
+	return len(reasons) == 0, nil
 }
@@ -344,3 +344,600 @@ func TestIsX509CertificateUpToDateBadCert(t *testing.T) {
 		t.Fatalf("checking if certificate is up to date should fail on bad certificate")
 	}
 }
+
+func TestValidateRenewThreshold(t *testing.T) {
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		RenewThreshold:   "doh",
+		RSABits:          2048,
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("certificate with unparseable renew threshold should be invalid")
+	}
+}
+
+func TestValidateRenewThresholdLongerThanValidity(t *testing.T) {
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		RenewThreshold:   "48h",
+		RSABits:          2048,
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("certificate with renew threshold longer than validity duration should be invalid")
+	}
+}
+
+// Plan() tests.
+func fullPKI() *pki.PKI {
+	return &pki.PKI{
+		Etcd: &pki.Etcd{
+			Peers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+			ClientCNs: []string{"root"},
+		},
+		Kubernetes: &pki.Kubernetes{
+			KubeAPIServer: &pki.KubeAPIServer{
+				ExternalNames: []string{"foo"},
+				ServerIPs:     []string{"1.1.1.1"},
+			},
+		},
+		Docker: &pki.Docker{
+			Servers: map[string]string{
+				"controller01": "192.168.1.10",
+			},
+		},
+	}
+}
+
+func TestPlanNotGenerated(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	expected := []pki.Renewal{
+		{
+			Name:    "rootCA",
+			Reasons: []string{"certificate not generated"},
+		},
+	}
+
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Fatalf("unexpected plan: %s", diff)
+	}
+}
+
+func TestPlanUpToDate(t *testing.T) {
+	t.Parallel()
+
+	p := fullPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("generated PKI should be up to date, got: %+v", plan)
+	}
+}
+
+func TestPlanDNSNamesChanged(t *testing.T) {
+	t.Parallel()
+
+	p := fullPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	p.Kubernetes.KubeAPIServer.ExternalNames = []string{"bar"}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	expected := []pki.Renewal{
+		{
+			Name:    "kubernetes.kubeAPIServer.serverCertificate",
+			Reasons: []string{"DNS names changed"},
+		},
+	}
+
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Fatalf("unexpected plan: %s", diff)
+	}
+}
+
+func TestPlanSubjectAndKeyUsageChanged(t *testing.T) {
+	t.Parallel()
+
+	p := fullPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	p.Kubernetes.AdminCertificate.CommonName = "foo"
+	p.Kubernetes.AdminCertificate.KeyUsage = []string{"digital_signature"}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	expected := []pki.Renewal{
+		{
+			Name:    "kubernetes.adminCertificate",
+			Reasons: []string{"subject changed", "key usage changed"},
+		},
+	}
+
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Fatalf("unexpected plan: %s", diff)
+	}
+}
+
+// Generate() renewal tests.
+func TestGenerateRenewExpiring(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			ValidityDuration: "2h",
+			RenewThreshold:   "1h",
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	cert := p.RootCA.X509Certificate
+
+	// Certificate expires in 2 hours, which is now within renew threshold.
+	p.RootCA.ValidityDuration = "24h"
+	p.RootCA.RenewThreshold = "3h"
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 1 || plan[0].Name != "rootCA" {
+		t.Fatalf("expiring root CA certificate should be renewed, got: %+v", plan)
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("re-generating PKI certificates should succeed, got: %v", err)
+	}
+
+	if cert == p.RootCA.X509Certificate {
+		t.Fatalf("expiring certificate should be renewed")
+	}
+}
+
+func TestGenerateShortValidityDefaultRenewThreshold(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Certificate: pki.Certificate{
+			ValidityDuration: "24h",
+		},
+		Etcd: &pki.Etcd{
+			Peers: map[string]string{
+				"foo": "10.0.0.1",
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating PKI with validity shorter than default renew threshold should work, got: %v", err)
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("freshly generated certificates should not be renewed, got: %+v", plan)
+	}
+}
+
+func TestGenerateRenewIssuedByOtherCA(t *testing.T) {
+	t.Parallel()
+
+	p := fullPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	other := fullPKI()
+
+	if err := other.Generate(); err != nil {
+		t.Fatalf("generating valid PKI should work, got: %v", err)
+	}
+
+	p.Kubernetes.CA = other.Kubernetes.CA
+	adminCert := p.Kubernetes.AdminCertificate.X509Certificate
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("planning should succeed, got: %v", err)
+	}
+
+	renewed := map[string][]string{}
+
+	for _, r := range plan {
+		renewed[r.Name] = r.Reasons
+	}
+
+	if diff := cmp.Diff([]string{"not issued by current CA"}, renewed["kubernetes.adminCertificate"]); diff != "" {
+		t.Fatalf("certificate issued by other CA should be renewed: %s", diff)
+	}
+
+	if _, ok := renewed["etcd.ca"]; ok {
+		t.Fatalf("certificates not related to changed CA should not be renewed")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("re-generating PKI certificates should succeed, got: %v", err)
+	}
+
+	if adminCert == p.Kubernetes.AdminCertificate.X509Certificate {
+		t.Fatalf("certificate issued by other CA should be renewed")
+	}
+
+	if plan, _ := p.Plan(); len(plan) != 0 {
+		t.Fatalf("PKI should be up to date after renewal, got: %+v", plan)
+	}
+}
+
+// Key algorithm tests.
+func TestValidateKeyAlgorithm(t *testing.T) {
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		RSABits:          2048,
+		KeyAlgorithm:     "dsa",
+	}
+
+	if err := c.Validate(); err == nil {
+		t.Fatalf("Certificate with unsupported key algorithm should be invalid")
+	}
+}
+
+func TestValidateKeyAlgorithmNoRSABits(t *testing.T) {
+	c := &pki.Certificate{
+		ValidityDuration: "24h",
+		KeyAlgorithm:     pki.KeyAlgorithmEd25519,
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("RSA bits should not be required for Ed25519 keys, got: %v", err)
+	}
+}
+
+func TestGenerateKeyAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		pki.KeyAlgorithmRSA:       "RSA PRIVATE KEY",
+		pki.KeyAlgorithmECDSAP256: "PRIVATE KEY",
+		pki.KeyAlgorithmECDSAP384: "PRIVATE KEY",
+		pki.KeyAlgorithmEd25519:   "PRIVATE KEY",
+	}
+
+	for a, header := range cases {
+		a, header := a, header
+
+		t.Run(a, func(t *testing.T) {
+			t.Parallel()
+
+			p := fullPKI()
+			p.KeyAlgorithm = a
+
+			if err := p.Generate(); err != nil {
+				t.Fatalf("Generating PKI should succeed, got: %v", err)
+			}
+
+			c := p.Etcd.PeerCertificates["controller01"]
+
+			der, _ := pem.Decode([]byte(c.PrivateKey))
+			if der == nil || der.Type != header {
+				t.Fatalf("Expected private key with %q PEM header, got: %v", header, c.PrivateKey)
+			}
+
+			if err := pki.ValidatePrivateKey(string(c.PrivateKey)); err != nil {
+				t.Fatalf("Generated private key should be valid, got: %v", err)
+			}
+
+			cert, err := c.DecodeX509Certificate()
+			if err != nil {
+				t.Fatalf("Decoding generated certificate should succeed, got: %v", err)
+			}
+
+			ca, err := p.Etcd.CA.DecodeX509Certificate()
+			if err != nil {
+				t.Fatalf("Decoding generated CA certificate should succeed, got: %v", err)
+			}
+
+			if err := cert.CheckSignatureFrom(ca); err != nil {
+				t.Fatalf("Certificate should be signed by etcd CA, got: %v", err)
+			}
+
+			// Re-generating should re-use existing private keys.
+			if err := p.Generate(); err != nil {
+				t.Fatalf("Re-generating PKI should succeed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyAlgorithmOverride(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Certificate: pki.Certificate{
+			KeyAlgorithm: pki.KeyAlgorithmECDSAP256,
+		},
+		Kubernetes: &pki.Kubernetes{
+			AdminCertificate: &pki.Certificate{
+				KeyAlgorithm: pki.KeyAlgorithmEd25519,
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	algorithms := map[x509.PublicKeyAlgorithm]*pki.Certificate{
+		x509.ECDSA:   p.Kubernetes.CA,
+		x509.Ed25519: p.Kubernetes.AdminCertificate,
+	}
+
+	for expected, c := range algorithms {
+		cert, err := c.DecodeX509Certificate()
+		if err != nil {
+			t.Fatalf("Decoding generated certificate should succeed, got: %v", err)
+		}
+
+		if cert.PublicKeyAlgorithm != expected {
+			t.Fatalf("Expected public key algorithm %v, got %v", expected, cert.PublicKeyAlgorithm)
+		}
+	}
+}
+
+// External CA tests.
+func testCA(t *testing.T, cn string, issuer *pki.Certificate) *pki.Certificate {
+	t.Helper()
+
+	c := &pki.Certificate{
+		CommonName:       cn,
+		Organization:     "corporate",
+		KeyAlgorithm:     pki.KeyAlgorithmECDSAP256,
+		ValidityDuration: "24h",
+		CA:               true,
+		KeyUsage:         []string{"cert_signing", "digital_signature"},
+	}
+
+	if err := c.Generate(issuer); err != nil {
+		t.Fatalf("Generating CA %q should succeed, got: %v", cn, err)
+	}
+
+	return c
+}
+
+func countChain(t *testing.T, chain []byte) []*x509.Certificate {
+	t.Helper()
+
+	certs := []*x509.Certificate{}
+
+	for {
+		var b *pem.Block
+
+		b, chain = pem.Decode(chain)
+		if b == nil {
+			return certs
+		}
+
+		cert, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			t.Fatalf("Parsing certificate from chain should succeed, got: %v", err)
+		}
+
+		certs = append(certs, cert)
+	}
+}
+
+func TestGenerateExternalIntermediateRootCA(t *testing.T) {
+	t.Parallel()
+
+	corporateRoot := testCA(t, "corporate-root", nil)
+	intermediate := testCA(t, "intermediate", corporateRoot)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			External:        true,
+			X509Certificate: intermediate.X509Certificate,
+			PrivateKey:      intermediate.PrivateKey,
+		},
+		Kubernetes: &pki.Kubernetes{
+			KubeAPIServer: &pki.KubeAPIServer{
+				ServerIPs: []string{"1.1.1.1"},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with external root CA should succeed, got: %v", err)
+	}
+
+	if p.RootCA.X509Certificate != intermediate.X509Certificate || p.RootCA.PublicKey == "" {
+		t.Fatalf("External root CA should be used as it is")
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("All certificates should be up to date, got: %v", plan)
+	}
+
+	chain := countChain(t, []byte(p.CertificateChain(p.Kubernetes.KubeAPIServer.ServerCertificate, pki.KubernetesCAName)))
+	if len(chain) != 3 {
+		t.Fatalf("Chain should include server certificate, Kubernetes CA and intermediate CA, got %d certificates", len(chain))
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(countChain(t, []byte(corporateRoot.X509Certificate))[0])
+
+	intermediates := x509.NewCertPool()
+
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Fatalf("Server certificate should be verifiable using corporate root CA, got: %v", err)
+	}
+}
+
+func TestCertificateChainTrustedIssuer(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			KubeAPIServer: &pki.KubeAPIServer{
+				ServerIPs: []string{"1.1.1.1"},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	sc := p.Kubernetes.KubeAPIServer.ServerCertificate
+
+	if c := p.CertificateChain(sc, pki.KubernetesCAName); c != sc.X509Certificate {
+		t.Fatalf("Chain should not be included, when certificate is issued by trusted CA, got %d certificates", len(countChain(t, []byte(c))))
+	}
+
+	if c := countChain(t, []byte(p.CertificateChain(sc, pki.RootCAName))); len(c) != 2 {
+		t.Fatalf("Chain should include Kubernetes CA, when only root CA is trusted, got %d certificates", len(c))
+	}
+}
+
+func TestGenerateExternalKubernetesCA(t *testing.T) {
+	t.Parallel()
+
+	ca := testCA(t, "corporate-root", nil)
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{
+			CA: &pki.Certificate{
+				External:        true,
+				X509Certificate: ca.X509Certificate,
+				PrivateKey:      ca.PrivateKey,
+			},
+			KubeAPIServer: &pki.KubeAPIServer{
+				ServerIPs: []string{"1.1.1.1"},
+			},
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI with external Kubernetes CA should succeed, got: %v", err)
+	}
+
+	// Self-signed root CA should not be included in the chain.
+	if c := countChain(t, []byte(p.CertificateChain(p.Kubernetes.AdminCertificate, pki.KubernetesCAName))); len(c) != 1 {
+		t.Fatalf("Chain should only include admin certificate, got %d certificates", len(c))
+	}
+}
+
+func TestGenerateExternalCANoPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	ca := testCA(t, "corporate-root", nil)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			External:        true,
+			X509Certificate: ca.X509Certificate,
+		},
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if err := p.Generate(); err == nil {
+		t.Fatalf("Issuing certificates using external CA without private key should fail")
+	}
+}
+
+func TestValidateExternal(t *testing.T) {
+	t.Parallel()
+
+	ca := testCA(t, "ca", nil)
+	other := testCA(t, "other", nil)
+
+	leaf := &pki.Certificate{
+		ValidityDuration: "24h",
+		KeyAlgorithm:     pki.KeyAlgorithmECDSAP256,
+	}
+
+	if err := leaf.Generate(ca); err != nil {
+		t.Fatalf("Generating leaf certificate should succeed, got: %v", err)
+	}
+
+	cases := map[string]*pki.Certificate{
+		"no certificate": {
+			CA: true,
+		},
+		"no private key": {
+			CA:              false,
+			X509Certificate: ca.X509Certificate,
+		},
+		"certificate is not CA": {
+			CA:              true,
+			X509Certificate: leaf.X509Certificate,
+		},
+		"key mismatch": {
+			CA:              true,
+			X509Certificate: ca.X509Certificate,
+			PrivateKey:      other.PrivateKey,
+		},
+	}
+
+	for n, c := range cases {
+		c := c
+
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			c.External = true
+			c.ValidityDuration = "24h"
+			c.RSABits = 2048
+
+			if err := c.Validate(); err == nil {
+				t.Fatalf("Validation should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// CertificateInfo describes generated certificate stored in PKI.
+type CertificateInfo struct {
+	// Name is a path of the certificate in PKI, e.g. 'etcd.peerCertificates.foo'.
+	Name string `json:"name"`
+
+	// Subject is a subject of the certificate.
+	Subject string `json:"subject"`
+
+	// DNSNames is a list of DNS names the certificate is valid for.
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// IPAddresses is a list of IP addresses the certificate is valid for.
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+
+	// Issuer is a subject of the CA, which issued the certificate.
+	Issuer string `json:"issuer"`
+
+	// KeyType is a type of the certificate public key, e.g. 'RSA'.
+	KeyType string `json:"keyType"`
+
+	// KeySize is a size of the certificate public key in bits.
+	KeySize int `json:"keySize"`
+
+	// NotBefore is a time from which the certificate is valid.
+	NotBefore time.Time `json:"notBefore"`
+
+	// NotAfter is a time when the certificate expires.
+	NotAfter time.Time `json:"notAfter"`
+
+	// RenewAt is a time, from which the certificate will be renewed by Generate.
+	RenewAt time.Time `json:"renewAt"`
+
+	// Expiring indicates, if certificate expires within the threshold of the report.
+	Expiring bool `json:"expiring"`
+}
+
+// Report contains information about all generated certificates in PKI.
+type Report struct {
+	// Certificates is a list of generated certificates, sorted by name.
+	Certificates []CertificateInfo `json:"certificates"`
+}
+
+// Report returns information about all generated certificates in PKI. Certificates
+// which expire within given threshold are marked as expiring. If threshold is 0,
+// renew threshold of each certificate is used instead. PKI is not modified, except
+// initializing empty certificates.
+func (p *PKI) Report(threshold time.Duration) (*Report, error) {
+	crs := p.certificateRequests()
+
+	names := map[*Certificate]string{}
+
+	for n, c := range p.certificates() {
+		names[c] = n
+	}
+
+	now := time.Now()
+	r := &Report{
+		Certificates: []CertificateInfo{},
+	}
+
+	for _, cr := range crs {
+		if cr.Target.X509Certificate == "" {
+			continue
+		}
+
+		c, err := buildCertificate(cr.Certificates...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build certificate configuration: %w", err)
+		}
+
+		info, err := c.info(names[cr.Target])
+		if err != nil {
+			return nil, fmt.Errorf("failed getting information about certificate %q: %w", names[cr.Target], err)
+		}
+
+		info.Expiring = !now.Before(info.RenewAt)
+
+		if threshold != 0 {
+			info.Expiring = !now.Add(threshold).Before(info.NotAfter)
+		}
+
+		r.Certificates = append(r.Certificates, *info)
+	}
+
+	sort.Slice(r.Certificates, func(i, j int) bool {
+		return r.Certificates[i].Name < r.Certificates[j].Name
+	})
+
+	return r, nil
+}
+
+// info returns information about generated X.509 certificate.
+func (c *Certificate) info(name string) (*CertificateInfo, error) {
+	cert, err := c.DecodeX509Certificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode X.509 certificate: %w", err)
+	}
+
+	rt := c.renewThreshold()
+
+	keyType, keySize := publicKeyInfo(cert)
+
+	info := &CertificateInfo{
+		Name:      name,
+		Subject:   cert.Subject.String(),
+		DNSNames:  cert.DNSNames,
+		Issuer:    cert.Issuer.String(),
+		KeyType:   keyType,
+		KeySize:   keySize,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		RenewAt:   cert.NotAfter.Add(-rt),
+	}
+
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+
+	return info, nil
+}
+
+// publicKeyInfo returns type and size in bits of public key of given certificate.
+func publicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", ed25519.PublicKeySize * 8
+	default:
+		return cert.PublicKeyAlgorithm.String(), 0
+	}
+}
+
+// Expiring returns certificates, which expire within the threshold of the report.
+func (r *Report) Expiring() []CertificateInfo {
+	e := []CertificateInfo{}
+
+	for _, c := range r.Certificates {
+		if c.Expiring {
+			e = append(e, c)
+		}
+	}
+
+	return e
+}
+
+// Err returns error describing expiring certificates or nil, if no certificate is expiring.
+func (r *Report) Err() error {
+	e := r.Expiring()
+
+	if len(e) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d certificates are expiring", len(e), len(r.Certificates))
+}
+
+// String returns human readable form of the report as a table.
+func (r *Report) String() string {
+	var sb strings.Builder
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tSUBJECT\tSANS\tISSUER\tKEY\tNOT BEFORE\tNOT AFTER\tRENEW AT\tSTATUS")
+
+	for _, c := range r.Certificates {
+		status := "OK"
+		if c.Expiring {
+			status = "EXPIRING"
+		}
+
+		sans := strings.Join(append(append([]string{}, c.DNSNames...), c.IPAddresses...), ",")
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %d\t%s\t%s\t%s\t%s\n",
+			c.Name,
+			c.Subject,
+			sans,
+			c.Issuer,
+			c.KeyType,
+			c.KeySize,
+			c.NotBefore.Format(time.RFC3339),
+			c.NotAfter.Format(time.RFC3339),
+			c.RenewAt.Format(time.RFC3339),
+			status,
+		)
+	}
+
+	// Writing to strings.Builder never fails.
+	_ = w.Flush()
+
+	return sb.String()
+}
@@ -0,0 +1,98 @@
+package pki_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+// Report() tests.
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	p := fullPKI()
+	p.KeyAlgorithm = pki.KeyAlgorithmECDSAP256
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	r, err := p.Report(0)
+	if err != nil {
+		t.Fatalf("Creating report should succeed, got: %v", err)
+	}
+
+	if err := r.Err(); err != nil {
+		t.Fatalf("Freshly generated certificates should not be expiring, got: %v", err)
+	}
+
+	var peer *pki.CertificateInfo
+
+	for i, c := range r.Certificates {
+		if c.Name == "etcd.peerCertificates.controller01" {
+			peer = &r.Certificates[i]
+		}
+	}
+
+	if peer == nil {
+		t.Fatalf("Report should include etcd peer certificate, got: %+v", r.Certificates)
+	}
+
+	if peer.KeyType != "ECDSA" || peer.KeySize != 256 {
+		t.Fatalf("Expected ECDSA 256 key, got: %s %d", peer.KeyType, peer.KeySize)
+	}
+
+	if !strings.Contains(strings.Join(peer.IPAddresses, ","), "192.168.1.10") {
+		t.Fatalf("Peer certificate should include peer IP address, got: %v", peer.IPAddresses)
+	}
+
+	if peer.Issuer == peer.Subject || !strings.Contains(peer.Issuer, pki.EtcdCACN) {
+		t.Fatalf("Peer certificate should be issued by etcd CA, got: %s", peer.Issuer)
+	}
+
+	if d := peer.NotAfter.Sub(peer.RenewAt); d != 720*time.Hour {
+		t.Fatalf("Renewal should be due renew threshold before expiry, got: %v", d)
+	}
+
+	if s := r.String(); !strings.Contains(s, "RENEW AT") || !strings.Contains(s, peer.Name) {
+		t.Fatalf("Report table should contain header and certificates, got:\n%s", s)
+	}
+}
+
+func TestReportThreshold(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	r, err := p.Report(10 * 8760 * time.Hour)
+	if err != nil {
+		t.Fatalf("Creating report should succeed, got: %v", err)
+	}
+
+	if len(r.Expiring()) != 1 || r.Err() == nil {
+		t.Fatalf("Root CA should be reported as expiring within threshold, got: %+v", r.Certificates)
+	}
+
+	if !strings.Contains(r.String(), "EXPIRING") {
+		t.Fatalf("Expiring certificates should be marked in report table")
+	}
+}
+
+func TestReportNotGenerated(t *testing.T) {
+	p := &pki.PKI{}
+
+	r, err := p.Report(0)
+	if err != nil {
+		t.Fatalf("Creating report should succeed, got: %v", err)
+	}
+
+	if len(r.Certificates) != 0 {
+		t.Fatalf("Not generated certificates should not be reported, got: %+v", r.Certificates)
+	}
+}
@@ -0,0 +1,212 @@
+package pki
+
+import (
+	"fmt"
+
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+const (
+	// CARotationPhaseTrust is a first phase of CA rotation. New CA certificate is generated,
+	// but certificates are still issued by the old CA. Trust bundles include both CA certificates,
+	// so all consumers can be updated to trust the new CA.
+	CARotationPhaseTrust = "trust"
+
+	// CARotationPhaseReissue is a second phase of CA rotation. New CA replaces the old one and
+	// all certificates issued by the CA are re-generated. Trust bundles still include both CA
+	// certificates, so certificates issued by the old CA remain valid until consumers are updated.
+	CARotationPhaseReissue = "reissue"
+)
+
+// CARotation describes CA certificate rotation in progress.
+type CARotation struct {
+	// Name is a name of rotated CA certificate in PKI, e.g. 'kubernetes.ca'.
+	Name string `json:"name"`
+
+	// Phase is a current phase of the rotation.
+	Phase string `json:"phase"`
+
+	// Next stores new CA certificate and private key, which will replace current
+	// CA in reissue phase.
+	Next *Certificate `json:"next,omitempty"`
+
+	// Previous stores replaced CA certificate, PEM encoded, which is trusted until
+	// the rotation is finished.
+	Previous types.Certificate `json:"previous,omitempty"`
+}
+
+// caNames returns names of all CA certificates, which can be rotated.
+func caNames() []string {
+	return []string{
+		RootCAName,
+		EtcdCAName,
+		KubernetesCAName,
+		KubernetesFrontProxyCAName,
+		DockerCAName,
+	}
+}
+
+// RotateCA advances rotation of CA certificate with given name, e.g. 'kubernetes.ca', by one
+// phase and returns new phase. Each phase should be followed by generating the PKI and by
+// deploying all resources consuming the CA certificate, before the next phase is started.
+//
+// Rotation has following phases:
+//
+// - "trust": new CA is generated and included in trust bundles next to the old CA.
+//
+// - "reissue": new CA replaces the old CA, so Generate re-issues all certificates signed by it.
+//
+// Advancing from "reissue" phase finishes the rotation, removes the old CA from trust bundles
+// and returns empty phase. Only one CA can be rotated at a time.
+func (p *PKI) RotateCA(name string) (string, error) {
+	if r := p.CARotation; r != nil && r.Name != name {
+		return "", fmt.Errorf("rotation of CA %q is already in progress", r.Name)
+	}
+
+	cr, err := p.caCertificateRequest(name)
+	if err != nil {
+		return "", err
+	}
+
+	if p.CARotation == nil {
+		return p.startCARotation(name, cr)
+	}
+
+	switch p.CARotation.Phase {
+	case CARotationPhaseTrust:
+		return p.replaceCA(cr.Target)
+	case CARotationPhaseReissue:
+		return p.finishCARotation()
+	default:
+		return "", fmt.Errorf("unknown CA rotation phase %q", p.CARotation.Phase)
+	}
+}
+
+// caCertificateRequest returns certificate request for generated CA certificate with given name.
+func (p *PKI) caCertificateRequest(name string) (*certificateRequest, error) {
+	if !isCAName(name) {
+		return nil, fmt.Errorf("unknown CA %q, valid CAs are: %v", name, caNames())
+	}
+
+	cr, err := p.certificateRequest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if cr.Target.X509Certificate == "" {
+		return nil, fmt.Errorf("CA %q is not generated", name)
+	}
+
+	return cr, nil
+}
+
+// isCAName checks, if given name is a name of CA certificate.
+func isCAName(name string) bool {
+	for _, n := range caNames() {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startCARotation generates new CA certificate using configuration from given certificate
+// request and starts the rotation.
+func (p *PKI) startCARotation(name string, cr *certificateRequest) (string, error) {
+	c, err := buildCertificate(cr.Certificates...)
+	if err != nil {
+		return "", fmt.Errorf("failed to build certificate configuration: %w", err)
+	}
+
+	if c.External {
+		return "", fmt.Errorf("external CA %q can't be rotated", name)
+	}
+
+	c.X509Certificate = ""
+	c.PrivateKey = ""
+	c.PublicKey = ""
+
+	if err := c.Generate(cr.CA); err != nil {
+		return "", fmt.Errorf("failed to generate new CA certificate: %w", err)
+	}
+
+	p.CARotation = &CARotation{
+		Name:  name,
+		Phase: CARotationPhaseTrust,
+		Next: &Certificate{
+			X509Certificate: c.X509Certificate,
+			PrivateKey:      c.PrivateKey,
+			PublicKey:       c.PublicKey,
+		},
+	}
+
+	return p.CARotation.Phase, nil
+}
+
+// replaceCA replaces given CA certificate with the new one.
+func (p *PKI) replaceCA(ca *Certificate) (string, error) {
+	r := p.CARotation
+
+	if r.Next == nil {
+		return "", fmt.Errorf("new CA certificate is missing")
+	}
+
+	r.Previous = ca.X509Certificate
+
+	ca.X509Certificate = r.Next.X509Certificate
+	ca.PrivateKey = r.Next.PrivateKey
+	ca.PublicKey = r.Next.PublicKey
+
+	r.Next = nil
+	r.Phase = CARotationPhaseReissue
+
+	return r.Phase, nil
+}
+
+// finishCARotation finishes the rotation, if all certificates has been re-issued.
+func (p *PKI) finishCARotation() (string, error) {
+	plan, err := p.Plan()
+	if err != nil {
+		return "", fmt.Errorf("failed checking if certificates has been re-issued: %w", err)
+	}
+
+	if len(plan) != 0 {
+		names := []string{}
+
+		for _, r := range plan {
+			names = append(names, r.Name)
+		}
+
+		return "", fmt.Errorf("PKI must be generated before finishing the rotation, pending certificates: %v", names)
+	}
+
+	p.CARotation = nil
+
+	return "", nil
+}
+
+// TrustBundle returns PEM encoded CA certificate with given name, e.g. 'kubernetes.ca'. If
+// the CA is being rotated, bundle also includes the other CA certificate involved in the
+// rotation, so certificates issued by both are trusted. Current CA certificate is always first.
+//
+// If CA certificate is not generated, empty string is returned.
+func (p *PKI) TrustBundle(name string) types.Certificate {
+	ca, ok := p.certificates()[name]
+	if !ok {
+		return ""
+	}
+
+	bundle := ca.X509Certificate
+
+	r := p.CARotation
+	if bundle == "" || r == nil || r.Name != name {
+		return bundle
+	}
+
+	if r.Next != nil {
+		bundle += r.Next.X509Certificate
+	}
+
+	return bundle + r.Previous
+}
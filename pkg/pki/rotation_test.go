@@ -0,0 +1,196 @@
+package pki_test
+
+import (
+	"encoding/pem"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+// countCertificates returns number of PEM blocks in given certificate bundle.
+func countCertificates(t *testing.T, bundle types.Certificate) int {
+	t.Helper()
+
+	rest := []byte(bundle)
+	count := 0
+
+	for {
+		var b *pem.Block
+
+		b, rest = pem.Decode(rest)
+		if b == nil {
+			return count
+		}
+
+		count++
+	}
+}
+
+func generatedPKI(t *testing.T) *pki.PKI {
+	t.Helper()
+
+	p := fullPKI()
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	return p
+}
+
+func rotateCA(t *testing.T, p *pki.PKI, name, expectedPhase string) {
+	t.Helper()
+
+	phase, err := p.RotateCA(name)
+	if err != nil {
+		t.Fatalf("Rotating CA should succeed, got: %v", err)
+	}
+
+	if phase != expectedPhase {
+		t.Fatalf("Expected phase %q, got %q", expectedPhase, phase)
+	}
+}
+
+// RotateCA() tests.
+func TestRotateCAUnknown(t *testing.T) {
+	p := &pki.PKI{}
+
+	if _, err := p.RotateCA("kubernetes.adminCertificate"); err == nil {
+		t.Fatalf("Rotating certificate which is not a CA should fail")
+	}
+}
+
+func TestRotateCANotGenerated(t *testing.T) {
+	p := &pki.PKI{}
+
+	if _, err := p.RotateCA(pki.EtcdCAName); err == nil {
+		t.Fatalf("Rotating not generated CA should fail")
+	}
+}
+
+func TestRotateCAOtherInProgress(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	rotateCA(t, p, pki.EtcdCAName, pki.CARotationPhaseTrust)
+
+	if _, err := p.RotateCA(pki.KubernetesCAName); err == nil {
+		t.Fatalf("Rotating CA while rotation of other CA is in progress should fail")
+	}
+}
+
+//nolint:funlen // Test walks through all rotation phases.
+func TestRotateCA(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+
+	oldCA := p.Etcd.CA.X509Certificate
+	oldPeer := p.Etcd.PeerCertificates["controller01"].X509Certificate
+
+	// Trust phase.
+	rotateCA(t, p, pki.EtcdCAName, pki.CARotationPhaseTrust)
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI in trust phase should succeed, got: %v", err)
+	}
+
+	if p.Etcd.CA.X509Certificate != oldCA {
+		t.Fatalf("CA should not be replaced in trust phase")
+	}
+
+	if p.Etcd.PeerCertificates["controller01"].X509Certificate != oldPeer {
+		t.Fatalf("Certificates should not be re-issued in trust phase")
+	}
+
+	if c := countCertificates(t, p.TrustBundle(pki.EtcdCAName)); c != 2 {
+		t.Fatalf("Trust bundle should contain both CA certificates in trust phase, got %d", c)
+	}
+
+	// Rotation state must survive saving the state.
+	s, err := yaml.Marshal(p)
+	if err != nil {
+		t.Fatalf("Serializing PKI should succeed, got: %v", err)
+	}
+
+	p = &pki.PKI{}
+
+	if err := yaml.Unmarshal(s, p); err != nil {
+		t.Fatalf("Deserializing PKI should succeed, got: %v", err)
+	}
+
+	// Reissue phase.
+	rotateCA(t, p, pki.EtcdCAName, pki.CARotationPhaseReissue)
+
+	if p.Etcd.CA.X509Certificate == oldCA {
+		t.Fatalf("CA should be replaced in reissue phase")
+	}
+
+	if _, err := p.RotateCA(pki.EtcdCAName); err == nil {
+		t.Fatalf("Finishing rotation before re-issuing certificates should fail")
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI in reissue phase should succeed, got: %v", err)
+	}
+
+	if p.Etcd.PeerCertificates["controller01"].X509Certificate == oldPeer {
+		t.Fatalf("Certificates should be re-issued in reissue phase")
+	}
+
+	bundle := p.TrustBundle(pki.EtcdCAName)
+
+	if c := countCertificates(t, bundle); c != 2 {
+		t.Fatalf("Trust bundle should contain both CA certificates in reissue phase, got %d", c)
+	}
+
+	if bundle[:len(p.Etcd.CA.X509Certificate)] != p.Etcd.CA.X509Certificate {
+		t.Fatalf("Current CA certificate should be first in trust bundle")
+	}
+
+	// Finish.
+	rotateCA(t, p, pki.EtcdCAName, "")
+
+	if p.CARotation != nil {
+		t.Fatalf("Rotation should be removed from the state when finished")
+	}
+
+	if bundle := p.TrustBundle(pki.EtcdCAName); bundle != p.Etcd.CA.X509Certificate {
+		t.Fatalf("Trust bundle should only contain new CA when rotation is finished, got: %s", bundle)
+	}
+}
+
+// TrustBundle() tests.
+func TestTrustBundleNotGenerated(t *testing.T) {
+	p := &pki.PKI{}
+
+	if b := p.TrustBundle(pki.KubernetesCAName); b != "" {
+		t.Fatalf("Trust bundle for not generated CA should be empty, got: %s", b)
+	}
+}
+
+func TestRotateCAExternal(t *testing.T) {
+	t.Parallel()
+
+	ca := testCA(t, "corporate-root", nil)
+
+	p := &pki.PKI{
+		RootCA: &pki.Certificate{
+			External:        true,
+			X509Certificate: ca.X509Certificate,
+			PrivateKey:      ca.PrivateKey,
+		},
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+
+	if _, err := p.RotateCA(pki.RootCAName); err == nil {
+		t.Fatalf("Rotating external CA should fail")
+	}
+}
@@ -0,0 +1,174 @@
+package pki
+
+import (
+	"fmt"
+
+	"github.com/flexkube/libflexkube/pkg/types"
+)
+
+const (
+	// ServiceAccountKeyStatePending is a state of service account key, which is trusted for
+	// verifying tokens, but is not used for signing them yet.
+	ServiceAccountKeyStatePending = "pending"
+
+	// ServiceAccountKeyStateRetired is a state of service account key, which is no longer used
+	// for signing tokens, but is still trusted, so existing tokens remain valid.
+	ServiceAccountKeyStateRetired = "retired"
+
+	// ServiceAccountRotationPhaseVerify is a first phase of service account key rotation. New key
+	// is generated and added to verification keys, while tokens are still signed by the old key.
+	ServiceAccountRotationPhaseVerify = "verify"
+
+	// ServiceAccountRotationPhaseSign is a second phase of service account key rotation. New key
+	// is used for signing tokens and old key is retired, but still trusted.
+	ServiceAccountRotationPhaseSign = "sign"
+)
+
+// ServiceAccountKey stores service account key, which is not used for signing tokens.
+type ServiceAccountKey struct {
+	// State is a state of the key, either 'pending' or 'retired'.
+	State string `json:"state"`
+
+	// PublicKey stores public key, PEM encoded, used for verifying tokens.
+	PublicKey string `json:"publicKey"`
+
+	// PrivateKey stores private key, PEM encoded. Only set for pending keys.
+	PrivateKey types.PrivateKey `json:"privateKey,omitempty"`
+}
+
+// RotateServiceAccountKey advances rotation of service account signing key by one phase and
+// returns new phase. Each phase should be followed by generating the PKI and by deploying the
+// controlplane, before the next phase is started.
+//
+// Rotation has following phases:
+//
+// - "verify": new key is generated and trusted by kube-apiserver next to the active key.
+//
+// - "sign": new key replaces the active key for signing tokens by kube-controller-manager.
+// Old key is retired, but kube-apiserver still trusts it, so existing tokens remain valid.
+//
+// Advancing from "sign" phase finishes the rotation, removes retired keys and returns empty
+// phase. It should only be done once all tokens signed by retired keys are refreshed.
+func (p *PKI) RotateServiceAccountKey() (string, error) {
+	cr, err := p.certificateRequest(ServiceAccountCertificateName)
+	if err != nil {
+		return "", err
+	}
+
+	if cr.Target.PrivateKey == "" {
+		return "", fmt.Errorf("service account key is not generated")
+	}
+
+	k := p.Kubernetes
+
+	if err := k.validateServiceAccountKeyAlgorithm(); err != nil {
+		return "", err
+	}
+
+	switch p.ServiceAccountRotationPhase() {
+	case "":
+		return p.startServiceAccountKeyRotation(cr)
+	case ServiceAccountRotationPhaseVerify:
+		return k.activateServiceAccountKey()
+	default:
+		k.ServiceAccountKeys = nil
+
+		return "", nil
+	}
+}
+
+// ServiceAccountRotationPhase returns current phase of service account key rotation or
+// empty string, if rotation is not in progress.
+func (p *PKI) ServiceAccountRotationPhase() string {
+	if p.Kubernetes == nil {
+		return ""
+	}
+
+	phase := ""
+
+	for _, sak := range p.Kubernetes.ServiceAccountKeys {
+		if sak.State == ServiceAccountKeyStatePending {
+			return ServiceAccountRotationPhaseVerify
+		}
+
+		phase = ServiceAccountRotationPhaseSign
+	}
+
+	return phase
+}
+
+// startServiceAccountKeyRotation generates new pending service account key using
+// configuration from given certificate request.
+func (p *PKI) startServiceAccountKeyRotation(cr *certificateRequest) (string, error) {
+	c, err := buildCertificate(cr.Certificates...)
+	if err != nil {
+		return "", fmt.Errorf("failed to build certificate configuration: %w", err)
+	}
+
+	c.PrivateKey = ""
+	c.PublicKey = ""
+
+	if _, err := c.generatePrivateKey(); err != nil {
+		return "", fmt.Errorf("failed to generate new service account key: %w", err)
+	}
+
+	p.Kubernetes.ServiceAccountKeys = append(p.Kubernetes.ServiceAccountKeys, &ServiceAccountKey{
+		State:      ServiceAccountKeyStatePending,
+		PublicKey:  c.PublicKey,
+		PrivateKey: c.PrivateKey,
+	})
+
+	return ServiceAccountRotationPhaseVerify, nil
+}
+
+// activateServiceAccountKey replaces active service account key with the pending one
+// and retires the active key.
+func (k *Kubernetes) activateServiceAccountKey() (string, error) {
+	sa := k.ServiceAccountCertificate
+	keys := []*ServiceAccountKey{}
+
+	for _, sak := range k.ServiceAccountKeys {
+		if sak.State != ServiceAccountKeyStatePending {
+			keys = append(keys, sak)
+
+			continue
+		}
+
+		keys = append(keys, &ServiceAccountKey{
+			State:     ServiceAccountKeyStateRetired,
+			PublicKey: sa.PublicKey,
+		})
+
+		sa.PrivateKey = sak.PrivateKey
+		sa.PublicKey = sak.PublicKey
+
+		// Certificate must be issued again for the new key.
+		sa.X509Certificate = ""
+	}
+
+	k.ServiceAccountKeys = keys
+
+	return ServiceAccountRotationPhaseSign, nil
+}
+
+// ServiceAccountVerificationKeys returns PEM encoded public keys, which should be trusted for
+// verifying service account tokens. Active key is always first, followed by pending and
+// retired keys.
+//
+// If service account key is not generated, empty string is returned.
+func (p *PKI) ServiceAccountVerificationKeys() string {
+	if p.Kubernetes == nil || p.Kubernetes.ServiceAccountCertificate == nil {
+		return ""
+	}
+
+	keys := p.Kubernetes.ServiceAccountCertificate.PublicKey
+	if keys == "" {
+		return ""
+	}
+
+	for _, sak := range p.Kubernetes.ServiceAccountKeys {
+		keys += sak.PublicKey
+	}
+
+	return keys
+}
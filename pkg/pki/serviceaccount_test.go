@@ -0,0 +1,100 @@
+package pki_test
+
+import (
+	"testing"
+
+	"github.com/flexkube/libflexkube/pkg/pki"
+)
+
+func rotateServiceAccountKey(t *testing.T, p *pki.PKI, expectedPhase string) {
+	t.Helper()
+
+	phase, err := p.RotateServiceAccountKey()
+	if err != nil {
+		t.Fatalf("Rotating service account key should succeed, got: %v", err)
+	}
+
+	if phase != expectedPhase {
+		t.Fatalf("Expected phase %q, got %q", expectedPhase, phase)
+	}
+
+	if p.ServiceAccountRotationPhase() != expectedPhase {
+		t.Fatalf("Expected rotation phase %q, got %q", expectedPhase, p.ServiceAccountRotationPhase())
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatalf("Generating PKI should succeed, got: %v", err)
+	}
+}
+
+// RotateServiceAccountKey() tests.
+func TestRotateServiceAccountKeyNotGenerated(t *testing.T) {
+	t.Parallel()
+
+	p := &pki.PKI{
+		Kubernetes: &pki.Kubernetes{},
+	}
+
+	if _, err := p.RotateServiceAccountKey(); err == nil {
+		t.Fatalf("Rotating not generated service account key should fail")
+	}
+}
+
+func TestRotateServiceAccountKey(t *testing.T) {
+	t.Parallel()
+
+	p := generatedPKI(t)
+	sa := p.Kubernetes.ServiceAccountCertificate
+	oldPrivateKey := sa.PrivateKey
+	oldPublicKey := sa.PublicKey
+	oldCertificate := sa.X509Certificate
+
+	if keys := p.ServiceAccountVerificationKeys(); keys != oldPublicKey {
+		t.Fatalf("Only active key should be trusted when rotation is not in progress, got: %s", keys)
+	}
+
+	rotateServiceAccountKey(t, p, pki.ServiceAccountRotationPhaseVerify)
+
+	if sa.PrivateKey != oldPrivateKey {
+		t.Fatalf("Tokens should be signed with old key in verify phase")
+	}
+
+	newPublicKey := p.Kubernetes.ServiceAccountKeys[0].PublicKey
+
+	if keys := p.ServiceAccountVerificationKeys(); keys != oldPublicKey+newPublicKey {
+		t.Fatalf("Both keys should be trusted in verify phase, active key first, got: %s", keys)
+	}
+
+	rotateServiceAccountKey(t, p, pki.ServiceAccountRotationPhaseSign)
+
+	if sa.PrivateKey == oldPrivateKey || sa.PublicKey != newPublicKey {
+		t.Fatalf("Tokens should be signed with new key in sign phase")
+	}
+
+	if keys := p.ServiceAccountVerificationKeys(); keys != newPublicKey+oldPublicKey {
+		t.Fatalf("Retired key should be trusted in sign phase, got: %s", keys)
+	}
+
+	if p.Kubernetes.ServiceAccountKeys[0].PrivateKey != "" {
+		t.Fatalf("Private key of retired key should be removed")
+	}
+
+	if sa.X509Certificate == oldCertificate {
+		t.Fatalf("Service account certificate should be issued for the new key")
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Planning should succeed, got: %v", err)
+	}
+
+	if len(plan) != 0 {
+		t.Fatalf("All certificates should be up to date, got: %v", plan)
+	}
+
+	rotateServiceAccountKey(t, p, "")
+
+	if keys := p.ServiceAccountVerificationKeys(); keys != newPublicKey {
+		t.Fatalf("Only new key should be trusted after rotation is finished, got: %s", keys)
+	}
+}
@@ -73,6 +73,14 @@ func TestParsePrivateKeyEC(t *testing.T) {
 	}
 }
 
+func TestParsePrivateKeyEd25519(t *testing.T) {
+	d := fmt.Sprintf("bar: |\n%s", util.Indent(strings.TrimSpace(utiltest.GenerateEd25519PrivateKey(t)), "  "))
+
+	if err := yaml.Unmarshal([]byte(d), &Foo{}); err != nil {
+		t.Fatalf("Parsing valid Ed25519 private key should succeed, got: %v", err)
+	}
+}
+
 func TestParsePrivateKeyBad(t *testing.T) {
 	if err := parsePrivateKey([]byte("notpem")); err == nil {
 		t.Fatalf("parsing not PEM format should fail")
@@ -3,11 +3,13 @@
 package types
 
 import (
+	"context"
 	"fmt"
 
 	"sigs.k8s.io/yaml"
 
 	"github.com/flexkube/libflexkube/pkg/container"
+	"github.com/flexkube/libflexkube/pkg/host/preflight"
 )
 
 // Resource interface defines common functionality between Flexkube resources like kubelet pool
@@ -24,12 +26,12 @@ type Resource interface {
 	//
 	// Calling CheckCurrentState is required before calling Deploy(), to ensure, that Deploy() executes
 	// correct actions.
-	CheckCurrentState() error
+	CheckCurrentState(ctx context.Context) error
 
 	// Deploy creates configured containers.
 	//
 	// CheckCurrentState() must be called before calling Deploy(), otherwise error will be returned.
-	Deploy() error
+	Deploy(ctx context.Context) error
 
 	// Containers gives access to the ContainersInterface from the resource, which allows accessing
 	// methods like DesiredState() and ToExported(), which can be used to calculate pending changes
@@ -37,6 +39,13 @@ type Resource interface {
 	Containers() container.ContainersInterface
 }
 
+// PreflightChecker is implemented by resources, which can verify, that their hosts are ready
+// for deployment, before any changes are made.
+type PreflightChecker interface {
+	// PreflightChecks returns checks, which should be executed on resource hosts before deploying.
+	PreflightChecks() []preflight.HostChecks
+}
+
 // ResourceConfig interface defines common functionality between all Flexkube resource configurations.
 type ResourceConfig interface {
 	// New creates new Resource object from given configuration and ensures, that the configuration